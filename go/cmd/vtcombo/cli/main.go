@@ -306,6 +306,7 @@ func run(cmd *cobra.Command, args []string) (err error) {
 	vtgate.QueryLogHandler = "/debug/vtgate/querylog"
 	vtgate.QueryLogzHandler = "/debug/vtgate/querylogz"
 	vtgate.QueryzHandler = "/debug/vtgate/queryz"
+	vtgate.QueryzResetHandler = "/debug/vtgate/queryz/reset"
 
 	// pass nil for healthcheck, it will get created
 	vtg := vtgate.Init(context.Background(), nil, resilientServer, tpb.Cells[0], tabletTypesToWait, plannerVersion, collationEnv)
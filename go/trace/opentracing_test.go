@@ -17,6 +17,7 @@ limitations under the License.
 package trace
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"testing"
@@ -39,6 +40,27 @@ func TestExtractMapFromString(t *testing.T) {
 	assert.Equal(t, expected, result)
 }
 
+// stringerSpan is a span whose context prints as its ID.
+type stringerSpan struct {
+	opentracing.Span
+	id string
+}
+
+type stringerSpanContext struct {
+	opentracing.SpanContext
+	id string
+}
+
+func (s stringerSpan) Context() opentracing.SpanContext { return stringerSpanContext{id: s.id} }
+
+func (sc stringerSpanContext) String() string { return sc.id }
+
+func TestSpanID(t *testing.T) {
+	assert.Equal(t, "123:456:789:1", spanID(openTracingSpan{otSpan: stringerSpan{id: "123:456:789:1"}}))
+	assert.Empty(t, spanID(NoopSpan{}))
+	assert.Empty(t, IDFromContext(context.Background()))
+}
+
 func TestErrorConditions(t *testing.T) {
 	encodedString := base64.StdEncoding.EncodeToString([]byte(`{"key":42}`))
 	_, err := extractMapFromString(encodedString) // malformed json {"key":42}
@@ -82,6 +82,30 @@ func NewContext(parent context.Context, span Span) context.Context {
 	return currentTracer.NewContext(parent, span)
 }
 
+// IDFromContext returns the identifiers of the Span of ctx, such as its
+// trace and span IDs, to correlate logs with traces. It returns "" if ctx
+// has no Span, or if the tracing plugin doesn't expose them.
+func IDFromContext(ctx context.Context) string {
+	span, ok := FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return spanID(span)
+}
+
+func spanID(span Span) string {
+	otSpan, ok := span.(openTracingSpan)
+	if !ok {
+		return ""
+	}
+	// The span contexts of the tracers, like the jaeger one, print as
+	// trace-id:span-id:parent-id:flags.
+	if sc, ok := otSpan.otSpan.Context().(fmt.Stringer); ok {
+		return sc.String()
+	}
+	return ""
+}
+
 // CopySpan creates a new context from parentCtx, with only the trace span
 // copied over from spanCtx, if it has any. If not, parentCtx is returned.
 func CopySpan(parentCtx, spanCtx context.Context) context.Context {
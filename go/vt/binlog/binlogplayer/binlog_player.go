@@ -83,21 +83,63 @@ type Stats struct {
 	heartbeatMutex sync.Mutex
 	heartbeat      int64
 
+	lastErrorMutex sync.Mutex
+	lastError      string
+	lastErrorTime  time.Time
+
+	throttleMutex      sync.Mutex
+	throttled          bool
+	componentThrottled string
+	timeThrottled      int64
+
+	tableCopyStatesMutex sync.Mutex
+	tableCopyStates      map[string]string
+
 	ReplicationLagSeconds atomic.Int64
-	History               *history.History
+	// ReplicationLags is a histogram of every replication lag sample, in
+	// seconds, so that lag spikes between two reads of the
+	// ReplicationLagSeconds gauge aren't lost.
+	ReplicationLags *stats.Histogram
+	History         *history.History
+
+	// SourceReadLagSeconds is how far behind the source the events read
+	// from it are, whether they have been applied or not.
+	SourceReadLagSeconds atomic.Int64
 
 	State atomic.Value
+	// StateTransitions counts the changes of State, by transition such as
+	// "Running->Error".
+	StateTransitions *stats.CountersWithSingleLabel
 
 	PhaseTimings       *stats.Timings
 	QueryTimings       *stats.Timings
 	QueryCount         *stats.CountersWithSingleLabel
 	BulkQueryCount     *stats.CountersWithSingleLabel
 	TrxQueryBatchCount *stats.CountersWithSingleLabel
+	// ApplyBatchSize is the configured maximum size, in bytes, of a
+	// transaction query batch. It is zero when batching is disabled.
+	ApplyBatchSize atomic.Int64
+	// ApplyBatchBytes counts the bytes sent in transaction query batches,
+	// and MaxApplyBatchBytes is the size of the largest batch sent.
+	ApplyBatchBytes    *stats.Counter
+	MaxApplyBatchBytes atomic.Int64
 	CopyRowCount       *stats.Counter
 	CopyLoopCount      *stats.Counter
 	ErrorCounts        *stats.CountersWithMultiLabels
+	ErrorRate          *ErrorRate
 	NoopQueryCount     *stats.CountersWithSingleLabel
 
+	// BytesReceived counts the size of the events received from the
+	// source, and BytesApplied the size of the queries sent to the target.
+	BytesReceived *stats.Counter
+	BytesApplied  *stats.Counter
+
+	// CopyRate is the rate of the rows copied in the copy phase, and
+	// CopyRowsEstimate the estimated number of rows to copy, or zero if
+	// it isn't known.
+	CopyRate         *CopyRate
+	CopyRowsEstimate atomic.Int64
+
 	VReplicationLags     *stats.Timings
 	VReplicationLagRates *stats.Rates
 
@@ -122,6 +164,143 @@ func (bps *Stats) Heartbeat() int64 {
 	return bps.heartbeat
 }
 
+// SetState sets State, and counts the transition from the previous state
+// if there was one and it differs.
+func (bps *Stats) SetState(state string) {
+	previous, _ := bps.State.Swap(state).(string)
+	if previous != "" && previous != state {
+		bps.StateTransitions.Add(previous+"->"+state, 1)
+	}
+}
+
+// HeartbeatAge returns how long ago the last heartbeat from vstreamer was
+// seen, or zero if none was.
+func (bps *Stats) HeartbeatAge() time.Duration {
+	heartbeat := bps.Heartbeat()
+	if heartbeat == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(heartbeat, 0))
+}
+
+// replicationLagCutoffs are the upper bounds, in seconds, of the buckets of
+// the ReplicationLags histogram.
+var replicationLagCutoffs = []int64{0, 1, 2, 5, 10, 30, 60, 300, 900, 3600}
+
+// RecordReplicationLag sets the replication lag to the given number of
+// seconds, and adds it to the ReplicationLags histogram.
+func (bps *Stats) RecordReplicationLag(seconds int64) {
+	bps.ReplicationLagSeconds.Store(seconds)
+	bps.ReplicationLags.Add(seconds)
+}
+
+// RecordThrottled records that the stream is being throttled by the
+// given component.
+func (bps *Stats) RecordThrottled(component string) {
+	bps.throttleMutex.Lock()
+	defer bps.throttleMutex.Unlock()
+	bps.throttled = true
+	bps.componentThrottled = component
+	bps.timeThrottled = time.Now().Unix()
+}
+
+// RecordUnthrottled records that the given component let the stream
+// through. The stream is no longer throttled if that component was the
+// last one to throttle it. The last throttling component and time are
+// kept.
+func (bps *Stats) RecordUnthrottled(component string) {
+	bps.throttleMutex.Lock()
+	defer bps.throttleMutex.Unlock()
+	if bps.componentThrottled == component {
+		bps.throttled = false
+	}
+}
+
+// ThrottleState returns whether the stream is throttled, and the component
+// that last throttled it and when, in seconds since the epoch. The time is
+// zero if it never was throttled.
+func (bps *Stats) ThrottleState() (throttled bool, component string, timeThrottled int64) {
+	bps.throttleMutex.Lock()
+	defer bps.throttleMutex.Unlock()
+	return bps.throttled, bps.componentThrottled, bps.timeThrottled
+}
+
+// The copy states of a table in TableCopyStates.
+const (
+	TableCopyPending    = "pending"
+	TableCopyInProgress = "in-progress"
+	TableCopyDone       = "done"
+)
+
+// SetTableCopyState records the copy state of a table.
+func (bps *Stats) SetTableCopyState(table, state string) {
+	bps.tableCopyStatesMutex.Lock()
+	defer bps.tableCopyStatesMutex.Unlock()
+	if bps.tableCopyStates == nil {
+		bps.tableCopyStates = make(map[string]string)
+	}
+	bps.tableCopyStates[table] = state
+}
+
+// TableCopyStates returns a copy of the copy state of the tables seen
+// during the copy phase, by table.
+func (bps *Stats) TableCopyStates() map[string]string {
+	bps.tableCopyStatesMutex.Lock()
+	defer bps.tableCopyStatesMutex.Unlock()
+	states := make(map[string]string, len(bps.tableCopyStates))
+	for table, state := range bps.tableCopyStates {
+		states[table] = state
+	}
+	return states
+}
+
+// RecordError counts an error of the given type, both in the
+// ErrorCounts totals and in the sliding ErrorRate windows, and keeps it as
+// the last error of the stream.
+func (bps *Stats) RecordError(typ string, err error) {
+	bps.ErrorCounts.Add([]string{typ}, 1)
+	bps.ErrorRate.Record()
+	bps.lastErrorMutex.Lock()
+	defer bps.lastErrorMutex.Unlock()
+	bps.lastError = fmt.Sprintf("%s: %v", typ, err)
+	bps.lastErrorTime = time.Now()
+}
+
+// LastError returns the last error recorded by RecordError, prefixed with
+// its type, and when it was recorded. The time is zero if no error was
+// recorded.
+func (bps *Stats) LastError() (string, time.Time) {
+	bps.lastErrorMutex.Lock()
+	defer bps.lastErrorMutex.Unlock()
+	return bps.lastError, bps.lastErrorTime
+}
+
+// RecordTrxQueryBatch counts a transaction query batch of size bytes, sent
+// with or without the commit as given by label.
+func (bps *Stats) RecordTrxQueryBatch(label string, size int64) {
+	bps.TrxQueryBatchCount.Add(label, 1)
+	bps.ApplyBatchBytes.Add(size)
+	for {
+		max := bps.MaxApplyBatchBytes.Load()
+		if size <= max || bps.MaxApplyBatchBytes.CompareAndSwap(max, size) {
+			return
+		}
+	}
+}
+
+// AvgApplyBatchBytes returns the average size in bytes of the transaction
+// query batches sent so far.
+func (bps *Stats) AvgApplyBatchBytes() int64 {
+	var batches int64
+	for _, count := range bps.TrxQueryBatchCount.Counts() {
+		batches += count
+	}
+	if batches == 0 {
+		return 0
+	}
+	return bps.ApplyBatchBytes.Get() / batches
+}
+
 // SetLastPosition sets the last replication position.
 func (bps *Stats) SetLastPosition(pos replication.Position) {
 	bps.lastPositionMutex.Lock()
@@ -160,14 +339,22 @@ func NewStats() *Stats {
 	bps.Rates = stats.NewRates("", bps.Timings, 15*60/5, 5*time.Second)
 	bps.History = history.New(3)
 	bps.ReplicationLagSeconds.Store(math.MaxInt64)
+	bps.ReplicationLags = stats.NewHistogram("", "", replicationLagCutoffs)
+	bps.SourceReadLagSeconds.Store(math.MaxInt64)
+	bps.StateTransitions = stats.NewCountersWithSingleLabel("", "", "Transition")
 	bps.PhaseTimings = stats.NewTimings("", "", "Phase")
 	bps.QueryTimings = stats.NewTimings("", "", "Phase")
 	bps.QueryCount = stats.NewCountersWithSingleLabel("", "", "Phase", "")
 	bps.BulkQueryCount = stats.NewCountersWithSingleLabel("", "", "Statement", "")
 	bps.TrxQueryBatchCount = stats.NewCountersWithSingleLabel("", "", "Statement", "")
+	bps.ApplyBatchBytes = stats.NewCounter("", "")
 	bps.CopyRowCount = stats.NewCounter("", "")
 	bps.CopyLoopCount = stats.NewCounter("", "")
+	bps.BytesReceived = stats.NewCounter("", "")
+	bps.BytesApplied = stats.NewCounter("", "")
+	bps.CopyRate = NewCopyRate()
 	bps.ErrorCounts = stats.NewCountersWithMultiLabels("", "", []string{"type"})
+	bps.ErrorRate = NewErrorRate()
 	bps.NoopQueryCount = stats.NewCountersWithSingleLabel("", "", "Statement", "")
 	bps.VReplicationLags = stats.NewTimings("", "", "")
 	bps.VReplicationLagRates = stats.NewRates("", bps.VReplicationLags, 15*60/5, 5*time.Second)
@@ -178,6 +365,114 @@ func NewStats() *Stats {
 	return bps
 }
 
+// errorRateBuckets is the number of one minute buckets kept by an
+// ErrorRate, which is also the longest window it can report on.
+const errorRateBuckets = 15
+
+// ErrorRate counts errors in one minute buckets over the last
+// errorRateBuckets minutes, so that the number of errors seen in a
+// recent window can be reported without keeping every error time.
+type ErrorRate struct {
+	mu      sync.Mutex
+	counts  [errorRateBuckets]int64
+	minutes [errorRateBuckets]int64
+	now     func() time.Time
+}
+
+// NewErrorRate creates a new ErrorRate.
+func NewErrorRate() *ErrorRate {
+	return &ErrorRate{now: time.Now}
+}
+
+// Record counts one error at the current time.
+func (er *ErrorRate) Record() {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+	minute := er.now().Unix() / 60
+	i := minute % errorRateBuckets
+	if er.minutes[i] != minute {
+		er.minutes[i] = minute
+		er.counts[i] = 0
+	}
+	er.counts[i]++
+}
+
+// Count returns the number of errors recorded in the last window
+// minutes, including the current minute. Windows longer than
+// errorRateBuckets minutes are capped.
+func (er *ErrorRate) Count(window int) int64 {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+	now := er.now().Unix() / 60
+	var total int64
+	for i, minute := range er.minutes {
+		if age := now - minute; age >= 0 && age < int64(window) {
+			total += er.counts[i]
+		}
+	}
+	return total
+}
+
+// copyRateBuckets is the number of copyRateBucketSeconds buckets kept by
+// a CopyRate, which makes the window its rate is computed over.
+const (
+	copyRateBuckets       = 30
+	copyRateBucketSeconds = 10
+)
+
+// CopyRate counts the rows copied in copyRateBucketSeconds buckets over a
+// sliding window, so that the recent copy rate can be reported.
+type CopyRate struct {
+	mu      sync.Mutex
+	counts  [copyRateBuckets]int64
+	buckets [copyRateBuckets]int64
+	first   int64
+	now     func() time.Time
+}
+
+// NewCopyRate creates a new CopyRate.
+func NewCopyRate() *CopyRate {
+	return &CopyRate{now: time.Now}
+}
+
+// Record counts rows copied at the current time.
+func (cr *CopyRate) Record(rows int64) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	now := cr.now().Unix()
+	if cr.first == 0 {
+		cr.first = now
+	}
+	bucket := now / copyRateBucketSeconds
+	i := bucket % copyRateBuckets
+	if cr.buckets[i] != bucket {
+		cr.buckets[i] = bucket
+		cr.counts[i] = 0
+	}
+	cr.counts[i] += rows
+}
+
+// RowsPerSecond returns the number of rows copied per second over the
+// window, or since the first record if that is more recent.
+func (cr *CopyRate) RowsPerSecond() float64 {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if cr.first == 0 {
+		return 0
+	}
+	now := cr.now().Unix()
+	var total int64
+	for i, bucket := range cr.buckets {
+		if age := now/copyRateBucketSeconds - bucket; age >= 0 && age < copyRateBuckets {
+			total += cr.counts[i]
+		}
+	}
+	// The window starts at the oldest bucket it covers.
+	windowStart := (now/copyRateBucketSeconds - copyRateBuckets + 1) * copyRateBucketSeconds
+	elapsed := now - max(windowStart, cr.first) + 1
+	return float64(total) / float64(elapsed)
+}
+
 // BinlogPlayer is for reading a stream of updates from BinlogServer.
 type BinlogPlayer struct {
 	tablet   *topodatapb.Tablet
@@ -514,7 +809,7 @@ func (blp *BinlogPlayer) writeRecoveryPosition(tx *binlogdatapb.BinlogTransactio
 	blp.position = position
 	blp.blplStats.SetLastPosition(blp.position)
 	if tx.EventToken.Timestamp != 0 {
-		blp.blplStats.ReplicationLagSeconds.Store(now - tx.EventToken.Timestamp)
+		blp.blplStats.RecordReplicationLag(now - tx.EventToken.Timestamp)
 	}
 	return nil
 }
@@ -526,7 +821,7 @@ func (blp *BinlogPlayer) setVReplicationState(state binlogdatapb.VReplicationWor
 			Message: message,
 		})
 	}
-	blp.blplStats.State.Store(state.String())
+	blp.blplStats.SetState(state.String())
 	query := fmt.Sprintf("update _vt.vreplication set state='%v', message=%v where id=%v", state.String(), encodeString(MessageTruncate(message)), blp.uid)
 	if _, err := blp.dbClient.ExecuteFetch(query, 1); err != nil {
 		return fmt.Errorf("could not set state: %v: %v", query, err)
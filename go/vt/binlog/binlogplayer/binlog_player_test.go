@@ -19,6 +19,7 @@ package binlogplayer
 import (
 	"context"
 	"errors"
+	"reflect"
 	"testing"
 	"time"
 
@@ -454,3 +455,62 @@ func TestReadVReplicationStatus(t *testing.T) {
 		t.Errorf("ReadVReplicationStatus(482821) = %#v, want %#v", got, want)
 	}
 }
+
+func TestErrorRate(t *testing.T) {
+	now := time.Unix(1000*60, 0)
+	er := NewErrorRate()
+	er.now = func() time.Time { return now }
+
+	er.Record()
+	er.Record()
+	now = now.Add(3 * time.Minute)
+	er.Record()
+	now = now.Add(7 * time.Minute)
+	er.Record()
+
+	counts := func() []int64 {
+		return []int64{er.Count(1), er.Count(5), er.Count(15)}
+	}
+	if got, want := counts(), []int64{1, 1, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("counts after records = %v, want %v", got, want)
+	}
+
+	// Buckets older than the window are dropped, and a reused bucket
+	// is reset before counting.
+	now = now.Add(12 * time.Minute)
+	if got, want := counts(), []int64{0, 0, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("counts after 12m = %v, want %v", got, want)
+	}
+	now = now.Add(3 * time.Minute)
+	er.Record()
+	if got, want := counts(), []int64{1, 1, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("counts after reusing bucket = %v, want %v", got, want)
+	}
+}
+
+func TestCopyRate(t *testing.T) {
+	now := time.Unix(1000*copyRateBucketSeconds, 0)
+	cr := NewCopyRate()
+	cr.now = func() time.Time { return now }
+
+	if got := cr.RowsPerSecond(); got != 0 {
+		t.Errorf("rate before records = %v, want 0", got)
+	}
+
+	// The rate is computed since the first record while it is more recent
+	// than the window.
+	cr.Record(100)
+	now = now.Add(9 * time.Second)
+	cr.Record(900)
+	if got, want := cr.RowsPerSecond(), 100.0; got != want {
+		t.Errorf("rate after 10s = %v, want %v", got, want)
+	}
+
+	// Then over the window only.
+	window := copyRateBuckets * copyRateBucketSeconds * time.Second
+	now = now.Add(window)
+	cr.Record(3000)
+	if got, want := cr.RowsPerSecond(), 3000/window.Seconds(); got != want {
+		t.Errorf("rate after the window = %v, want %v", got, want)
+	}
+}
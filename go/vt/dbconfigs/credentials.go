@@ -88,16 +88,7 @@ func init() {
 	signal.Notify(sigChan, syscall.SIGHUP)
 	go func() {
 		for range sigChan {
-			if fcs, ok := AllCredentialsServers["file"].(*FileCredentialsServer); ok {
-				fcs.mu.Lock()
-				fcs.dbCredentials = nil
-				fcs.mu.Unlock()
-			}
-			if vcs, ok := AllCredentialsServers["vault"].(*VaultCredentialsServer); ok {
-				vcs.mu.Lock()
-				vcs.dbCredsCache = nil
-				vcs.mu.Unlock()
-			}
+			ReloadCredentials()
 		}
 	}()
 
@@ -133,6 +124,22 @@ func GetCredentialsServer() CredentialsServer {
 	return cs
 }
 
+// ReloadCredentials drops the credentials cached by the credentials
+// servers, so that they are read again from their source on the next
+// connection. It is what SIGHUP does.
+func ReloadCredentials() {
+	if fcs, ok := AllCredentialsServers["file"].(*FileCredentialsServer); ok {
+		fcs.mu.Lock()
+		fcs.dbCredentials = nil
+		fcs.mu.Unlock()
+	}
+	if vcs, ok := AllCredentialsServers["vault"].(*VaultCredentialsServer); ok {
+		vcs.mu.Lock()
+		vcs.dbCredsCache = nil
+		vcs.mu.Unlock()
+	}
+}
+
 // FileCredentialsServer is a simple implementation of CredentialsServer using
 // a json file. Protected by mu.
 type FileCredentialsServer struct {
@@ -303,6 +303,31 @@ func hupTest(t *testing.T, tmpFile *os.File, oldStr, newStr string) {
 	}
 }
 
+func TestReloadCredentials(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "credentials.json")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	dbCredentialsFile = tmpFile.Name()
+	dbCredentialsServer = "file"
+	require.NoError(t, os.WriteFile(tmpFile.Name(), []byte(`{"vt_filtered": ["old"]}`), 0600))
+	// Drop what previous tests cached.
+	ReloadCredentials()
+	cs := GetCredentialsServer()
+	_, pass, err := cs.GetUserAndPassword("vt_filtered")
+	require.NoError(t, err)
+	require.Equal(t, "old", pass)
+
+	require.NoError(t, os.WriteFile(tmpFile.Name(), []byte(`{"vt_filtered": ["new"]}`), 0600))
+	_, pass, err = cs.GetUserAndPassword("vt_filtered")
+	require.NoError(t, err)
+	require.Equal(t, "old", pass, "the file is only read again after a reload")
+
+	ReloadCredentials()
+	_, pass, err = cs.GetUserAndPassword("vt_filtered")
+	require.NoError(t, err)
+	require.Equal(t, "new", pass)
+}
+
 func TestYaml(t *testing.T) {
 	db := DBConfigs{
 		Socket: "a",
@@ -0,0 +1,38 @@
+/*
+Copyright 2024 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package topo
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"vitess.io/vitess/go/vt/vterrors"
+)
+
+func TestIsErrType(t *testing.T) {
+	noNode := NewError(NoNode, "keyspaces/ks/Keyspace")
+	assert.True(t, IsErrType(noNode, NoNode))
+	assert.False(t, IsErrType(noNode, NodeExists))
+	assert.True(t, IsErrType(fmt.Errorf("GetKeyspace: %w", noNode), NoNode))
+	// The errors wrapped by vterrors.Wrap are seen through too, since
+	// vterrors.Wrap supports errors.As.
+	assert.True(t, IsErrType(vterrors.Wrapf(vterrors.Wrap(noNode, "GetKeyspace"), "GetShard(%s)", "0"), NoNode))
+	assert.False(t, IsErrType(vterrors.Wrap(fmt.Errorf("%s", noNode), "GetKeyspace"), NoNode))
+	assert.False(t, IsErrType(nil, NoNode))
+}
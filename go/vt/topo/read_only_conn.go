@@ -0,0 +1,67 @@
+/*
+Copyright 2024 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package topo
+
+import (
+	"context"
+
+	"vitess.io/vitess/go/vt/proto/vtrpc"
+	"vitess.io/vitess/go/vt/vterrors"
+)
+
+var _ Conn = (*readOnlyConn)(nil)
+
+// readOnlyConn is the Conn of the servers returned by Server.ReadOnly. It
+// reads through the Conn of the server it was returned by, and refuses the
+// writes, the locks and the leader elections. It doesn't own that Conn,
+// so closing it does nothing.
+type readOnlyConn struct {
+	Conn
+}
+
+// Create is part of the Conn interface
+func (c *readOnlyConn) Create(ctx context.Context, filePath string, contents []byte) (Version, error) {
+	return nil, vterrors.Errorf(vtrpc.Code_READ_ONLY, readOnlyErrorStrFormat, "Create", filePath)
+}
+
+// Update is part of the Conn interface
+func (c *readOnlyConn) Update(ctx context.Context, filePath string, contents []byte, version Version) (Version, error) {
+	return nil, vterrors.Errorf(vtrpc.Code_READ_ONLY, readOnlyErrorStrFormat, "Update", filePath)
+}
+
+// Delete is part of the Conn interface
+func (c *readOnlyConn) Delete(ctx context.Context, filePath string, version Version) error {
+	return vterrors.Errorf(vtrpc.Code_READ_ONLY, readOnlyErrorStrFormat, "Delete", filePath)
+}
+
+// Lock is part of the Conn interface
+func (c *readOnlyConn) Lock(ctx context.Context, dirPath, contents string) (LockDescriptor, error) {
+	return nil, vterrors.Errorf(vtrpc.Code_READ_ONLY, readOnlyErrorStrFormat, "Lock", dirPath)
+}
+
+// TryLock is part of the Conn interface
+func (c *readOnlyConn) TryLock(ctx context.Context, dirPath, contents string) (LockDescriptor, error) {
+	return nil, vterrors.Errorf(vtrpc.Code_READ_ONLY, readOnlyErrorStrFormat, "Lock", dirPath)
+}
+
+// NewLeaderParticipation is part of the Conn interface
+func (c *readOnlyConn) NewLeaderParticipation(name, id string) (LeaderParticipation, error) {
+	return nil, vterrors.Errorf(vtrpc.Code_READ_ONLY, readOnlyErrorStrFormat, "NewLeaderParticipation", name)
+}
+
+// Close is part of the Conn interface
+func (c *readOnlyConn) Close() {}
@@ -0,0 +1,63 @@
+/*
+Copyright 2024 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package topo_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/vt/topo/memorytopo"
+	"vitess.io/vitess/go/vt/vterrors"
+
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+func TestServerReadOnly(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ts := memorytopo.NewServer(ctx, "zone1")
+	defer ts.Close()
+	require.NoError(t, ts.CreateKeyspace(ctx, "ks", &topodatapb.Keyspace{}))
+	srvKeyspace := &topodatapb.SrvKeyspace{}
+	require.NoError(t, ts.UpdateSrvKeyspace(ctx, "zone1", "ks", srvKeyspace))
+
+	ro := ts.ReadOnly()
+	_, err := ro.GetKeyspace(ctx, "ks")
+	require.NoError(t, err)
+	_, err = ro.GetSrvKeyspace(ctx, "zone1", "ks")
+	require.NoError(t, err)
+
+	err = ro.CreateKeyspace(ctx, "ks2", &topodatapb.Keyspace{})
+	require.Equal(t, vtrpcpb.Code_READ_ONLY, vterrors.Code(err), "%v", err)
+	_, _, err = ro.LockKeyspace(ctx, "ks", "TestServerReadOnly")
+	require.Equal(t, vtrpcpb.Code_READ_ONLY, vterrors.Code(err), "%v", err)
+	err = ro.UpdateSrvKeyspace(ctx, "zone1", "ks", srvKeyspace)
+	require.Equal(t, vtrpcpb.Code_READ_ONLY, vterrors.Code(err), "%v", err)
+	err = ro.DeleteSrvKeyspace(ctx, "zone1", "ks")
+	require.Equal(t, vtrpcpb.Code_READ_ONLY, vterrors.Code(err), "%v", err)
+
+	// Closing the read-only server leaves the connections of the server it
+	// reads through open.
+	ro.Close()
+	_, err = ts.GetKeyspace(ctx, "ks")
+	require.NoError(t, err)
+	_, err = ts.GetSrvKeyspace(ctx, "zone1", "ks")
+	require.NoError(t, err)
+}
@@ -141,6 +141,10 @@ type Server struct {
 	// will read the list of addresses for that cell from the
 	// global cluster and create clients as needed.
 	cellConns map[string]cellConn
+
+	// readOnlyOf is the server this server reads through, if it was
+	// returned by ReadOnly.
+	readOnlyOf *Server
 }
 
 type cellConn struct {
@@ -253,9 +257,32 @@ func Open() *Server {
 	return ts
 }
 
+// ReadOnly returns a server that reads through the connections of this
+// server, and refuses to write, lock or elect a leader in any cell. It is
+// meant for the operations that must not change anything, and doesn't own
+// any connection: closing it does nothing, and this server must not be
+// closed while it is in use.
+func (ts *Server) ReadOnly() *Server {
+	return &Server{
+		globalCell:         &readOnlyConn{Conn: ts.globalCell},
+		globalReadOnlyCell: &readOnlyConn{Conn: ts.globalReadOnlyCell},
+		factory:            ts.factory,
+		cellConns:          make(map[string]cellConn),
+		readOnlyOf:         ts,
+	}
+}
+
 // ConnForCell returns a Conn object for the given cell.
 // It caches Conn objects from previously requested cells.
 func (ts *Server) ConnForCell(ctx context.Context, cell string) (Conn, error) {
+	if ts.readOnlyOf != nil && cell != GlobalCell {
+		conn, err := ts.readOnlyOf.ConnForCell(ctx, cell)
+		if err != nil {
+			return nil, err
+		}
+		return &readOnlyConn{Conn: conn}, nil
+	}
+
 	// Global cell is the easy case.
 	if cell == GlobalCell {
 		if ctx.Err() != nil {
@@ -94,8 +94,13 @@ type VtctldServer struct {
 
 // NewVtctldServer returns a new VtctldServer for the given topo server.
 func NewVtctldServer(ts *topo.Server, collationEnv *collations.Environment, parser *sqlparser.Parser) *VtctldServer {
-	tmc := tmclient.NewTabletManagerClient()
+	return NewVtctldServerWithTabletManagerClient(ts, tmclient.NewTabletManagerClient(), collationEnv, parser)
+}
 
+// NewVtctldServerWithTabletManagerClient returns a new VtctldServer for the
+// given topo server that uses the given tablet manager client, which the
+// caller keeps owning.
+func NewVtctldServerWithTabletManagerClient(ts *topo.Server, tmc tmclient.TabletManagerClient, collationEnv *collations.Environment, parser *sqlparser.Parser) *VtctldServer {
 	return &VtctldServer{
 		ts:  ts,
 		tmc: tmc,
@@ -103,6 +108,12 @@ func NewVtctldServer(ts *topo.Server, collationEnv *collations.Environment, pars
 	}
 }
 
+// Close closes the tablet manager client of the server. It must only be
+// called on servers created by NewVtctldServer, which own their client.
+func (s *VtctldServer) Close() {
+	s.tmc.Close()
+}
+
 // NewTestVtctldServer returns a new VtctldServer for the given topo server
 // AND tmclient for use in tests. This should NOT be used in production.
 func NewTestVtctldServer(ts *topo.Server, tmc tmclient.TabletManagerClient) *VtctldServer {
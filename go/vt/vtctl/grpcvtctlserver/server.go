@@ -47,11 +47,14 @@ type VtctlServer struct {
 	ts           *topo.Server
 	collationEnv *collations.Environment
 	parser       *sqlparser.Parser
+	exporter     *servenv.Exporter
 }
 
-// NewVtctlServer returns a new Vtctl Server for the topo server.
-func NewVtctlServer(ts *topo.Server, collationEnv *collations.Environment, parser *sqlparser.Parser) *VtctlServer {
-	return &VtctlServer{ts: ts, collationEnv: collationEnv, parser: parser}
+// NewVtctlServer returns a new Vtctl Server for the topo server. The
+// wranglers running the commands export their stats to the exporter,
+// if not nil.
+func NewVtctlServer(ts *topo.Server, collationEnv *collations.Environment, parser *sqlparser.Parser, exporter *servenv.Exporter) *VtctlServer {
+	return &VtctlServer{ts: ts, collationEnv: collationEnv, parser: parser, exporter: exporter}
 }
 
 // ExecuteVtctlCommand is part of the vtctldatapb.VtctlServer interface
@@ -79,12 +82,14 @@ func (s *VtctlServer) ExecuteVtctlCommand(args *vtctldatapb.ExecuteVtctlCommandR
 	tmc := tmclient.NewTabletManagerClient()
 	defer tmc.Close()
 	wr := wrangler.New(logger, s.ts, tmc, s.collationEnv, s.parser)
+	defer wr.Close()
+	wr.SetStatsExporter(s.exporter)
 
 	// execute the command
 	return vtctl.RunCommand(stream.Context(), wr, args.Args)
 }
 
 // StartServer registers the VtctlServer for RPCs
-func StartServer(s *grpc.Server, ts *topo.Server, collationEnv *collations.Environment, parser *sqlparser.Parser) {
-	vtctlservicepb.RegisterVtctlServer(s, NewVtctlServer(ts, collationEnv, parser))
+func StartServer(s *grpc.Server, ts *topo.Server, collationEnv *collations.Environment, parser *sqlparser.Parser, exporter *servenv.Exporter) {
+	vtctlservicepb.RegisterVtctlServer(s, NewVtctlServer(ts, collationEnv, parser, exporter))
 }
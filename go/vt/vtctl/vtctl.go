@@ -2115,6 +2115,20 @@ func commandVReplicationWorkflow(ctx context.Context, wr *wrangler.Wrangler, sub
 	targetShards := subFlags.String("target_shards", "", "Reshard only. Target shards")
 	*targetShards = strings.TrimSpace(*targetShards)
 	skipSchemaCopy := subFlags.Bool("skip_schema_copy", false, "Reshard only. Skip copying of schema to target shards")
+	strictMySQLVersionCheck := subFlags.Bool("strict_mysql_version_check", false, "Reshard only. Check the MySQL version of every source and target primary, and fail if a target primary runs an older version than a source primary.")
+	validateSequences := subFlags.Bool("validate_sequences", false, "Reshard only. Log the tables of the keyspace whose sequence is missing, which would make inserts fail after the cutover.")
+	verbose := subFlags.Bool("verbose", false, "Reshard only. Log the shards, reference streams and streams found or created, and the time taken by each phase.")
+	ignoreFrozenTargetStreams := subFlags.Bool("ignore_frozen_target_streams", false, "Reshard only. Allow target shards that still have the frozen streams of a completed workflow, instead of failing. The ignored streams are logged.")
+	streamBatchSize := subFlags.Int("stream_batch_size", 0, "Reshard only. Maximum number of streams created by a single insert on each target shard, to stay below max_allowed_packet when there are many reference tables. 0 creates all streams in one insert.")
+	ignoreTargetWorkflows := subFlags.StringSlice("ignore_target_workflows", nil, "Reshard only. Comma-separated list of workflows whose streams may exist on the target shards, such as an unrelated workflow that keeps running. The streams of any other workflow still fail the reshard.")
+	repairReferenceStreams := subFlags.Bool("repair_reference_streams", false, "Reshard only. If the source shards don't have the same reference streams, copy those of the first source shard that has all of them instead of failing. The missing ones are logged.")
+	includeReferenceTables := subFlags.StringSlice("include_reference_tables", nil, "Reshard only. Comma-separated list of reference tables to copy like sharded tables.")
+	deferSecondaryKeysTables := subFlags.StringSlice("defer_secondary_keys_tables", nil, "Reshard only. Comma-separated list of tables whose secondary keys are created after their copy, when --defer-secondary-keys is not set.")
+	stopPositions := subFlags.StringToString("stop_positions", nil, "Reshard only. Comma-separated list of source_shard=position pairs at which the streams replicating from each source shard stop. Can't be combined with --stop_after_copy.")
+	shardConcurrency := subFlags.Int("shard_concurrency", 0, "Reshard only. Maximum number of shards worked on concurrently. 0 uses the default of 16.")
+	copySchemaTimeout := subFlags.Duration("copy_schema_timeout", 0, "Reshard only. How long to wait for the replicas of each target shard when copying the schema to it. 0 uses the default of 30s.")
+	execRetries := subFlags.Int("exec_retries", 0, "Reshard only. How many times a query on a tablet that failed with a transient error is retried. 0 uses the default of 3.")
+	execRetryDelay := subFlags.Duration("exec_retry_delay", 0, "Reshard only. How long to wait before the first retry of a query on a tablet, doubled before each following retry. 0 uses the default of 100ms.")
 
 	if err := subFlags.Parse(args); err != nil {
 		return err
@@ -2280,6 +2294,22 @@ func commandVReplicationWorkflow(ctx context.Context, wr *wrangler.Wrangler, sub
 			vrwp.SourceShards = strings.Split(*sourceShards, ",")
 			vrwp.TargetShards = strings.Split(*targetShards, ",")
 			vrwp.SkipSchemaCopy = *skipSchemaCopy
+			vrwp.ReshardOptions = wrangler.ReshardOptions{
+				StreamBatchSize:           *streamBatchSize,
+				StrictMySQLVersionCheck:   *strictMySQLVersionCheck,
+				ValidateSequences:         *validateSequences,
+				Verbose:                   *verbose,
+				IgnoreFrozenTargetStreams: *ignoreFrozenTargetStreams,
+				IgnoreTargetWorkflows:     *ignoreTargetWorkflows,
+				RepairReferenceStreams:    *repairReferenceStreams,
+				IncludeReferenceTables:    *includeReferenceTables,
+				DeferSecondaryKeysTables:  *deferSecondaryKeysTables,
+				StopPositions:             *stopPositions,
+				ShardConcurrency:          *shardConcurrency,
+				CopySchemaTimeout:         *copySchemaTimeout,
+				ExecRetries:               *execRetries,
+				ExecRetryDelay:            *execRetryDelay,
+			}
 			vrwp.SourceKeyspace = target
 		default:
 			return fmt.Errorf("unknown workflow type passed: %v", workflowType)
@@ -21,22 +21,32 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"google.golang.org/protobuf/encoding/prototext"
 
+	"vitess.io/vitess/go/constants/sidecar"
+	"vitess.io/vitess/go/sqlescape"
 	"vitess.io/vitess/go/sqltypes"
 	"vitess.io/vitess/go/vt/concurrency"
+	"vitess.io/vitess/go/vt/discovery"
 	"vitess.io/vitess/go/vt/key"
 	"vitess.io/vitess/go/vt/schema"
+	"vitess.io/vitess/go/vt/sqlparser"
 	"vitess.io/vitess/go/vt/topo"
+	"vitess.io/vitess/go/vt/topo/topoproto"
 	"vitess.io/vitess/go/vt/topotools"
 	"vitess.io/vitess/go/vt/vterrors"
 	"vitess.io/vitess/go/vt/vtgate/vindexes"
 	"vitess.io/vitess/go/vt/vttablet/tabletmanager/vreplication"
 
 	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
 )
 
@@ -57,8 +67,18 @@ type resharder struct {
 	stopAfterCopy      bool
 	onDDL              string
 	deferSecondaryKeys bool
+	// metadataTabletTypes are the tablet types of the tablets read by
+	// validateTargets and readRefStreams, see metadataTablet.
+	metadataTabletTypes string
+	// tableFilters are the WHERE expressions that the rows of some tables
+	// must match to be copied, see setTableFilters.
+	tableFilters map[string]string
 }
 
+// metadataMaxRows is the maximum number of rows that readMetadata reads
+// from the tablets other than the primaries.
+const metadataMaxRows = 10000
+
 type refStream struct {
 	workflow    string
 	bls         *binlogdatapb.BinlogSource
@@ -66,16 +86,17 @@ type refStream struct {
 	tabletTypes string
 }
 
-func (s *Server) buildResharder(ctx context.Context, keyspace, workflow string, sources, targets []string, cell, tabletTypes string) (*resharder, error) {
+func (s *Server) buildResharder(ctx context.Context, keyspace, workflow string, sources, targets []string, cell, tabletTypes, metadataTabletTypes string) (*resharder, error) {
 	ts := s.ts
 	rs := &resharder{
-		s:               s,
-		keyspace:        keyspace,
-		workflow:        workflow,
-		sourcePrimaries: make(map[string]*topo.TabletInfo),
-		targetPrimaries: make(map[string]*topo.TabletInfo),
-		cell:            cell,
-		tabletTypes:     tabletTypes,
+		s:                   s,
+		keyspace:            keyspace,
+		workflow:            workflow,
+		sourcePrimaries:     make(map[string]*topo.TabletInfo),
+		targetPrimaries:     make(map[string]*topo.TabletInfo),
+		cell:                cell,
+		tabletTypes:         tabletTypes,
+		metadataTabletTypes: metadataTabletTypes,
 	}
 	for _, shard := range sources {
 		si, err := ts.GetShard(ctx, keyspace, shard)
@@ -126,16 +147,134 @@ func (s *Server) buildResharder(ctx context.Context, keyspace, workflow string,
 	return rs, nil
 }
 
+// buildReshardTeardown builds a resharder to tear down the Reshard
+// workflow of the keyspace before its traffic is switched: the serving
+// shards are its sources and the non-serving ones with streams of the
+// workflow its targets, so it can be run again after a partial teardown.
+// The shards without a primary can't have any stream and are left out.
+func (s *Server) buildReshardTeardown(ctx context.Context, keyspace, workflow string) (*resharder, error) {
+	ts := s.ts
+	rs := &resharder{
+		s:               s,
+		keyspace:        keyspace,
+		workflow:        workflow,
+		sourcePrimaries: make(map[string]*topo.TabletInfo),
+		targetPrimaries: make(map[string]*topo.TabletInfo),
+	}
+	shards, err := ts.FindAllShardsInKeyspace(ctx, keyspace, nil)
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "FindAllShardsInKeyspace(%s) failed", keyspace)
+	}
+	var candidates []*topo.ShardInfo
+	candidatePrimaries := make(map[string]*topo.TabletInfo)
+	for _, si := range shards {
+		if si.PrimaryAlias == nil {
+			continue
+		}
+		primary, err := ts.GetTablet(ctx, si.PrimaryAlias)
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "GetTablet(%s) failed", si.PrimaryAlias)
+		}
+		if si.IsPrimaryServing {
+			rs.sourceShards = append(rs.sourceShards, si)
+			rs.sourcePrimaries[si.ShardName()] = primary
+		} else {
+			candidates = append(candidates, si)
+			candidatePrimaries[si.ShardName()] = primary
+		}
+	}
+	if err := rs.validateSourcesForTeardown(ctx); err != nil {
+		return nil, vterrors.Wrap(err, "validateSourcesForTeardown")
+	}
+	if err := rs.setTeardownTargets(ctx, candidates, candidatePrimaries); err != nil {
+		return nil, vterrors.Wrap(err, "setTeardownTargets")
+	}
+
+	vschema, err := ts.GetVSchema(ctx, keyspace)
+	if err != nil {
+		return nil, vterrors.Wrap(err, "GetVSchema")
+	}
+	rs.vschema = vschema
+
+	if err := rs.readRefStreams(ctx); err != nil {
+		return nil, vterrors.Wrap(err, "readRefStreams")
+	}
+	return rs, nil
+}
+
+// validateSourcesForTeardown ensures that the workflow has no streams on
+// the serving shards, which would mean that its traffic was switched and
+// that tearing it down would not bring the keyspace back to its state
+// before the Reshard.
+func (rs *resharder) validateSourcesForTeardown(ctx context.Context) error {
+	err := rs.forAll(rs.sourceShards, func(source *topo.ShardInfo) error {
+		sourcePrimary := rs.sourcePrimaries[source.ShardName()]
+		query := fmt.Sprintf("select 1 from _vt.vreplication where db_name=%s and workflow=%s", encodeString(sourcePrimary.DbName()), encodeString(rs.workflow))
+		p3qr, err := rs.s.tmc.VReplicationExec(ctx, sourcePrimary.Tablet, query)
+		if err != nil {
+			return vterrors.Wrapf(err, "VReplicationExec(%v, %s)", sourcePrimary.Tablet, query)
+		}
+		if len(p3qr.Rows) != 0 {
+			return fmt.Errorf("workflow %s has streams on the serving shard %s, its traffic was already switched", rs.workflow, source.ShardName())
+		}
+		return nil
+	})
+	return err
+}
+
+// setTeardownTargets sets the targets of the workflow to tear down to the
+// non-serving candidate shards that have streams of the workflow. It
+// fails if any tablet type of the keyspace is served by one of them, as
+// that means that the traffic of that type was already switched, which
+// the serving state of the primaries doesn't tell.
+func (rs *resharder) setTeardownTargets(ctx context.Context, candidates []*topo.ShardInfo, candidatePrimaries map[string]*topo.TabletInfo) error {
+	var mu sync.Mutex
+	err := rs.forAll(candidates, func(target *topo.ShardInfo) error {
+		targetPrimary := candidatePrimaries[target.ShardName()]
+		query := fmt.Sprintf("select 1 from _vt.vreplication where db_name=%s and workflow=%s", encodeString(targetPrimary.DbName()), encodeString(rs.workflow))
+		p3qr, err := rs.s.tmc.VReplicationExec(ctx, targetPrimary.Tablet, query)
+		if err != nil {
+			return vterrors.Wrapf(err, "VReplicationExec(%v, %s)", targetPrimary.Tablet, query)
+		}
+		if len(p3qr.Rows) == 0 {
+			return nil
+		}
+		servingTypes, err := rs.s.ts.GetShardServingTypes(ctx, target)
+		if err != nil {
+			return vterrors.Wrapf(err, "GetShardServingTypes(%s/%s) failed", target.Keyspace(), target.ShardName())
+		}
+		if len(servingTypes) != 0 {
+			return fmt.Errorf("workflow %s has its %s traffic served by the shard %s, its traffic was already switched", rs.workflow, topoproto.MakeStringTypeCSV(servingTypes), target.ShardName())
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		rs.targetShards = append(rs.targetShards, target)
+		rs.targetPrimaries[target.ShardName()] = targetPrimary
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(rs.targetShards, func(i, j int) bool {
+		return rs.targetShards[i].ShardName() < rs.targetShards[j].ShardName()
+	})
+	return nil
+}
+
 // validateTargets ensures that the target shards have no existing
 // VReplication workflow streams as that is an invalid starting
 // state for the non-serving shards involved in a Reshard.
 func (rs *resharder) validateTargets(ctx context.Context) error {
 	err := rs.forAll(rs.targetShards, func(target *topo.ShardInfo) error {
 		targetPrimary := rs.targetPrimaries[target.ShardName()]
-		query := fmt.Sprintf("select 1 from _vt.vreplication where db_name=%s", encodeString(targetPrimary.DbName()))
-		p3qr, err := rs.s.tmc.VReplicationExec(ctx, targetPrimary.Tablet, query)
+		tablet, err := rs.metadataTablet(ctx, target, targetPrimary)
 		if err != nil {
-			return vterrors.Wrapf(err, "VReplicationExec(%v, %s)", targetPrimary.Tablet, query)
+			return err
+		}
+		query := fmt.Sprintf("select 1 from %s.vreplication where db_name=%s", sidecar.GetIdentifier(), encodeString(targetPrimary.DbName()))
+		p3qr, err := rs.readMetadata(ctx, tablet, query)
+		if err != nil {
+			return err
 		}
 		if len(p3qr.Rows) != 0 {
 			return errors.New("some streams already exist in the target shards, please clean them up and retry the command")
@@ -145,15 +284,66 @@ func (rs *resharder) validateTargets(ctx context.Context) error {
 	return err
 }
 
+// metadataTablet returns the tablet of the shard to read the vreplication
+// metadata from: its primary, unless metadataTabletTypes is set, in which
+// case it is a healthy and serving tablet of those types, from the cells of
+// the streams or else from the cell of the primary.
+func (rs *resharder) metadataTablet(ctx context.Context, si *topo.ShardInfo, primary *topo.TabletInfo) (*topodatapb.Tablet, error) {
+	if rs.metadataTabletTypes == "" {
+		return primary.Tablet, nil
+	}
+	cells := []string{primary.Alias.Cell}
+	if rs.cell != "" {
+		cells = strings.Split(rs.cell, ",")
+	}
+	tp, err := discovery.NewTabletPicker(ctx, rs.s.ts, cells, primary.Alias.Cell, si.Keyspace(), si.ShardName(), rs.metadataTabletTypes, discovery.TabletPickerOptions{})
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "NewTabletPicker(%s/%s)", si.Keyspace(), si.ShardName())
+	}
+	tablets := tp.GetMatchingTablets(ctx)
+	if len(tablets) == 0 {
+		return nil, fmt.Errorf("no healthy serving tablet of types %s found in shard %s/%s", rs.metadataTabletTypes, si.Keyspace(), si.ShardName())
+	}
+	return tablets[0].Tablet, nil
+}
+
+// readMetadata runs the read-only query on the vreplication table of the
+// tablet, which the query must qualify with the sidecar database name. The
+// vreplication engine only runs on the primaries, so the other tablets are
+// queried directly, on their database.
+func (rs *resharder) readMetadata(ctx context.Context, tablet *topodatapb.Tablet, query string) (*querypb.QueryResult, error) {
+	if tablet.Type == topodatapb.TabletType_PRIMARY {
+		p3qr, err := rs.s.tmc.VReplicationExec(ctx, tablet, query)
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "VReplicationExec(%v, %s)", tablet, query)
+		}
+		return p3qr, nil
+	}
+	p3qr, err := rs.s.tmc.ExecuteFetchAsDba(ctx, tablet, false, &tabletmanagerdatapb.ExecuteFetchAsDbaRequest{
+		Query:   []byte(query),
+		DbName:  topoproto.TabletDbName(tablet),
+		MaxRows: metadataMaxRows,
+	})
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "ExecuteFetchAsDba(%v, %s)", tablet, query)
+	}
+	return p3qr, nil
+}
+
 func (rs *resharder) readRefStreams(ctx context.Context) error {
 	var mu sync.Mutex
 	err := rs.forAll(rs.sourceShards, func(source *topo.ShardInfo) error {
 		sourcePrimary := rs.sourcePrimaries[source.ShardName()]
+		tablet, err := rs.metadataTablet(ctx, source, sourcePrimary)
+		if err != nil {
+			return err
+		}
 
-		query := fmt.Sprintf("select workflow, source, cell, tablet_types from _vt.vreplication where db_name=%s and message != 'FROZEN'", encodeString(sourcePrimary.DbName()))
-		p3qr, err := rs.s.tmc.VReplicationExec(ctx, sourcePrimary.Tablet, query)
+		query := fmt.Sprintf("select workflow, source, cell, tablet_types from %s.vreplication where db_name=%s and message != 'FROZEN'",
+			sidecar.GetIdentifier(), encodeString(sourcePrimary.DbName()))
+		p3qr, err := rs.readMetadata(ctx, tablet, query)
 		if err != nil {
-			return vterrors.Wrapf(err, "VReplicationExec(%v, %s)", sourcePrimary.Tablet, query)
+			return err
 		}
 		qr := sqltypes.Proto3ToResult(p3qr)
 
@@ -263,6 +453,51 @@ func (rs *resharder) copySchema(ctx context.Context) error {
 	return err
 }
 
+// setTableFilters makes the streams only copy the rows of the given
+// tables that match their WHERE expression, for example to leave the
+// soft-deleted rows out with {"customer": "deleted_at is null"}. The
+// tables must be in the vschema, and the filters of the reference tables
+// are ignored since those tables are excluded from the streams.
+func (rs *resharder) setTableFilters(filters map[string]string) error {
+	tableFilters := make(map[string]string, len(filters))
+	for table, filter := range filters {
+		if _, ok := rs.vschema.Tables[table]; !ok {
+			return fmt.Errorf("table %v of the filter %q not found in vschema", table, filter)
+		}
+		expr, err := rs.s.SQLParser().ParseExpr(filter)
+		if err != nil {
+			return vterrors.Wrapf(err, "invalid filter %q for table %v", filter, table)
+		}
+		tableFilters[table] = sqlparser.String(expr)
+	}
+	rs.tableFilters = tableFilters
+	return nil
+}
+
+// filterRules returns the rules that copy the rows of keyRange which
+// match the table filters. They come after the exclude rules of the
+// reference tables, which take precedence, and before the rule that
+// copies the rows of keyRange of the other tables.
+func (rs *resharder) filterRules(keyRange *topodatapb.KeyRange) []*binlogdatapb.Rule {
+	tables := make([]string, 0, len(rs.tableFilters))
+	for table := range rs.tableFilters {
+		if rs.vschema.Tables[table].GetType() == vindexes.TypeReference {
+			continue
+		}
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	rules := make([]*binlogdatapb.Rule, 0, len(tables))
+	for _, table := range tables {
+		rules = append(rules, &binlogdatapb.Rule{
+			Match: table,
+			Filter: fmt.Sprintf("select * from %s where in_keyrange(%s) and (%s)",
+				sqlescape.EscapeID(table), encodeString(key.KeyRangeString(keyRange)), rs.tableFilters[table]),
+		})
+	}
+	return rules
+}
+
 // createStreams creates all of the VReplication streams that
 // need to now exist on the new shards.
 func (rs *resharder) createStreams(ctx context.Context) error {
@@ -281,8 +516,9 @@ func (rs *resharder) createStreams(ctx context.Context) error {
 
 		ig := vreplication.NewInsertGenerator(binlogdatapb.VReplicationWorkflowState_Stopped, targetPrimary.DbName())
 
-		// copy excludeRules to prevent data race.
-		copyExcludeRules := append([]*binlogdatapb.Rule(nil), excludeRules...)
+		// copy excludeRules to prevent data race, the filter rules of the
+		// target come right after them.
+		copyExcludeRules := append(append([]*binlogdatapb.Rule(nil), excludeRules...), rs.filterRules(target.KeyRange)...)
 		for _, source := range rs.sourceShards {
 			if !key.KeyRangeIntersect(target.KeyRange, source.KeyRange) {
 				continue
@@ -342,6 +578,33 @@ func (rs *resharder) startStreams(ctx context.Context) error {
 	return err
 }
 
+// deleteStreams stops and deletes, on the target shards, the streams of
+// the workflow and the reference streams that createStreams copied along
+// with them. Deleting a stream stops it.
+func (rs *resharder) deleteStreams(ctx context.Context) error {
+	refWorkflows := make(map[string]bool)
+	for _, rstream := range rs.refStreams {
+		if rstream.workflow != rs.workflow {
+			refWorkflows[rstream.workflow] = true
+		}
+	}
+	workflows := []string{encodeString(rs.workflow)}
+	for workflow := range refWorkflows {
+		workflows = append(workflows, encodeString(workflow))
+	}
+	sort.Strings(workflows[1:])
+
+	err := rs.forAll(rs.targetShards, func(target *topo.ShardInfo) error {
+		targetPrimary := rs.targetPrimaries[target.ShardName()]
+		query := fmt.Sprintf("delete from _vt.vreplication where db_name=%s and workflow in (%s)", encodeString(targetPrimary.DbName()), strings.Join(workflows, ", "))
+		if _, err := rs.s.tmc.VReplicationExec(ctx, targetPrimary.Tablet, query); err != nil {
+			return vterrors.Wrapf(err, "VReplicationExec(%v, %s)", targetPrimary.Tablet, query)
+		}
+		return nil
+	})
+	return err
+}
+
 func (rs *resharder) forAll(shards []*topo.ShardInfo, f func(*topo.ShardInfo) error) error {
 	var wg sync.WaitGroup
 	allErrors := &concurrency.AllErrorRecorder{}
@@ -0,0 +1,264 @@
+/*
+Copyright 2024 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/constants/sidecar"
+	"vitess.io/vitess/go/mysql/collations"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/test/utils"
+	"vitess.io/vitess/go/vt/key"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/topo"
+	"vitess.io/vitess/go/vt/topo/memorytopo"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+	"vitess.io/vitess/go/vt/vttablet/tmclient"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+)
+
+const (
+	rtSelectWorkflowQuery = "select 1 from _vt.vreplication where db_name='vt_ks' and workflow='wf'"
+	rtReadRefStreamsQuery = "select workflow, source, cell, tablet_types from _vt.vreplication where db_name='vt_ks' and message != 'FROZEN'"
+)
+
+// newReshardTeardownEnv returns an env for a Reshard of the ks keyspace
+// from its serving 0 shard, on tablet 100, to its non-serving -80 and 80-
+// shards, on tablets 200 and 210.
+func newReshardTeardownEnv(t *testing.T, ctx context.Context) *testMaterializerEnv {
+	ms := &vtctldatapb.MaterializeSettings{
+		SourceKeyspace: "ks",
+		TargetKeyspace: "ks",
+	}
+	env := newTestMaterializerEnv(t, ctx, ms, []string{"0"}, nil)
+	for i, shard := range []string{"-80", "80-"} {
+		env.addTablet(200+10*i, "ks", shard, topodatapb.TabletType_PRIMARY)
+		_, err := env.topoServ.UpdateShardFields(ctx, "ks", shard, func(si *topo.ShardInfo) error {
+			si.IsPrimaryServing = false
+			return nil
+		})
+		require.NoError(t, err)
+	}
+	err := env.topoServ.SaveVSchema(ctx, "ks", &vschemapb.Keyspace{
+		Tables: map[string]*vschemapb.Table{
+			"ref": {Type: vindexes.TypeReference},
+		},
+	})
+	require.NoError(t, err)
+	return env
+}
+
+func TestReshardTeardown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newReshardTeardownEnv(t, ctx)
+	defer env.close()
+
+	env.tmc.expectVRQuery(100, rtSelectWorkflowQuery, &sqltypes.Result{})
+	env.tmc.expectVRQuery(100, rtReadRefStreamsQuery, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("workflow|source|cell|tablet_types", "varchar|varchar|varchar|varchar"),
+		`refwf|keyspace:"ks2" shard:"0" filter:{rules:{match:"ref"}}||`,
+	))
+	// The -80 shard still has its streams, the teardown of the 80- shard
+	// already happened.
+	env.tmc.expectVRQuery(200, rtSelectWorkflowQuery, sqltypes.MakeTestResult(sqltypes.MakeTestFields("1", "int64"), "1"))
+	env.tmc.expectVRQuery(200, "delete from _vt.vreplication where db_name='vt_ks' and workflow in ('wf', 'refwf')", &sqltypes.Result{RowsAffected: 2})
+	env.tmc.expectVRQuery(210, rtSelectWorkflowQuery, &sqltypes.Result{})
+
+	require.NoError(t, env.ws.ReshardTeardown(ctx, "ks", "wf"))
+	env.tmc.verifyQueries(t)
+}
+
+func TestReshardTeardownSwitched(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newReshardTeardownEnv(t, ctx)
+	defer env.close()
+
+	env.tmc.expectVRQuery(100, rtSelectWorkflowQuery, sqltypes.MakeTestResult(sqltypes.MakeTestFields("1", "int64"), "1"))
+
+	err := env.ws.ReshardTeardown(ctx, "ks", "wf")
+	require.ErrorContains(t, err, "workflow wf has streams on the serving shard 0, its traffic was already switched")
+	env.tmc.verifyQueries(t)
+}
+
+func TestReshardTeardownReplicaSwitched(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newReshardTeardownEnv(t, ctx)
+	defer env.close()
+
+	// The replica traffic was switched, which leaves the workflow streams
+	// and the primary serving state as they were.
+	err := env.topoServ.UpdateSrvKeyspace(ctx, env.cell, "ks", &topodatapb.SrvKeyspace{
+		Partitions: []*topodatapb.SrvKeyspace_KeyspacePartition{{
+			ServedType:      topodatapb.TabletType_PRIMARY,
+			ShardReferences: []*topodatapb.ShardReference{{Name: "0"}},
+		}, {
+			ServedType:      topodatapb.TabletType_REPLICA,
+			ShardReferences: []*topodatapb.ShardReference{{Name: "-80"}, {Name: "80-"}},
+		}},
+	})
+	require.NoError(t, err)
+	env.tmc.expectVRQuery(100, rtSelectWorkflowQuery, &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, rtSelectWorkflowQuery, sqltypes.MakeTestResult(sqltypes.MakeTestFields("1", "int64"), "1"))
+	env.tmc.expectVRQuery(210, rtSelectWorkflowQuery, sqltypes.MakeTestResult(sqltypes.MakeTestFields("1", "int64"), "1"))
+
+	err = env.ws.ReshardTeardown(ctx, "ks", "wf")
+	require.ErrorContains(t, err, "workflow wf has its replica traffic served by the shard -80, its traffic was already switched")
+	env.tmc.verifyQueries(t)
+}
+
+func TestResharderTableFilters(t *testing.T) {
+	rs := &resharder{
+		s: NewServer(nil, nil, collations.MySQL8(), sqlparser.NewTestParser()),
+		vschema: &vschemapb.Keyspace{
+			Tables: map[string]*vschemapb.Table{
+				"customer": {},
+				"corder":   {},
+				"ref":      {Type: vindexes.TypeReference},
+			},
+		},
+	}
+
+	err := rs.setTableFilters(map[string]string{"unknown": "deleted_at is null"})
+	require.EqualError(t, err, `table unknown of the filter "deleted_at is null" not found in vschema`)
+	err = rs.setTableFilters(map[string]string{"customer": "deleted_at is"})
+	require.ErrorContains(t, err, `invalid filter "deleted_at is" for table customer`)
+
+	err = rs.setTableFilters(map[string]string{
+		"customer": "deleted_at IS NULL",
+		"corder":   "status != 'deleted'",
+		"ref":      "deleted_at is null",
+	})
+	require.NoError(t, err)
+	keyRange, err := key.ParseShardingSpec("-80")
+	require.NoError(t, err)
+	want := []*binlogdatapb.Rule{{
+		Match:  "corder",
+		Filter: "select * from `corder` where in_keyrange('-80') and (`status` != 'deleted')",
+	}, {
+		Match:  "customer",
+		Filter: "select * from `customer` where in_keyrange('-80') and (deleted_at is null)",
+	}}
+	utils.MustMatch(t, want, rs.filterRules(keyRange[0]))
+}
+
+// metadataTMC records which RPCs read the vreplication metadata.
+type metadataTMC struct {
+	tmclient.TabletManagerClient
+	calls []string
+}
+
+func (tmc *metadataTMC) VReplicationExec(ctx context.Context, tablet *topodatapb.Tablet, query string) (*querypb.QueryResult, error) {
+	tmc.calls = append(tmc.calls, "VReplicationExec: "+query)
+	return &querypb.QueryResult{}, nil
+}
+
+func (tmc *metadataTMC) ExecuteFetchAsDba(ctx context.Context, tablet *topodatapb.Tablet, usePool bool, req *tabletmanagerdatapb.ExecuteFetchAsDbaRequest) (*querypb.QueryResult, error) {
+	tmc.calls = append(tmc.calls, fmt.Sprintf("ExecuteFetchAsDba(%s): %s", req.DbName, req.Query))
+	return &querypb.QueryResult{}, nil
+}
+
+func TestResharderMetadataTablet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ts := memorytopo.NewServer(ctx, "cell")
+	tmc := &metadataTMC{}
+	rs := &resharder{s: NewServer(ts, tmc, collations.MySQL8(), sqlparser.NewTestParser())}
+
+	si := topo.NewShardInfo("ks", "0", &topodatapb.Shard{}, nil)
+	primary := &topo.TabletInfo{Tablet: &topodatapb.Tablet{
+		Alias: &topodatapb.TabletAlias{Cell: "cell", Uid: 100},
+		Type:  topodatapb.TabletType_PRIMARY,
+	}}
+	tablet, err := rs.metadataTablet(ctx, si, primary)
+	require.NoError(t, err)
+	require.Same(t, primary.Tablet, tablet)
+
+	rs.metadataTabletTypes = "replica"
+	_, err = rs.metadataTablet(ctx, si, primary)
+	require.EqualError(t, err, "no healthy serving tablet of types replica found in shard ks/0")
+
+	replica := &topodatapb.Tablet{
+		Alias:    &topodatapb.TabletAlias{Cell: "cell", Uid: 101},
+		Keyspace: "ks",
+		Type:     topodatapb.TabletType_REPLICA,
+	}
+	_, err = rs.readMetadata(ctx, primary.Tablet, "select 1 from _vt.vreplication")
+	require.NoError(t, err)
+	_, err = rs.readMetadata(ctx, replica, "select 2 from _vt.vreplication")
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"VReplicationExec: select 1 from _vt.vreplication",
+		"ExecuteFetchAsDba(vt_ks): select 2 from _vt.vreplication",
+	}, tmc.calls)
+}
+
+func TestResharderMetadataSidecarName(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ts := memorytopo.NewServer(ctx, "cell")
+	tmc := &metadataTMC{}
+	rs := &resharder{s: NewServer(ts, tmc, collations.MySQL8(), sqlparser.NewTestParser())}
+
+	sidecar.SetName("_vt_custom")
+	defer sidecar.SetName(sidecar.DefaultName)
+	primary := &topo.TabletInfo{Tablet: &topodatapb.Tablet{
+		Alias:    &topodatapb.TabletAlias{Cell: "cell", Uid: 100},
+		Keyspace: "ks",
+		Type:     topodatapb.TabletType_PRIMARY,
+	}}
+	rs.targetShards = []*topo.ShardInfo{topo.NewShardInfo("ks", "0", &topodatapb.Shard{}, nil)}
+	rs.targetPrimaries = map[string]*topo.TabletInfo{"0": primary}
+	require.NoError(t, rs.validateTargets(ctx))
+	require.Equal(t, []string{
+		"VReplicationExec: select 1 from _vt_custom.vreplication where db_name='vt_ks'",
+	}, tmc.calls)
+}
+
+func TestReshardCreateWithNilOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newReshardTeardownEnv(t, ctx)
+	defer env.close()
+
+	// Nil options are the default ones, so the request fails like it would
+	// without options.
+	req := &vtctldatapb.ReshardCreateRequest{
+		Keyspace:     "unknown",
+		Workflow:     "wf",
+		SourceShards: []string{"0"},
+		TargetShards: []string{"-80", "80-"},
+	}
+	_, wantErr := env.ws.ReshardCreate(ctx, req)
+	require.Error(t, wantErr)
+	_, err := env.ws.ReshardCreateWithOptions(ctx, req, nil)
+	require.EqualError(t, err, wantErr.Error())
+}
@@ -1644,6 +1644,32 @@ func (s *Server) MoveTablesComplete(ctx context.Context, req *vtctldatapb.MoveTa
 
 // ReshardCreate is part of the vtctlservicepb.VtctldServer interface.
 func (s *Server) ReshardCreate(ctx context.Context, req *vtctldatapb.ReshardCreateRequest) (*vtctldatapb.WorkflowStatusResponse, error) {
+	return s.ReshardCreateWithOptions(ctx, req, &ReshardOptions{})
+}
+
+// ReshardOptions are the options of a Reshard that the
+// ReshardCreateRequest does not carry.
+type ReshardOptions struct {
+	// TableFilters are the WHERE expressions, by table name, that the rows
+	// of those tables must match to be copied, for example
+	// {"customer": "deleted_at is null"} to leave the soft-deleted rows
+	// out. The reference tables, which are excluded from the Reshard
+	// streams, can't be filtered.
+	TableFilters map[string]string
+	// MetadataTabletTypes are the tablet types of the tablets read to
+	// validate the target shards and to find the reference streams of the
+	// source shards, in the format of the tablet types of the streams,
+	// such as "replica". The tablets read must be healthy and serving.
+	// The primary tablets are read when it is empty.
+	MetadataTabletTypes string
+}
+
+// ReshardCreateWithOptions is ReshardCreate with the given options. Nil
+// options are the default ones.
+func (s *Server) ReshardCreateWithOptions(ctx context.Context, req *vtctldatapb.ReshardCreateRequest, opts *ReshardOptions) (*vtctldatapb.WorkflowStatusResponse, error) {
+	if opts == nil {
+		opts = &ReshardOptions{}
+	}
 	span, ctx := trace.NewSpan(ctx, "workflow.Server.ReshardCreate")
 	defer span.Finish()
 
@@ -1654,6 +1680,7 @@ func (s *Server) ReshardCreate(ctx context.Context, req *vtctldatapb.ReshardCrea
 	span.Annotate("cells", req.Cells)
 	span.Annotate("tablet_types", req.TabletTypes)
 	span.Annotate("on_ddl", req.OnDdl)
+	span.Annotate("metadata_tablet_types", opts.MetadataTabletTypes)
 
 	keyspace := req.Keyspace
 	cells := req.Cells
@@ -1664,13 +1691,16 @@ func (s *Server) ReshardCreate(ctx context.Context, req *vtctldatapb.ReshardCrea
 		log.Errorf("%w", err2)
 		return nil, err
 	}
-	rs, err := s.buildResharder(ctx, keyspace, req.Workflow, req.SourceShards, req.TargetShards, strings.Join(cells, ","), "")
+	rs, err := s.buildResharder(ctx, keyspace, req.Workflow, req.SourceShards, req.TargetShards, strings.Join(cells, ","), "", opts.MetadataTabletTypes)
 	if err != nil {
 		return nil, vterrors.Wrap(err, "buildResharder")
 	}
 	rs.onDDL = req.OnDdl
 	rs.stopAfterCopy = req.StopAfterCopy
 	rs.deferSecondaryKeys = req.DeferSecondaryKeys
+	if err := rs.setTableFilters(opts.TableFilters); err != nil {
+		return nil, vterrors.Wrap(err, "setTableFilters")
+	}
 	if !req.SkipSchemaCopy {
 		if err := rs.copySchema(ctx); err != nil {
 			return nil, vterrors.Wrap(err, "copySchema")
@@ -1690,6 +1720,28 @@ func (s *Server) ReshardCreate(ctx context.Context, req *vtctldatapb.ReshardCrea
 	return nil, nil
 }
 
+// ReshardTeardown abandons a Reshard workflow created by ReshardCreate
+// whose traffic has not been switched: it stops and deletes the streams of
+// the workflow on the target shards, and leaves the source shards
+// untouched, which brings the keyspace back to its state before the
+// Reshard. It can be run again after a partial teardown.
+func (s *Server) ReshardTeardown(ctx context.Context, keyspace, workflow string) error {
+	span, ctx := trace.NewSpan(ctx, "workflow.Server.ReshardTeardown")
+	defer span.Finish()
+
+	span.Annotate("keyspace", keyspace)
+	span.Annotate("workflow", workflow)
+
+	rs, err := s.buildReshardTeardown(ctx, keyspace, workflow)
+	if err != nil {
+		return vterrors.Wrap(err, "buildReshardTeardown")
+	}
+	if err := rs.deleteStreams(ctx); err != nil {
+		return vterrors.Wrap(err, "deleteStreams")
+	}
+	return nil
+}
+
 // VDiffCreate is part of the vtctlservicepb.VtctldServer interface.
 // It passes on the request to the target primary tablets that are
 // participating in the given workflow and VDiff.
@@ -18,8 +18,12 @@ package vtctld
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/pflag"
 
@@ -32,6 +36,7 @@ import (
 	"vitess.io/vitess/go/vt/servenv"
 	"vitess.io/vitess/go/vt/topo"
 	"vitess.io/vitess/go/vt/topo/topoproto"
+	"vitess.io/vitess/go/vt/vtctl/grpcvtctldserver"
 	"vitess.io/vitess/go/vt/vttablet/tmclient"
 	"vitess.io/vitess/go/vt/wrangler"
 
@@ -48,6 +53,11 @@ type ActionResult struct {
 	Parameters string
 	Output     string
 	Error      bool
+	// Data is the structured result of the action, if it has one. It is
+	// served as JSON along with Output.
+	Data any `json:",omitempty"`
+	// DryRun is set when the action only reported what it would change.
+	DryRun bool `json:",omitempty"`
 }
 
 func (ar *ActionResult) error(text string) {
@@ -75,56 +85,367 @@ type actionShardMethod func(ctx context.Context, wr *wrangler.Wrangler, keyspace
 
 type actionTabletMethod func(ctx context.Context, wr *wrangler.Wrangler, tabletAlias *topodatapb.TabletAlias) (output string, err error)
 
+// action{Keyspace,Shard,Tablet}DataMethod is like the methods above for
+// actions that also return structured data, which ends up in
+// ActionResult.Data.
+type actionKeyspaceDataMethod func(ctx context.Context, wr *wrangler.Wrangler, keyspace string) (output string, data any, err error)
+
+type actionShardDataMethod func(ctx context.Context, wr *wrangler.Wrangler, keyspace, shard string) (output string, data any, err error)
+
+type actionTabletDataMethod func(ctx context.Context, wr *wrangler.Wrangler, tabletAlias *topodatapb.TabletAlias) (output string, data any, err error)
+
+// action{Keyspace,Shard,Tablet}Record is a registered action with the
+// role needed to apply it and how long it may run. An empty role doesn't
+// need any access check, and a zero timeout uses actionTimeout.
+type actionKeyspaceRecord struct {
+	role    string
+	timeout time.Duration
+	method  actionKeyspaceDataMethod
+	// dryRun, if set, returns what method would change without
+	// changing it.
+	dryRun actionKeyspaceMethod
+}
+
+type actionShardRecord struct {
+	role    string
+	timeout time.Duration
+	method  actionShardDataMethod
+	dryRun  actionShardMethod
+}
+
 type actionTabletRecord struct {
-	role   string
-	method actionTabletMethod
+	role    string
+	timeout time.Duration
+	method  actionTabletDataMethod
+}
+
+// actionContext returns the context an action with the given timeout
+// runs in.
+func actionContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		timeout = actionTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
 }
 
 // ActionRepository is a repository of actions that can be performed
 // on a {Keyspace,Shard,Tablet}.
 type ActionRepository struct {
-	keyspaceActions map[string]actionKeyspaceMethod
-	shardActions    map[string]actionShardMethod
+	keyspaceActions map[string]actionKeyspaceRecord
+	shardActions    map[string]actionShardRecord
 	tabletActions   map[string]actionTabletRecord
 	ts              *topo.Server
 	collationEnv    *collations.Environment
 	parser          *sqlparser.Parser
+
+	// tmc is shared by the actions so that they reuse its connections,
+	// and so is vtctld, which uses it.
+	tmc    tmclient.TabletManagerClient
+	vtctld *grpcvtctldserver.VtctldServer
+	// newWrangler returns the wrangler an action runs with.
+	newWrangler func() *wrangler.Wrangler
+
+	// auditHook, if set, is called after each action is applied.
+	auditHook func(ActionAuditEvent)
+
+	// running has the cancel functions of the running actions that were
+	// given an id, see StartCancelable.
+	runningMu sync.Mutex
+	running   map[string]context.CancelFunc
+
+	// inFlight has the actions being applied, by kind, name and target, so
+	// that the same action doesn't run twice at once on a target.
+	inFlightMu sync.Mutex
+	inFlight   map[string]bool
+}
+
+// startInFlight marks the action of the kind as being applied to the target
+// and returns the function to call once it's done. It returns false if the
+// action is already being applied to the target.
+func (ar *ActionRepository) startInFlight(kind, name, target string) (done func(), ok bool) {
+	key := kind + "/" + name + "/" + target
+	ar.inFlightMu.Lock()
+	defer ar.inFlightMu.Unlock()
+	if ar.inFlight[key] {
+		return nil, false
+	}
+	if ar.inFlight == nil {
+		ar.inFlight = make(map[string]bool)
+	}
+	ar.inFlight[key] = true
+	return func() {
+		ar.inFlightMu.Lock()
+		delete(ar.inFlight, key)
+		ar.inFlightMu.Unlock()
+	}, true
+}
+
+// StartCancelable returns a context for an action that CancelAction(id)
+// cancels until done is called. An empty id returns a context that only
+// ctx cancels.
+func (ar *ActionRepository) StartCancelable(ctx context.Context, id string) (actionCtx context.Context, done func(), err error) {
+	if id == "" {
+		return ctx, func() {}, nil
+	}
+	ar.runningMu.Lock()
+	defer ar.runningMu.Unlock()
+	if _, ok := ar.running[id]; ok {
+		return nil, nil, fmt.Errorf("an action with id %v is already running", id)
+	}
+	if ar.running == nil {
+		ar.running = make(map[string]context.CancelFunc)
+	}
+	actionCtx, cancel := context.WithCancel(ctx)
+	ar.running[id] = cancel
+	return actionCtx, func() {
+		ar.runningMu.Lock()
+		delete(ar.running, id)
+		ar.runningMu.Unlock()
+		cancel()
+	}, nil
+}
+
+// CancelAction cancels the running action started with the id, and returns
+// whether there was one.
+func (ar *ActionRepository) CancelAction(id string) bool {
+	ar.runningMu.Lock()
+	defer ar.runningMu.Unlock()
+	cancel, ok := ar.running[id]
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// ActionAuditEvent describes an action that was applied, for auditing.
+type ActionAuditEvent struct {
+	Name       string
+	Parameters string
+	// Identity is who asked for the action, see requestIdentity.
+	Identity string
+	Error    bool
+	DryRun   bool
+	Duration time.Duration
+}
+
+// SetAuditHook sets a function that is called after each action is
+// applied, including the ones that were unknown or denied.
+func (ar *ActionRepository) SetAuditHook(hook func(ActionAuditEvent)) {
+	ar.auditHook = hook
+}
+
+// audit reports the action of result, applied since start, to the audit
+// hook.
+func (ar *ActionRepository) audit(r *http.Request, result *ActionResult, start time.Time) {
+	if ar.auditHook == nil {
+		return
+	}
+	ar.auditHook(ActionAuditEvent{
+		Name:       result.Name,
+		Parameters: result.Parameters,
+		Identity:   requestIdentity(r),
+		Error:      result.Error,
+		DryRun:     result.DryRun,
+		Duration:   time.Since(start),
+	})
+}
+
+// requestIdentity returns who sent r: the common name of the verified
+// client certificate, or else the basic auth user, with the remote address.
+func requestIdentity(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	var user string
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		user = r.TLS.VerifiedChains[0][0].Subject.CommonName
+	} else if username, _, ok := r.BasicAuth(); ok {
+		user = username
+	}
+	if user == "" {
+		return r.RemoteAddr
+	}
+	return user + "@" + r.RemoteAddr
 }
 
 // NewActionRepository creates and returns a new ActionRepository,
 // with no actions.
 func NewActionRepository(ts *topo.Server, collationEnv *collations.Environment, parser *sqlparser.Parser) *ActionRepository {
-	return &ActionRepository{
-		keyspaceActions: make(map[string]actionKeyspaceMethod),
-		shardActions:    make(map[string]actionShardMethod),
+	return NewActionRepositoryWithTabletManagerClient(ts, tmclient.NewTabletManagerClient(), collationEnv, parser)
+}
+
+// NewActionRepositoryWithTabletManagerClient creates and returns a new
+// ActionRepository, with no actions, whose actions use the given tablet
+// manager client. It lets a process share a single client with its other
+// users.
+func NewActionRepositoryWithTabletManagerClient(ts *topo.Server, tmc tmclient.TabletManagerClient, collationEnv *collations.Environment, parser *sqlparser.Parser) *ActionRepository {
+	ar := &ActionRepository{
+		keyspaceActions: make(map[string]actionKeyspaceRecord),
+		shardActions:    make(map[string]actionShardRecord),
 		tabletActions:   make(map[string]actionTabletRecord),
 		ts:              ts,
+		tmc:             tmc,
+		vtctld:          grpcvtctldserver.NewVtctldServerWithTabletManagerClient(ts, tmc, collationEnv, parser),
 		collationEnv:    collationEnv,
 		parser:          parser,
 	}
+	ar.newWrangler = func() *wrangler.Wrangler {
+		return wrangler.NewWithVtctldServer(logutil.NewConsoleLogger(), ar.ts, ar.tmc, ar.vtctld, ar.collationEnv, ar.parser)
+	}
+	return ar
+}
+
+// TabletManagerClient returns the tablet manager client shared by the
+// actions.
+func (ar *ActionRepository) TabletManagerClient() tmclient.TabletManagerClient {
+	return ar.tmc
+}
+
+// SetWranglerFactory makes the actions run with the wranglers returned by
+// newWrangler instead of ones using the shared tablet manager client.
+func (ar *ActionRepository) SetWranglerFactory(newWrangler func() *wrangler.Wrangler) {
+	ar.newWrangler = newWrangler
 }
 
 // RegisterKeyspaceAction registers a new action on a keyspace.
-func (ar *ActionRepository) RegisterKeyspaceAction(name string, method actionKeyspaceMethod) {
-	ar.keyspaceActions[name] = method
+func (ar *ActionRepository) RegisterKeyspaceAction(name, role string, method actionKeyspaceMethod) {
+	ar.RegisterKeyspaceActionWithTimeout(name, role, 0, method)
+}
+
+// RegisterKeyspaceActionWithTimeout registers a new action on a keyspace
+// that may run for timeout instead of --action_timeout.
+func (ar *ActionRepository) RegisterKeyspaceActionWithTimeout(name, role string, timeout time.Duration, method actionKeyspaceMethod) {
+	ar.RegisterKeyspaceDataAction(name, role, timeout, func(ctx context.Context, wr *wrangler.Wrangler, keyspace string) (string, any, error) {
+		output, err := method(ctx, wr, keyspace)
+		return output, nil, err
+	})
+}
+
+// RegisterKeyspaceDataAction registers a new action on a keyspace that
+// returns structured data. A zero timeout uses --action_timeout.
+func (ar *ActionRepository) RegisterKeyspaceDataAction(name, role string, timeout time.Duration, method actionKeyspaceDataMethod) {
+	ar.keyspaceActions[name] = actionKeyspaceRecord{
+		role:    role,
+		timeout: timeout,
+		method:  method,
+	}
 }
 
 // RegisterShardAction registers a new action on a shard.
-func (ar *ActionRepository) RegisterShardAction(name string, method actionShardMethod) {
-	ar.shardActions[name] = method
+func (ar *ActionRepository) RegisterShardAction(name, role string, method actionShardMethod) {
+	ar.RegisterShardActionWithTimeout(name, role, 0, method)
+}
+
+// RegisterShardActionWithTimeout registers a new action on a shard that may
+// run for timeout instead of --action_timeout.
+func (ar *ActionRepository) RegisterShardActionWithTimeout(name, role string, timeout time.Duration, method actionShardMethod) {
+	ar.RegisterShardDataAction(name, role, timeout, func(ctx context.Context, wr *wrangler.Wrangler, keyspace, shard string) (string, any, error) {
+		output, err := method(ctx, wr, keyspace, shard)
+		return output, nil, err
+	})
+}
+
+// RegisterShardDataAction registers a new action on a shard that returns
+// structured data. A zero timeout uses --action_timeout.
+func (ar *ActionRepository) RegisterShardDataAction(name, role string, timeout time.Duration, method actionShardDataMethod) {
+	ar.shardActions[name] = actionShardRecord{
+		role:    role,
+		timeout: timeout,
+		method:  method,
+	}
 }
 
 // RegisterTabletAction registers a new action on a tablet.
 func (ar *ActionRepository) RegisterTabletAction(name, role string, method actionTabletMethod) {
+	ar.RegisterTabletActionWithTimeout(name, role, 0, method)
+}
+
+// RegisterTabletActionWithTimeout registers a new action on a tablet that
+// may run for timeout instead of --action_timeout.
+func (ar *ActionRepository) RegisterTabletActionWithTimeout(name, role string, timeout time.Duration, method actionTabletMethod) {
+	ar.RegisterTabletDataAction(name, role, timeout, func(ctx context.Context, wr *wrangler.Wrangler, tabletAlias *topodatapb.TabletAlias) (string, any, error) {
+		output, err := method(ctx, wr, tabletAlias)
+		return output, nil, err
+	})
+}
+
+// RegisterTabletDataAction registers a new action on a tablet that returns
+// structured data. A zero timeout uses --action_timeout.
+func (ar *ActionRepository) RegisterTabletDataAction(name, role string, timeout time.Duration, method actionTabletDataMethod) {
 	ar.tabletActions[name] = actionTabletRecord{
-		role:   role,
-		method: method,
+		role:    role,
+		timeout: timeout,
+		method:  method,
 	}
 }
 
-// ApplyKeyspaceAction applies the provided action to the keyspace.
-func (ar *ActionRepository) ApplyKeyspaceAction(ctx context.Context, actionName, keyspace string) *ActionResult {
-	result := &ActionResult{Name: actionName, Parameters: keyspace}
+// RegisterKeyspaceActionDryRun registers the dry run of a keyspace action,
+// which returns the changes the action would make in its output.
+func (ar *ActionRepository) RegisterKeyspaceActionDryRun(name string, dryRun actionKeyspaceMethod) error {
+	action, ok := ar.keyspaceActions[name]
+	if !ok {
+		return fmt.Errorf("unknown keyspace action %v", name)
+	}
+	action.dryRun = dryRun
+	ar.keyspaceActions[name] = action
+	return nil
+}
+
+// RegisterShardActionDryRun registers the dry run of a shard action, which
+// returns the changes the action would make in its output.
+func (ar *ActionRepository) RegisterShardActionDryRun(name string, dryRun actionShardMethod) error {
+	action, ok := ar.shardActions[name]
+	if !ok {
+		return fmt.Errorf("unknown shard action %v", name)
+	}
+	action.dryRun = dryRun
+	ar.shardActions[name] = action
+	return nil
+}
+
+// TabletActionInfo describes a registered tablet action.
+type TabletActionInfo struct {
+	Name string
+	// Role is the role needed to apply the action, empty if none is.
+	Role string
+}
+
+// ListKeyspaceActions returns the sorted names of the keyspace actions.
+func (ar *ActionRepository) ListKeyspaceActions() []string {
+	names := make([]string, 0, len(ar.keyspaceActions))
+	for name := range ar.keyspaceActions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ListShardActions returns the sorted names of the shard actions.
+func (ar *ActionRepository) ListShardActions() []string {
+	names := make([]string, 0, len(ar.shardActions))
+	for name := range ar.shardActions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ListTabletActions returns the tablet actions sorted by name.
+func (ar *ActionRepository) ListTabletActions() []TabletActionInfo {
+	actions := make([]TabletActionInfo, 0, len(ar.tabletActions))
+	for name, action := range ar.tabletActions {
+		actions = append(actions, TabletActionInfo{Name: name, Role: action.role})
+	}
+	sort.Slice(actions, func(i, j int) bool { return actions[i].Name < actions[j].Name })
+	return actions
+}
+
+// ApplyKeyspaceAction applies the provided action to the keyspace. With
+// dryRun, it only reports what the action would change, if the action
+// supports it.
+func (ar *ActionRepository) ApplyKeyspaceAction(ctx context.Context, actionName, keyspace string, dryRun bool, r *http.Request) *ActionResult {
+	result := &ActionResult{Name: actionName, Parameters: keyspace, DryRun: dryRun}
+	defer ar.audit(r, result, time.Now())
 
 	action, ok := ar.keyspaceActions[actionName]
 	if !ok {
@@ -132,26 +453,58 @@ func (ar *ActionRepository) ApplyKeyspaceAction(ctx context.Context, actionName,
 		return result
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, actionTimeout)
-	wr := wrangler.New(logutil.NewConsoleLogger(), ar.ts, tmclient.NewTabletManagerClient(), ar.collationEnv, ar.parser)
-	output, err := action(ctx, wr, keyspace)
+	// check the role
+	if action.role != "" {
+		if err := acl.CheckAccessHTTP(r, action.role); err != nil {
+			result.error("Access denied")
+			return result
+		}
+	}
+
+	method := action.method
+	if dryRun {
+		if action.dryRun == nil {
+			result.error("Dry run isn't available for this keyspace action")
+			return result
+		}
+		method = func(ctx context.Context, wr *wrangler.Wrangler, keyspace string) (string, any, error) {
+			output, err := action.dryRun(ctx, wr, keyspace)
+			return output, nil, err
+		}
+	}
+	if !dryRun {
+		done, ok := ar.startInFlight("keyspace", actionName, keyspace)
+		if !ok {
+			result.error("Action already in progress")
+			return result
+		}
+		defer done()
+	}
+
+	ctx, cancel := actionContext(ctx, action.timeout)
+	wr := ar.newWrangler()
+	defer wr.Close()
+	output, data, err := method(ctx, wr, keyspace)
 	cancel()
 	if err != nil {
 		result.error(err.Error())
 		return result
 	}
 	result.Output = output
+	result.Data = data
 	return result
 }
 
-// ApplyShardAction applies the provided action to the shard.
-func (ar *ActionRepository) ApplyShardAction(ctx context.Context, actionName, keyspace, shard string) *ActionResult {
+// ApplyShardAction applies the provided action to the shard. With dryRun,
+// it only reports what the action would change, if the action supports it.
+func (ar *ActionRepository) ApplyShardAction(ctx context.Context, actionName, keyspace, shard string, dryRun bool, r *http.Request) *ActionResult {
 	// if the shard name contains a '-', we assume it's the
 	// name for a ranged based shard, so we lower case it.
 	if strings.Contains(shard, "-") {
 		shard = strings.ToLower(shard)
 	}
-	result := &ActionResult{Name: actionName, Parameters: keyspace + "/" + shard}
+	result := &ActionResult{Name: actionName, Parameters: keyspace + "/" + shard, DryRun: dryRun}
+	defer ar.audit(r, result, time.Now())
 
 	action, ok := ar.shardActions[actionName]
 	if !ok {
@@ -159,15 +512,45 @@ func (ar *ActionRepository) ApplyShardAction(ctx context.Context, actionName, ke
 		return result
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, actionTimeout)
-	wr := wrangler.New(logutil.NewConsoleLogger(), ar.ts, tmclient.NewTabletManagerClient(), ar.collationEnv, ar.parser)
-	output, err := action(ctx, wr, keyspace, shard)
+	// check the role
+	if action.role != "" {
+		if err := acl.CheckAccessHTTP(r, action.role); err != nil {
+			result.error("Access denied")
+			return result
+		}
+	}
+
+	method := action.method
+	if dryRun {
+		if action.dryRun == nil {
+			result.error("Dry run isn't available for this shard action")
+			return result
+		}
+		method = func(ctx context.Context, wr *wrangler.Wrangler, keyspace, shard string) (string, any, error) {
+			output, err := action.dryRun(ctx, wr, keyspace, shard)
+			return output, nil, err
+		}
+	}
+	if !dryRun {
+		done, ok := ar.startInFlight("shard", actionName, result.Parameters)
+		if !ok {
+			result.error("Action already in progress")
+			return result
+		}
+		defer done()
+	}
+
+	ctx, cancel := actionContext(ctx, action.timeout)
+	wr := ar.newWrangler()
+	defer wr.Close()
+	output, data, err := method(ctx, wr, keyspace, shard)
 	cancel()
 	if err != nil {
 		result.error(err.Error())
 		return result
 	}
 	result.Output = output
+	result.Data = data
 	return result
 }
 
@@ -177,6 +560,7 @@ func (ar *ActionRepository) ApplyTabletAction(ctx context.Context, actionName st
 		Name:       actionName,
 		Parameters: topoproto.TabletAliasString(tabletAlias),
 	}
+	defer ar.audit(r, result, time.Now())
 
 	action, ok := ar.tabletActions[actionName]
 	if !ok {
@@ -192,15 +576,24 @@ func (ar *ActionRepository) ApplyTabletAction(ctx context.Context, actionName st
 		}
 	}
 
+	done, ok := ar.startInFlight("tablet", actionName, result.Parameters)
+	if !ok {
+		result.error("Action already in progress")
+		return result
+	}
+	defer done()
+
 	// run the action
-	ctx, cancel := context.WithTimeout(ctx, actionTimeout)
-	wr := wrangler.New(logutil.NewConsoleLogger(), ar.ts, tmclient.NewTabletManagerClient(), ar.collationEnv, ar.parser)
-	output, err := action.method(ctx, wr, tabletAlias)
+	ctx, cancel := actionContext(ctx, action.timeout)
+	wr := ar.newWrangler()
+	defer wr.Close()
+	output, data, err := action.method(ctx, wr, tabletAlias)
 	cancel()
 	if err != nil {
 		result.error(err.Error())
 		return result
 	}
 	result.Output = output
+	result.Data = data
 	return result
 }
@@ -0,0 +1,273 @@
+/*
+Copyright 2024 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtctld
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/acl"
+	"vitess.io/vitess/go/mysql/collations"
+	"vitess.io/vitess/go/vt/logutil"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vttablet/faketmclient"
+	"vitess.io/vitess/go/vt/vttablet/tmclient"
+	"vitess.io/vitess/go/vt/wrangler"
+
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+)
+
+func TestActionRepositoryTabletManagerClient(t *testing.T) {
+	tmc := faketmclient.NewFakeTabletManagerClient()
+	ar := NewActionRepositoryWithTabletManagerClient(nil, tmc, collations.MySQL8(), sqlparser.NewTestParser())
+	require.Equal(t, tmc, ar.TabletManagerClient())
+	require.Equal(t, tmc, ar.newWrangler().TabletManagerClient())
+	// The actions also share the VtctldServer, which uses the same client.
+	require.Same(t, ar.vtctld, ar.newWrangler().VtctldServer())
+	require.Same(t, ar.newWrangler().VtctldServer(), ar.newWrangler().VtctldServer())
+}
+
+func TestListActions(t *testing.T) {
+	ar := NewActionRepository(nil, collations.MySQL8(), sqlparser.NewTestParser())
+	require.Empty(t, ar.ListKeyspaceActions())
+	require.Empty(t, ar.ListShardActions())
+	require.Empty(t, ar.ListTabletActions())
+
+	keyspaceAction := func(ctx context.Context, wr *wrangler.Wrangler, keyspace string) (string, error) {
+		return "", nil
+	}
+	shardAction := func(ctx context.Context, wr *wrangler.Wrangler, keyspace, shard string) (string, error) {
+		return "", nil
+	}
+	tabletAction := func(ctx context.Context, wr *wrangler.Wrangler, tabletAlias *topodatapb.TabletAlias) (string, error) {
+		return "", nil
+	}
+	ar.RegisterKeyspaceAction("ValidateSchemaKeyspace", "", keyspaceAction)
+	ar.RegisterKeyspaceAction("ValidateKeyspace", "", keyspaceAction)
+	ar.RegisterShardAction("ValidateShard", "", shardAction)
+	ar.RegisterShardAction("ValidatePermissionsShard", acl.ADMIN, shardAction)
+	ar.RegisterTabletAction("RefreshState", acl.ADMIN, tabletAction)
+	ar.RegisterTabletAction("Ping", "", tabletAction)
+
+	require.Equal(t, []string{"ValidateKeyspace", "ValidateSchemaKeyspace"}, ar.ListKeyspaceActions())
+	require.Equal(t, []string{"ValidatePermissionsShard", "ValidateShard"}, ar.ListShardActions())
+	require.Equal(t, []TabletActionInfo{
+		{Name: "Ping"},
+		{Name: "RefreshState", Role: acl.ADMIN},
+	}, ar.ListTabletActions())
+}
+
+func TestActionTimeout(t *testing.T) {
+	oldActionTimeout := actionTimeout
+	actionTimeout = 10 * time.Millisecond
+	defer func() { actionTimeout = oldActionTimeout }()
+
+	ar := NewActionRepository(nil, collations.MySQL8(), sqlparser.NewTestParser())
+	slowAction := func(ctx context.Context, wr *wrangler.Wrangler, keyspace string) (string, error) {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+			return "done", nil
+		}
+	}
+	ar.RegisterKeyspaceAction("Slow", "", slowAction)
+	ar.RegisterKeyspaceActionWithTimeout("SlowWithTimeout", "", time.Minute, slowAction)
+
+	// Without a timeout of its own, the action is cut off at actionTimeout.
+	result := ar.ApplyKeyspaceAction(context.Background(), "Slow", "ks", false, nil)
+	require.True(t, result.Error)
+	require.Equal(t, context.DeadlineExceeded.Error(), result.Output)
+
+	result = ar.ApplyKeyspaceAction(context.Background(), "SlowWithTimeout", "ks", false, nil)
+	require.False(t, result.Error, result.Output)
+	require.Equal(t, "done", result.Output)
+}
+
+func TestActionAuditHook(t *testing.T) {
+	ar := NewActionRepository(nil, collations.MySQL8(), sqlparser.NewTestParser())
+	ar.RegisterKeyspaceAction("Succeed", "", func(ctx context.Context, wr *wrangler.Wrangler, keyspace string) (string, error) {
+		return "ok", nil
+	})
+	ar.RegisterShardAction("Fail", "", func(ctx context.Context, wr *wrangler.Wrangler, keyspace, shard string) (string, error) {
+		return "", errors.New("failed")
+	})
+
+	// Without a hook, actions are applied as before.
+	result := ar.ApplyKeyspaceAction(context.Background(), "Succeed", "ks", false, nil)
+	require.False(t, result.Error)
+
+	var events []ActionAuditEvent
+	ar.SetAuditHook(func(event ActionAuditEvent) {
+		events = append(events, event)
+	})
+	r := httptest.NewRequest("POST", "/api/keyspaces/ks", nil)
+	r.SetBasicAuth("alice", "secret")
+	ar.ApplyKeyspaceAction(context.Background(), "Succeed", "ks", false, r)
+	ar.ApplyShardAction(context.Background(), "Fail", "ks", "-80", false, r)
+	ar.ApplyTabletAction(context.Background(), "Unknown", &topodatapb.TabletAlias{Cell: "zone1", Uid: 100}, nil)
+
+	require.Len(t, events, 3)
+	for i := range events {
+		events[i].Duration = 0
+	}
+	require.Equal(t, []ActionAuditEvent{
+		{Name: "Succeed", Parameters: "ks", Identity: "alice@" + r.RemoteAddr},
+		{Name: "Fail", Parameters: "ks/-80", Identity: "alice@" + r.RemoteAddr, Error: true},
+		{Name: "Unknown", Parameters: "zone1-0000000100", Error: true},
+	}, events)
+}
+
+func TestActionWrangler(t *testing.T) {
+	ar := NewActionRepository(nil, collations.MySQL8(), sqlparser.NewTestParser())
+	var tmcs []tmclient.TabletManagerClient
+	ar.RegisterKeyspaceAction("Record", "", func(ctx context.Context, wr *wrangler.Wrangler, keyspace string) (string, error) {
+		tmcs = append(tmcs, wr.TabletManagerClient())
+		return "", nil
+	})
+
+	// The actions share one tablet manager client.
+	ar.ApplyKeyspaceAction(context.Background(), "Record", "ks", false, nil)
+	ar.ApplyKeyspaceAction(context.Background(), "Record", "ks", false, nil)
+	require.Len(t, tmcs, 2)
+	require.Same(t, ar.tmc, tmcs[0])
+	require.Same(t, ar.tmc, tmcs[1])
+
+	// Or use the wranglers of the factory.
+	created := 0
+	ar.SetWranglerFactory(func() *wrangler.Wrangler {
+		created++
+		return wrangler.New(logutil.NewConsoleLogger(), nil, ar.tmc, ar.collationEnv, ar.parser)
+	})
+	ar.ApplyKeyspaceAction(context.Background(), "Record", "ks", false, nil)
+	require.Equal(t, 1, created)
+}
+
+func TestActionDryRun(t *testing.T) {
+	ar := NewActionRepository(nil, collations.MySQL8(), sqlparser.NewTestParser())
+	applied := false
+	ar.RegisterKeyspaceAction("Rebuild", "", func(ctx context.Context, wr *wrangler.Wrangler, keyspace string) (string, error) {
+		applied = true
+		return "rebuilt", nil
+	})
+	require.NoError(t, ar.RegisterKeyspaceActionDryRun("Rebuild", func(ctx context.Context, wr *wrangler.Wrangler, keyspace string) (string, error) {
+		return "would rebuild " + keyspace, nil
+	}))
+	ar.RegisterShardAction("Delete", "", func(ctx context.Context, wr *wrangler.Wrangler, keyspace, shard string) (string, error) {
+		applied = true
+		return "deleted", nil
+	})
+	require.EqualError(t, ar.RegisterShardActionDryRun("Unknown", nil), "unknown shard action Unknown")
+
+	result := ar.ApplyKeyspaceAction(context.Background(), "Rebuild", "ks", true, nil)
+	require.Equal(t, &ActionResult{Name: "Rebuild", Parameters: "ks", Output: "would rebuild ks", DryRun: true}, result)
+	require.False(t, applied)
+
+	// An action without a dry run isn't applied instead.
+	result = ar.ApplyShardAction(context.Background(), "Delete", "ks", "-80", true, nil)
+	require.True(t, result.Error)
+	require.Equal(t, "Dry run isn't available for this shard action", result.Output)
+	require.False(t, applied)
+
+	result = ar.ApplyKeyspaceAction(context.Background(), "Rebuild", "ks", false, nil)
+	require.Equal(t, "rebuilt", result.Output)
+	require.True(t, applied)
+}
+
+func TestCancelAction(t *testing.T) {
+	ar := NewActionRepository(nil, collations.MySQL8(), sqlparser.NewTestParser())
+	started := make(chan struct{})
+	ar.RegisterTabletAction("Wait", "", func(ctx context.Context, wr *wrangler.Wrangler, tabletAlias *topodatapb.TabletAlias) (string, error) {
+		close(started)
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	require.False(t, ar.CancelAction("a1"))
+
+	ctx, done, err := ar.StartCancelable(context.Background(), "a1")
+	require.NoError(t, err)
+	_, _, err = ar.StartCancelable(context.Background(), "a1")
+	require.EqualError(t, err, "an action with id a1 is already running")
+
+	results := make(chan *ActionResult)
+	go func() {
+		defer done()
+		results <- ar.ApplyTabletAction(ctx, "Wait", &topodatapb.TabletAlias{Cell: "zone1", Uid: 100}, nil)
+	}()
+	<-started
+	require.True(t, ar.CancelAction("a1"))
+	result := <-results
+	require.True(t, result.Error)
+	require.Equal(t, context.Canceled.Error(), result.Output)
+
+	// Once done, the id can be used again.
+	require.Eventually(t, func() bool {
+		_, done, err := ar.StartCancelable(context.Background(), "a1")
+		if err != nil {
+			return false
+		}
+		done()
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestActionInFlight(t *testing.T) {
+	ar := NewActionRepository(nil, collations.MySQL8(), sqlparser.NewTestParser())
+	started := make(chan struct{})
+	release := make(chan struct{})
+	ar.RegisterShardAction("Reparent", "", func(ctx context.Context, wr *wrangler.Wrangler, keyspace, shard string) (string, error) {
+		started <- struct{}{}
+		<-release
+		return "reparented", nil
+	})
+
+	results := make(chan *ActionResult)
+	go func() {
+		results <- ar.ApplyShardAction(context.Background(), "Reparent", "ks", "-80", false, nil)
+	}()
+	<-started
+
+	// The same action on the same shard is refused while the first runs,
+	// while another shard isn't affected.
+	result := ar.ApplyShardAction(context.Background(), "Reparent", "ks", "-80", false, nil)
+	require.True(t, result.Error)
+	require.Equal(t, "Action already in progress", result.Output)
+
+	go func() {
+		results <- ar.ApplyShardAction(context.Background(), "Reparent", "ks", "80-", false, nil)
+	}()
+	<-started
+	release <- struct{}{}
+	release <- struct{}{}
+	for i := 0; i < 2; i++ {
+		result := <-results
+		require.False(t, result.Error, result.Output)
+	}
+
+	// Once done, the action can run again.
+	go func() {
+		<-started
+		release <- struct{}{}
+	}()
+	result = ar.ApplyShardAction(context.Background(), "Reparent", "ks", "-80", false, nil)
+	require.False(t, result.Error, result.Output)
+}
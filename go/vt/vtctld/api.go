@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -40,7 +41,6 @@ import (
 	"vitess.io/vitess/go/vt/topo"
 	"vitess.io/vitess/go/vt/topo/topoproto"
 	"vitess.io/vitess/go/vt/vtctl"
-	"vitess.io/vitess/go/vt/vttablet/tmclient"
 	"vitess.io/vitess/go/vt/wrangler"
 
 	logutilpb "vitess.io/vitess/go/vt/proto/logutil"
@@ -178,9 +178,23 @@ func unmarshalRequest(r *http.Request, v any) error {
 	return json.Unmarshal(data, v)
 }
 
+// parseDryRun returns the dry_run parameter of an action request, false if
+// it isn't set.
+func parseDryRun(r *http.Request) (bool, error) {
+	v := r.FormValue("dry_run")
+	if v == "" {
+		return false, nil
+	}
+	dryRun, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid dry_run %q: %v", v, err)
+	}
+	return dryRun, nil
+}
+
 func initAPI(ctx context.Context, ts *topo.Server, actions *ActionRepository) {
 	tabletHealthCache := newTabletHealthCache(ts)
-	tmClient := tmclient.NewTabletManagerClient()
+	tmClient := actions.tmc
 
 	// Cells
 	handleCollection("cells", func(r *http.Request) (any, error) {
@@ -219,7 +233,16 @@ func initAPI(ctx context.Context, ts *topo.Server, actions *ActionRepository) {
 			if action == "" {
 				return nil, errors.New("a POST request must specify action")
 			}
-			return actions.ApplyKeyspaceAction(ctx, action, keyspace), nil
+			dryRun, err := parseDryRun(r)
+			if err != nil {
+				return nil, err
+			}
+			actionCtx, done, err := actions.StartCancelable(ctx, r.FormValue("action_id"))
+			if err != nil {
+				return nil, err
+			}
+			defer done()
+			return actions.ApplyKeyspaceAction(actionCtx, action, keyspace, dryRun, r), nil
 		default:
 			return nil, fmt.Errorf("unsupported HTTP method: %v", r.Method)
 		}
@@ -309,7 +332,16 @@ func initAPI(ctx context.Context, ts *topo.Server, actions *ActionRepository) {
 			if action == "" {
 				return nil, errors.New("must specify action")
 			}
-			return actions.ApplyShardAction(ctx, action, keyspace, shard), nil
+			dryRun, err := parseDryRun(r)
+			if err != nil {
+				return nil, err
+			}
+			actionCtx, done, err := actions.StartCancelable(ctx, r.FormValue("action_id"))
+			if err != nil {
+				return nil, err
+			}
+			defer done()
+			return actions.ApplyShardAction(actionCtx, action, keyspace, shard, dryRun, r), nil
 		}
 
 		// Get the shard record.
@@ -442,7 +474,12 @@ func initAPI(ctx context.Context, ts *topo.Server, actions *ActionRepository) {
 			if action == "" {
 				return nil, errors.New("must specify action")
 			}
-			return actions.ApplyTabletAction(ctx, action, tabletAlias, r), nil
+			actionCtx, done, err := actions.StartCancelable(ctx, r.FormValue("action_id"))
+			if err != nil {
+				return nil, err
+			}
+			defer done()
+			return actions.ApplyTabletAction(actionCtx, action, tabletAlias, r), nil
 		}
 
 		// Get the tablet record.
@@ -454,6 +491,25 @@ func initAPI(ctx context.Context, ts *topo.Server, actions *ActionRepository) {
 		return newTabletWithURL(t.Tablet), nil
 	})
 
+	// Cancel a running action that was given an action_id.
+	handleCollection("actions", func(r *http.Request) (any, error) {
+		id := getItemPath(r.URL.Path)
+		if id == "" || r.Method != "POST" {
+			return nil, errors.New("a POST request needs an action id in the URL")
+		}
+		result := &ActionResult{Name: "Cancel", Parameters: id}
+		if err := acl.CheckAccessHTTP(r, acl.ADMIN); err != nil {
+			result.error("Access denied")
+			return result, nil
+		}
+		if !actions.CancelAction(id) {
+			result.error("No running action with this id")
+			return result, nil
+		}
+		result.Output = "Canceled"
+		return result, nil
+	})
+
 	// Healthcheck real time status per (cell, keyspace, tablet type, metric).
 	handleAPI("tablet_statuses/", func(w http.ResponseWriter, r *http.Request) error {
 		http.NotFound(w, r)
@@ -488,6 +544,7 @@ func initAPI(ctx context.Context, ts *topo.Server, actions *ActionRepository) {
 		logstream := logutil.NewMemoryLogger()
 
 		wr := wrangler.New(logstream, ts, tmClient, actions.collationEnv, actions.parser)
+		defer wr.Close()
 		err := vtctl.RunCommand(r.Context(), wr, args)
 		if err != nil {
 			resp.Error = err.Error()
@@ -524,6 +581,7 @@ func initAPI(ctx context.Context, ts *topo.Server, actions *ActionRepository) {
 			w.Write([]byte(logutil.EventString(ev)))
 		})
 		wr := wrangler.New(logger, ts, tmClient, actions.collationEnv, actions.parser)
+		defer wr.Close()
 
 		apiCallUUID, err := schema.CreateUUID()
 		if err != nil {
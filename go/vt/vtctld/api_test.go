@@ -113,14 +113,23 @@ func TestAPI(t *testing.T) {
 	ts.CreateTablet(ctx, &tablet2)
 
 	// Populate fake actions.
-	actionRepo.RegisterKeyspaceAction("TestKeyspaceAction",
+	actionRepo.RegisterKeyspaceAction("TestKeyspaceAction", "",
 		func(ctx context.Context, wr *wrangler.Wrangler, keyspace string) (string, error) {
 			return "TestKeyspaceAction Result", nil
 		})
-	actionRepo.RegisterShardAction("TestShardAction",
+	err := actionRepo.RegisterKeyspaceActionDryRun("TestKeyspaceAction",
+		func(ctx context.Context, wr *wrangler.Wrangler, keyspace string) (string, error) {
+			return "TestKeyspaceAction Dry Run", nil
+		})
+	require.NoError(t, err)
+	actionRepo.RegisterShardAction("TestShardAction", "",
 		func(ctx context.Context, wr *wrangler.Wrangler, keyspace, shard string) (string, error) {
 			return "TestShardAction Result", nil
 		})
+	actionRepo.RegisterShardDataAction("TestShardDataAction", "", 0,
+		func(ctx context.Context, wr *wrangler.Wrangler, keyspace, shard string) (string, any, error) {
+			return "TestShardDataAction Result", map[string]any{"keyspace": keyspace, "shards": []string{shard}}, nil
+		})
 	actionRepo.RegisterTabletAction("TestTabletAction", "",
 		func(ctx context.Context, wr *wrangler.Wrangler, tabletAlias *topodatapb.TabletAlias) (string, error) {
 			return "TestTabletAction Result", nil
@@ -255,6 +264,13 @@ func TestAPI(t *testing.T) {
 				"Output": "TestKeyspaceAction Result",
 				"Error": false
 			}`, http.StatusOK},
+		{"POST", "keyspaces/ks1?action=TestKeyspaceAction&dry_run=true", "", `{
+				"Name": "TestKeyspaceAction",
+				"Parameters": "ks1",
+				"Output": "TestKeyspaceAction Dry Run",
+				"Error": false,
+				"DryRun": true
+			}`, http.StatusOK},
 
 		// Shards
 		{"GET", "shards/ks1/", "", `["-80","80-"]`, http.StatusOK},
@@ -276,6 +292,20 @@ func TestAPI(t *testing.T) {
 				"Output": "TestShardAction Result",
 				"Error": false
 			}`, http.StatusOK},
+		{"POST", "shards/ks1/-80?action=TestShardAction&dry_run=1", "", `{
+				"Name": "TestShardAction",
+				"Parameters": "ks1/-80",
+				"Output": "Dry run isn't available for this shard action",
+				"Error": true,
+				"DryRun": true
+			}`, http.StatusOK},
+		{"POST", "shards/ks1/-80?action=TestShardDataAction", "", `{
+				"Name": "TestShardDataAction",
+				"Parameters": "ks1/-80",
+				"Output": "TestShardDataAction Result",
+				"Error": false,
+				"Data": {"keyspace": "ks1", "shards": ["-80"]}
+			}`, http.StatusOK},
 
 		// Tablets
 		{"GET", "tablets/?shard=ks1%2F-80", "", `[
@@ -310,6 +340,12 @@ func TestAPI(t *testing.T) {
 				"Output": "TestTabletAction Result",
 				"Error": false
 			}`, http.StatusOK},
+		{"POST", "actions/a1", "", `{
+				"Name": "Cancel",
+				"Parameters": "a1",
+				"Output": "No running action with this id",
+				"Error": true
+			}`, http.StatusOK},
 
 		// Tablet Updates
 		{"GET", "tablet_statuses/?keyspace=all&cell=all&type=all&metric=lag", "", "404 page not found", http.StatusNotFound},
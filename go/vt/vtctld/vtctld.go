@@ -20,6 +20,7 @@ package vtctld
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/spf13/pflag"
 
@@ -34,7 +35,6 @@ import (
 	"vitess.io/vitess/go/vt/wrangler"
 
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
-	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
 )
 
 var (
@@ -56,43 +56,43 @@ func InitVtctld(ts *topo.Server, collationEnv *collations.Environment, parser *s
 	actionRepo := NewActionRepository(ts, collationEnv, parser)
 
 	// keyspace actions
-	actionRepo.RegisterKeyspaceAction("ValidateKeyspace",
+	actionRepo.RegisterKeyspaceAction("ValidateKeyspace", "",
 		func(ctx context.Context, wr *wrangler.Wrangler, keyspace string) (string, error) {
 			return "", wr.ValidateKeyspace(ctx, keyspace, false)
 		})
 
-	actionRepo.RegisterKeyspaceAction("ValidateSchemaKeyspace",
+	actionRepo.RegisterKeyspaceAction("ValidateSchemaKeyspace", "",
 		func(ctx context.Context, wr *wrangler.Wrangler, keyspace string) (string, error) {
 			return "", wr.ValidateSchemaKeyspace(ctx, keyspace, nil /*excludeTables*/, false /*includeViews*/, false /*skipNoPrimary*/, false /*includeVSchema*/)
 		})
 
-	actionRepo.RegisterKeyspaceAction("ValidateVersionKeyspace",
+	actionRepo.RegisterKeyspaceAction("ValidateVersionKeyspace", "",
 		func(ctx context.Context, wr *wrangler.Wrangler, keyspace string) (string, error) {
 			return "", wr.ValidateVersionKeyspace(ctx, keyspace)
 		})
 
-	actionRepo.RegisterKeyspaceAction("ValidatePermissionsKeyspace",
+	actionRepo.RegisterKeyspaceAction("ValidatePermissionsKeyspace", "",
 		func(ctx context.Context, wr *wrangler.Wrangler, keyspace string) (string, error) {
 			return "", wr.ValidatePermissionsKeyspace(ctx, keyspace)
 		})
 
 	// shard actions
-	actionRepo.RegisterShardAction("ValidateShard",
+	actionRepo.RegisterShardAction("ValidateShard", "",
 		func(ctx context.Context, wr *wrangler.Wrangler, keyspace, shard string) (string, error) {
 			return "", wr.ValidateShard(ctx, keyspace, shard, false)
 		})
 
-	actionRepo.RegisterShardAction("ValidateSchemaShard",
+	actionRepo.RegisterShardAction("ValidateSchemaShard", "",
 		func(ctx context.Context, wr *wrangler.Wrangler, keyspace, shard string) (string, error) {
 			return "", wr.ValidateSchemaShard(ctx, keyspace, shard, nil, false, false /*includeVSchema*/)
 		})
 
-	actionRepo.RegisterShardAction("ValidateVersionShard",
+	actionRepo.RegisterShardAction("ValidateVersionShard", "",
 		func(ctx context.Context, wr *wrangler.Wrangler, keyspace, shard string) (string, error) {
 			return "", wr.ValidateVersionShard(ctx, keyspace, shard)
 		})
 
-	actionRepo.RegisterShardAction("ValidatePermissionsShard",
+	actionRepo.RegisterShardAction("ValidatePermissionsShard", "",
 		func(ctx context.Context, wr *wrangler.Wrangler, keyspace, shard string) (string, error) {
 			return "", wr.ValidatePermissionsShard(ctx, keyspace, shard)
 		})
@@ -123,10 +123,20 @@ func InitVtctld(ts *topo.Server, collationEnv *collations.Environment, parser *s
 
 	actionRepo.RegisterTabletAction("ReloadSchema", acl.ADMIN,
 		func(ctx context.Context, wr *wrangler.Wrangler, tabletAlias *topodatapb.TabletAlias) (string, error) {
-			_, err := wr.VtctldServer().ReloadSchema(ctx, &vtctldatapb.ReloadSchemaRequest{
-				TabletAlias: tabletAlias,
-			})
-			return "", err
+			tables, err := wr.ReloadTabletSchema(ctx, tabletAlias)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Reloaded schema with %d tables", tables), nil
+		})
+
+	actionRepo.RegisterTabletAction("ReloadVReplicationCredentials", acl.ADMIN,
+		func(ctx context.Context, wr *wrangler.Wrangler, tabletAlias *topodatapb.TabletAlias) (string, error) {
+			restarted, err := wr.RestartVReplicationStreams(ctx, tabletAlias)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Reloaded credentials and restarted %d failed VReplication streams", restarted), nil
 		})
 
 	// Serve the REST API
@@ -91,6 +91,27 @@ func TestUnwrap(t *testing.T) {
 	}
 }
 
+func TestWrapErrorsAs(t *testing.T) {
+	cause := &nilError{}
+	err := Wrapf(Wrap(cause, "inner"), "outer %d", 1)
+	var target *nilError
+	assert.True(t, errors.As(err, &target))
+	assert.Same(t, cause, target)
+	assert.True(t, errors.Is(Wrap(context.Canceled, "some message"), context.Canceled))
+
+	// The helpers of this package see through Wrap as they did before
+	// errors.Is and errors.As could.
+	canceled := Wrapf(Wrap(context.Canceled, "inner"), "outer %d", 1)
+	assert.Equal(t, vtrpcpb.Code_CANCELED, Code(canceled))
+	assert.Equal(t, context.Canceled, UnwrapAll(canceled))
+	assert.Equal(t, context.Canceled, RootCause(canceled))
+	wasWrapped, unwrapped := Unwrap(canceled)
+	assert.True(t, wasWrapped)
+	assert.Equal(t, "inner: context canceled", unwrapped.Error())
+	// The code of a wrapped error is still that of its cause.
+	assert.Equal(t, vtrpcpb.Code_NOT_FOUND, Code(Wrap(Errorf(vtrpcpb.Code_NOT_FOUND, "not found"), "some message")))
+}
+
 func TestUnwrapAll(t *testing.T) {
 	tests := []struct {
 		err error
@@ -290,6 +290,10 @@ type wrapping struct {
 func (w *wrapping) Error() string { return w.msg + ": " + w.cause.Error() }
 func (w *wrapping) Cause() error  { return w.cause }
 
+// Unwrap returns the cause, so that errors.Is and errors.As see through
+// Wrap and Wrapf.
+func (w *wrapping) Unwrap() error { return w.cause }
+
 func (w *wrapping) Format(s fmt.State, verb rune) {
 	if rune('v') == verb {
 		panicIfError(fmt.Fprintf(s, "%v\n", w.Cause()))
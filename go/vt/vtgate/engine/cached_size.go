@@ -752,7 +752,7 @@ func (cached *Plan) CachedSize(alloc bool) int64 {
 	}
 	size := int64(0)
 	if alloc {
-		size += int64(144)
+		size += int64(152)
 	}
 	// field Original string
 	size += hack.RuntimeAllocSize(int64(len(cached.Original)))
@@ -18,10 +18,14 @@ package engine
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"vitess.io/vitess/go/stats"
 	"vitess.io/vitess/go/vt/proto/query"
 	"vitess.io/vitess/go/vt/sqlparser"
 )
@@ -38,36 +42,213 @@ type Plan struct {
 	BindVarNeeds *sqlparser.BindVarNeeds // Stores BindVars needed to be provided as part of expression rewriting
 	Warnings     []*query.QueryWarning   // Warnings that need to be yielded every time this query runs
 	TablesUsed   []string                // TablesUsed is the list of tables that this plan will query
+	Internal     bool                    // Internal is set if the query uses sidecar, system schema, online DDL or table GC tables
 
-	ExecCount    uint64 // Count of times this plan was executed
-	ExecTime     uint64 // Total execution time
-	ShardQueries uint64 // Total number of shard queries
-	RowsReturned uint64 // Total number of rows
-	RowsAffected uint64 // Total number of rows
-	Errors       uint64 // Total number of errors
+	ExecCount     uint64 // Count of times this plan was executed
+	ExecTime      uint64 // Total execution time
+	ShardQueries  uint64 // Total number of shard queries
+	RowsReturned  uint64 // Total number of rows
+	RowsAffected  uint64 // Total number of rows
+	BytesReturned uint64 // Total size of the values of the rows returned
+	Errors        uint64 // Total number of errors
+
+	latencies    atomic.Pointer[stats.Histogram] // Execution times, created on first use
+	firstSeen    atomic.Int64                    // Unix time in nanoseconds of the first execution
+	lastExecuted atomic.Int64                    // Unix time in nanoseconds of the last execution
+
+	// minShardQueries and maxShardQueries are the fewest and most shard
+	// queries of a single execution. minShardQueries is stored plus one, so
+	// that zero means that no execution was recorded.
+	minShardQueries atomic.Uint64
+	maxShardQueries atomic.Uint64
+
+	errorCodesMu sync.Mutex
+	errorCodes   map[string]uint64 // Number of errors by error code
+}
+
+// planLatencyCutoffs are the upper bounds, in nanoseconds, of the buckets of
+// the execution time histogram of a plan.
+var planLatencyCutoffs = []int64{
+	1e5, 2.5e5, 5e5, 1e6, 2.5e6, 5e6, 1e7, 2.5e7, 5e7, 1e8, 2.5e8, 5e8, 1e9, 2.5e9, 5e9, 1e10,
+}
+
+func (p *Plan) latencyHistogram() *stats.Histogram {
+	if h := p.latencies.Load(); h != nil {
+		return h
+	}
+	p.latencies.CompareAndSwap(nil, stats.NewHistogram("", "", planLatencyCutoffs))
+	return p.latencies.Load()
 }
 
 // AddStats updates the plan execution statistics
-func (p *Plan) AddStats(execCount uint64, execTime time.Duration, shardQueries, rowsAffected, rowsReturned, errors uint64) {
+func (p *Plan) AddStats(execCount uint64, execTime time.Duration, shardQueries, rowsAffected, rowsReturned, bytesReturned, errors uint64) {
 	atomic.AddUint64(&p.ExecCount, execCount)
 	atomic.AddUint64(&p.ExecTime, uint64(execTime))
 	atomic.AddUint64(&p.ShardQueries, shardQueries)
 	atomic.AddUint64(&p.RowsAffected, rowsAffected)
 	atomic.AddUint64(&p.RowsReturned, rowsReturned)
+	atomic.AddUint64(&p.BytesReturned, bytesReturned)
 	atomic.AddUint64(&p.Errors, errors)
+	if execCount > 0 {
+		now := time.Now().UnixNano()
+		p.firstSeen.CompareAndSwap(0, now)
+		p.lastExecuted.Store(now)
+		h := p.latencyHistogram()
+		for i := uint64(0); i < execCount; i++ {
+			h.Add(int64(execTime) / int64(execCount))
+		}
+		p.addShardQueriesRange(shardQueries / execCount)
+	}
+}
+
+// addShardQueriesRange widens the range of shard queries of a single
+// execution to include n.
+func (p *Plan) addShardQueriesRange(n uint64) {
+	for {
+		cur := p.minShardQueries.Load()
+		if (cur != 0 && cur-1 <= n) || p.minShardQueries.CompareAndSwap(cur, n+1) {
+			break
+		}
+	}
+	for {
+		cur := p.maxShardQueries.Load()
+		if cur >= n || p.maxShardQueries.CompareAndSwap(cur, n) {
+			break
+		}
+	}
+}
+
+// ShardQueriesRange returns the fewest and most shard queries of a single
+// execution of the plan, or zeros if it wasn't executed since its stats
+// were reset.
+func (p *Plan) ShardQueriesRange() (minQueries, maxQueries uint64) {
+	if minQueries = p.minShardQueries.Load(); minQueries > 0 {
+		minQueries--
+	}
+	return minQueries, p.maxShardQueries.Load()
 }
 
 // Stats returns a copy of the plan execution statistics
-func (p *Plan) Stats() (execCount uint64, execTime time.Duration, shardQueries, rowsAffected, rowsReturned, errors uint64) {
+func (p *Plan) Stats() (execCount uint64, execTime time.Duration, shardQueries, rowsAffected, rowsReturned, bytesReturned, errors uint64) {
 	execCount = atomic.LoadUint64(&p.ExecCount)
 	execTime = time.Duration(atomic.LoadUint64(&p.ExecTime))
 	shardQueries = atomic.LoadUint64(&p.ShardQueries)
 	rowsAffected = atomic.LoadUint64(&p.RowsAffected)
 	rowsReturned = atomic.LoadUint64(&p.RowsReturned)
+	bytesReturned = atomic.LoadUint64(&p.BytesReturned)
 	errors = atomic.LoadUint64(&p.Errors)
 	return
 }
 
+// ResetStats zeroes the plan execution statistics.
+func (p *Plan) ResetStats() {
+	atomic.StoreUint64(&p.ExecCount, 0)
+	atomic.StoreUint64(&p.ExecTime, 0)
+	atomic.StoreUint64(&p.ShardQueries, 0)
+	atomic.StoreUint64(&p.RowsAffected, 0)
+	atomic.StoreUint64(&p.RowsReturned, 0)
+	atomic.StoreUint64(&p.BytesReturned, 0)
+	atomic.StoreUint64(&p.Errors, 0)
+	p.latencies.Store(nil)
+	p.lastExecuted.Store(0)
+	p.minShardQueries.Store(0)
+	p.maxShardQueries.Store(0)
+	p.errorCodesMu.Lock()
+	p.errorCodes = nil
+	p.errorCodesMu.Unlock()
+}
+
+// AddErrorCode counts an error of the plan with the given code.
+func (p *Plan) AddErrorCode(code string) {
+	p.errorCodesMu.Lock()
+	defer p.errorCodesMu.Unlock()
+	if p.errorCodes == nil {
+		p.errorCodes = make(map[string]uint64)
+	}
+	p.errorCodes[code]++
+}
+
+// ErrorCodes returns a copy of the number of errors of the plan by error
+// code.
+func (p *Plan) ErrorCodes() map[string]uint64 {
+	p.errorCodesMu.Lock()
+	defer p.errorCodesMu.Unlock()
+	codes := make(map[string]uint64, len(p.errorCodes))
+	for code, count := range p.errorCodes {
+		codes[code] = count
+	}
+	return codes
+}
+
+// FirstSeen returns when the plan was first executed, or the zero time if
+// it never was. Unlike the other stats, it is kept by ResetStats.
+func (p *Plan) FirstSeen() time.Time {
+	return unixNanoTime(p.firstSeen.Load())
+}
+
+// LastExecuted returns when the plan was last executed, or the zero time if
+// it wasn't executed since it was built or its stats were reset.
+func (p *Plan) LastExecuted() time.Time {
+	return unixNanoTime(p.lastExecuted.Load())
+}
+
+func unixNanoTime(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// Percentiles returns the 50th, 95th and 99th percentile of the execution
+// times of the plan. Each is the upper bound of the histogram bucket it
+// falls in, or the highest bound for executions slower than all of them.
+func (p *Plan) Percentiles() (p50, p95, p99 time.Duration) {
+	h := p.latencies.Load()
+	if h == nil {
+		return 0, 0, 0
+	}
+	buckets := h.Buckets()
+	var count int64
+	for _, n := range buckets {
+		count += n
+	}
+	return bucketPercentile(buckets, count, 50), bucketPercentile(buckets, count, 95), bucketPercentile(buckets, count, 99)
+}
+
+func bucketPercentile(buckets []int64, count int64, percentile int64) time.Duration {
+	if count == 0 {
+		return 0
+	}
+	// The rank of the percentile, rounded up, among count executions.
+	rank := (count*percentile + 99) / 100
+	var seen int64
+	for i, n := range buckets {
+		seen += n
+		if seen >= rank && i < len(planLatencyCutoffs) {
+			return time.Duration(planLatencyCutoffs[i])
+		}
+	}
+	return time.Duration(planLatencyCutoffs[len(planLatencyCutoffs)-1])
+}
+
+// Fingerprint returns a stable hash of the query type, the original
+// (normalized) query and the structure of the instructions. Equivalent
+// plans have the same fingerprint on every vtgate, so it can be used to
+// aggregate plan stats across a fleet.
+func (p *Plan) Fingerprint() string {
+	h := sha256.New()
+	h.Write([]byte(p.Type.String()))
+	h.Write([]byte{0})
+	h.Write([]byte(p.Original))
+	h.Write([]byte{0})
+	if p.Instructions != nil {
+		// The description is deterministic: the encoding of maps is
+		// sorted by key and the inputs are kept in order.
+		_ = json.NewEncoder(h).Encode(PrimitiveToPlanDescription(p.Instructions))
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
 // MarshalJSON serializes the plan into a JSON representation.
 func (p *Plan) MarshalJSON() ([]byte, error) {
 	var instructions *PrimitiveDescription
@@ -77,27 +258,29 @@ func (p *Plan) MarshalJSON() ([]byte, error) {
 	}
 
 	marshalPlan := struct {
-		QueryType    string
-		Original     string                `json:",omitempty"`
-		Instructions *PrimitiveDescription `json:",omitempty"`
-		ExecCount    uint64                `json:",omitempty"`
-		ExecTime     time.Duration         `json:",omitempty"`
-		ShardQueries uint64                `json:",omitempty"`
-		RowsAffected uint64                `json:",omitempty"`
-		RowsReturned uint64                `json:",omitempty"`
-		Errors       uint64                `json:",omitempty"`
-		TablesUsed   []string              `json:",omitempty"`
+		QueryType     string
+		Original      string                `json:",omitempty"`
+		Instructions  *PrimitiveDescription `json:",omitempty"`
+		ExecCount     uint64                `json:",omitempty"`
+		ExecTime      time.Duration         `json:",omitempty"`
+		ShardQueries  uint64                `json:",omitempty"`
+		RowsAffected  uint64                `json:",omitempty"`
+		RowsReturned  uint64                `json:",omitempty"`
+		BytesReturned uint64                `json:",omitempty"`
+		Errors        uint64                `json:",omitempty"`
+		TablesUsed    []string              `json:",omitempty"`
 	}{
-		QueryType:    p.Type.String(),
-		Original:     p.Original,
-		Instructions: instructions,
-		ExecCount:    atomic.LoadUint64(&p.ExecCount),
-		ExecTime:     time.Duration(atomic.LoadUint64(&p.ExecTime)),
-		ShardQueries: atomic.LoadUint64(&p.ShardQueries),
-		RowsAffected: atomic.LoadUint64(&p.RowsAffected),
-		RowsReturned: atomic.LoadUint64(&p.RowsReturned),
-		Errors:       atomic.LoadUint64(&p.Errors),
-		TablesUsed:   p.TablesUsed,
+		QueryType:     p.Type.String(),
+		Original:      p.Original,
+		Instructions:  instructions,
+		ExecCount:     atomic.LoadUint64(&p.ExecCount),
+		ExecTime:      time.Duration(atomic.LoadUint64(&p.ExecTime)),
+		ShardQueries:  atomic.LoadUint64(&p.ShardQueries),
+		RowsAffected:  atomic.LoadUint64(&p.RowsAffected),
+		RowsReturned:  atomic.LoadUint64(&p.RowsReturned),
+		BytesReturned: atomic.LoadUint64(&p.BytesReturned),
+		Errors:        atomic.LoadUint64(&p.Errors),
+		TablesUsed:    p.TablesUsed,
 	}
 
 	b := new(bytes.Buffer)
@@ -19,6 +19,8 @@ package engine
 import (
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
 	"vitess.io/vitess/go/vt/sqlparser"
 	"vitess.io/vitess/go/vt/vtgate/evalengine"
 
@@ -105,3 +107,30 @@ func getDescriptionFor(route *Route) PrimitiveDescription {
 		Inputs: []PrimitiveDescription{},
 	}
 }
+
+func TestPlanFingerprint(t *testing.T) {
+	newPlan := func() *Plan {
+		return &Plan{
+			Type:         sqlparser.StmtSelect,
+			Original:     "select * from t",
+			Instructions: createRoute(),
+			ExecCount:    3,
+		}
+	}
+	plan := newPlan()
+	fingerprint := plan.Fingerprint()
+	require.Len(t, fingerprint, 16)
+
+	// Equivalent plans built independently, e.g. on another vtgate, have
+	// the same fingerprint regardless of their stats.
+	other := newPlan()
+	other.ExecCount = 10
+	require.Equal(t, fingerprint, other.Fingerprint())
+
+	other.Original = "select * from t2"
+	require.NotEqual(t, fingerprint, other.Fingerprint())
+
+	other = newPlan()
+	other.Instructions.(*Route).Opcode = Unsharded
+	require.NotEqual(t, fingerprint, other.Fingerprint())
+}
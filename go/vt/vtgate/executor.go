@@ -125,6 +125,9 @@ type Executor struct {
 
 	collEnv *collations.Environment
 	parser  *sqlparser.Parser
+
+	// liveQueries tracks the plans being executed, for /debug/queryz.
+	liveQueries *liveQueries
 }
 
 var executorOnce sync.Once
@@ -175,6 +178,7 @@ func NewExecutor(
 		warmingReadsChannel: make(chan bool, warmingReadsConcurrency),
 		collEnv:             collationEnv,
 		parser:              parser,
+		liveQueries:         newLiveQueries(),
 	}
 
 	vschemaacl.Init()
@@ -207,6 +211,7 @@ func NewExecutor(
 		stats.NewCounterFunc("QueryPlanCacheMisses", "Query plan cache misses", func() int64 {
 			return e.plans.Metrics.Hits()
 		})
+		e.registerQueryzMetrics()
 		servenv.HTTPHandle(pathQueryPlans, e)
 		servenv.HTTPHandle(pathScatterStats, e)
 		servenv.HTTPHandle(pathVSchema, e)
@@ -1121,6 +1126,7 @@ func (e *Executor) buildStatement(
 
 	plan.Warnings = vcursor.warnings
 	vcursor.warnings = nil
+	plan.Internal = queryzIsInternal(stmt)
 
 	err = e.checkThatPlanIsValid(stmt, plan)
 	return plan, err
@@ -1379,7 +1385,7 @@ func (e *Executor) handlePrepare(ctx context.Context, safeSession *SafeSession,
 	}
 	logStats.RowsAffected = qr.RowsAffected
 
-	plan.AddStats(1, time.Since(logStats.StartTime), logStats.ShardQueries, qr.RowsAffected, uint64(len(qr.Rows)), errCount)
+	plan.AddStats(1, time.Since(logStats.StartTime), logStats.ShardQueries, qr.RowsAffected, uint64(len(qr.Rows)), resultBytes(qr), errCount)
 
 	return qr.Fields, err
 }
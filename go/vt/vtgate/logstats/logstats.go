@@ -49,6 +49,7 @@ type LogStats struct {
 	ShardQueries   uint64
 	RowsAffected   uint64
 	RowsReturned   uint64
+	BytesReturned  uint64
 	PlanTime       time.Duration
 	ExecuteTime    time.Duration
 	CommitTime     time.Duration
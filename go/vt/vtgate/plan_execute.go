@@ -22,6 +22,7 @@ import (
 	"strings"
 	"time"
 
+	"vitess.io/vitess/go/mysql/sqlerror"
 	"vitess.io/vitess/go/sqltypes"
 	"vitess.io/vitess/go/vt/log"
 	querypb "vitess.io/vitess/go/vt/proto/query"
@@ -144,15 +145,19 @@ func (e *Executor) newExecute(
 			return err
 		}
 
-		// 5: Execute the plan and retry if needed
-		if plan.Instructions.NeedsTransaction() {
-			err = e.insideTransaction(ctx, safeSession, logStats,
-				func() error {
-					return execPlan(ctx, plan, vcursor, bindVars, execStart)
-				})
-		} else {
-			err = execPlan(ctx, plan, vcursor, bindVars, execStart)
-		}
+		// 5: Execute the plan and retry if needed. The execution, streaming
+		// or not, shows on /debug/queryz?live=1 until it's finished.
+		err = func() error {
+			finished := e.liveQueries.add(plan)
+			defer finished()
+			if plan.Instructions.NeedsTransaction() {
+				return e.insideTransaction(ctx, safeSession, logStats,
+					func() error {
+						return execPlan(ctx, plan, vcursor, bindVars, execStart)
+					})
+			}
+			return execPlan(ctx, plan, vcursor, bindVars, execStart)
+		}()
 
 		if err == nil || safeSession.InTransaction() {
 			return err
@@ -343,7 +348,19 @@ func (e *Executor) setLogStats(logStats *logstats.LogStats, plan *engine.Plan, v
 	logStats.TablesUsed = plan.TablesUsed
 	logStats.TabletType = vcursor.TabletType().String()
 	errCount := e.logExecutionEnd(logStats, execStart, plan, err, qr)
-	plan.AddStats(1, time.Since(logStats.StartTime), logStats.ShardQueries, logStats.RowsAffected, logStats.RowsReturned, errCount)
+	plan.AddStats(1, time.Since(logStats.StartTime), logStats.ShardQueries, logStats.RowsAffected, logStats.RowsReturned, logStats.BytesReturned, errCount)
+	if err != nil {
+		plan.AddErrorCode(planErrorCode(err))
+	}
+}
+
+// planErrorCode returns the MySQL error number the client gets for err, by
+// which the errors of a plan are counted.
+func planErrorCode(err error) string {
+	if sqlErr, ok := sqlerror.NewSQLErrorFromError(err).(*sqlerror.SQLError); ok {
+		return sqlErr.Number().ToString()
+	}
+	return sqlerror.ERUnknownError.ToString()
 }
 
 func (e *Executor) logExecutionEnd(logStats *logstats.LogStats, execStart time.Time, plan *engine.Plan, err error, qr *sqltypes.Result) uint64 {
@@ -358,10 +375,22 @@ func (e *Executor) logExecutionEnd(logStats *logstats.LogStats, execStart time.T
 	} else {
 		logStats.RowsAffected = qr.RowsAffected
 		logStats.RowsReturned = uint64(len(qr.Rows))
+		logStats.BytesReturned = resultBytes(qr)
 	}
 	return errCount
 }
 
+// resultBytes returns the total size of the values of the rows of qr.
+func resultBytes(qr *sqltypes.Result) uint64 {
+	var size uint64
+	for _, row := range qr.Rows {
+		for _, col := range row {
+			size += uint64(col.Len())
+		}
+	}
+	return size
+}
+
 func (e *Executor) logPlanningFinished(logStats *logstats.LogStats, plan *engine.Plan) time.Time {
 	execStart := time.Now()
 	if plan != nil {
@@ -33,6 +33,9 @@ var (
 
 	// QueryzHandler is the debug UI path for exposing query plan stats
 	QueryzHandler = "/debug/queryz"
+
+	// QueryzResetHandler is the debug path for resetting query plan stats
+	QueryzResetHandler = "/debug/queryz/reset"
 )
 
 func (e *Executor) defaultQueryLogger() error {
@@ -49,6 +52,10 @@ func (e *Executor) defaultQueryLogger() error {
 		queryzHandler(e, w, r)
 	})
 
+	servenv.HTTPHandleFunc(QueryzResetHandler, func(w http.ResponseWriter, r *http.Request) {
+		queryzResetHandler(e, w, r)
+	})
+
 	if queryLogToFile != "" {
 		_, err := queryLogger.LogToFile(queryLogToFile, streamlog.GetFormatter(queryLogger))
 		if err != nil {
@@ -17,33 +17,127 @@ limitations under the License.
 package vtgate
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"regexp"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/safehtml/template"
 
 	"vitess.io/vitess/go/acl"
+	"vitess.io/vitess/go/constants/sidecar"
+	"vitess.io/vitess/go/stats"
 	"vitess.io/vitess/go/vt/log"
 	"vitess.io/vitess/go/vt/logz"
+	"vitess.io/vitess/go/vt/schema"
+	"vitess.io/vitess/go/vt/sqlparser"
 	"vitess.io/vitess/go/vt/vtgate/engine"
 )
 
+var (
+	// queryzThresholds are the time per query from which a plan is shown as
+	// medium and high on /debug/queryz.
+	queryzThresholds = []time.Duration{10 * time.Millisecond, 100 * time.Millisecond}
+
+	// queryzThresholdsByKind makes /debug/queryz use the thresholds of the
+	// statement kind of a plan instead of queryzThresholds.
+	queryzThresholdsByKind bool
+	queryzReadThresholds   = []time.Duration{10 * time.Millisecond, 100 * time.Millisecond}
+	queryzWriteThresholds  = []time.Duration{50 * time.Millisecond, 500 * time.Millisecond}
+	queryzOtherThresholds  = []time.Duration{time.Second, 10 * time.Second}
+
+	// queryzErrorRateThreshold is the errors per query from which a plan is
+	// shown as high on /debug/queryz, however fast it is. Zero, the default,
+	// disables it.
+	queryzErrorRateThreshold float64
+
+	// queryzMetricsMaxQueries is the number of plans, by execution count,
+	// that get their own series in the QueryPlan* metrics.
+	queryzMetricsMaxQueries = 100
+)
+
 var (
 	queryzHeader = []byte(`<thead>
 		<tr>
 			<th>Query</th>
+			<th>Kind</th>
+			<th>Fingerprint</th>
+			<th>Count</th>
+			<th>Time</th>
+			<th>Shard Queries</th>
+			<th>RowsAffected</th>
+			<th>RowsReturned</th>
+			<th>BytesReturned</th>
+			<th>Errors</th>
+			<th>Time per query</th>
+			<th>Shard queries per query</th>
+			<th>Min shard queries</th>
+			<th>Max shard queries</th>
+			<th>RowsAffected per query</th>
+			<th>RowsReturned per query</th>
+			<th>BytesReturned per query</th>
+			<th>Errors per query</th>
+			<th>P50</th>
+			<th>P95</th>
+			<th>P99</th>
+			<th>First seen</th>
+			<th>Last executed</th>
+		</tr>
+        </thead>
+	`)
+	queryzLiveHeader = []byte(`<thead>
+		<tr>
+			<th>Query</th>
+			<th>Kind</th>
+			<th>Fingerprint</th>
+			<th>Running</th>
+			<th>Oldest Running</th>
+			<th>Count</th>
+			<th>Time</th>
+			<th>Shard Queries</th>
+			<th>RowsAffected</th>
+			<th>RowsReturned</th>
+			<th>BytesReturned</th>
+			<th>Errors</th>
+			<th>Time per query</th>
+			<th>Shard queries per query</th>
+			<th>Min shard queries</th>
+			<th>Max shard queries</th>
+			<th>RowsAffected per query</th>
+			<th>RowsReturned per query</th>
+			<th>BytesReturned per query</th>
+			<th>Errors per query</th>
+			<th>P50</th>
+			<th>P95</th>
+			<th>P99</th>
+			<th>First seen</th>
+			<th>Last executed</th>
+		</tr>
+        </thead>
+	`)
+	queryzTableHeader = []byte(`<thead>
+		<tr>
+			<th>Table</th>
 			<th>Count</th>
 			<th>Time</th>
 			<th>Shard Queries</th>
 			<th>RowsAffected</th>
 			<th>RowsReturned</th>
+			<th>BytesReturned</th>
 			<th>Errors</th>
 			<th>Time per query</th>
 			<th>Shard queries per query</th>
+			<th>Min shard queries</th>
+			<th>Max shard queries</th>
 			<th>RowsAffected per query</th>
 			<th>RowsReturned per query</th>
+			<th>BytesReturned per query</th>
 			<th>Errors per query</th>
 		</tr>
         </thead>
@@ -51,16 +145,49 @@ var (
 	queryzTmpl = template.Must(template.New("example").Parse(`
 		<tr class="{{.Color}}">
 			<td>{{.Query}}</td>
+			<td>{{.Kind}}</td>
+			<td>{{.Fingerprint}}</td>{{if .Live}}
+			<td>{{.Running}}</td>
+			<td>{{.OldestRunning}}</td>{{end}}
 			<td>{{.Count}}</td>
 			<td>{{.Time}}</td>
 			<td>{{.ShardQueries}}</td>
 			<td>{{.RowsAffected}}</td>
 			<td>{{.RowsReturned}}</td>
-			<td>{{.Errors}}</td>
+			<td>{{.BytesReturned}}</td>
+			<td>{{.Errors}}{{with .ErrorCodes}}<details><summary>by code</summary>{{range $code, $count := .}}{{$code}}: {{$count}}<br>{{end}}</details>{{end}}</td>
 			<td>{{.TimePQ}}</td>
 			<td>{{.ShardQueriesPQ}}</td>
+			<td>{{.MinShardQueries}}</td>
+			<td>{{.MaxShardQueries}}</td>
 			<td>{{.RowsAffectedPQ}}</td>
 			<td>{{.RowsReturnedPQ}}</td>
+			<td>{{.BytesReturnedPQ}}</td>
+			<td>{{.ErrorsPQ}}</td>
+			<td>{{.P50}}</td>
+			<td>{{.P95}}</td>
+			<td>{{.P99}}</td>
+			<td>{{.FirstSeen}}</td>
+			<td>{{.LastExecuted}}</td>
+		</tr>
+	`))
+	queryzTableTmpl = template.Must(template.New("table").Parse(`
+		<tr>
+			<td>{{.Table}}</td>
+			<td>{{.Count}}</td>
+			<td>{{.Time}}</td>
+			<td>{{.ShardQueries}}</td>
+			<td>{{.RowsAffected}}</td>
+			<td>{{.RowsReturned}}</td>
+			<td>{{.BytesReturned}}</td>
+			<td>{{.Errors}}{{with .ErrorCodes}}<details><summary>by code</summary>{{range $code, $count := .}}{{$code}}: {{$count}}<br>{{end}}</details>{{end}}</td>
+			<td>{{.TimePQ}}</td>
+			<td>{{.ShardQueriesPQ}}</td>
+			<td>{{.MinShardQueries}}</td>
+			<td>{{.MaxShardQueries}}</td>
+			<td>{{.RowsAffectedPQ}}</td>
+			<td>{{.RowsReturnedPQ}}</td>
+			<td>{{.BytesReturnedPQ}}</td>
 			<td>{{.ErrorsPQ}}</td>
 		</tr>
 	`))
@@ -69,15 +196,88 @@ var (
 // queryzRow is used for rendering query stats
 // using go's template.
 type queryzRow struct {
-	Query        string
-	Table        string
-	Count        uint64
-	tm           time.Duration
-	ShardQueries uint64
-	RowsAffected uint64
-	RowsReturned uint64
-	Errors       uint64
-	Color        string
+	Query         string
+	rawQuery      string
+	Kind          string
+	Fingerprint   string
+	Table         string
+	Count         uint64
+	tm            time.Duration
+	ShardQueries  uint64
+	RowsAffected  uint64
+	RowsReturned  uint64
+	BytesReturned uint64
+	Errors        uint64
+	Color         string
+
+	// MinShardQueries and MaxShardQueries are the fewest and most shard
+	// queries of a single execution, which show whether a plan sometimes
+	// scatters even though it usually doesn't.
+	MinShardQueries uint64
+	MaxShardQueries uint64
+
+	// ErrorCodes is the number of errors by MySQL error number.
+	ErrorCodes map[string]uint64
+
+	// P50, P95 and P99 are percentiles of the execution times of the plan.
+	P50 queryzSeconds
+	P95 queryzSeconds
+	P99 queryzSeconds
+
+	// firstSeen and lastExecuted are when the plan was first and last
+	// executed, and now is when the page was rendered.
+	firstSeen    time.Time
+	lastExecuted time.Time
+	now          time.Time
+
+	// Live is set when the row shows the executions of the plan that are
+	// running now: how many there are and since when the oldest runs.
+	Live          bool
+	Running       int
+	oldestRunning time.Duration
+}
+
+// queryzSeconds is a duration that renders in seconds like the other
+// times on the page.
+type queryzSeconds time.Duration
+
+func (d queryzSeconds) String() string {
+	return fmt.Sprintf("%.6f", float64(d)/1e9)
+}
+
+// queryzAgo returns how long before now t was, in a form for humans.
+func queryzAgo(now, t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	ago := now.Sub(t)
+	switch {
+	case ago < time.Second:
+		return "just now"
+	case ago < time.Minute:
+		return fmt.Sprintf("%ds ago", int(ago/time.Second))
+	case ago < time.Hour:
+		return fmt.Sprintf("%dm ago", int(ago/time.Minute))
+	case ago < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(ago/time.Hour))
+	default:
+		return fmt.Sprintf("%dd ago", int(ago/(24*time.Hour)))
+	}
+}
+
+// FirstSeen returns how long ago the plan was first executed.
+func (qzs *queryzRow) FirstSeen() string {
+	return queryzAgo(qzs.now, qzs.firstSeen)
+}
+
+// LastExecuted returns how long ago the plan was last executed.
+func (qzs *queryzRow) LastExecuted() string {
+	return queryzAgo(qzs.now, qzs.lastExecuted)
+}
+
+// OldestRunning returns the age of the oldest running execution as a string.
+func (qzs *queryzRow) OldestRunning() string {
+	return fmt.Sprintf("%.6f", float64(qzs.oldestRunning)/1e9)
 }
 
 // Time returns the total time as a string.
@@ -112,11 +312,314 @@ func (qzs *queryzRow) RowsReturnedPQ() string {
 	return fmt.Sprintf("%.6f", val)
 }
 
+// BytesReturnedPQ returns the bytes returned per query as a string.
+func (qzs *queryzRow) BytesReturnedPQ() string {
+	val := float64(qzs.BytesReturned) / float64(qzs.Count)
+	return fmt.Sprintf("%.6f", val)
+}
+
 // ErrorsPQ returns the error count per query as a string.
 func (qzs *queryzRow) ErrorsPQ() string {
 	return fmt.Sprintf("%.6f", float64(qzs.Errors)/float64(qzs.Count))
 }
 
+// queryzJSONRow is the JSON representation of a queryzRow.
+type queryzJSONRow struct {
+	Query         string
+	Kind          string
+	Fingerprint   string
+	Table         string `json:",omitempty"`
+	Count         uint64
+	Time          time.Duration
+	ShardQueries  uint64
+	RowsAffected  uint64
+	RowsReturned  uint64
+	BytesReturned uint64
+	Errors        uint64
+	ErrorCodes    map[string]uint64 `json:",omitempty"`
+
+	MinShardQueries uint64
+	MaxShardQueries uint64
+
+	TimePQ          float64
+	ShardQueriesPQ  float64
+	RowsAffectedPQ  float64
+	RowsReturnedPQ  float64
+	BytesReturnedPQ float64
+	ErrorsPQ        float64
+
+	P50 time.Duration `json:",omitempty"`
+	P95 time.Duration `json:",omitempty"`
+	P99 time.Duration `json:",omitempty"`
+
+	FirstSeen    *time.Time `json:",omitempty"`
+	LastExecuted *time.Time `json:",omitempty"`
+
+	Running       int           `json:",omitempty"`
+	OldestRunning time.Duration `json:",omitempty"`
+}
+
+// perQuery returns val divided by the count of queries, or zero if there
+// were none, since NaN can't be represented in JSON.
+func (qzs *queryzRow) perQuery(val float64) float64 {
+	if qzs.Count == 0 {
+		return 0
+	}
+	return val / float64(qzs.Count)
+}
+
+// queryzJSONTime returns t for the JSON representation, where it's encoded
+// as RFC 3339, or nil if it's unset.
+func queryzJSONTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+// jsonRow returns the JSON representation of the row.
+func (qzs *queryzRow) jsonRow() queryzJSONRow {
+	return queryzJSONRow{
+		Query:         qzs.rawQuery,
+		Kind:          qzs.Kind,
+		Fingerprint:   qzs.Fingerprint,
+		Table:         qzs.Table,
+		Count:         qzs.Count,
+		Time:          qzs.tm,
+		ShardQueries:  qzs.ShardQueries,
+		RowsAffected:  qzs.RowsAffected,
+		RowsReturned:  qzs.RowsReturned,
+		BytesReturned: qzs.BytesReturned,
+		Errors:        qzs.Errors,
+		ErrorCodes:    qzs.ErrorCodes,
+
+		MinShardQueries: qzs.MinShardQueries,
+		MaxShardQueries: qzs.MaxShardQueries,
+
+		TimePQ:          qzs.perQuery(float64(qzs.tm) / 1e9),
+		ShardQueriesPQ:  qzs.perQuery(float64(qzs.ShardQueries)),
+		RowsAffectedPQ:  qzs.perQuery(float64(qzs.RowsAffected)),
+		RowsReturnedPQ:  qzs.perQuery(float64(qzs.RowsReturned)),
+		BytesReturnedPQ: qzs.perQuery(float64(qzs.BytesReturned)),
+		ErrorsPQ:        qzs.perQuery(float64(qzs.Errors)),
+
+		P50: time.Duration(qzs.P50),
+		P95: time.Duration(qzs.P95),
+		P99: time.Duration(qzs.P99),
+
+		FirstSeen:    queryzJSONTime(qzs.firstSeen),
+		LastExecuted: queryzJSONTime(qzs.lastExecuted),
+
+		Running:       qzs.Running,
+		OldestRunning: qzs.oldestRunning,
+	}
+}
+
+// queryzCSVStatsColumns are the columns of the CSV export with the stats
+// of a plan or table, in the order of the HTML table.
+var queryzCSVStatsColumns = []string{
+	"Count", "Time", "Shard Queries", "RowsAffected", "RowsReturned", "BytesReturned", "Errors",
+	"Time per query", "Shard queries per query", "Min shard queries", "Max shard queries", "RowsAffected per query", "RowsReturned per query", "BytesReturned per query", "Errors per query",
+}
+
+// queryzCSVHeader returns the header row of the CSV export, with the
+// columns of the HTML table for rows grouped by table or, if live is set,
+// with the running executions.
+func queryzCSVHeader(byTable, live bool) []string {
+	if byTable {
+		return append([]string{"Table"}, queryzCSVStatsColumns...)
+	}
+	header := []string{"Query", "Kind", "Fingerprint"}
+	if live {
+		header = append(header, "Running", "Oldest Running")
+	}
+	header = append(header, queryzCSVStatsColumns...)
+	return append(header, "P50", "P95", "P99", "First seen", "Last executed")
+}
+
+// csvRecord returns the row for the CSV export, with the columns of
+// queryzCSVHeader.
+func (qzs *queryzRow) csvRecord(byTable bool) []string {
+	stats := []string{
+		strconv.FormatUint(qzs.Count, 10),
+		qzs.Time(),
+		strconv.FormatUint(qzs.ShardQueries, 10),
+		strconv.FormatUint(qzs.RowsAffected, 10),
+		strconv.FormatUint(qzs.RowsReturned, 10),
+		strconv.FormatUint(qzs.BytesReturned, 10),
+		strconv.FormatUint(qzs.Errors, 10),
+		qzs.TimePQ(),
+		qzs.ShardQueriesPQ(),
+		strconv.FormatUint(qzs.MinShardQueries, 10),
+		strconv.FormatUint(qzs.MaxShardQueries, 10),
+		qzs.RowsAffectedPQ(),
+		qzs.RowsReturnedPQ(),
+		qzs.BytesReturnedPQ(),
+		qzs.ErrorsPQ(),
+	}
+	if byTable {
+		return append([]string{qzs.Table}, stats...)
+	}
+	record := []string{qzs.rawQuery, qzs.Kind, qzs.Fingerprint}
+	if qzs.Live {
+		record = append(record, strconv.Itoa(qzs.Running), qzs.OldestRunning())
+	}
+	record = append(record, stats...)
+	return append(record, qzs.P50.String(), qzs.P95.String(), qzs.P99.String(), qzs.FirstSeen(), qzs.LastExecuted())
+}
+
+// writeQueryzCSV writes the rows as a CSV file for download.
+func writeQueryzCSV(w http.ResponseWriter, rows []*queryzRow, byTable, live bool) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=queryz.csv")
+	cw := csv.NewWriter(w)
+	if err := cw.Write(queryzCSVHeader(byTable, live)); err != nil {
+		log.Errorf("queryz: couldn't write CSV: %v", err)
+		return
+	}
+	for _, row := range rows {
+		if err := cw.Write(row.csvRecord(byTable)); err != nil {
+			log.Errorf("queryz: couldn't write CSV: %v", err)
+			return
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		log.Errorf("queryz: couldn't write CSV: %v", err)
+	}
+}
+
+// liveQueries tracks the executions of plans that are running, with the
+// time each of them started.
+type liveQueries struct {
+	mu      sync.Mutex
+	nextID  uint64
+	running map[*engine.Plan]map[uint64]time.Time
+}
+
+func newLiveQueries() *liveQueries {
+	return &liveQueries{running: make(map[*engine.Plan]map[uint64]time.Time)}
+}
+
+// add records that an execution of the plan started, and returns the
+// function to call once it is finished.
+func (lq *liveQueries) add(plan *engine.Plan) func() {
+	if lq == nil {
+		return func() {}
+	}
+	lq.mu.Lock()
+	defer lq.mu.Unlock()
+	lq.nextID++
+	id := lq.nextID
+	if lq.running[plan] == nil {
+		lq.running[plan] = make(map[uint64]time.Time)
+	}
+	lq.running[plan][id] = time.Now()
+	return func() {
+		lq.mu.Lock()
+		defer lq.mu.Unlock()
+		delete(lq.running[plan], id)
+		if len(lq.running[plan]) == 0 {
+			delete(lq.running, plan)
+		}
+	}
+}
+
+// liveQueryStats is the number of running executions of a plan, and the
+// start time of the oldest one.
+type liveQueryStats struct {
+	running int
+	oldest  time.Time
+}
+
+// stats returns the running executions of every plan that has some.
+func (lq *liveQueries) stats() map[*engine.Plan]liveQueryStats {
+	result := make(map[*engine.Plan]liveQueryStats)
+	if lq == nil {
+		return result
+	}
+	lq.mu.Lock()
+	defer lq.mu.Unlock()
+	for plan, executions := range lq.running {
+		var st liveQueryStats
+		for _, start := range executions {
+			if st.running == 0 || start.Before(st.oldest) {
+				st.oldest = start
+			}
+			st.running++
+		}
+		result[plan] = st
+	}
+	return result
+}
+
+// queryzStatementKind returns the kind of statement, "read", "write" or
+// "other", that decides which thresholds apply to a plan of the given type.
+func queryzStatementKind(stmtType sqlparser.StatementType) string {
+	switch stmtType {
+	case sqlparser.StmtSelect, sqlparser.StmtStream, sqlparser.StmtShow:
+		return "read"
+	case sqlparser.StmtInsert, sqlparser.StmtReplace, sqlparser.StmtUpdate, sqlparser.StmtDelete:
+		return "write"
+	default:
+		return "other"
+	}
+}
+
+// validateQueryzThresholds returns an error if the thresholds set with the
+// --queryz-*thresholds flags aren't two durations each.
+func validateQueryzThresholds() error {
+	for _, flag := range []struct {
+		name       string
+		thresholds []time.Duration
+	}{
+		{"queryz-thresholds", queryzThresholds},
+		{"queryz-read-thresholds", queryzReadThresholds},
+		{"queryz-write-thresholds", queryzWriteThresholds},
+		{"queryz-other-thresholds", queryzOtherThresholds},
+	} {
+		if len(flag.thresholds) != 2 {
+			return fmt.Errorf("invalid value for --%s: %v, want exactly two durations", flag.name, flag.thresholds)
+		}
+	}
+	return nil
+}
+
+// queryzColor returns the color of a plan of the given statement type that
+// takes timepq per query. The thresholds are validated on startup, see
+// validateQueryzThresholds.
+func queryzColor(stmtType sqlparser.StatementType, timepq time.Duration) string {
+	thresholds := queryzThresholds
+	if queryzThresholdsByKind {
+		switch queryzStatementKind(stmtType) {
+		case "read":
+			thresholds = queryzReadThresholds
+		case "write":
+			thresholds = queryzWriteThresholds
+		default:
+			thresholds = queryzOtherThresholds
+		}
+	}
+	switch {
+	case timepq < thresholds[0]:
+		return "low"
+	case timepq < thresholds[1]:
+		return "medium"
+	default:
+		return "high"
+	}
+}
+
+// queryzHighErrorRate returns whether a plan that failed errors times out
+// of count executions is shown as high because of its error rate.
+func queryzHighErrorRate(errors, count uint64) bool {
+	if count == 0 || queryzErrorRateThreshold <= 0 {
+		return false
+	}
+	return float64(errors)/float64(count) >= queryzErrorRateThreshold
+}
+
 type queryzSorter struct {
 	rows []*queryzRow
 	less func(row1, row2 *queryzRow) bool
@@ -126,46 +629,385 @@ func (s *queryzSorter) Len() int           { return len(s.rows) }
 func (s *queryzSorter) Swap(i, j int)      { s.rows[i], s.rows[j] = s.rows[j], s.rows[i] }
 func (s *queryzSorter) Less(i, j int) bool { return s.less(s.rows[i], s.rows[j]) }
 
+// queryzDefaultLimit bounds the rows queryz shows when no limit is given,
+// so that a large plan cache doesn't produce a page too big to render.
+const queryzDefaultLimit = 1000
+
+// queryzSortKeys maps the values of the sort parameter to the column
+// the rows are ordered by.
+var queryzSortKeys = map[string]func(row *queryzRow) float64{
+	"count":             func(row *queryzRow) float64 { return float64(row.Count) },
+	"time":              func(row *queryzRow) float64 { return float64(row.tm) },
+	"shard_queries":     func(row *queryzRow) float64 { return float64(row.ShardQueries) },
+	"max_shard_queries": func(row *queryzRow) float64 { return float64(row.MaxShardQueries) },
+	"rows_affected":     func(row *queryzRow) float64 { return float64(row.RowsAffected) },
+	"rows_returned":     func(row *queryzRow) float64 { return float64(row.RowsReturned) },
+	"bytes_returned":    func(row *queryzRow) float64 { return float64(row.BytesReturned) },
+	"errors":            func(row *queryzRow) float64 { return float64(row.Errors) },
+	"time_per_query":    func(row *queryzRow) float64 { return row.timePQ() },
+}
+
+// queryzLess returns the ordering for the sort and order parameters. An
+// unknown or missing sort key orders by time per query, and anything but
+// "asc" orders descending.
+func queryzLess(sortKey, order string) func(row1, row2 *queryzRow) bool {
+	key, ok := queryzSortKeys[sortKey]
+	if !ok {
+		key = queryzSortKeys["time_per_query"]
+	}
+	if order == "asc" {
+		return func(row1, row2 *queryzRow) bool { return key(row1) < key(row2) }
+	}
+	return func(row1, row2 *queryzRow) bool { return key(row1) > key(row2) }
+}
+
+// queryzPrimaryTable returns the first table the plan uses, or an empty
+// string for plans that don't use any.
+func queryzPrimaryTable(plan *engine.Plan) string {
+	if len(plan.TablesUsed) == 0 {
+		return ""
+	}
+	return plan.TablesUsed[0]
+}
+
+// queryzIsInternal returns whether the statement uses a table of the sidecar
+// database or a system schema, or one of the internal tables of online DDL
+// and table GC. It is computed once when the plan is built.
+func queryzIsInternal(stmt sqlparser.Statement) bool {
+	internal := false
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		tableName, ok := node.(sqlparser.TableName)
+		if !ok {
+			return true, nil
+		}
+		qualifier := tableName.Qualifier.String()
+		if qualifier == sidecar.GetName() || qualifier == sidecar.DefaultName || sqlparser.SystemSchema(qualifier) ||
+			schema.IsInternalOperationTableName(tableName.Name.String()) {
+			internal = true
+			return false, nil
+		}
+		return true, nil
+	}, stmt)
+	return internal
+}
+
+// queryzGroupByTable aggregates the stats of the rows into one row per
+// primary table, in the order the tables are first seen.
+func queryzGroupByTable(rows []*queryzRow) []*queryzRow {
+	var grouped []*queryzRow
+	byTable := make(map[string]*queryzRow)
+	for _, row := range rows {
+		group, ok := byTable[row.Table]
+		if !ok {
+			group = &queryzRow{Table: row.Table}
+			byTable[row.Table] = group
+			grouped = append(grouped, group)
+		}
+		if row.Count > 0 {
+			if group.Count == 0 || row.MinShardQueries < group.MinShardQueries {
+				group.MinShardQueries = row.MinShardQueries
+			}
+			group.MaxShardQueries = max(group.MaxShardQueries, row.MaxShardQueries)
+		}
+		group.Count += row.Count
+		group.tm += row.tm
+		group.ShardQueries += row.ShardQueries
+		group.RowsAffected += row.RowsAffected
+		group.RowsReturned += row.RowsReturned
+		group.BytesReturned += row.BytesReturned
+		group.Errors += row.Errors
+		for code, count := range row.ErrorCodes {
+			if group.ErrorCodes == nil {
+				group.ErrorCodes = make(map[string]uint64)
+			}
+			group.ErrorCodes[code] += count
+		}
+	}
+	return grouped
+}
+
 func queryzHandler(e *Executor, w http.ResponseWriter, r *http.Request) {
 	if err := acl.CheckAccessHTTP(r, acl.DEBUGGING); err != nil {
 		acl.SendError(w, err)
 		return
 	}
-	logz.StartHTMLTable(w)
-	defer logz.EndHTMLTable(w)
-	w.Write(queryzHeader)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, fmt.Sprintf("cannot parse form: %s", err), http.StatusInternalServerError)
+		return
+	}
+	asCSV := r.FormValue("format") == "csv"
+	asJSON := !asCSV && (r.FormValue("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json"))
+	limit := queryzDefaultLimit
+	if v := r.FormValue("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, fmt.Sprintf("invalid limit %q: want a positive number", v), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	var filter *regexp.Regexp
+	if v := r.FormValue("filter"); v != "" {
+		var err error
+		if filter, err = regexp.Compile(v); err != nil {
+			http.Error(w, fmt.Sprintf("invalid filter %q: %v", v, err), http.StatusBadRequest)
+			return
+		}
+	}
+	excludeInternal := r.FormValue("exclude_internal") == "true"
+	byTable := false
+	switch groupBy := r.FormValue("groupby"); groupBy {
+	case "":
+	case "table":
+		byTable = true
+	default:
+		http.Error(w, fmt.Sprintf("invalid groupby %q: want table", groupBy), http.StatusBadRequest)
+		return
+	}
+	var live map[*engine.Plan]liveQueryStats
+	if r.FormValue("live") != "" {
+		live = e.liveQueries.stats()
+	}
+	now := time.Now()
 
 	sorter := queryzSorter{
 		rows: nil,
-		less: func(row1, row2 *queryzRow) bool {
-			return row1.timePQ() > row2.timePQ()
-		},
+		less: queryzLess(r.FormValue("sort"), r.FormValue("order")),
 	}
 
 	e.ForEachPlan(func(plan *engine.Plan) bool {
+		if filter != nil && !filter.MatchString(plan.Original) {
+			return true
+		}
+		if excludeInternal && plan.Internal {
+			return true
+		}
+		query := e.parser.TruncateForUI(plan.Original)
 		Value := &queryzRow{
-			Query: logz.Wrappable(e.parser.TruncateForUI(plan.Original)),
+			Query:       logz.Wrappable(query),
+			rawQuery:    query,
+			Kind:        plan.Type.String(),
+			Fingerprint: plan.Fingerprint(),
+			Table:       queryzPrimaryTable(plan),
+
+			firstSeen:    plan.FirstSeen(),
+			lastExecuted: plan.LastExecuted(),
+			now:          now,
+		}
+		Value.Count, Value.tm, Value.ShardQueries, Value.RowsAffected, Value.RowsReturned, Value.BytesReturned, Value.Errors = plan.Stats()
+		Value.MinShardQueries, Value.MaxShardQueries = plan.ShardQueriesRange()
+		if codes := plan.ErrorCodes(); len(codes) > 0 {
+			Value.ErrorCodes = codes
+		}
+		p50, p95, p99 := plan.Percentiles()
+		Value.P50, Value.P95, Value.P99 = queryzSeconds(p50), queryzSeconds(p95), queryzSeconds(p99)
+		if live != nil {
+			Value.Live = true
+			if st, ok := live[plan]; ok {
+				Value.Running = st.running
+				Value.oldestRunning = now.Sub(st.oldest)
+			}
 		}
-		Value.Count, Value.tm, Value.ShardQueries, Value.RowsAffected, Value.RowsReturned, Value.Errors = plan.Stats()
 		var timepq time.Duration
 		if Value.Count != 0 {
 			timepq = time.Duration(uint64(Value.tm) / Value.Count)
 		}
-		if timepq < 10*time.Millisecond {
-			Value.Color = "low"
-		} else if timepq < 100*time.Millisecond {
-			Value.Color = "medium"
-		} else {
+		Value.Color = queryzColor(plan.Type, timepq)
+		if queryzHighErrorRate(Value.Errors, Value.Count) {
 			Value.Color = "high"
 		}
 		sorter.rows = append(sorter.rows, Value)
 		return true
 	})
 
+	if byTable {
+		sorter.rows = queryzGroupByTable(sorter.rows)
+	}
 	sort.Sort(&sorter)
+	// The limit applies after sorting so that the top rows are kept.
+	if len(sorter.rows) > limit {
+		sorter.rows = sorter.rows[:limit]
+	}
+	if asCSV {
+		writeQueryzCSV(w, sorter.rows, byTable, live != nil)
+		return
+	}
+	if asJSON {
+		jsonRows := make([]queryzJSONRow, 0, len(sorter.rows))
+		for _, row := range sorter.rows {
+			jsonRows = append(jsonRows, row.jsonRow())
+		}
+		js, err := json.Marshal(jsonRows)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(js)
+		return
+	}
+
+	logz.StartHTMLTable(w)
+	defer logz.EndHTMLTable(w)
+	tmpl := queryzTmpl
+	switch {
+	case byTable:
+		w.Write(queryzTableHeader)
+		tmpl = queryzTableTmpl
+	case live != nil:
+		w.Write(queryzLiveHeader)
+	default:
+		w.Write(queryzHeader)
+	}
 	for _, row := range sorter.rows {
-		if err := queryzTmpl.Execute(w, row); err != nil {
+		if err := tmpl.Execute(w, row); err != nil {
 			log.Errorf("queryz: couldn't execute template: %v", err)
 		}
 	}
 }
+
+// queryzResetHandler zeroes the stats of all plans in the cache and writes
+// how many plans were reset. It only accepts POST, so that a browser
+// prefetching the link can't reset the stats.
+func queryzResetHandler(e *Executor, w http.ResponseWriter, r *http.Request) {
+	if err := acl.CheckAccessHTTP(r, acl.ADMIN); err != nil {
+		acl.SendError(w, err)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "queryz reset requires POST", http.StatusMethodNotAllowed)
+		return
+	}
+	count := 0
+	e.ForEachPlan(func(plan *engine.Plan) bool {
+		plan.ResetStats()
+		count++
+		return true
+	})
+	fmt.Fprintf(w, "%d\n", count)
+}
+
+const (
+	// queryzMetricsOther is the query label of the series that adds up the
+	// plans without a series of their own.
+	queryzMetricsOther = "other"
+
+	// queryzMetricsQueryLength is the length the query label is cut to.
+	queryzMetricsQueryLength = 100
+
+	// queryzMetricsSnapshotTTL is how long the gauges of the plans reuse
+	// the stats computed for the first of them, see queryzMetricsSnapshot.
+	queryzMetricsSnapshotTTL = time.Second
+)
+
+// queryzMetric is the stats of the plans exported under one query label.
+type queryzMetric struct {
+	count        int64
+	time         int64
+	rowsAffected int64
+	rowsReturned int64
+	errors       int64
+}
+
+// queryzMetricsLabel returns the query label of a plan: its query without
+// the dots that separate labels in the stats. A query longer than
+// queryzMetricsQueryLength characters is cut to that length and followed
+// by the fingerprint of its plan, so that the queries that only differ
+// after the cut keep series of their own.
+func queryzMetricsLabel(query string, fingerprint func() string) string {
+	if runes := []rune(query); len(runes) > queryzMetricsQueryLength {
+		query = string(runes[:queryzMetricsQueryLength]) + " " + fingerprint()
+	}
+	return strings.ReplaceAll(query, ".", "_")
+}
+
+// queryzMetrics returns the stats of the plans by query label. Only the
+// maxQueries plans executed most get a label of their own, the stats of
+// the others are added up under queryzMetricsOther to bound the number of
+// series.
+func (e *Executor) queryzMetrics(maxQueries int) map[string]*queryzMetric {
+	type planRow struct {
+		*queryzRow
+		plan *engine.Plan
+	}
+	var rows []planRow
+	e.ForEachPlan(func(plan *engine.Plan) bool {
+		row := &queryzRow{rawQuery: plan.Original}
+		row.Count, row.tm, _, row.RowsAffected, row.RowsReturned, _, row.Errors = plan.Stats()
+		rows = append(rows, planRow{queryzRow: row, plan: plan})
+		return true
+	})
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Count != rows[j].Count {
+			return rows[i].Count > rows[j].Count
+		}
+		return rows[i].rawQuery < rows[j].rawQuery
+	})
+
+	metrics := make(map[string]*queryzMetric)
+	for i, row := range rows {
+		label := queryzMetricsOther
+		if i < maxQueries {
+			label = queryzMetricsLabel(row.rawQuery, row.plan.Fingerprint)
+		}
+		metric, ok := metrics[label]
+		if !ok {
+			metric = &queryzMetric{}
+			metrics[label] = metric
+		}
+		metric.count += int64(row.Count)
+		metric.time += int64(row.tm)
+		metric.rowsAffected += int64(row.RowsAffected)
+		metric.rowsReturned += int64(row.RowsReturned)
+		metric.errors += int64(row.Errors)
+	}
+	return metrics
+}
+
+// queryzMetricsSnapshot computes the stats of the plans once for all the
+// gauges of a scrape, which read them one after the other: a snapshot is
+// reused for queryzMetricsSnapshotTTL.
+type queryzMetricsSnapshot struct {
+	compute func() map[string]*queryzMetric
+
+	mu      sync.Mutex
+	taken   time.Time
+	metrics map[string]*queryzMetric
+}
+
+// get returns the current snapshot, after computing a new one if it is
+// too old.
+func (s *queryzMetricsSnapshot) get() map[string]*queryzMetric {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.metrics == nil || time.Since(s.taken) >= queryzMetricsSnapshotTTL {
+		s.metrics = s.compute()
+		s.taken = time.Now()
+	}
+	return s.metrics
+}
+
+// registerQueryzMetrics exports the stats shown on /debug/queryz as gauges
+// labeled by query. They are gauges because plans leave the cache and their
+// stats can be reset.
+func (e *Executor) registerQueryzMetrics() {
+	snapshot := &queryzMetricsSnapshot{compute: func() map[string]*queryzMetric {
+		return e.queryzMetrics(queryzMetricsMaxQueries)
+	}}
+	gauge := func(name, help string, value func(*queryzMetric) int64) {
+		stats.NewGaugesFuncWithMultiLabels(name, help, []string{"Query"}, func() map[string]int64 {
+			metrics := snapshot.get()
+			values := make(map[string]int64, len(metrics))
+			for label, metric := range metrics {
+				values[label] = value(metric)
+			}
+			return values
+		})
+	}
+	gauge("QueryPlanExecCount", "Executions of the cached query plans", func(m *queryzMetric) int64 { return m.count })
+	gauge("QueryPlanExecTime", "Execution time of the cached query plans in nanoseconds", func(m *queryzMetric) int64 { return m.time })
+	gauge("QueryPlanRowsAffected", "Rows affected by the cached query plans", func(m *queryzMetric) int64 { return m.rowsAffected })
+	gauge("QueryPlanRowsReturned", "Rows returned by the cached query plans", func(m *queryzMetric) int64 { return m.rowsReturned })
+	gauge("QueryPlanErrors", "Errors of the cached query plans", func(m *queryzMetric) int64 { return m.errors })
+}
@@ -17,9 +17,13 @@ limitations under the License.
 package vtgate
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"regexp"
 	"strings"
 	"testing"
@@ -29,7 +33,9 @@ import (
 
 	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
 
+	"vitess.io/vitess/go/mysql/sqlerror"
 	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/sqlparser"
 	"vitess.io/vitess/go/vt/vtgate/engine"
 
 	querypb "vitess.io/vitess/go/vt/proto/query"
@@ -87,71 +93,329 @@ func TestQueryzHandler(t *testing.T) {
 	planPattern1 := []string{
 		`<tr class="low">`,
 		"<td>select id from `user` where id = 1</td>",
+		`<td>SELECT</td>`,
+		`<td>` + plan1.Fingerprint() + `</td>`,
 		`<td>1</td>`,
 		`<td>0.001000</td>`,
 		`<td>1</td>`,
 		`<td>0</td>`,
 		`<td>1</td>`,
+		`<td>4</td>`,
 		`<td>0</td>`,
 		`<td>0.001000</td>`,
 		`<td>1.000000</td>`,
+		`<td>1</td>`,
+		`<td>1</td>`,
 		`<td>0.000000</td>`,
 		`<td>1.000000</td>`,
+		`<td>4.000000</td>`,
 		`<td>0.000000</td>`,
+		`<td>[0-9.]+</td>`,
+		`<td>[0-9.]+</td>`,
+		`<td>[0-9.]+</td>`,
+		`<td>[^<]+</td>`,
+		`<td>[^<]+</td>`,
 		`</tr>`,
 	}
 	checkQueryzHasPlan(t, planPattern1, plan1, body)
 	planPattern2 := []string{
 		`<tr class="high">`,
 		"<td>select id from `user`</td>",
+		`<td>SELECT</td>`,
+		`<td>` + plan2.Fingerprint() + `</td>`,
 		`<td>1</td>`,
 		`<td>1.000000</td>`,
 		`<td>8</td>`,
 		`<td>0</td>`,
 		`<td>8</td>`,
+		`<td>32</td>`,
 		`<td>0</td>`,
 		`<td>1.000000</td>`,
 		`<td>8.000000</td>`,
+		`<td>8</td>`,
+		`<td>8</td>`,
 		`<td>0.000000</td>`,
 		`<td>8.000000</td>`,
+		`<td>32.000000</td>`,
 		`<td>0.000000</td>`,
+		`<td>[0-9.]+</td>`,
+		`<td>[0-9.]+</td>`,
+		`<td>[0-9.]+</td>`,
+		`<td>[^<]+</td>`,
+		`<td>[^<]+</td>`,
 		`</tr>`,
 	}
 	checkQueryzHasPlan(t, planPattern2, plan2, body)
 	planPattern3 := []string{
 		`<tr class="medium">`,
 		"<td>insert into `user`.*</td>",
+		`<td>INSERT</td>`,
+		`<td>` + plan3.Fingerprint() + `</td>`,
 		`<td>2</td>`,
 		`<td>0.100000</td>`,
 		`<td>2</td>`,
 		`<td>2</td>`,
 		`<td>0</td>`,
 		`<td>0</td>`,
+		`<td>0</td>`,
 		`<td>0.050000</td>`,
 		`<td>1.000000</td>`,
+		`<td>1</td>`,
+		`<td>1</td>`,
 		`<td>1.000000</td>`,
 		`<td>0.000000</td>`,
 		`<td>0.000000</td>`,
+		`<td>0.000000</td>`,
+		`<td>[0-9.]+</td>`,
+		`<td>[0-9.]+</td>`,
+		`<td>[0-9.]+</td>`,
+		`<td>[^<]+</td>`,
+		`<td>[^<]+</td>`,
 		`</tr>`,
 	}
 	checkQueryzHasPlan(t, planPattern3, plan3, body)
 	planPattern4 := []string{
 		`<tr class="high">`,
 		`<td>insert into name_user_map.*</td>`,
+		`<td>INSERT</td>`,
+		`<td>` + plan4.Fingerprint() + `</td>`,
 		`<td>2</td>`,
 		`<td>0.200000</td>`,
 		`<td>2</td>`,
 		`<td>2</td>`,
 		`<td>0</td>`,
 		`<td>0</td>`,
+		`<td>0</td>`,
 		`<td>0.100000</td>`,
 		`<td>1.000000</td>`,
+		`<td>1</td>`,
+		`<td>1</td>`,
 		`<td>1.000000</td>`,
 		`<td>0.000000</td>`,
 		`<td>0.000000</td>`,
+		`<td>0.000000</td>`,
+		`<td>[0-9.]+</td>`,
+		`<td>[0-9.]+</td>`,
+		`<td>[0-9.]+</td>`,
+		`<td>[^<]+</td>`,
+		`<td>[^<]+</td>`,
 		`</tr>`,
 	}
 	checkQueryzHasPlan(t, planPattern4, plan4, body)
+
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/queryz?format=json", nil)
+	queryzHandler(executor, resp, req)
+	var rows []queryzJSONRow
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &rows))
+	fingerprints := make(map[string]string)
+	bytesReturned := make(map[string]uint64)
+	for _, row := range rows {
+		fingerprints[row.Query] = row.Fingerprint
+		bytesReturned[row.Query] = row.BytesReturned
+	}
+	require.Equal(t, plan1.Fingerprint(), fingerprints["select id from `user` where id = 1"])
+	require.Equal(t, plan2.Fingerprint(), fingerprints["select id from `user`"])
+	require.Equal(t, uint64(32), bytesReturned["select id from `user`"])
+
+	// The JSON output is also served for the Accept header, sorted like the
+	// HTML table and with the values per query.
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/queryz", nil)
+	req.Header.Set("Accept", "application/json")
+	queryzHandler(executor, resp, req)
+	require.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+	rows = nil
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &rows))
+	require.NotEmpty(t, rows)
+	for i, row := range rows {
+		if i > 0 {
+			require.GreaterOrEqual(t, rows[i-1].TimePQ, row.TimePQ)
+		}
+		if row.Query == "select id from `user`" {
+			require.Equal(t, float64(row.BytesReturned)/float64(row.Count), row.BytesReturnedPQ)
+			require.Equal(t, float64(row.ShardQueries)/float64(row.Count), row.ShardQueriesPQ)
+		}
+	}
+
+	// The rows can be sorted by a selected column in either order, and an
+	// unknown column falls back to the time per query.
+	sorted := func(query string) []queryzJSONRow {
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/queryz?format=json&"+query, nil)
+		queryzHandler(executor, resp, req)
+		var rows []queryzJSONRow
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &rows))
+		require.NotEmpty(t, rows)
+		return rows
+	}
+	rows = sorted("sort=count")
+	for i := 1; i < len(rows); i++ {
+		require.GreaterOrEqual(t, rows[i-1].Count, rows[i].Count)
+	}
+	rows = sorted("sort=rows_affected&order=asc")
+	for i := 1; i < len(rows); i++ {
+		require.LessOrEqual(t, rows[i-1].RowsAffected, rows[i].RowsAffected)
+	}
+	rows = sorted("sort=time&order=asc")
+	for i := 1; i < len(rows); i++ {
+		require.LessOrEqual(t, rows[i-1].Time, rows[i].Time)
+	}
+	rows = sorted("sort=bogus")
+	for i := 1; i < len(rows); i++ {
+		require.GreaterOrEqual(t, rows[i-1].TimePQ, rows[i].TimePQ)
+	}
+
+	// A limit keeps only the top rows after sorting.
+	all := sorted("sort=time")
+	require.Greater(t, len(all), 2)
+	rows = sorted("sort=time&limit=2")
+	require.Equal(t, all[:2], rows)
+	require.Equal(t, "select id from `user`", rows[0].Query)
+
+	// A filter keeps the plans whose query matches, before sorting and
+	// limiting.
+	rows = sorted("filter=" + url.QueryEscape("^insert into") + "&sort=time&order=asc")
+	require.Len(t, rows, 2)
+	require.Equal(t, "insert into `user`(id, `name`) values (:id, :name)", rows[0].Query)
+	require.Equal(t, "insert into name_user_map(`name`, user_id) values (:name_0, :user_id_0)", rows[1].Query)
+	rows = sorted("filter=" + url.QueryEscape("from `user`") + "&limit=1")
+	require.Len(t, rows, 1)
+	require.Equal(t, "select id from `user`", rows[0].Query)
+
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/queryz?filter="+url.QueryEscape("("), nil)
+	queryzHandler(executor, resp, req)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+	require.Contains(t, resp.Body.String(), "invalid filter")
+
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/queryz?limit=0", nil)
+	queryzHandler(executor, resp, req)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	// Grouped by table, the stats of all plans of a table add up.
+	want := make(map[string]queryzJSONRow)
+	for _, row := range all {
+		group := want[row.Table]
+		group.Count += row.Count
+		group.Time += row.Time
+		group.RowsReturned += row.RowsReturned
+		group.Errors += row.Errors
+		want[row.Table] = group
+	}
+	require.NotEmpty(t, want[plan1.TablesUsed[0]].Count)
+	rows = sorted("groupby=table")
+	require.Len(t, rows, len(want))
+	for _, row := range rows {
+		require.Empty(t, row.Query)
+		require.Equal(t, want[row.Table].Count, row.Count, row.Table)
+		require.Equal(t, want[row.Table].Time, row.Time, row.Table)
+		require.Equal(t, want[row.Table].RowsReturned, row.RowsReturned, row.Table)
+		require.Equal(t, want[row.Table].Errors, row.Errors, row.Table)
+	}
+
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/queryz?groupby=table", nil)
+	queryzHandler(executor, resp, req)
+	html := resp.Body.String()
+	require.Contains(t, html, "<th>Table</th>")
+	require.Contains(t, html, "<td>"+plan1.TablesUsed[0]+"</td>")
+	require.NotContains(t, html, "<th>Query</th>")
+
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/queryz?groupby=kind", nil)
+	queryzHandler(executor, resp, req)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestQueryzExcludeInternal(t *testing.T) {
+	executor, _, _, _, ctx := createExecutorEnv(t)
+
+	session := &vtgatepb.Session{TargetString: "@primary"}
+	_, err := executorExec(ctx, executor, session, "select id from user where id = 1", nil)
+	require.NoError(t, err)
+	_, err = executorExec(ctx, executor, session, "select table_name from information_schema.`tables`", nil)
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+
+	queries := func(params string) []string {
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/queryz?format=json"+params, nil)
+		queryzHandler(executor, resp, req)
+		var rows []queryzJSONRow
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &rows))
+		var queries []string
+		for _, row := range rows {
+			queries = append(queries, row.Query)
+		}
+		return queries
+	}
+	require.ElementsMatch(t, []string{
+		"select id from `user` where id = 1",
+		"select table_name from information_schema.`tables`",
+	}, queries(""))
+	require.Equal(t, []string{"select id from `user` where id = 1"}, queries("&exclude_internal=true"))
+}
+
+func TestQueryzIsInternal(t *testing.T) {
+	parser := sqlparser.NewTestParser()
+	for _, tc := range []struct {
+		query    string
+		internal bool
+	}{
+		{"select id from user", false},
+		{"select id from user join user_extra using (id)", false},
+		{"select id from _vt.vreplication", true},
+		{"select 1 from information_schema.tables", true},
+		{"select id from user where id in (select id from mysql.user)", true},
+		{"drop table _vt_hld_6ace8bcef73211ea87e9f875a4d24e90_20200915120410_", true},
+	} {
+		stmt, err := parser.Parse(tc.query)
+		require.NoError(t, err)
+		require.Equal(t, tc.internal, queryzIsInternal(stmt), tc.query)
+	}
+}
+
+func TestQueryzColor(t *testing.T) {
+	defer func(byKind bool) { queryzThresholdsByKind = byKind }(queryzThresholdsByKind)
+
+	queryzThresholdsByKind = false
+	require.Equal(t, "low", queryzColor(sqlparser.StmtSelect, 5*time.Millisecond))
+	require.Equal(t, "medium", queryzColor(sqlparser.StmtInsert, 50*time.Millisecond))
+	require.Equal(t, "high", queryzColor(sqlparser.StmtDDL, 100*time.Millisecond))
+
+	queryzThresholdsByKind = true
+	require.Equal(t, "high", queryzColor(sqlparser.StmtSelect, 100*time.Millisecond))
+	require.Equal(t, "medium", queryzColor(sqlparser.StmtUpdate, 100*time.Millisecond))
+	require.Equal(t, "low", queryzColor(sqlparser.StmtDDL, 100*time.Millisecond))
+
+	defer func(thresholds []time.Duration) { queryzWriteThresholds = thresholds }(queryzWriteThresholds)
+	queryzWriteThresholds = []time.Duration{time.Millisecond, 2 * time.Millisecond}
+	require.Equal(t, "high", queryzColor(sqlparser.StmtDelete, 5*time.Millisecond))
+}
+
+func TestValidateQueryzThresholds(t *testing.T) {
+	require.NoError(t, validateQueryzThresholds())
+
+	defer func(thresholds []time.Duration) { queryzReadThresholds = thresholds }(queryzReadThresholds)
+	queryzReadThresholds = []time.Duration{time.Millisecond}
+	require.EqualError(t, validateQueryzThresholds(), "invalid value for --queryz-read-thresholds: [1ms], want exactly two durations")
+}
+
+func TestQueryzHighErrorRate(t *testing.T) {
+	defer func(threshold float64) { queryzErrorRateThreshold = threshold }(queryzErrorRateThreshold)
+	// Plans are colored by time per query only by default.
+	require.False(t, queryzHighErrorRate(10, 10))
+
+	queryzErrorRateThreshold = 0.3
+	require.False(t, queryzHighErrorRate(0, 0))
+	require.False(t, queryzHighErrorRate(2, 10))
+	require.True(t, queryzHighErrorRate(3, 10))
+	require.True(t, queryzHighErrorRate(10, 10))
+
+	queryzErrorRateThreshold = 0
+	require.False(t, queryzHighErrorRate(10, 10))
 }
 
 func checkQueryzHasPlan(t *testing.T, planPattern []string, plan *engine.Plan, page []byte) {
@@ -161,3 +425,429 @@ func checkQueryzHasPlan(t *testing.T, planPattern []string, plan *engine.Plan, p
 		t.Fatalf("queryz page does not contain\nplan:\n%v\npattern:\n%v\npage:\n%s", plan, strings.Join(planPattern, `\s*`), string(page))
 	}
 }
+
+func TestQueryzLive(t *testing.T) {
+	executor, _, _, _, ctx := createExecutorEnv(t)
+
+	session := &vtgatepb.Session{TargetString: "@primary"}
+	_, err := executorExec(ctx, executor, session, "select id from user where id = 1", nil)
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	plan := assertCacheContains(t, executor, nil, "select id from `user` where id = 1")
+	require.Empty(t, executor.liveQueries.stats())
+
+	// Simulate two executions of the plan that are still running.
+	finished1 := executor.liveQueries.add(plan)
+	time.Sleep(10 * time.Millisecond)
+	finished2 := executor.liveQueries.add(plan)
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/queryz?live=1", nil)
+	queryzHandler(executor, resp, req)
+	body, _ := io.ReadAll(resp.Body)
+	require.Contains(t, string(body), "<th>Running</th>")
+	checkQueryzHasPlan(t, []string{
+		`<tr class="low">`,
+		"<td>select id from `user` where id = 1</td>",
+		`<td>SELECT</td>`,
+		`<td>` + plan.Fingerprint() + `</td>`,
+		`<td>2</td>`,
+		`<td>0.0[1-9][0-9]*</td>`,
+		`<td>1</td>`,
+	}, plan, body)
+
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/queryz?live=1&format=json", nil)
+	queryzHandler(executor, resp, req)
+	var rows []queryzJSONRow
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &rows))
+	require.Len(t, rows, 1)
+	require.Equal(t, 2, rows[0].Running)
+	require.GreaterOrEqual(t, rows[0].OldestRunning, 10*time.Millisecond)
+
+	finished1()
+	finished2()
+	require.Empty(t, executor.liveQueries.stats())
+
+	// Without the live parameter the running executions are not shown.
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/queryz", nil)
+	queryzHandler(executor, resp, req)
+	require.NotContains(t, resp.Body.String(), "<th>Running</th>")
+}
+
+func TestQueryzLiveStream(t *testing.T) {
+	executor, _, _, _, ctx := createExecutorEnv(t)
+
+	// The streaming execution shows as running while it sends its results.
+	session := NewSafeSession(&vtgatepb.Session{TargetString: "@primary"})
+	var running []liveQueryStats
+	err := executor.StreamExecute(ctx, nil, "TestQueryzLiveStream", session, "select id from user where id = 1", nil, func(*sqltypes.Result) error {
+		for _, st := range executor.liveQueries.stats() {
+			running = append(running, st)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, running)
+	for _, st := range running {
+		require.Equal(t, 1, st.running)
+	}
+	require.Empty(t, executor.liveQueries.stats())
+}
+
+func TestQueryzPercentiles(t *testing.T) {
+	executor, _, _, _, ctx := createExecutorEnv(t)
+
+	session := &vtgatepb.Session{TargetString: "@primary"}
+	_, err := executorExec(ctx, executor, session, "select id from user where id = 1", nil)
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	plan := assertCacheContains(t, executor, nil, "select id from `user` where id = 1")
+
+	// Replace the recorded execution with 90 fast and 10 slow ones.
+	plan.ResetStats()
+	for i := 0; i < 90; i++ {
+		plan.AddStats(1, time.Millisecond, 1, 0, 1, 0, 0)
+	}
+	for i := 0; i < 10; i++ {
+		plan.AddStats(1, 2*time.Second, 1, 0, 1, 0, 0)
+	}
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/queryz?format=json", nil)
+	queryzHandler(executor, resp, req)
+	var rows []queryzJSONRow
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &rows))
+	require.Len(t, rows, 1)
+	require.Equal(t, time.Millisecond, rows[0].P50)
+	require.Equal(t, 2500*time.Millisecond, rows[0].P95)
+	require.Equal(t, 2500*time.Millisecond, rows[0].P99)
+
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/queryz", nil)
+	queryzHandler(executor, resp, req)
+	body, _ := io.ReadAll(resp.Body)
+	checkQueryzHasPlan(t, []string{
+		`<td>0.000000</td>`,
+		`<td>0.001000</td>`,
+		`<td>2.500000</td>`,
+		`<td>2.500000</td>`,
+		`<td>[^<]+</td>`,
+		`<td>just now</td>`,
+		`</tr>`,
+	}, plan, body)
+}
+
+func TestQueryzSeen(t *testing.T) {
+	executor, _, _, _, ctx := createExecutorEnv(t)
+
+	before := time.Now()
+	session := &vtgatepb.Session{TargetString: "@primary"}
+	_, err := executorExec(ctx, executor, session, "select id from user where id = 1", nil)
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	plan := assertCacheContains(t, executor, nil, "select id from `user` where id = 1")
+	firstSeen := plan.FirstSeen()
+	require.False(t, firstSeen.Before(before))
+	require.Equal(t, firstSeen, plan.LastExecuted())
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/queryz?format=json", nil)
+	queryzHandler(executor, resp, req)
+	var rows []queryzJSONRow
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &rows))
+	require.Len(t, rows, 1)
+	require.True(t, firstSeen.Equal(*rows[0].FirstSeen))
+	require.True(t, plan.LastExecuted().Equal(*rows[0].LastExecuted))
+	require.Contains(t, resp.Body.String(), `"FirstSeen":"`+firstSeen.UTC().Format(time.RFC3339Nano)+`"`)
+
+	// Once reset, the plan shows it wasn't executed since, but keeps when
+	// it was first seen.
+	plan.ResetStats()
+	require.True(t, plan.LastExecuted().IsZero())
+	require.Equal(t, firstSeen, plan.FirstSeen())
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/queryz", nil)
+	queryzHandler(executor, resp, req)
+	body, _ := io.ReadAll(resp.Body)
+	checkQueryzHasPlan(t, []string{
+		`<td>[^<]+</td>`,
+		`<td>never</td>`,
+		`</tr>`,
+	}, plan, body)
+
+	now := time.Now()
+	require.Equal(t, "never", queryzAgo(now, time.Time{}))
+	require.Equal(t, "just now", queryzAgo(now, now.Add(-time.Millisecond)))
+	require.Equal(t, "42s ago", queryzAgo(now, now.Add(-42*time.Second)))
+	require.Equal(t, "5m ago", queryzAgo(now, now.Add(-5*time.Minute-time.Second)))
+	require.Equal(t, "3h ago", queryzAgo(now, now.Add(-3*time.Hour)))
+	require.Equal(t, "2d ago", queryzAgo(now, now.Add(-50*time.Hour)))
+}
+
+func TestQueryzCSV(t *testing.T) {
+	executor, _, _, _, ctx := createExecutorEnv(t)
+
+	session := &vtgatepb.Session{TargetString: "@primary"}
+	for _, sql := range []string{"select id from user where id = 1", "select id from user where id = 1", "select id from user", "select id from music"} {
+		_, err := executorExec(ctx, executor, session, sql, nil)
+		require.NoError(t, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	readCSV := func(query string) [][]string {
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/queryz?format=csv&"+query, nil)
+		req.Header.Set("Accept", "application/json")
+		queryzHandler(executor, resp, req)
+		require.Equal(t, "text/csv; charset=utf-8", resp.Header().Get("Content-Type"))
+		require.Equal(t, "attachment; filename=queryz.csv", resp.Header().Get("Content-Disposition"))
+		records, err := csv.NewReader(resp.Body).ReadAll()
+		require.NoError(t, err)
+		return records
+	}
+
+	// The rows are sorted and filtered like the HTML table, and have the
+	// same columns.
+	records := readCSV("sort=count&filter=" + url.QueryEscape("from `user`"))
+	require.Len(t, records, 3)
+	require.Equal(t, queryzCSVHeader(false, false), records[0])
+	require.Equal(t, []string{"Query", "Kind", "Fingerprint", "Count"}, records[0][:4])
+	require.Equal(t, "Last executed", records[0][len(records[0])-1])
+	require.Equal(t, []string{"select id from `user` where id = 1", "SELECT"}, records[1][:2])
+	require.Equal(t, "2", records[1][3])
+	require.Equal(t, "1.000000", records[1][11])
+	require.Equal(t, "select id from `user`", records[2][0])
+	require.Equal(t, "1", records[2][3])
+	require.Equal(t, "8", records[2][5])
+	require.Equal(t, "8.000000", records[2][11])
+	for _, record := range records[1:] {
+		require.Len(t, record, len(records[0]))
+	}
+
+	records = readCSV("live=1&limit=1")
+	require.Len(t, records, 2)
+	require.Equal(t, []string{"Query", "Kind", "Fingerprint", "Running", "Oldest Running"}, records[0][:5])
+	require.Equal(t, "0", records[1][3])
+
+	records = readCSV("groupby=table&sort=count")
+	require.Equal(t, []string{"Table", "Count", "Time", "Shard Queries"}, records[0][:4])
+	require.Len(t, records, 3)
+	require.Equal(t, []string{"TestExecutor.user", "3"}, records[1][:2])
+	require.Equal(t, []string{"TestExecutor.music", "1"}, records[2][:2])
+}
+
+func TestQueryzShardQueriesRange(t *testing.T) {
+	executor, _, _, _, ctx := createExecutorEnv(t)
+
+	session := &vtgatepb.Session{TargetString: "@primary"}
+	for _, sql := range []string{"select id from user where id = 1", "select id from user"} {
+		_, err := executorExec(ctx, executor, session, sql, nil)
+		require.NoError(t, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	plan := assertCacheContains(t, executor, nil, "select id from `user` where id = 1")
+	minQueries, maxQueries := plan.ShardQueriesRange()
+	require.EqualValues(t, 1, minQueries)
+	require.EqualValues(t, 1, maxQueries)
+
+	// An execution that scattered widens the range, while the average
+	// barely moves.
+	plan.AddStats(1, time.Millisecond, 8, 0, 0, 0, 0)
+	plan.AddStats(3, time.Millisecond, 3, 0, 0, 0, 0)
+	minQueries, maxQueries = plan.ShardQueriesRange()
+	require.EqualValues(t, 1, minQueries)
+	require.EqualValues(t, 8, maxQueries)
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/queryz?format=json&sort=max_shard_queries&order=asc", nil)
+	queryzHandler(executor, resp, req)
+	var rows []queryzJSONRow
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &rows))
+	require.Len(t, rows, 2)
+	require.Equal(t, "select id from `user` where id = 1", rows[1].Query)
+	require.EqualValues(t, 1, rows[1].MinShardQueries)
+	require.EqualValues(t, 8, rows[1].MaxShardQueries)
+	require.Equal(t, "select id from `user`", rows[0].Query)
+	require.EqualValues(t, 8, rows[0].MinShardQueries)
+	require.EqualValues(t, 8, rows[0].MaxShardQueries)
+
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/queryz?format=json&groupby=table", nil)
+	queryzHandler(executor, resp, req)
+	rows = nil
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &rows))
+	require.Len(t, rows, 1)
+	require.EqualValues(t, 1, rows[0].MinShardQueries)
+	require.EqualValues(t, 8, rows[0].MaxShardQueries)
+
+	// Executions without shard queries count, and a reset clears the range.
+	plan.AddStats(1, time.Millisecond, 0, 0, 0, 0, 0)
+	minQueries, _ = plan.ShardQueriesRange()
+	require.EqualValues(t, 0, minQueries)
+	plan.ResetStats()
+	minQueries, maxQueries = plan.ShardQueriesRange()
+	require.EqualValues(t, 0, minQueries)
+	require.EqualValues(t, 0, maxQueries)
+}
+
+func TestQueryzReset(t *testing.T) {
+	executor, _, _, _, ctx := createExecutorEnv(t)
+
+	session := &vtgatepb.Session{TargetString: "@primary"}
+	_, err := executorExec(ctx, executor, session, "select id from user where id = 1", nil)
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	plan := assertCacheContains(t, executor, nil, "select id from `user` where id = 1")
+	count, _, _, _, _, _, _ := plan.Stats()
+	require.EqualValues(t, 1, count)
+
+	// A GET doesn't reset anything.
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/queryz/reset", nil)
+	queryzResetHandler(executor, resp, req)
+	require.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+	count, _, _, _, _, _, _ = plan.Stats()
+	require.EqualValues(t, 1, count)
+
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/queryz/reset", nil)
+	queryzResetHandler(executor, resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	plans := 0
+	executor.ForEachPlan(func(*engine.Plan) bool {
+		plans++
+		return true
+	})
+	require.Equal(t, fmt.Sprintf("%d\n", plans), resp.Body.String())
+	count, execTime, shardQueries, _, rowsReturned, _, _ := plan.Stats()
+	require.Zero(t, count)
+	require.Zero(t, execTime)
+	require.Zero(t, shardQueries)
+	require.Zero(t, rowsReturned)
+}
+
+func TestQueryzMetrics(t *testing.T) {
+	executor, _, _, _, ctx := createExecutorEnv(t)
+
+	session := &vtgatepb.Session{TargetString: "@primary"}
+	for i := 0; i < 3; i++ {
+		_, err := executorExec(ctx, executor, session, "select id from user where id = 1", nil)
+		require.NoError(t, err)
+	}
+	for i := 0; i < 2; i++ {
+		_, err := executorExec(ctx, executor, session, "select id from user", nil)
+		require.NoError(t, err)
+	}
+	_, err := executorExec(ctx, executor, session, "select id from music_user_map", nil)
+	require.NoError(t, err)
+	_, err = executorExec(ctx, executor, session, "select id from user_extra", nil)
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+
+	// The two plans executed most get their own series, the others are
+	// added up.
+	metrics := executor.queryzMetrics(2)
+	require.Len(t, metrics, 3)
+	require.EqualValues(t, 3, metrics["select id from `user` where id = 1"].count)
+	require.EqualValues(t, 2, metrics["select id from `user`"].count)
+	require.EqualValues(t, 2, metrics[queryzMetricsOther].count)
+	require.EqualValues(t, 3, metrics["select id from `user` where id = 1"].rowsReturned)
+
+	metrics = executor.queryzMetrics(0)
+	require.Len(t, metrics, 1)
+	require.EqualValues(t, 7, metrics[queryzMetricsOther].count)
+
+	fingerprint := func() string { return "0123456789abcdef" }
+	require.Equal(t, "select a_b from t", queryzMetricsLabel("select a.b from t", fingerprint))
+	require.Equal(t, strings.Repeat("é", queryzMetricsQueryLength)+" 0123456789abcdef", queryzMetricsLabel(strings.Repeat("é", 200), fingerprint))
+
+	// The queries that only differ after the cut keep series of their own.
+	prefix := "select id from user where " + strings.Repeat("id = 1 and ", 10)
+	for _, query := range []string{prefix + "id = 2", prefix + "id = 3"} {
+		_, err := executorExec(ctx, executor, session, query, nil)
+		require.NoError(t, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	metrics = executor.queryzMetrics(10)
+	var long []string
+	for label := range metrics {
+		if len(label) > queryzMetricsQueryLength {
+			long = append(long, label)
+		}
+	}
+	require.Len(t, long, 2)
+	require.NotEqual(t, long[0], long[1])
+}
+
+func TestQueryzMetricsSnapshot(t *testing.T) {
+	computed := 0
+	snapshot := &queryzMetricsSnapshot{compute: func() map[string]*queryzMetric {
+		computed++
+		return map[string]*queryzMetric{queryzMetricsOther: {count: int64(computed)}}
+	}}
+
+	// The gauges of a scrape share one snapshot.
+	require.EqualValues(t, 1, snapshot.get()[queryzMetricsOther].count)
+	require.EqualValues(t, 1, snapshot.get()[queryzMetricsOther].count)
+	require.Equal(t, 1, computed)
+
+	// The next scrape computes a new one.
+	snapshot.taken = time.Now().Add(-queryzMetricsSnapshotTTL)
+	require.EqualValues(t, 2, snapshot.get()[queryzMetricsOther].count)
+	require.Equal(t, 2, computed)
+}
+
+func TestQueryzErrorCodes(t *testing.T) {
+	defer func(threshold float64) { queryzErrorRateThreshold = threshold }(queryzErrorRateThreshold)
+	queryzErrorRateThreshold = 0.1
+	executor, sbc1, _, _, ctx := createExecutorEnv(t)
+
+	session := &vtgatepb.Session{TargetString: "@primary"}
+	sql := "select id from user where id = 1"
+	for i := 0; i < 2; i++ {
+		sbc1.EphemeralShardErr = sqlerror.NewSQLError(sqlerror.ERLockDeadlock, sqlerror.SSLockDeadlock, "deadlock")
+		_, err := executorExec(ctx, executor, session, sql, nil)
+		require.Error(t, err)
+	}
+	sbc1.EphemeralShardErr = sqlerror.NewSQLError(sqlerror.ERAccessDeniedError, sqlerror.SSAccessDeniedError, "denied")
+	_, err := executorExec(ctx, executor, session, sql, nil)
+	require.Error(t, err)
+	sbc1.EphemeralShardErr = nil
+	_, err = executorExec(ctx, executor, session, sql, nil)
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	plan := assertCacheContains(t, executor, nil, "select id from `user` where id = 1")
+
+	wantCodes := map[string]uint64{
+		sqlerror.ERLockDeadlock.ToString():      2,
+		sqlerror.ERAccessDeniedError.ToString(): 1,
+	}
+	require.Equal(t, wantCodes, plan.ErrorCodes())
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/queryz?format=json", nil)
+	queryzHandler(executor, resp, req)
+	var rows []queryzJSONRow
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &rows))
+	require.Len(t, rows, 1)
+	require.EqualValues(t, 3, rows[0].Errors)
+	require.Equal(t, wantCodes, rows[0].ErrorCodes)
+
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/queryz", nil)
+	queryzHandler(executor, resp, req)
+	body, _ := io.ReadAll(resp.Body)
+	checkQueryzHasPlan(t, []string{
+		`<td>3<details><summary>by code</summary>1045: 1<br>1213: 2<br></details></td>`,
+	}, plan, body)
+	// The plan is fast, but most of its executions failed.
+	checkQueryzHasPlan(t, []string{
+		`<tr class="high">`,
+		"<td>select id from `user` where id = 1</td>",
+	}, plan, body)
+
+	plan.ResetStats()
+	require.Empty(t, plan.ErrorCodes())
+}
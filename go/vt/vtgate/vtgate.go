@@ -153,6 +153,13 @@ func registerFlags(fs *pflag.FlagSet) {
 	fs.IntVar(&warmingReadsPercent, "warming-reads-percent", 0, "Percentage of reads on the primary to forward to replicas. Useful for keeping buffer pools warm")
 	fs.IntVar(&warmingReadsConcurrency, "warming-reads-concurrency", 500, "Number of concurrent warming reads allowed")
 	fs.DurationVar(&warmingReadsQueryTimeout, "warming-reads-query-timeout", 5*time.Second, "Timeout of warming read queries")
+	fs.DurationSliceVar(&queryzThresholds, "queryz-thresholds", queryzThresholds, "Time per query from which a plan is colored medium and high on /debug/queryz")
+	fs.BoolVar(&queryzThresholdsByKind, "queryz-thresholds-by-kind", queryzThresholdsByKind, "Color plans on /debug/queryz with the thresholds of their statement kind instead of --queryz-thresholds")
+	fs.DurationSliceVar(&queryzReadThresholds, "queryz-read-thresholds", queryzReadThresholds, "Time per query from which a read plan is colored medium and high on /debug/queryz, with --queryz-thresholds-by-kind")
+	fs.DurationSliceVar(&queryzWriteThresholds, "queryz-write-thresholds", queryzWriteThresholds, "Time per query from which a write plan is colored medium and high on /debug/queryz, with --queryz-thresholds-by-kind")
+	fs.DurationSliceVar(&queryzOtherThresholds, "queryz-other-thresholds", queryzOtherThresholds, "Time per query from which any other plan is colored medium and high on /debug/queryz, with --queryz-thresholds-by-kind")
+	fs.Float64Var(&queryzErrorRateThreshold, "queryz-error-rate-threshold", queryzErrorRateThreshold, "Errors per query from which a plan is colored high on /debug/queryz whatever its time per query, or 0 to color plans by time per query only")
+	fs.IntVar(&queryzMetricsMaxQueries, "queryz-metrics-max-queries", queryzMetricsMaxQueries, `Number of plans, by execution count, that get their own series in the QueryPlan* metrics; the others are added up in the "other" series`)
 }
 
 func init() {
@@ -274,6 +281,9 @@ func Init(
 	if _, err := schema.ParseDDLStrategy(defaultDDLStrategy); err != nil {
 		log.Fatalf("Invalid value for -ddl_strategy: %v", err.Error())
 	}
+	if err := validateQueryzThresholds(); err != nil {
+		log.Fatalf("%v", err)
+	}
 	tc := NewTxConn(gw, getTxMode())
 	// ScatterConn depends on TxConn to perform forced rollbacks.
 	sc := NewScatterConn("VttabletCall", tc, gw)
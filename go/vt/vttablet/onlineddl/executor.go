@@ -283,7 +283,7 @@ func NewExecutor(env tabletenv.Env, tabletAlias *topodatapb.TabletAlias, ts *top
 }
 
 func (e *Executor) executeQuery(ctx context.Context, query string) (result *sqltypes.Result, err error) {
-	defer e.env.LogError()
+	defer e.env.LogErrorWithContext(ctx)
 
 	conn, err := e.pool.Get(ctx, nil)
 	if err != nil {
@@ -295,7 +295,7 @@ func (e *Executor) executeQuery(ctx context.Context, query string) (result *sqlt
 }
 
 func (e *Executor) executeQueryWithSidecarDBReplacement(ctx context.Context, query string) (result *sqltypes.Result, err error) {
-	defer e.env.LogError()
+	defer e.env.LogErrorWithContext(ctx)
 
 	conn, err := e.pool.Get(ctx, nil)
 	if err != nil {
@@ -23,6 +23,7 @@ import (
 
 	"vitess.io/vitess/go/vt/vterrors"
 
+	"vitess.io/vitess/go/vt/dbconfigs"
 	"vitess.io/vitess/go/vt/hook"
 	"vitess.io/vitess/go/vt/mysqlctl"
 	"vitess.io/vitess/go/vt/topotools"
@@ -132,13 +133,16 @@ func (tm *TabletManager) ExecuteHook(ctx context.Context, hk *hook.Hook) *hook.H
 	return hk.Execute()
 }
 
-// RefreshState reload the tablet record from the topo server.
+// RefreshState reload the tablet record from the topo server. It also drops
+// the cached DB credentials, so that new connections, e.g. the ones of the
+// restarted VReplication streams, use the rotated ones.
 func (tm *TabletManager) RefreshState(ctx context.Context) error {
 	if err := tm.lock(ctx); err != nil {
 		return err
 	}
 	defer tm.unlock()
 
+	dbconfigs.ReloadCredentials()
 	return tm.tmState.RefreshFromTopo(ctx)
 }
 
@@ -99,7 +99,7 @@ func newController(ctx context.Context, params map[string]string, dbClientFactor
 	ct.lastWorkflowError = vterrors.NewLastError(fmt.Sprintf("VReplication controller %d for workflow %q", ct.id, ct.workflow), maxTimeToRetryError)
 
 	state := params["state"]
-	blpStats.State.Store(state)
+	blpStats.SetState(state)
 	if err := prototext.Unmarshal([]byte(params["source"]), ct.source); err != nil {
 		return nil, err
 	}
@@ -165,7 +165,7 @@ func (ct *controller) run(ctx context.Context) {
 		default:
 		}
 
-		ct.blpStats.ErrorCounts.Add([]string{"Stream Error"}, 1)
+		ct.blpStats.RecordError("Stream Error", err)
 		binlogplayer.LogError(fmt.Sprintf("error in stream %v, will retry after %v", ct.id, retryDelay), err)
 		timer := time.NewTimer(retryDelay)
 		select {
@@ -209,7 +209,7 @@ func (ct *controller) runBlp(ctx context.Context) (err error) {
 		// Table names can have search patterns. Resolve them against the schema.
 		tables, err := mysqlctl.ResolveTables(ctx, ct.mysqld, dbClient.DBName(), ct.source.Tables)
 		if err != nil {
-			ct.blpStats.ErrorCounts.Add([]string{"Invalid Source"}, 1)
+			ct.blpStats.RecordError("Invalid Source", err)
 			return vterrors.Wrap(err, "failed to resolve table names")
 		}
 
@@ -277,8 +277,9 @@ func (ct *controller) runBlp(ctx context.Context) (err error) {
 		}
 		return err
 	}
-	ct.blpStats.ErrorCounts.Add([]string{"Invalid Source"}, 1)
-	return fmt.Errorf("missing source")
+	err = fmt.Errorf("missing source")
+	ct.blpStats.RecordError("Invalid Source", err)
+	return err
 }
 
 func (ct *controller) setMessage(dbClient binlogplayer.DBClient, message string) error {
@@ -309,7 +310,7 @@ func (ct *controller) pickSourceTablet(ctx context.Context, dbClient binlogplaye
 		select {
 		case <-ctx.Done():
 		default:
-			ct.blpStats.ErrorCounts.Add([]string{"No Source Tablet Found"}, 1)
+			ct.blpStats.RecordError("No Source Tablet Found", err)
 			ct.setMessage(dbClient, fmt.Sprintf("Error picking tablet: %s", err.Error()))
 		}
 		return tablet, err
@@ -17,17 +17,26 @@ limitations under the License.
 package vreplication
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/google/safehtml/template"
+	"github.com/google/safehtml/template/uncheckedconversions"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"vitess.io/vitess/go/acl"
 	"vitess.io/vitess/go/vt/binlog/binlogplayer"
+	"vitess.io/vitess/go/vt/log"
 
 	"vitess.io/vitess/go/stats"
 	"vitess.io/vitess/go/vt/servenv"
 
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 )
 
@@ -49,6 +58,163 @@ func AddStatusPart() {
 	servenv.AddStatusPart("VReplication", vreplicationTemplate, func() any {
 		return globalStats.status()
 	})
+	servenv.HTTPHandleFunc("/debug/vreplication", func(w http.ResponseWriter, r *http.Request) {
+		vreplicationStatusHandler(globalStats, w, r)
+	})
+	servenv.HTTPHandleFunc("/debug/vreplication/workflows", func(w http.ResponseWriter, r *http.Request) {
+		workflowStatusHandler(globalStats, w, r)
+	})
+	servenv.HTTPHandleFunc("/debug/vreplication/export", func(w http.ResponseWriter, r *http.Request) {
+		debugArchiveHandler(globalStats, w, r)
+	})
+}
+
+// statusColumn is a column of the VReplication status table.
+type statusColumn struct {
+	name   string
+	header string
+	cell   string
+}
+
+// statusColumns lists the columns of the VReplication status table, in
+// rendering order. The cells match the ones in vreplicationTemplate.
+var statusColumns = []statusColumn{
+	{"index", "Index", "{{.Index}}"},
+	{"source", "Source", "{{.Source}}"},
+	{"source_tablet", "Source Tablet", "{{.SourceTablet}}"},
+	{"state", "State", "{{.State}}"},
+	{"stop_position", "Stop Position", "{{.StopPosition}}"},
+	{"last_position", "Last Position", "{{.LastPosition}}"},
+	{"lag", "VReplication Lag", "{{.ReplicationLagSeconds}}"},
+	{"read_lag", "Source Read Lag", "{{.SourceReadLagSeconds}}"},
+	{"heartbeat_age", "Heartbeat Age", "{{if .Heartbeat}}{{.HeartbeatAge}}{{else}}never{{end}}"},
+	{"counts", "Counts", "{{range $key, $value := .Counts}}<b>{{$key}}</b>: {{$value}}<br>{{end}}"},
+	{"rates", "Rates", "{{range $key, $values := .Rates}}<b>{{$key}}</b>: {{range $values}}{{.}} {{end}}<br>{{end}}"},
+	{"batch_size", "Apply Batch Size (max/avg/largest)", "{{.ApplyBatchSize}}/{{.AvgApplyBatchSize}}/{{.MaxApplyBatchSize}}"},
+	{"errors", "Errors (1m/5m/15m)", "{{.ErrorsLast1m}}/{{.ErrorsLast5m}}/{{.ErrorsLast15m}}"},
+	{"copy_tables", "Copy Progress", "{{range $table, $status := .CopyTables}}<b>{{$table}}</b>: {{$status.State}} ({{$status.RowsCopied}} rows)<br>{{end}}"},
+	{"last_error", "Last Error", "{{if .LastError}}{{.LastErrorTime.Format \"2006-01-02 15:04:05\"}}: {{.LastError}}{{end}}"},
+	{"copy_eta", "Copy ETA", "{{if .CopyETA}}{{.CopyETA}}{{else if .CopyRowsPerSecond}}{{printf \"%.1f\" .CopyRowsPerSecond}} rows/s{{end}}"},
+	{"throttled", "Throttled", "{{if .TimeThrottled}}{{if .Throttled}}Yes{{else}}No{{end}}, last by {{.ComponentThrottled}} at {{.TimeThrottled}}{{else}}No{{end}}"},
+	{"messages", "Last Message", "{{range $index, $value := .Messages}}{{$value}}<br>{{end}}"},
+}
+
+// defaultStatusColumns are the columns rendered by /debug/vreplication
+// when no cols parameter is given. They fit on a narrow screen.
+var defaultStatusColumns = []string{"index", "source", "state", "lag", "errors", "messages"}
+
+// statusTableTemplate builds a template rendering the VReplication status
+// table with only the named columns.
+func statusTableTemplate(cols []string) (*template.Template, error) {
+	visible := make(map[string]bool, len(cols))
+	for _, col := range cols {
+		visible[strings.TrimSpace(col)] = true
+	}
+	var header, row strings.Builder
+	for _, col := range statusColumns {
+		if !visible[col.name] {
+			continue
+		}
+		delete(visible, col.name)
+		fmt.Fprintf(&header, "    <th>%s</th>\n", col.header)
+		fmt.Fprintf(&row, "      <td>%s</td>\n", col.cell)
+	}
+	if len(visible) > 0 {
+		unknown := make([]string, 0, len(visible))
+		for col := range visible {
+			unknown = append(unknown, col)
+		}
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown columns: %s", strings.Join(unknown, ", "))
+	}
+	// The template is only assembled from the constant column definitions
+	// above, so it is as trusted as vreplicationTemplate.
+	return template.New("vreplication").ParseFromTrustedTemplate(uncheckedconversions.TrustedTemplateFromStringKnownToSatisfyTypeContract(`{{if .IsOpen}}VReplication state: Open</br>
+<table>
+  <tr>
+` + header.String() + `  </tr>
+  {{range .Controllers}}<tr>
+` + row.String() + `    </tr>{{end}}
+</table>{{else}}VReplication is closed.{{end}}
+`))
+}
+
+// vreplicationStatusHandler renders the VReplication status table. The
+// cols parameter is a comma-separated list of the columns to render, and
+// the workflow and state parameters only keep the controllers of that
+// workflow or in that state. With format=json, the full status of the
+// controllers is returned as JSON instead.
+func vreplicationStatusHandler(st *vrStats, w http.ResponseWriter, r *http.Request) {
+	if err := acl.CheckAccessHTTP(r, acl.DEBUGGING); err != nil {
+		acl.SendError(w, err)
+		return
+	}
+	status := st.filteredStatus(r.FormValue("workflow"), r.FormValue("state"))
+	if r.FormValue("format") == "json" {
+		js, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(js)
+		return
+	}
+	cols := defaultStatusColumns
+	if v := r.FormValue("cols"); v != "" {
+		cols = strings.Split(v, ",")
+	}
+	tmpl, err := statusTableTemplate(cols)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, status); err != nil {
+		log.Errorf("vreplication: couldn't execute status template: %v", err)
+	}
+}
+
+// workflowStatusHandler renders the status of the workflows of the tablet,
+// as an HTML table or as JSON if format=json is given.
+func workflowStatusHandler(st *vrStats, w http.ResponseWriter, r *http.Request) {
+	if err := acl.CheckAccessHTTP(r, acl.DEBUGGING); err != nil {
+		acl.SendError(w, err)
+		return
+	}
+	workflows := st.workflowStatus()
+	if r.FormValue("format") == "json" {
+		js, err := json.MarshalIndent(workflows, "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(js)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := workflowStatusTemplate.Execute(w, workflows); err != nil {
+		log.Errorf("vreplication: couldn't execute workflow status template: %v", err)
+	}
+}
+
+// debugArchiveHandler serves everything known about the streams of the
+// tablet as a JSON file download, to attach to support tickets.
+func debugArchiveHandler(st *vrStats, w http.ResponseWriter, r *http.Request) {
+	if err := acl.CheckAccessHTTP(r, acl.DEBUGGING); err != nil {
+		acl.SendError(w, err)
+		return
+	}
+	archive := st.debugArchive()
+	js, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=vreplication-%s.json", archive.Time.UTC().Format("20060102T150405Z")))
+	w.Write(js)
 }
 
 // vrStats exports the stats for Engine. It's a separate structure to
@@ -97,6 +263,20 @@ func (st *vrStats) register() {
 			return result
 		})
 
+	stats.NewGaugesFuncWithMultiLabels(
+		"VReplicationSourceReadLagSeconds",
+		"vreplication seconds behind the source of the events read, applied or not",
+		[]string{"source_keyspace", "source_shard", "workflow", "counts"},
+		func() map[string]int64 {
+			st.mu.Lock()
+			defer st.mu.Unlock()
+			result := make(map[string]int64, len(st.controllers))
+			for _, ct := range st.controllers {
+				result[ct.source.Keyspace+"."+ct.source.Shard+"."+ct.workflow+"."+fmt.Sprintf("%v", ct.id)] = ct.blpStats.SourceReadLagSeconds.Load()
+			}
+			return result
+		})
+
 	stats.NewCounterFunc(
 		"VReplicationLagSecondsTotal",
 		"vreplication seconds behind primary aggregated across all streams",
@@ -534,28 +714,53 @@ func (st *vrStats) status() *EngineStatus {
 	for _, ct := range st.controllers {
 		status.Controllers[i] = &ControllerStatus{
 			Index:                 ct.id,
+			Workflow:              ct.workflow,
 			Source:                ct.source.String(),
+			SourceKeyspace:        ct.source.GetKeyspace(),
+			SourceShard:           ct.source.GetShard(),
 			StopPosition:          ct.stopPos,
 			LastPosition:          ct.blpStats.LastPosition().String(),
 			Heartbeat:             ct.blpStats.Heartbeat(),
+			HeartbeatAge:          ct.blpStats.HeartbeatAge().Truncate(time.Second),
 			ReplicationLagSeconds: ct.blpStats.ReplicationLagSeconds.Load(),
+			ReplicationLags:       ct.blpStats.ReplicationLags.Counts(),
+			SourceReadLagSeconds:  ct.blpStats.SourceReadLagSeconds.Load(),
 			Counts:                ct.blpStats.Timings.Counts(),
+			StateTransitions:      ct.blpStats.StateTransitions.Counts(),
 			Rates:                 ct.blpStats.Rates.Get(),
 			SourceTablet:          ct.sourceTablet.Load().(*topodatapb.TabletAlias),
 			Messages:              ct.blpStats.MessageHistory(),
 			QueryCounts:           ct.blpStats.QueryCount.Counts(),
 			BulkQueryCounts:       ct.blpStats.BulkQueryCount.Counts(),
 			TrxQueryBatchCounts:   ct.blpStats.TrxQueryBatchCount.Counts(),
+			ApplyBatchSize:        ct.blpStats.ApplyBatchSize.Load(),
+			AvgApplyBatchSize:     ct.blpStats.AvgApplyBatchBytes(),
+			MaxApplyBatchSize:     ct.blpStats.MaxApplyBatchBytes.Load(),
 			PhaseTimings:          ct.blpStats.PhaseTimings.Counts(),
 			CopyRowCount:          ct.blpStats.CopyRowCount.Get(),
 			CopyLoopCount:         ct.blpStats.CopyLoopCount.Get(),
+			BytesReceived:         ct.blpStats.BytesReceived.Get(),
+			BytesApplied:          ct.blpStats.BytesApplied.Get(),
 			NoopQueryCounts:       ct.blpStats.NoopQueryCount.Counts(),
 			TableCopyTimings:      ct.blpStats.TableCopyTimings.Counts(),
+			CopyTables:            tableCopyStatus(ct.blpStats),
+			ErrorsLast1m:          ct.blpStats.ErrorRate.Count(1),
+			ErrorsLast5m:          ct.blpStats.ErrorRate.Count(5),
+			ErrorsLast15m:         ct.blpStats.ErrorRate.Count(15),
 		}
 		state := ct.blpStats.State.Load()
 		if state != nil {
 			status.Controllers[i].State = state.(string)
 		}
+		status.Controllers[i].Throttled, status.Controllers[i].ComponentThrottled, status.Controllers[i].TimeThrottled = ct.blpStats.ThrottleState()
+		status.Controllers[i].LastError, status.Controllers[i].LastErrorTime = ct.blpStats.LastError()
+		if status.Controllers[i].State == binlogdatapb.VReplicationWorkflowState_Copying.String() {
+			rate := ct.blpStats.CopyRate.RowsPerSecond()
+			estimate := ct.blpStats.CopyRowsEstimate.Load()
+			status.Controllers[i].CopyRowsPerSecond = rate
+			status.Controllers[i].CopyRowsEstimate = estimate
+			status.Controllers[i].CopyETA = copyETA(rate, status.Controllers[i].CopyRowCount, estimate)
+		}
 
 		i++
 	}
@@ -563,6 +768,146 @@ func (st *vrStats) status() *EngineStatus {
 	return status
 }
 
+// filteredStatus returns the status of the controllers of the given
+// workflow, whose name is case-sensitive like in _vt.vreplication, and in
+// the given state, compared case-insensitively. An empty workflow or state
+// matches every controller.
+func (st *vrStats) filteredStatus(workflow, state string) *EngineStatus {
+	status := st.status()
+	if workflow == "" && state == "" {
+		return status
+	}
+	controllers := status.Controllers[:0]
+	for _, ct := range status.Controllers {
+		if workflow != "" && ct.Workflow != workflow {
+			continue
+		}
+		if state != "" && !strings.EqualFold(ct.State, state) {
+			continue
+		}
+		controllers = append(controllers, ct)
+	}
+	status.Controllers = controllers
+	return status
+}
+
+// copyETA estimates how long copying the remaining of the estimated rows
+// takes at the given rate. It returns zero if the estimate or the rate is
+// unknown.
+func copyETA(rowsPerSecond float64, copied, estimate int64) time.Duration {
+	if estimate <= 0 || rowsPerSecond <= 0 {
+		return 0
+	}
+	remaining := max(estimate-copied, 0)
+	return (time.Duration(float64(remaining)/rowsPerSecond) * time.Second).Truncate(time.Second)
+}
+
+// tableCopyStatus returns the copy state and the number of rows copied of
+// each table seen during the copy phase of a controller.
+func tableCopyStatus(blpStats *binlogplayer.Stats) map[string]*TableCopyStatus {
+	states := blpStats.TableCopyStates()
+	if len(states) == 0 {
+		return nil
+	}
+	rows := blpStats.TableCopyRowCounts.Counts()
+	tables := make(map[string]*TableCopyStatus, len(states))
+	for table, state := range states {
+		tables[table] = &TableCopyStatus{State: state, RowsCopied: rows[table]}
+	}
+	return tables
+}
+
+// workflowStatus rolls the status of the controllers up by workflow.
+func (st *vrStats) workflowStatus() []*WorkflowStatus {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	byWorkflow := make(map[string]*WorkflowStatus)
+	for _, ct := range st.controllers {
+		ws, ok := byWorkflow[ct.workflow]
+		if !ok {
+			ws = &WorkflowStatus{Workflow: ct.workflow}
+			byWorkflow[ct.workflow] = ws
+		}
+		state, _ := ct.blpStats.State.Load().(string)
+		switch {
+		case ws.Streams == 0:
+			ws.State = "all-" + strings.ToLower(state)
+		case ws.State != "all-"+strings.ToLower(state):
+			ws.State = "mixed"
+		}
+		ws.Streams++
+		if lag := ct.blpStats.ReplicationLagSeconds.Load(); lag > ws.MaxReplicationLagSeconds {
+			ws.MaxReplicationLagSeconds = lag
+		}
+	}
+	workflows := make([]*WorkflowStatus, 0, len(byWorkflow))
+	for _, ws := range byWorkflow {
+		workflows = append(workflows, ws)
+	}
+	sort.Slice(workflows, func(i, j int) bool { return workflows[i].Workflow < workflows[j].Workflow })
+	return workflows
+}
+
+// debugArchive returns the status of every stream along with its source and
+// its recent history.
+func (st *vrStats) debugArchive() *DebugArchive {
+	status := st.status()
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	archive := &DebugArchive{Time: time.Now(), IsOpen: status.IsOpen}
+	for _, cs := range status.Controllers {
+		ct, ok := st.controllers[cs.Index]
+		if !ok {
+			// The stream was removed since its status was taken.
+			continue
+		}
+		info := &StreamDebugInfo{Workflow: ct.workflow, Status: cs}
+		if ct.source != nil {
+			source, err := protojson.Marshal(ct.source)
+			if err != nil {
+				log.Errorf("vreplication: couldn't marshal the source of stream %d: %v", ct.id, err)
+			} else {
+				info.BinlogSource = source
+			}
+		}
+		for _, record := range ct.blpStats.History.Records() {
+			if h, ok := record.(*binlogplayer.StatsHistoryRecord); ok {
+				info.History = append(info.History, h)
+			}
+		}
+		archive.Streams = append(archive.Streams, info)
+	}
+	return archive
+}
+
+// DebugArchive is everything known about the VReplication streams of the
+// tablet at a point in time.
+type DebugArchive struct {
+	Time    time.Time
+	IsOpen  bool
+	Streams []*StreamDebugInfo
+}
+
+// StreamDebugInfo is the status, source and recent history of a stream.
+type StreamDebugInfo struct {
+	Workflow     string
+	Status       *ControllerStatus
+	BinlogSource json.RawMessage `json:",omitempty"`
+	History      []*binlogplayer.StatsHistoryRecord
+}
+
+// WorkflowStatus is the status of the streams of a workflow on this tablet.
+// State is "all-" followed by the state of the streams, like all-running,
+// if they are all in the same state, and "mixed" otherwise.
+type WorkflowStatus struct {
+	Workflow                 string
+	Streams                  int
+	State                    string
+	MaxReplicationLagSeconds int64
+}
+
 // EngineStatus contains a renderable status of the Engine.
 type EngineStatus struct {
 	IsOpen      bool
@@ -572,27 +917,73 @@ type EngineStatus struct {
 // ControllerStatus contains a renderable status of a controller.
 type ControllerStatus struct {
 	Index                 int32
+	Workflow              string
 	Source                string
+	SourceKeyspace        string
 	SourceShard           string
 	StopPosition          string
 	LastPosition          string
 	Heartbeat             int64
+	HeartbeatAge          time.Duration
 	ReplicationLagSeconds int64
+	ReplicationLags       map[string]int64
+	SourceReadLagSeconds  int64
 	Counts                map[string]int64
 	Rates                 map[string][]float64
 	State                 string
+	StateTransitions      map[string]int64
 	SourceTablet          *topodatapb.TabletAlias
 	Messages              []string
 	QueryCounts           map[string]int64
 	BulkQueryCounts       map[string]int64
 	TrxQueryBatchCounts   map[string]int64
+	ApplyBatchSize        int64
+	AvgApplyBatchSize     int64
+	MaxApplyBatchSize     int64
 	PhaseTimings          map[string]int64
 	CopyRowCount          int64
 	CopyLoopCount         int64
+	CopyRowsPerSecond     float64
+	CopyRowsEstimate      int64
+	CopyETA               time.Duration
+	BytesReceived         int64
+	BytesApplied          int64
 	NoopQueryCounts       map[string]int64
 	TableCopyTimings      map[string]int64
+	CopyTables            map[string]*TableCopyStatus
+	Throttled             bool
+	ComponentThrottled    string
+	TimeThrottled         int64
+	ErrorsLast1m          int64
+	ErrorsLast5m          int64
+	ErrorsLast15m         int64
+	LastError             string
+	LastErrorTime         time.Time
 }
 
+// TableCopyStatus is the copy progress of a table of a controller.
+type TableCopyStatus struct {
+	State      string
+	RowsCopied int64
+}
+
+var workflowStatusTemplate = template.Must(template.New("workflows").Parse(`
+<table>
+  <tr>
+    <th>Workflow</th>
+    <th>Streams</th>
+    <th>State</th>
+    <th>Max VReplication Lag</th>
+  </tr>
+  {{range .}}<tr>
+      <td>{{.Workflow}}</td>
+      <td>{{.Streams}}</td>
+      <td>{{.State}}</td>
+      <td>{{.MaxReplicationLagSeconds}}</td>
+    </tr>{{end}}
+</table>
+`))
+
 const vreplicationTemplate = `
 {{if .IsOpen}}VReplication state: Open</br>
 <table>
@@ -606,6 +997,9 @@ const vreplicationTemplate = `
     <th>VReplication Lag</th>
     <th>Counts</th>
     <th>Rates</th>
+    <th>Errors (1m/5m/15m)</th>
+    <th>Throttled</th>
+    <th>Copy ETA</th>
     <th>Last Message</th>
   </tr>
   {{range .Controllers}}<tr>
@@ -618,6 +1012,9 @@ const vreplicationTemplate = `
       <td>{{.ReplicationLagSeconds}}</td>
       <td>{{range $key, $value := .Counts}}<b>{{$key}}</b>: {{$value}}<br>{{end}}</td>
       <td>{{range $key, $values := .Rates}}<b>{{$key}}</b>: {{range $values}}{{.}} {{end}}<br>{{end}}</td>
+      <td>{{.ErrorsLast1m}}/{{.ErrorsLast5m}}/{{.ErrorsLast15m}}</td>
+      <td>{{if .TimeThrottled}}{{if .Throttled}}Yes{{else}}No{{end}}, last by {{.ComponentThrottled}} at {{.TimeThrottled}}{{else}}No{{end}}</td>
+      <td>{{if .CopyETA}}{{.CopyETA}}{{else if .CopyRowsPerSecond}}{{printf "%.1f" .CopyRowsPerSecond}} rows/s{{end}}</td>
       <td>{{range $index, $value := .Messages}}{{$value}}<br>{{end}}</td>
     </tr>{{end}}
 <div id="vreplication_qps_chart" style="height: 500px; width: 900px">QPS All Streams </div>
@@ -18,6 +18,12 @@ package vreplication
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -27,6 +33,7 @@ import (
 
 	"vitess.io/vitess/go/mysql/replication"
 
+	"vitess.io/vitess/go/sqltypes"
 	"vitess.io/vitess/go/vt/binlog/binlogplayer"
 	"vitess.io/vitess/go/vt/proto/binlogdata"
 
@@ -179,12 +186,512 @@ func TestVReplicationStats(t *testing.T) {
 	require.Equal(t, int64(10), testStats.status().Controllers[0].TrxQueryBatchCounts["without_commit"])
 	require.Equal(t, int64(2193), testStats.status().Controllers[0].TrxQueryBatchCounts["with_commit"])
 
+	blpStats.ApplyBatchSize.Store(4096)
+	blpStats.RecordTrxQueryBatch("without_commit", 1000)
+	blpStats.RecordTrxQueryBatch("with_commit", 3000)
+	blpStats.RecordTrxQueryBatch("with_commit", 2000)
+	require.Equal(t, int64(11), testStats.status().Controllers[0].TrxQueryBatchCounts["without_commit"])
+	require.Equal(t, int64(2195), testStats.status().Controllers[0].TrxQueryBatchCounts["with_commit"])
+	require.Equal(t, int64(4096), testStats.status().Controllers[0].ApplyBatchSize)
+	// The batches counted above were added without their sizes.
+	require.Equal(t, int64(6000/2206), testStats.status().Controllers[0].AvgApplyBatchSize)
+	require.Equal(t, int64(3000), testStats.status().Controllers[0].MaxApplyBatchSize)
+
 	blpStats.CopyLoopCount.Add(100)
 	blpStats.CopyRowCount.Add(200)
 	require.Equal(t, int64(100), testStats.status().Controllers[0].CopyLoopCount)
 	require.Equal(t, int64(200), testStats.status().Controllers[0].CopyRowCount)
 
+	blpStats.BytesReceived.Add(1024)
+	blpStats.BytesApplied.Add(512)
+	require.Equal(t, int64(1024), testStats.status().Controllers[0].BytesReceived)
+	require.Equal(t, int64(512), testStats.status().Controllers[0].BytesApplied)
+
+	dbClient := binlogplayer.NewMockDBClient(t)
+	dbClient.ExpectRequest("select 1", &sqltypes.Result{}, nil)
+	_, err := newVDBClient(dbClient, blpStats).Execute("select 1")
+	require.NoError(t, err)
+	require.Equal(t, int64(512+len("select 1")), testStats.status().Controllers[0].BytesApplied)
+
+	events := []*binlogdata.VEvent{{Type: binlogdata.VEventType_HEARTBEAT}, {Type: binlogdata.VEventType_GTID, Gtid: "MariaDB/1-2-3"}}
+	(&vplayer{vr: &vreplicator{stats: blpStats}}).recordBytesReceived(events)
+	require.Equal(t, int64(1024+events[0].SizeVT()+events[1].SizeVT()), testStats.status().Controllers[0].BytesReceived)
+
+	blpStats.RecordError("Copy", errors.New("copy failed"))
+	blpStats.RecordError("Apply", errors.New("apply failed"))
+	require.Equal(t, int64(2), testStats.status().Controllers[0].ErrorsLast1m)
+	require.Equal(t, int64(2), testStats.status().Controllers[0].ErrorsLast15m)
+	require.Equal(t, int64(1), blpStats.ErrorCounts.Counts()["Copy"])
+
+	blpStats.RecordReplicationLag(0)
+	blpStats.RecordReplicationLag(4)
+	blpStats.RecordReplicationLag(40)
+	blpStats.RecordReplicationLag(3)
+	require.Equal(t, int64(3), testStats.status().Controllers[0].ReplicationLagSeconds)
+	lags := testStats.status().Controllers[0].ReplicationLags
+	require.Equal(t, int64(1), lags["0"])
+	require.Equal(t, int64(2), lags["5"])
+	require.Equal(t, int64(1), lags["60"])
+	require.Equal(t, int64(0), lags["inf"])
+
+	require.Equal(t, int64(math.MaxInt64), testStats.status().Controllers[0].SourceReadLagSeconds)
+	vp := &vplayer{vr: &vreplicator{stats: blpStats}}
+	vp.recordSourceReadLag([]*binlogdata.VEvent{
+		{Type: binlogdata.VEventType_BEGIN, Timestamp: 100, CurrentTime: 130 * 1e9},
+		{Type: binlogdata.VEventType_ROW},
+	})
+	require.Equal(t, int64(30), testStats.status().Controllers[0].SourceReadLagSeconds)
+	vp.recordSourceReadLag([]*binlogdata.VEvent{{Type: binlogdata.VEventType_HEARTBEAT, CurrentTime: 140 * 1e9}})
+	require.Equal(t, int64(0), testStats.status().Controllers[0].SourceReadLagSeconds)
+
 	var tm int64 = 1234567890
 	blpStats.RecordHeartbeat(tm)
 	require.Equal(t, tm, blpStats.Heartbeat())
+
+	blpStats.RecordHeartbeat(time.Now().Add(-time.Minute).Unix())
+	age := testStats.status().Controllers[0].HeartbeatAge
+	require.Positive(t, age)
+	require.InDelta(t, time.Minute.Seconds(), age.Seconds(), 2)
+}
+
+func TestVReplicationThrottleStats(t *testing.T) {
+	blpStats := binlogplayer.NewStats()
+	defer blpStats.Stop()
+	testStats := &vrStats{}
+	testStats.isOpen = true
+	testStats.controllers = map[int32]*controller{
+		1: {
+			id: 1,
+			source: &binlogdata.BinlogSource{
+				Keyspace: "ks",
+				Shard:    "0",
+			},
+			blpStats: blpStats,
+			done:     make(chan struct{}),
+		},
+	}
+	testStats.controllers[1].sourceTablet.Store(&topodatapb.TabletAlias{
+		Cell: "zone1",
+		Uid:  01,
+	})
+
+	status := testStats.status().Controllers[0]
+	require.False(t, status.Throttled)
+	require.Empty(t, status.ComponentThrottled)
+	require.Zero(t, status.TimeThrottled)
+
+	before := time.Now().Unix()
+	blpStats.RecordThrottled("vplayer")
+	status = testStats.status().Controllers[0]
+	require.True(t, status.Throttled)
+	require.Equal(t, "vplayer", status.ComponentThrottled)
+	require.GreaterOrEqual(t, status.TimeThrottled, before)
+	throttledAt := status.TimeThrottled
+
+	// Another component letting the stream through doesn't unthrottle it.
+	blpStats.RecordUnthrottled("vstreamer")
+	require.True(t, testStats.status().Controllers[0].Throttled)
+
+	blpStats.RecordUnthrottled("vplayer")
+	status = testStats.status().Controllers[0]
+	require.False(t, status.Throttled)
+	require.Equal(t, "vplayer", status.ComponentThrottled)
+	require.Equal(t, throttledAt, status.TimeThrottled)
+
+	req, err := http.NewRequest("GET", "/debug/vreplication?cols=index,throttled", nil)
+	require.NoError(t, err)
+	resp := httptest.NewRecorder()
+	vreplicationStatusHandler(testStats, resp, req)
+	require.Contains(t, resp.Body.String(), fmt.Sprintf("<td>No, last by vplayer at %d</td>", throttledAt))
+}
+
+func TestVReplicationLastError(t *testing.T) {
+	blpStats := binlogplayer.NewStats()
+	defer blpStats.Stop()
+	testStats := &vrStats{
+		isOpen: true,
+		controllers: map[int32]*controller{
+			1: {
+				id:       1,
+				source:   &binlogdata.BinlogSource{Keyspace: "ks", Shard: "0"},
+				blpStats: blpStats,
+				done:     make(chan struct{}),
+			},
+		},
+	}
+	testStats.controllers[1].sourceTablet.Store(&topodatapb.TabletAlias{Cell: "zone1", Uid: 1})
+
+	status := testStats.status().Controllers[0]
+	require.Empty(t, status.LastError)
+	require.True(t, status.LastErrorTime.IsZero())
+
+	before := time.Now()
+	blpStats.RecordError("Apply", errors.New("duplicate key"))
+	status = testStats.status().Controllers[0]
+	require.Equal(t, "Apply: duplicate key", status.LastError)
+	require.False(t, status.LastErrorTime.Before(before))
+	require.False(t, status.LastErrorTime.After(time.Now()))
+
+	req, err := http.NewRequest("GET", "/debug/vreplication?cols=index,last_error", nil)
+	require.NoError(t, err)
+	resp := httptest.NewRecorder()
+	vreplicationStatusHandler(testStats, resp, req)
+	require.Contains(t, resp.Body.String(), status.LastErrorTime.Format("2006-01-02 15:04:05")+": Apply: duplicate key")
+}
+
+func TestCopyETA(t *testing.T) {
+	testcases := []struct {
+		name          string
+		rowsPerSecond float64
+		copied        int64
+		estimate      int64
+		want          time.Duration
+	}{
+		{name: "steady rate", rowsPerSecond: 100, copied: 1000, estimate: 7000, want: time.Minute},
+		{name: "fractional rate", rowsPerSecond: 33.3, copied: 0, estimate: 10000, want: 300 * time.Second},
+		{name: "unknown estimate", rowsPerSecond: 100, copied: 1000},
+		{name: "no rate", copied: 1000, estimate: 7000},
+		{name: "estimate exceeded", rowsPerSecond: 100, copied: 8000, estimate: 7000},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := copyETA(tc.rowsPerSecond, tc.copied, tc.estimate)
+			require.InDelta(t, tc.want.Seconds(), got.Seconds(), 1)
+		})
+	}
+
+	blpStats := binlogplayer.NewStats()
+	defer blpStats.Stop()
+	blpStats.State.Store(binlogdata.VReplicationWorkflowState_Copying.String())
+	testStats := &vrStats{
+		isOpen: true,
+		controllers: map[int32]*controller{
+			1: {
+				id:       1,
+				source:   &binlogdata.BinlogSource{Keyspace: "ks", Shard: "0"},
+				blpStats: blpStats,
+				done:     make(chan struct{}),
+			},
+		},
+	}
+	testStats.controllers[1].sourceTablet.Store(&topodatapb.TabletAlias{Cell: "zone1", Uid: 1})
+
+	// Without an estimate, only the rate is reported.
+	blpStats.CopyRowCount.Add(1000)
+	blpStats.CopyRate.Record(1000)
+	status := testStats.status().Controllers[0]
+	require.Zero(t, status.CopyETA)
+	require.Greater(t, status.CopyRowsPerSecond, 0.0)
+
+	// 1000 rows were copied within the last second or two, which leaves
+	// one to two minutes for the remaining 60000 rows.
+	blpStats.CopyRowsEstimate.Store(61000)
+	status = testStats.status().Controllers[0]
+	require.Equal(t, int64(61000), status.CopyRowsEstimate)
+	require.GreaterOrEqual(t, status.CopyETA, 59*time.Second)
+	require.LessOrEqual(t, status.CopyETA, 120*time.Second)
+}
+
+func TestVReplicationStateTransitions(t *testing.T) {
+	blpStats := binlogplayer.NewStats()
+	defer blpStats.Stop()
+	testStats := &vrStats{
+		isOpen: true,
+		controllers: map[int32]*controller{
+			1: {
+				id:       1,
+				source:   &binlogdata.BinlogSource{Keyspace: "ks", Shard: "0"},
+				blpStats: blpStats,
+				done:     make(chan struct{}),
+			},
+		},
+	}
+	testStats.controllers[1].sourceTablet.Store(&topodatapb.TabletAlias{Cell: "zone1", Uid: 1})
+
+	// Setting the initial state isn't a transition, and neither is setting
+	// the same state again.
+	blpStats.SetState("Running")
+	blpStats.SetState("Running")
+	require.Empty(t, testStats.status().Controllers[0].StateTransitions)
+
+	blpStats.SetState("Error")
+	blpStats.SetState("Running")
+	blpStats.SetState("Error")
+	blpStats.SetState("Stopped")
+	status := testStats.status().Controllers[0]
+	require.Equal(t, "Stopped", status.State)
+	require.Equal(t, map[string]int64{
+		"Running->Error": 2,
+		"Error->Running": 1,
+		"Error->Stopped": 1,
+	}, status.StateTransitions)
+}
+
+func TestStatusColumns(t *testing.T) {
+	blpStats := binlogplayer.NewStats()
+	defer blpStats.Stop()
+	blpStats.ReplicationLagSeconds.Store(2)
+
+	testStats := &vrStats{}
+	testStats.isOpen = true
+	testStats.controllers = map[int32]*controller{
+		1: {
+			id: 1,
+			source: &binlogdata.BinlogSource{
+				Keyspace: "ks",
+				Shard:    "0",
+			},
+			stopPos:  "MariaDB/1-2-4",
+			blpStats: blpStats,
+			done:     make(chan struct{}),
+		},
+	}
+	testStats.controllers[1].sourceTablet.Store(&topodatapb.TabletAlias{
+		Cell: "zone1",
+		Uid:  01,
+	})
+
+	get := func(url string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", url, nil)
+		require.NoError(t, err)
+		resp := httptest.NewRecorder()
+		vreplicationStatusHandler(testStats, resp, req)
+		return resp
+	}
+
+	resp := get("/debug/vreplication")
+	require.Equal(t, http.StatusOK, resp.Code)
+	body := resp.Body.String()
+	require.Contains(t, body, "<th>Index</th>")
+	require.Contains(t, body, "<th>Errors (1m/5m/15m)</th>")
+	require.NotContains(t, body, "<th>Stop Position</th>")
+
+	resp = get("/debug/vreplication?cols=stop_position,index")
+	require.Equal(t, http.StatusOK, resp.Code)
+	body = resp.Body.String()
+	require.Contains(t, body, "<th>Index</th>\n    <th>Stop Position</th>\n  </tr>")
+	require.Contains(t, body, "<td>1</td>\n      <td>MariaDB/1-2-4</td>\n    </tr>")
+	require.NotContains(t, body, "<th>Source</th>")
+
+	resp = get("/debug/vreplication?cols=index,bogus")
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+	require.Contains(t, resp.Body.String(), "unknown columns: bogus")
+}
+
+func TestStatusJSON(t *testing.T) {
+	blpStats := binlogplayer.NewStats()
+	defer blpStats.Stop()
+	blpStats.State.Store("Running")
+	blpStats.ReplicationLagSeconds.Store(2)
+	blpStats.QueryCount.Add("replicate", 3)
+	blpStats.CopyRowCount.Add(10)
+	blpStats.TableCopyRowCounts.Add("t1", 10)
+	blpStats.SetTableCopyState("t1", binlogplayer.TableCopyDone)
+	blpStats.SetTableCopyState("t2", binlogplayer.TableCopyInProgress)
+	blpStats.SetTableCopyState("t3", binlogplayer.TableCopyPending)
+
+	testStats := &vrStats{
+		isOpen: true,
+		controllers: map[int32]*controller{
+			1: {
+				id: 1,
+				source: &binlogdata.BinlogSource{
+					Keyspace: "ks",
+					Shard:    "-80",
+				},
+				stopPos:  "MariaDB/1-2-4",
+				blpStats: blpStats,
+				done:     make(chan struct{}),
+			},
+		},
+	}
+	testStats.controllers[1].sourceTablet.Store(&topodatapb.TabletAlias{Cell: "zone1", Uid: 100})
+
+	req, err := http.NewRequest("GET", "/debug/vreplication?format=json", nil)
+	require.NoError(t, err)
+	resp := httptest.NewRecorder()
+	vreplicationStatusHandler(testStats, resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+
+	var got EngineStatus
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &got))
+	require.True(t, got.IsOpen)
+	require.Len(t, got.Controllers, 1)
+	ct := got.Controllers[0]
+	require.Equal(t, int32(1), ct.Index)
+	require.Equal(t, "ks", ct.SourceKeyspace)
+	require.Equal(t, "-80", ct.SourceShard)
+	require.Equal(t, "Running", ct.State)
+	require.Equal(t, "MariaDB/1-2-4", ct.StopPosition)
+	require.Equal(t, int64(2), ct.ReplicationLagSeconds)
+	require.Equal(t, int64(3), ct.QueryCounts["replicate"])
+	require.Equal(t, int64(10), ct.CopyRowCount)
+	require.Equal(t, "zone1", ct.SourceTablet.Cell)
+	require.Equal(t, uint32(100), ct.SourceTablet.Uid)
+	require.Equal(t, map[string]*TableCopyStatus{
+		"t1": {State: binlogplayer.TableCopyDone, RowsCopied: 10},
+		"t2": {State: binlogplayer.TableCopyInProgress},
+		"t3": {State: binlogplayer.TableCopyPending},
+	}, ct.CopyTables)
+
+	req, err = http.NewRequest("GET", "/debug/vreplication?cols=index,copy_tables", nil)
+	require.NoError(t, err)
+	resp = httptest.NewRecorder()
+	vreplicationStatusHandler(testStats, resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Contains(t, resp.Body.String(), "<b>t1</b>: done (10 rows)<br><b>t2</b>: in-progress (0 rows)<br><b>t3</b>: pending (0 rows)<br>")
+}
+
+func TestStatusFilter(t *testing.T) {
+	testStats := &vrStats{}
+	testStats.isOpen = true
+	testStats.controllers = make(map[int32]*controller)
+	for id, stream := range []struct {
+		workflow string
+		state    string
+	}{
+		{"wf1", "Running"},
+		{"wf1", "Error"},
+		{"wf2", "Running"},
+		{"wf2", "Stopped"},
+	} {
+		blpStats := binlogplayer.NewStats()
+		defer blpStats.Stop()
+		blpStats.State.Store(stream.state)
+		testStats.controllers[int32(id+1)] = &controller{
+			id:       int32(id + 1),
+			workflow: stream.workflow,
+			source:   &binlogdata.BinlogSource{Keyspace: "ks", Shard: "0"},
+			blpStats: blpStats,
+			done:     make(chan struct{}),
+		}
+		testStats.controllers[int32(id+1)].sourceTablet.Store(&topodatapb.TabletAlias{Cell: "zone1", Uid: 1})
+	}
+
+	indexes := func(status *EngineStatus) []int32 {
+		var ids []int32
+		for _, ct := range status.Controllers {
+			ids = append(ids, ct.Index)
+		}
+		return ids
+	}
+	require.Equal(t, []int32{1, 2, 3, 4}, indexes(testStats.filteredStatus("", "")))
+	require.Equal(t, []int32{1, 2}, indexes(testStats.filteredStatus("wf1", "")))
+	require.Equal(t, []int32{1, 3}, indexes(testStats.filteredStatus("", "running")))
+	require.Equal(t, []int32{4}, indexes(testStats.filteredStatus("wf2", "Stopped")))
+	require.Empty(t, indexes(testStats.filteredStatus("wf3", "")))
+	// Only the state is compared case-insensitively.
+	require.Empty(t, indexes(testStats.filteredStatus("WF1", "")))
+	require.Equal(t, []int32{1}, indexes(testStats.filteredStatus("wf1", "RUNNING")))
+
+	req, err := http.NewRequest("GET", "/debug/vreplication?workflow=wf1&state=Error&format=json", nil)
+	require.NoError(t, err)
+	resp := httptest.NewRecorder()
+	vreplicationStatusHandler(testStats, resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var got EngineStatus
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &got))
+	require.Equal(t, []int32{2}, indexes(&got))
+	require.Equal(t, "wf1", got.Controllers[0].Workflow)
+
+	req, err = http.NewRequest("GET", "/debug/vreplication?cols=index,state&state=Stopped", nil)
+	require.NoError(t, err)
+	resp = httptest.NewRecorder()
+	vreplicationStatusHandler(testStats, resp, req)
+	require.Contains(t, resp.Body.String(), "<td>4</td>\n      <td>Stopped</td>")
+	require.NotContains(t, resp.Body.String(), "Running")
+}
+
+func TestWorkflowStatus(t *testing.T) {
+	testStats := &vrStats{}
+	testStats.isOpen = true
+	testStats.controllers = make(map[int32]*controller)
+	for id, stream := range []struct {
+		workflow string
+		state    string
+		lag      int64
+	}{
+		{"wf1", "Running", 2},
+		{"wf1", "Running", 5},
+		{"wf2", "Running", 0},
+		{"wf2", "Stopped", 0},
+		{"wf3", "Stopped", 0},
+	} {
+		blpStats := binlogplayer.NewStats()
+		defer blpStats.Stop()
+		blpStats.State.Store(stream.state)
+		blpStats.ReplicationLagSeconds.Store(stream.lag)
+		testStats.controllers[int32(id+1)] = &controller{
+			id:       int32(id + 1),
+			workflow: stream.workflow,
+			blpStats: blpStats,
+			done:     make(chan struct{}),
+		}
+	}
+
+	want := []*WorkflowStatus{
+		{Workflow: "wf1", Streams: 2, State: "all-running", MaxReplicationLagSeconds: 5},
+		{Workflow: "wf2", Streams: 2, State: "mixed"},
+		{Workflow: "wf3", Streams: 1, State: "all-stopped"},
+	}
+	require.Equal(t, want, testStats.workflowStatus())
+
+	req, err := http.NewRequest("GET", "/debug/vreplication/workflows?format=json", nil)
+	require.NoError(t, err)
+	resp := httptest.NewRecorder()
+	workflowStatusHandler(testStats, resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var got []*WorkflowStatus
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &got))
+	require.Equal(t, want, got)
+
+	req, err = http.NewRequest("GET", "/debug/vreplication/workflows", nil)
+	require.NoError(t, err)
+	resp = httptest.NewRecorder()
+	workflowStatusHandler(testStats, resp, req)
+	require.Contains(t, resp.Body.String(), "<td>wf2</td>\n      <td>2</td>\n      <td>mixed</td>")
+}
+
+func TestDebugArchive(t *testing.T) {
+	blpStats := binlogplayer.NewStats()
+	defer blpStats.Stop()
+	blpStats.State.Store("Running")
+	blpStats.History.Add(&binlogplayer.StatsHistoryRecord{Time: time.Now(), Message: "Picked source tablet"})
+	testStats := &vrStats{
+		isOpen: true,
+		controllers: map[int32]*controller{
+			1: {
+				id:       1,
+				workflow: "wf1",
+				source: &binlogdata.BinlogSource{
+					Keyspace: "ks",
+					Shard:    "-80",
+					Filter:   &binlogdata.Filter{Rules: []*binlogdata.Rule{{Match: "/.*", Filter: "80-"}}},
+				},
+				blpStats: blpStats,
+				done:     make(chan struct{}),
+			},
+		},
+	}
+	testStats.controllers[1].sourceTablet.Store(&topodatapb.TabletAlias{Cell: "zone1", Uid: 100})
+
+	req, err := http.NewRequest("GET", "/debug/vreplication/export", nil)
+	require.NoError(t, err)
+	resp := httptest.NewRecorder()
+	debugArchiveHandler(testStats, resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Contains(t, resp.Header().Get("Content-Disposition"), "attachment; filename=vreplication-")
+
+	var archive DebugArchive
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &archive))
+	require.True(t, archive.IsOpen)
+	require.Len(t, archive.Streams, 1)
+	stream := archive.Streams[0]
+	require.Equal(t, "wf1", stream.Workflow)
+	require.Equal(t, int32(1), stream.Status.Index)
+	require.Equal(t, "Running", stream.Status.State)
+	require.JSONEq(t, `{"keyspace":"ks","shard":"-80","filter":{"rules":[{"match":"/.*","filter":"80-"}]}}`, string(stream.BinlogSource))
+	require.Len(t, stream.History, 1)
+	require.Equal(t, "Picked source tablet", stream.History[0].Message)
 }
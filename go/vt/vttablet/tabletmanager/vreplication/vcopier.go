@@ -318,12 +318,62 @@ func (vc *vcopier) copyNext(ctx context.Context, settings binlogplayer.VRSetting
 	if len(copyState) == 0 {
 		return fmt.Errorf("unexpected: there are no tables to copy")
 	}
+	for tableName := range copyState {
+		state := binlogplayer.TableCopyPending
+		if tableName == tableToCopy {
+			state = binlogplayer.TableCopyInProgress
+		}
+		vc.vr.stats.SetTableCopyState(tableName, state)
+	}
+	if vc.vr.stats.CopyRowsEstimate.Load() == 0 {
+		if err := vc.estimateCopyRows(); err != nil {
+			log.Warningf("Could not estimate the rows to copy in workflow %s: %v", vc.vr.WorkflowName, err)
+		}
+	}
 	if err := vc.catchup(ctx, copyState); err != nil {
 		return err
 	}
 	return vc.copyTable(ctx, tableToCopy, copyState)
 }
 
+// estimateCopyRows sets the estimated number of rows to copy from the table
+// statistics of the source tables. They can only be read when the source
+// tables are in the database of the stream, as in an Online DDL, so the
+// estimate is left unknown otherwise.
+func (vc *vcopier) estimateCopyRows() error {
+	if vc.vr.WorkflowType != int32(binlogdatapb.VReplicationWorkflowType_OnlineDDL) {
+		return nil
+	}
+	plan, err := buildReplicatorPlan(vc.vr.source, vc.vr.colInfoMap, nil, vc.vr.stats, vc.vr.vre.collationEnv, vc.vr.vre.parser)
+	if err != nil {
+		return err
+	}
+	if len(plan.TargetTables) == 0 {
+		return nil
+	}
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "select ifnull(sum(table_rows), 0) from information_schema.tables where table_schema = %s and table_name in (", encodeString(vc.vr.dbClient.DBName()))
+	prefix := ""
+	for _, table := range plan.TargetTables {
+		fmt.Fprintf(&buf, "%s%s", prefix, encodeString(table.SendRule.Match))
+		prefix = ", "
+	}
+	buf.WriteString(")")
+	qr, err := vc.vr.dbClient.Execute(buf.String())
+	if err != nil {
+		return err
+	}
+	if len(qr.Rows) != 1 {
+		return fmt.Errorf("unexpected result for the table rows estimate: %v", qr.Rows)
+	}
+	rows, err := qr.Rows[0][0].ToInt64()
+	if err != nil {
+		return err
+	}
+	vc.vr.stats.CopyRowsEstimate.Store(rows)
+	return nil
+}
+
 // catchup replays events to the subset of the tables that have been copied
 // until replication is caught up. In order to stop, the seconds behind primary has
 // to fall below replicationLagTolerance.
@@ -462,12 +512,14 @@ func (vc *vcopier) copyTable(ctx context.Context, tableName string, copyState ma
 				_ = vc.vr.updateTimeThrottled(throttlerapp.RowStreamerName)
 				return nil
 			}
+			vc.vr.stats.RecordUnthrottled(throttlerapp.RowStreamerName.String())
 			if rows.Heartbeat {
 				_ = vc.vr.updateHeartbeatTime(time.Now().Unix())
 				return nil
 			}
 			// verify throttler is happy, otherwise keep looping
 			if vc.vr.vre.throttlerClient.ThrottleCheckOKOrWaitAppName(ctx, throttlerapp.Name(vc.throttlerAppName)) {
+				vc.vr.stats.RecordUnthrottled(throttlerapp.VCopierName.String())
 				break // out of 'for' loop
 			} else { // we're throttled
 				_ = vc.vr.updateTimeThrottled(throttlerapp.VCopierName)
@@ -549,10 +601,11 @@ func (vc *vcopier) copyTable(ctx context.Context, tableName string, copyState ma
 		// Update stats after task is done.
 		currT.lifecycle.onResult().do(func(_ context.Context, result *vcopierCopyTaskResult) {
 			if result.state == vcopierCopyTaskFail {
-				vc.vr.stats.ErrorCounts.Add([]string{"Copy"}, 1)
+				vc.vr.stats.RecordError("Copy", result.err)
 			}
 			if result.state == vcopierCopyTaskComplete {
 				vc.vr.stats.CopyRowCount.Add(int64(len(result.args.rows)))
+				vc.vr.stats.CopyRate.Record(int64(len(result.args.rows)))
 				vc.vr.stats.QueryCount.Add("copy", 1)
 				vc.vr.stats.TableCopyRowCounts.Add(tableName, int64(len(result.args.rows)))
 				vc.vr.stats.TableCopyTimings.Add(tableName, time.Since(result.startedAt))
@@ -675,6 +728,7 @@ func (vc *vcopier) copyTable(ctx context.Context, tableName string, copyState ma
 	if _, err := vc.vr.dbClient.Execute(buf.String()); err != nil {
 		return err
 	}
+	vc.vr.stats.SetTableCopyState(tableName, binlogplayer.TableCopyDone)
 
 	return nil
 }
@@ -62,6 +62,7 @@ func newCopyAllState(vc *vcopier) (*copyAllState, error) {
 	state.tables = make(map[string]bool, len(plan.TargetTables))
 	for _, table := range plan.TargetTables {
 		state.tables[table.TargetName] = false
+		vc.vr.stats.SetTableCopyState(table.TargetName, binlogplayer.TableCopyPending)
 	}
 	return state, nil
 }
@@ -143,6 +144,7 @@ func (vc *vcopier) copyAll(ctx context.Context, settings binlogplayer.VRSettings
 			}
 
 			state.currentTableName = tableName
+			vc.vr.stats.SetTableCopyState(tableName, binlogplayer.TableCopyInProgress)
 		}
 
 		// A new copy queue is created for each table. The queue is closed when the table is done.
@@ -228,10 +230,11 @@ func (vc *vcopier) copyAll(ctx context.Context, settings binlogplayer.VRSettings
 		// Update stats after task is done.
 		currT.lifecycle.onResult().do(func(_ context.Context, result *vcopierCopyTaskResult) {
 			if result.state == vcopierCopyTaskFail {
-				vc.vr.stats.ErrorCounts.Add([]string{"Copy"}, 1)
+				vc.vr.stats.RecordError("Copy", result.err)
 			}
 			if result.state == vcopierCopyTaskComplete {
 				vc.vr.stats.CopyRowCount.Add(int64(len(result.args.rows)))
+				vc.vr.stats.CopyRate.Record(int64(len(result.args.rows)))
 				vc.vr.stats.QueryCount.Add("copy", 1)
 				vc.vr.stats.TableCopyRowCounts.Add(tableName, int64(len(result.args.rows)))
 				vc.vr.stats.TableCopyTimings.Add(tableName, time.Since(result.startedAt))
@@ -307,5 +310,6 @@ func (vc *vcopier) deleteCopyState(tableName string) error {
 	if _, err := vc.vr.dbClient.Execute(delQuery); err != nil {
 		return err
 	}
+	vc.vr.stats.SetTableCopyState(tableName, binlogplayer.TableCopyDone)
 	return nil
 }
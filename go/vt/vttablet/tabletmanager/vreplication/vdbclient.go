@@ -95,7 +95,8 @@ func (vc *vdbClient) CommitTrxQueryBatch() error {
 	vc.queries = nil
 	vc.queriesPos = 0
 	vc.batchSize = 0
-	vc.stats.TrxQueryBatchCount.Add("with_commit", 1)
+	vc.stats.RecordTrxQueryBatch("with_commit", int64(len(queries)))
+	vc.stats.BytesApplied.Add(int64(len(queries)))
 	vc.stats.Timings.Record(binlogplayer.BlplBatchTransaction, vc.startTime)
 	return nil
 }
@@ -120,6 +121,7 @@ func (vc *vdbClient) ExecuteFetch(query string, maxrows int) (*sqltypes.Result,
 	} else {
 		vc.queries = append(vc.queries, query)
 	}
+	vc.stats.BytesApplied.Add(int64(len(query)))
 	return vc.DBClient.ExecuteFetch(query, maxrows)
 }
 
@@ -149,11 +151,13 @@ func (vc *vdbClient) AddQueryToTrxBatch(query string) error {
 func (vc *vdbClient) ExecuteTrxQueryBatch() ([]*sqltypes.Result, error) {
 	defer vc.stats.Timings.Record(binlogplayer.BlplMultiQuery, time.Now())
 
-	qrs, err := vc.DBClient.ExecuteFetchMulti(strings.Join(vc.queries[vc.queriesPos:], ";"), -1)
+	queries := strings.Join(vc.queries[vc.queriesPos:], ";")
+	qrs, err := vc.DBClient.ExecuteFetchMulti(queries, -1)
 	if err != nil {
 		return nil, err
 	}
-	vc.stats.TrxQueryBatchCount.Add("without_commit", 1)
+	vc.stats.RecordTrxQueryBatch("without_commit", int64(len(queries)))
+	vc.stats.BytesApplied.Add(int64(len(queries)))
 	vc.queriesPos += int64(len(vc.queries[vc.queriesPos:]))
 	vc.batchSize = 0
 
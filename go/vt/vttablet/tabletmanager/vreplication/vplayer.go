@@ -151,6 +151,7 @@ func newVPlayer(vr *vreplicator, settings binlogplayer.VRSettings, copyState map
 			return vr.dbClient.CommitTrxQueryBatch() // Commit the current trx batch
 		}
 		vr.dbClient.maxBatchSize = maxAllowedPacket
+		vr.stats.ApplyBatchSize.Store(maxAllowedPacket)
 	}
 
 	return &vplayer{
@@ -182,7 +183,7 @@ func (vp *vplayer) play(ctx context.Context) error {
 
 	plan, err := buildReplicatorPlan(vp.vr.source, vp.vr.colInfoMap, vp.copyState, vp.vr.stats, vp.vr.vre.collationEnv, vp.vr.vre.parser)
 	if err != nil {
-		vp.vr.stats.ErrorCounts.Add([]string{"Plan"}, 1)
+		vp.vr.stats.RecordError("Plan", err)
 		return err
 	}
 	vp.replicatorPlan = plan
@@ -258,9 +259,12 @@ func (vp *vplayer) fetchAndApply(ctx context.Context) (err error) {
 
 	relay := newRelayLog(ctx, relayLogMaxItems, relayLogMaxSize)
 
+	defer vp.vr.stats.SourceReadLagSeconds.Store(math.MaxInt64)
 	streamErr := make(chan error, 1)
 	go func() {
 		streamErr <- vp.vr.sourceVStreamer.VStream(ctx, replication.EncodePosition(vp.startPos), nil, vp.replicatorPlan.VStreamFilter, func(events []*binlogdatapb.VEvent) error {
+			vp.recordSourceReadLag(events)
+			vp.recordBytesReceived(events)
 			return relay.Send(events)
 		})
 	}()
@@ -451,6 +455,32 @@ func (vp *vplayer) recordHeartbeat() error {
 // this from becoming a tight loop.
 // TODO(sougou): we can look at recognizing self-generated events and find a better
 // way to handle them.
+// recordSourceReadLag records how far behind the source the last of the
+// events read from it is. A heartbeat means that everything has been read.
+func (vp *vplayer) recordSourceReadLag(events []*binlogdatapb.VEvent) {
+	for i := len(events) - 1; i >= 0; i-- {
+		event := events[i]
+		switch {
+		case event.Type == binlogdatapb.VEventType_HEARTBEAT:
+			vp.vr.stats.SourceReadLagSeconds.Store(0)
+			return
+		case event.Timestamp != 0:
+			vp.vr.stats.SourceReadLagSeconds.Store(max(event.CurrentTime/1e9-event.Timestamp, 0))
+			return
+		}
+	}
+}
+
+// recordBytesReceived counts the encoded size of the events read from the
+// source.
+func (vp *vplayer) recordBytesReceived(events []*binlogdatapb.VEvent) {
+	var size int
+	for _, event := range events {
+		size += event.SizeVT()
+	}
+	vp.vr.stats.BytesReceived.Add(int64(size))
+}
+
 func (vp *vplayer) applyEvents(ctx context.Context, relay *relayLog) error {
 	defer vp.vr.dbClient.Rollback()
 
@@ -469,6 +499,7 @@ func (vp *vplayer) applyEvents(ctx context.Context, relay *relayLog) error {
 			_ = vp.vr.updateTimeThrottled(throttlerapp.VPlayerName)
 			continue
 		}
+		vp.vr.stats.RecordUnthrottled(throttlerapp.VPlayerName.String())
 
 		items, err := relay.Fetch()
 		if err != nil {
@@ -478,7 +509,7 @@ func (vp *vplayer) applyEvents(ctx context.Context, relay *relayLog) error {
 		// So, we should assume we're falling behind.
 		if len(items) == 0 {
 			behind := time.Now().UnixNano() - vp.lastTimestampNs - vp.timeOffsetNs
-			vp.vr.stats.ReplicationLagSeconds.Store(behind / 1e9)
+			vp.vr.stats.RecordReplicationLag(behind / 1e9)
 			vp.vr.stats.VReplicationLags.Add(strconv.Itoa(int(vp.vr.id)), time.Duration(behind/1e9)*time.Second)
 		}
 		// Empty transactions are saved at most once every idleTimeout.
@@ -525,7 +556,7 @@ func (vp *vplayer) applyEvents(ctx context.Context, relay *relayLog) error {
 				}
 				if err := vp.applyEvent(ctx, event, mustSave); err != nil {
 					if err != io.EOF {
-						vp.vr.stats.ErrorCounts.Add([]string{"Apply"}, 1)
+						vp.vr.stats.RecordError("Apply", err)
 						log.Errorf("Error applying event: %s", err.Error())
 					}
 					return err
@@ -534,7 +565,7 @@ func (vp *vplayer) applyEvents(ctx context.Context, relay *relayLog) error {
 		}
 
 		if sbm >= 0 {
-			vp.vr.stats.ReplicationLagSeconds.Store(sbm)
+			vp.vr.stats.RecordReplicationLag(sbm)
 			vp.vr.stats.VReplicationLags.Add(strconv.Itoa(int(vp.vr.id)), time.Duration(sbm)*time.Second)
 		}
 
@@ -762,6 +793,8 @@ func (vp *vplayer) applyEvent(ctx context.Context, event *binlogdatapb.VEvent, m
 			if err := vp.vr.updateTimeThrottled(throttlerapp.VStreamerName); err != nil {
 				return err
 			}
+		} else {
+			vp.vr.stats.RecordUnthrottled(throttlerapp.VStreamerName.String())
 		}
 		if !vp.vr.dbClient.InTransaction {
 			vp.numAccumulatedHeartbeats++
@@ -275,12 +275,12 @@ func (vr *vreplicator) replicate(ctx context.Context) error {
 			if vr.WorkflowSubType == int32(binlogdatapb.VReplicationWorkflowSubType_AtomicCopy) {
 				if err := newVCopier(vr).copyAll(ctx, settings); err != nil {
 					log.Infof("Error atomically copying all tables: %v", err)
-					vr.stats.ErrorCounts.Add([]string{"CopyAll"}, 1)
+					vr.stats.RecordError("CopyAll", err)
 					return err
 				}
 			} else {
 				if err := newVCopier(vr).copyNext(ctx, settings); err != nil {
-					vr.stats.ErrorCounts.Add([]string{"Copy"}, 1)
+					vr.stats.RecordError("Copy", err)
 					return err
 				}
 				settings, numTablesToCopy, err = vr.loadSettings(ctx, vr.dbClient)
@@ -295,7 +295,7 @@ func (vr *vreplicator) replicate(ctx context.Context) error {
 			}
 		case settings.StartPos.IsZero():
 			if err := newVCopier(vr).initTablesForCopy(ctx); err != nil {
-				vr.stats.ErrorCounts.Add([]string{"Copy"}, 1)
+				vr.stats.RecordError("Copy", err)
 				return err
 			}
 		default:
@@ -307,7 +307,7 @@ func (vr *vreplicator) replicate(ctx context.Context) error {
 				return vr.setState(binlogdatapb.VReplicationWorkflowState_Stopped, "Stopped after copy.")
 			}
 			if err := vr.setState(binlogdatapb.VReplicationWorkflowState_Running, ""); err != nil {
-				vr.stats.ErrorCounts.Add([]string{"Replicate"}, 1)
+				vr.stats.RecordError("Replicate", err)
 				return err
 			}
 			return newVPlayer(vr, settings, nil, replication.Position{}, "replicate").play(ctx)
@@ -481,7 +481,7 @@ func (vr *vreplicator) setState(state binlogdatapb.VReplicationWorkflowState, me
 			Message: message,
 		})
 	}
-	vr.stats.State.Store(state.String())
+	vr.stats.SetState(state.String())
 	query := fmt.Sprintf("update _vt.vreplication set state='%v', message=%v where id=%v", state, encodeString(binlogplayer.MessageTruncate(message)), vr.id)
 	if _, err := vr.dbClient.ExecuteFetch(query, 1); err != nil {
 		return fmt.Errorf("could not set state: %v: %v", query, err)
@@ -581,6 +581,7 @@ func (vr *vreplicator) throttlerAppName() string {
 }
 
 func (vr *vreplicator) updateTimeThrottled(appThrottled throttlerapp.Name) error {
+	vr.stats.RecordThrottled(appThrottled.String())
 	err := vr.throttleUpdatesRateLimiter.Do(func() error {
 		tm := time.Now().Unix()
 		update, err := binlogplayer.GenerateUpdateTimeThrottled(vr.id, tm, appThrottled.String())
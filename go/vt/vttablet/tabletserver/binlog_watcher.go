@@ -38,26 +38,26 @@ type VStreamer interface {
 // replication stream.  It will trigger schema reloads if a DDL
 // is encountered.
 type BinlogWatcher struct {
-	env              tabletenv.Env
-	watchReplication bool
-	vs               VStreamer
+	env tabletenv.Env
+	vs  VStreamer
 
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
 }
 
 // NewBinlogWatcher creates a new BinlogWatcher.
-func NewBinlogWatcher(env tabletenv.Env, vs VStreamer, config *tabletenv.TabletConfig) *BinlogWatcher {
+func NewBinlogWatcher(env tabletenv.Env, vs VStreamer) *BinlogWatcher {
 	return &BinlogWatcher{
-		env:              env,
-		vs:               vs,
-		watchReplication: config.WatchReplication || config.TrackSchemaVersions,
+		env: env,
+		vs:  vs,
 	}
 }
 
-// Open starts the BinlogWatcher service.
+// Open starts the BinlogWatcher service, if the current config of the env
+// watches the replication or tracks the schema versions.
 func (blw *BinlogWatcher) Open() {
-	if blw.cancel != nil || !blw.watchReplication {
+	config := blw.env.Config()
+	if blw.cancel != nil || !(config.WatchReplication || config.TrackSchemaVersions) {
 		return
 	}
 	log.Info("Binlog Watcher: opening")
@@ -27,6 +27,7 @@ import (
 
 	"vitess.io/vitess/go/acl"
 	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/tabletenv"
 )
 
 var (
@@ -121,11 +122,17 @@ func debugEnvHandler(tsv *TabletServer, w http.ResponseWriter, r *http.Request)
 		case "WarnResultSize":
 			setIntVal(tsv.SetWarnResultSize)
 		case "RowStreamerMaxInnoDBTrxHistLen":
-			setInt64Val(func(val int64) { tsv.Config().RowStreamer.MaxInnoDBTrxHistLen = val })
+			setInt64Val(func(val int64) {
+				tsv.config.Update(func(config *tabletenv.TabletConfig) { config.RowStreamer.MaxInnoDBTrxHistLen = val })
+			})
 		case "RowStreamerMaxMySQLReplLagSecs":
-			setInt64Val(func(val int64) { tsv.Config().RowStreamer.MaxMySQLReplLagSecs = val })
+			setInt64Val(func(val int64) {
+				tsv.config.Update(func(config *tabletenv.TabletConfig) { config.RowStreamer.MaxMySQLReplLagSecs = val })
+			})
 		case "UnhealthyThreshold":
-			setDurationVal(func(d time.Duration) { tsv.Config().Healthcheck.UnhealthyThreshold = d })
+			setDurationVal(func(d time.Duration) {
+				tsv.config.Update(func(config *tabletenv.TabletConfig) { config.Healthcheck.UnhealthyThreshold = d })
+			})
 			setDurationVal(tsv.hs.SetUnhealthyThreshold)
 			setDurationVal(tsv.sm.SetUnhealthyThreshold)
 		case "ThrottleMetricThreshold":
@@ -62,6 +62,9 @@ type TabletPlan struct {
 	Original   string
 	Rules      *rules.Rules
 	Authorized []*tableacl.ACLResult
+	// Internal is set if the query uses the tables of the sidecar database,
+	// of a system schema, or of online DDL and table GC.
+	Internal bool
 
 	QueryCount   uint64
 	Time         uint64
@@ -367,7 +370,7 @@ func (qe *QueryEngine) getPlan(curSchema *currentSchema, sql string) (*TabletPla
 	if err != nil {
 		return nil, err
 	}
-	plan := &TabletPlan{Plan: splan, Original: sql}
+	plan := &TabletPlan{Plan: splan, Original: sql, Internal: isInternalQuery(statement)}
 	plan.Rules = qe.queryRuleSources.FilterByPlan(sql, plan.PlanID, plan.TableNames()...)
 	plan.buildAuthorized()
 	if sqlparser.CachePlan(statement) {
@@ -413,7 +416,7 @@ func (qe *QueryEngine) getStreamPlan(curSchema *currentSchema, sql string) (*Tab
 		return nil, err
 	}
 
-	plan := &TabletPlan{Plan: splan, Original: sql}
+	plan := &TabletPlan{Plan: splan, Original: sql, Internal: isInternalQuery(statement)}
 	plan.Rules = qe.queryRuleSources.FilterByPlan(sql, plan.PlanID, plan.TableName().String())
 	plan.buildAuthorized()
 
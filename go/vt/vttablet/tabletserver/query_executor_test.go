@@ -306,7 +306,7 @@ func TestQueryExecutorPlans(t *testing.T) {
 			if tcase.txThrottler != nil {
 				tsv.txThrottler = tcase.txThrottler
 			}
-			tsv.config.DB.DBName = "ks"
+			tsv.Config().DB.DBName = "ks"
 			defer tsv.StopService()
 
 			tsv.SetPassthroughDMLs(tcase.passThrough)
@@ -401,8 +401,8 @@ func TestQueryExecutorQueryAnnotation(t *testing.T) {
 			}
 			ctx := callerid.NewContext(context.Background(), nil, callerID)
 			tsv := newTestTabletServer(ctx, noFlags, db)
-			tsv.config.DB.DBName = "ks"
-			tsv.config.AnnotateQueries = true
+			tsv.Config().DB.DBName = "ks"
+			tsv.Config().AnnotateQueries = true
 			defer tsv.StopService()
 
 			tsv.SetPassthroughDMLs(tcase.passThrough)
@@ -1779,7 +1779,7 @@ func TestQueryExecSchemaReloadCount(t *testing.T) {
 	db := setUpQueryExecutorTest(t)
 	defer db.Close()
 	tsv := newTestTabletServer(context.Background(), noFlags, db)
-	tsv.config.DB.DBName = "ks"
+	tsv.Config().DB.DBName = "ks"
 	defer tsv.StopService()
 	for _, tcase := range testcases {
 		t.Run(tcase.input, func(t *testing.T) {
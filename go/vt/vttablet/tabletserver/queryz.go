@@ -25,8 +25,11 @@ import (
 	"github.com/google/safehtml/template"
 
 	"vitess.io/vitess/go/acl"
+	"vitess.io/vitess/go/constants/sidecar"
 	"vitess.io/vitess/go/vt/log"
 	"vitess.io/vitess/go/vt/logz"
+	"vitess.io/vitess/go/vt/schema"
+	"vitess.io/vitess/go/vt/sqlparser"
 	"vitess.io/vitess/go/vt/vttablet/tabletserver/planbuilder"
 )
 
@@ -136,11 +139,36 @@ func (s *queryzSorter) Len() int           { return len(s.rows) }
 func (s *queryzSorter) Swap(i, j int)      { s.rows[i], s.rows[j] = s.rows[j], s.rows[i] }
 func (s *queryzSorter) Less(i, j int) bool { return s.less(s.rows[i], s.rows[j]) }
 
+// isInternalQuery returns whether the statement uses a table of the sidecar
+// database or a system schema, or one of the internal tables of online DDL
+// and table GC. It is computed once when the plan is built.
+func isInternalQuery(stmt sqlparser.Statement) bool {
+	internal := false
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		tableName, ok := node.(sqlparser.TableName)
+		if !ok {
+			return true, nil
+		}
+		qualifier := tableName.Qualifier.String()
+		if qualifier == sidecar.GetName() || sqlparser.SystemSchema(qualifier) || schema.IsInternalOperationTableName(tableName.Name.String()) {
+			internal = true
+			return false, nil
+		}
+		return true, nil
+	}, stmt)
+	return internal
+}
+
 func queryzHandler(qe *QueryEngine, w http.ResponseWriter, r *http.Request) {
 	if err := acl.CheckAccessHTTP(r, acl.DEBUGGING); err != nil {
 		acl.SendError(w, err)
 		return
 	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, fmt.Sprintf("cannot parse form: %s", err), http.StatusInternalServerError)
+		return
+	}
+	excludeInternal := r.FormValue("exclude_internal") == "true"
 	logz.StartHTMLTable(w)
 	defer logz.EndHTMLTable(w)
 	w.Write(queryzHeader)
@@ -155,6 +183,9 @@ func queryzHandler(qe *QueryEngine, w http.ResponseWriter, r *http.Request) {
 		if plan == nil {
 			return true
 		}
+		if excludeInternal && plan.Internal {
+			return true
+		}
 		Value := &queryzRow{
 			Query: logz.Wrappable(qe.env.SQLParser().TruncateForUI(plan.Original)),
 			Table: plan.TableName().String(),
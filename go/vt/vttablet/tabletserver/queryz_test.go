@@ -165,6 +165,65 @@ func TestQueryzHandler(t *testing.T) {
 	checkQueryzHasPlan(t, planPattern4, plan4, body)
 }
 
+func TestQueryzHandlerExcludeInternal(t *testing.T) {
+	qe := newTestQueryEngine(10*time.Second, true, &dbconfigs.DBConfigs{})
+
+	queries := []string{
+		"select name from test_table",
+		"select id from _vt.vreplication",
+		"select 1 from information_schema.tables",
+		"drop table _vt_hld_6ace8bcef73211ea87e9f875a4d24e90_20200915120410_",
+	}
+	for i, query := range queries {
+		plan := &TabletPlan{
+			Original: query,
+			Plan:     &planbuilder.Plan{PlanID: planbuilder.PlanSelect},
+			Internal: i != 0,
+		}
+		plan.AddStats(1, time.Millisecond, time.Millisecond, 0, 1, 0)
+		qe.plans.Set(PlanCacheKey(query), plan, 0, 0)
+	}
+	// Wait for cache to settle
+	time.Sleep(100 * time.Millisecond)
+
+	for _, excludeInternal := range []bool{false, true} {
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/queryz?exclude_internal=%t", excludeInternal), nil)
+		queryzHandler(qe, resp, req)
+		body, _ := io.ReadAll(resp.Body)
+		for i, query := range queries {
+			want := i == 0 || !excludeInternal
+			if got := strings.Contains(string(body), "<td>"+query+"</td>"); got != want {
+				t.Errorf("exclude_internal=%t: queryz lists %q: %t, want %t", excludeInternal, query, got, want)
+			}
+		}
+	}
+}
+
+func TestIsInternalQuery(t *testing.T) {
+	parser := sqlparser.NewTestParser()
+	for _, tc := range []struct {
+		query    string
+		internal bool
+	}{
+		{"select name from test_table", false},
+		{"select name from test_table join other_table using (id)", false},
+		{"select id from _vt.vreplication", true},
+		{"select t.id from test_table t join _vt.vreplication v on t.id = v.id", true},
+		{"select 1 from information_schema.tables", true},
+		{"select 1 from test_table where id in (select id from mysql.user)", true},
+		{"drop table _vt_hld_6ace8bcef73211ea87e9f875a4d24e90_20200915120410_", true},
+	} {
+		stmt, err := parser.Parse(tc.query)
+		if err != nil {
+			t.Fatalf("cannot parse %q: %v", tc.query, err)
+		}
+		if got := isInternalQuery(stmt); got != tc.internal {
+			t.Errorf("isInternalQuery(%q): %t, want %t", tc.query, got, tc.internal)
+		}
+	}
+}
+
 func checkQueryzHasPlan(t *testing.T, planPattern []string, plan *TabletPlan, page []byte) {
 	matcher := regexp.MustCompile(strings.Join(planPattern, `\s*`))
 	if !matcher.Match(page) {
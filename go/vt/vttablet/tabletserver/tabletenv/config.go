@@ -858,6 +858,9 @@ func (c *TabletConfig) TxTimeoutForWorkload(workload querypb.ExecuteOptions_Work
 
 // Verify checks for contradicting flags.
 func (c *TabletConfig) Verify() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
 	if err := c.verifyTransactionLimitConfig(); err != nil {
 		return err
 	}
@@ -879,6 +882,56 @@ func (c *TabletConfig) Verify() error {
 	return nil
 }
 
+// Validate checks the numeric settings of the config that would make the
+// sub-components fail later: the pool sizes and the intervals must be
+// positive, and the timeouts can't be negative, zero disabling them. The
+// error names the flag and config field of the first invalid setting.
+func (c *TabletConfig) Validate() error {
+	positiveInts := []struct {
+		flag, field string
+		value       int
+	}{
+		{"queryserver-config-pool-size", "OltpReadPool.Size", c.OltpReadPool.Size},
+		{"queryserver-config-stream-pool-size", "OlapReadPool.Size", c.OlapReadPool.Size},
+		{"queryserver-config-transaction-cap", "TxPool.Size", c.TxPool.Size},
+		{"queryserver-config-message-postpone-cap", "MessagePostponeParallelism", c.MessagePostponeParallelism},
+		{"queryserver-config-stream-buffer-size", "StreamBufferSize", c.StreamBufferSize},
+	}
+	for _, v := range positiveInts {
+		if v.value <= 0 {
+			return fmt.Errorf("--%s (%s) must be > 0 (specified value: %v)", v.flag, v.field, v.value)
+		}
+	}
+	positiveDurations := []struct {
+		flag, field string
+		value       time.Duration
+	}{
+		{"health_check_interval", "Healthcheck.Interval", c.Healthcheck.Interval},
+	}
+	for _, v := range positiveDurations {
+		if v.value <= 0 {
+			return fmt.Errorf("--%s (%s) must be > 0 (specified value: %v)", v.flag, v.field, v.value)
+		}
+	}
+	timeouts := []struct {
+		flag, field string
+		value       time.Duration
+	}{
+		{"queryserver-config-query-timeout", "Oltp.QueryTimeout", c.Oltp.QueryTimeout},
+		{"queryserver-config-transaction-timeout", "Oltp.TxTimeout", c.Oltp.TxTimeout},
+		{"queryserver-config-olap-transaction-timeout", "Olap.TxTimeout", c.Olap.TxTimeout},
+		{"queryserver-config-query-pool-timeout", "OltpReadPool.Timeout", c.OltpReadPool.Timeout},
+		{"queryserver-config-stream-pool-timeout", "OlapReadPool.Timeout", c.OlapReadPool.Timeout},
+		{"queryserver-config-txpool-timeout", "TxPool.Timeout", c.TxPool.Timeout},
+	}
+	for _, v := range timeouts {
+		if v.value < 0 {
+			return fmt.Errorf("--%s (%s) must be >= 0 (specified value: %v)", v.flag, v.field, v.value)
+		}
+	}
+	return nil
+}
+
 // verifyTransactionLimitConfig checks TransactionLimitConfig for sanity
 func (c *TabletConfig) verifyTransactionLimitConfig() error {
 	actual, dryRun := c.EnableTransactionLimit, c.EnableTransactionLimitDryRun
@@ -450,3 +450,79 @@ func TestVerifyTxThrottlerConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		update  func(config *TabletConfig)
+		wantErr string
+	}{
+		{
+			name:   "default",
+			update: func(config *TabletConfig) {},
+		},
+		{
+			name:   "zero timeouts",
+			update: func(config *TabletConfig) { config.Oltp.QueryTimeout = 0; config.TxPool.Timeout = 0 },
+		},
+		{
+			name:    "zero pool size",
+			update:  func(config *TabletConfig) { config.OltpReadPool.Size = 0 },
+			wantErr: "--queryserver-config-pool-size (OltpReadPool.Size) must be > 0 (specified value: 0)",
+		},
+		{
+			name:    "negative stream pool size",
+			update:  func(config *TabletConfig) { config.OlapReadPool.Size = -1 },
+			wantErr: "--queryserver-config-stream-pool-size (OlapReadPool.Size) must be > 0 (specified value: -1)",
+		},
+		{
+			name:    "negative transaction cap",
+			update:  func(config *TabletConfig) { config.TxPool.Size = -1 },
+			wantErr: "--queryserver-config-transaction-cap (TxPool.Size) must be > 0 (specified value: -1)",
+		},
+		{
+			name:    "zero message postpone cap",
+			update:  func(config *TabletConfig) { config.MessagePostponeParallelism = 0 },
+			wantErr: "--queryserver-config-message-postpone-cap (MessagePostponeParallelism) must be > 0 (specified value: 0)",
+		},
+		{
+			name:    "zero stream buffer size",
+			update:  func(config *TabletConfig) { config.StreamBufferSize = 0 },
+			wantErr: "--queryserver-config-stream-buffer-size (StreamBufferSize) must be > 0 (specified value: 0)",
+		},
+		{
+			name:    "zero health check interval",
+			update:  func(config *TabletConfig) { config.Healthcheck.Interval = 0 },
+			wantErr: "--health_check_interval (Healthcheck.Interval) must be > 0 (specified value: 0s)",
+		},
+		{
+			name:    "negative query timeout",
+			update:  func(config *TabletConfig) { config.Oltp.QueryTimeout = -time.Second },
+			wantErr: "--queryserver-config-query-timeout (Oltp.QueryTimeout) must be >= 0 (specified value: -1s)",
+		},
+		{
+			name:    "negative olap transaction timeout",
+			update:  func(config *TabletConfig) { config.Olap.TxTimeout = -time.Second },
+			wantErr: "--queryserver-config-olap-transaction-timeout (Olap.TxTimeout) must be >= 0 (specified value: -1s)",
+		},
+		{
+			name:    "negative txpool timeout",
+			update:  func(config *TabletConfig) { config.TxPool.Timeout = -time.Second },
+			wantErr: "--queryserver-config-txpool-timeout (TxPool.Timeout) must be >= 0 (specified value: -1s)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewDefaultConfig()
+			tt.update(config)
+			err := config.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				assert.NoError(t, config.Verify())
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+			assert.EqualError(t, config.Verify(), tt.wantErr)
+		})
+	}
+}
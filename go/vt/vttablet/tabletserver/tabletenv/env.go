@@ -19,8 +19,16 @@ limitations under the License.
 package tabletenv
 
 import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
 	"vitess.io/vitess/go/mysql/collations"
 	"vitess.io/vitess/go/tb"
+	"vitess.io/vitess/go/trace"
+	"vitess.io/vitess/go/vt/callerid"
 	"vitess.io/vitess/go/vt/log"
 	"vitess.io/vitess/go/vt/servenv"
 	"vitess.io/vitess/go/vt/sqlparser"
@@ -30,45 +38,185 @@ import (
 // that the sub-components need to access.
 type Env interface {
 	CheckMySQL()
+	// Config returns the current config. It must be read again, rather
+	// than kept, by the sub-components that want to see reloads.
 	Config() *TabletConfig
+	// OnConfigChange registers fn to be called with the new config every
+	// time the config is reloaded.
+	OnConfigChange(fn func(config *TabletConfig))
 	Exporter() *servenv.Exporter
 	Stats() *Stats
 	SQLParser() *sqlparser.Parser
 	LogError()
+	// LogErrorWithContext is LogError for the callers that serve a
+	// request: the log line also identifies the caller and the trace of
+	// ctx, see PanicContext.
+	LogErrorWithContext(ctx context.Context)
 	CollationEnv() *collations.Environment
 }
 
+// PanicContext describes ctx in the logs of the panics, with the given
+// fields, such as the tablet alias, then the effective caller and the
+// trace span of ctx, if any. It returns "" if there is nothing to log.
+func PanicContext(ctx context.Context, fields ...string) string {
+	if principal := callerid.GetPrincipal(callerid.EffectiveCallerIDFromContext(ctx)); principal != "" {
+		fields = append(fields, "caller: "+principal)
+	}
+	if id := trace.IDFromContext(ctx); id != "" {
+		fields = append(fields, "trace: "+id)
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", strings.Join(fields, ", "))
+}
+
+// ConfigReloader is implemented by the Envs whose config can be reloaded
+// at runtime, such as the TabletServer.
+type ConfigReloader interface {
+	// SetConfig makes config the current config and notifies the
+	// callbacks registered with OnConfigChange. Only the settings that
+	// are read from Config at use time, or applied by a callback, take
+	// effect: the implementations document which ones.
+	SetConfig(config *TabletConfig)
+}
+
+// ReloadableConfig holds the current config of an Env. Readers get a
+// consistent snapshot from Load: a reload stores a new config rather than
+// changing the current one, so a config must not be modified once it is
+// stored.
+type ReloadableConfig struct {
+	config atomic.Pointer[TabletConfig]
+
+	// mu serializes the reloads, so the callbacks see them in order.
+	mu        sync.Mutex
+	callbacks []func(config *TabletConfig)
+}
+
+// NewReloadableConfig returns a ReloadableConfig holding config.
+func NewReloadableConfig(config *TabletConfig) *ReloadableConfig {
+	rc := &ReloadableConfig{}
+	rc.config.Store(config)
+	return rc
+}
+
+// Load returns the current config.
+func (rc *ReloadableConfig) Load() *TabletConfig {
+	return rc.config.Load()
+}
+
+// Store makes config the current config, then calls the registered
+// callbacks with it. The callbacks must not call Store or OnChange.
+func (rc *ReloadableConfig) Store(config *TabletConfig) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.config.Store(config)
+	for _, fn := range rc.callbacks {
+		fn(config)
+	}
+}
+
+// Update stores a copy of the current config changed by fn, without
+// calling the registered callbacks: it is meant for the settings whose
+// change the caller applies itself, such as those of /debug/env. The copy
+// shares the db configs of the current config.
+func (rc *ReloadableConfig) Update(fn func(config *TabletConfig)) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	config := *rc.config.Load()
+	fn(&config)
+	rc.config.Store(&config)
+}
+
+// OnChange registers fn to be called by Store.
+func (rc *ReloadableConfig) OnChange(fn func(config *TabletConfig)) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.callbacks = append(rc.callbacks, fn)
+}
+
 type testEnv struct {
-	config       *TabletConfig
+	config       *ReloadableConfig
 	exporter     *servenv.Exporter
 	stats        *Stats
 	collationEnv *collations.Environment
 	parser       *sqlparser.Parser
+	checkMySQL   func()
 }
 
 // NewEnv creates an Env that can be used for tabletserver subcomponents
-// without an actual TabletServer.
+// without an actual TabletServer. It implements ConfigReloader, to test
+// the reloads.
 func NewEnv(config *TabletConfig, exporterName string, collationEnv *collations.Environment, parser *sqlparser.Parser) Env {
-	exporter := servenv.NewExporter(exporterName, "Tablet")
+	return NewEnvWithCheckMySQL(config, exporterName, collationEnv, parser, nil)
+}
+
+// NewEnvWithError is NewEnv for a config that has not been validated yet:
+// it returns the error of config.Validate, if any, instead of an Env that
+// would fail later.
+func NewEnvWithError(config *TabletConfig, exporterName string, collationEnv *collations.Environment, parser *sqlparser.Parser) (Env, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return NewEnv(config, exporterName, collationEnv, parser), nil
+}
+
+// NewEnvWithCheckMySQL is NewEnv for an Env whose CheckMySQL calls
+// checkMySQL, for example to simulate MySQL becoming unreachable. A nil
+// checkMySQL makes CheckMySQL a no-op, like in the Envs of NewEnv.
+func NewEnvWithCheckMySQL(config *TabletConfig, exporterName string, collationEnv *collations.Environment, parser *sqlparser.Parser, checkMySQL func()) Env {
+	return newTestEnv(config, servenv.NewExporter(exporterName, "Tablet"), collationEnv, parser, checkMySQL)
+}
+
+// NewEnvWithExporter is NewEnv for an Env whose stats, see Stats, are
+// exported by exporter rather than by a new exporter with the "Tablet"
+// label. It lets the Envs of a process that runs several tablets choose
+// their namespace and label.
+func NewEnvWithExporter(config *TabletConfig, exporter *servenv.Exporter, collationEnv *collations.Environment, parser *sqlparser.Parser) Env {
+	return newTestEnv(config, exporter, collationEnv, parser, nil)
+}
+
+func newTestEnv(config *TabletConfig, exporter *servenv.Exporter, collationEnv *collations.Environment, parser *sqlparser.Parser, checkMySQL func()) *testEnv {
 	return &testEnv{
-		config:       config,
+		config:       NewReloadableConfig(config),
 		exporter:     exporter,
 		stats:        NewStats(exporter),
 		collationEnv: collationEnv,
 		parser:       parser,
+		checkMySQL:   checkMySQL,
 	}
 }
 
-func (*testEnv) CheckMySQL()                              {}
-func (te *testEnv) Config() *TabletConfig                 { return te.config }
+func (te *testEnv) CheckMySQL() {
+	if te.checkMySQL != nil {
+		te.checkMySQL()
+	}
+}
+
+func (te *testEnv) Config() *TabletConfig                 { return te.config.Load() }
 func (te *testEnv) Exporter() *servenv.Exporter           { return te.exporter }
 func (te *testEnv) Stats() *Stats                         { return te.stats }
 func (te *testEnv) CollationEnv() *collations.Environment { return te.collationEnv }
 func (te *testEnv) SQLParser() *sqlparser.Parser          { return te.parser }
 
+func (te *testEnv) SetConfig(config *TabletConfig) {
+	te.config.Store(config)
+}
+
+func (te *testEnv) OnConfigChange(fn func(config *TabletConfig)) {
+	te.config.OnChange(fn)
+}
+
 func (te *testEnv) LogError() {
 	if x := recover(); x != nil {
 		log.Errorf("Uncaught panic:\n%v\n%s", x, tb.Stack(4))
 		te.Stats().InternalErrors.Add("Panic", 1)
 	}
 }
+
+func (te *testEnv) LogErrorWithContext(ctx context.Context) {
+	if x := recover(); x != nil {
+		log.Errorf("Uncaught panic%s:\n%v\n%s", PanicContext(ctx), x, tb.Stack(4))
+		te.Stats().InternalErrors.Add("Panic", 1)
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2024 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tabletenv
+
+import (
+	"context"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/vt/callerid"
+	"vitess.io/vitess/go/vt/dbconfigs"
+	"vitess.io/vitess/go/vt/servenv"
+)
+
+func TestReloadableConfig(t *testing.T) {
+	initial := NewDefaultConfig()
+	env := NewEnv(initial, "TestReloadableConfig", nil, nil)
+	require.Same(t, initial, env.Config())
+
+	var notified []*TabletConfig
+	env.OnConfigChange(func(config *TabletConfig) {
+		notified = append(notified, config)
+	})
+
+	reloaded := NewDefaultConfig()
+	reloaded.Oltp.QueryTimeout = 5 * time.Second
+	env.(ConfigReloader).SetConfig(reloaded)
+	assert.Same(t, reloaded, env.Config())
+	assert.Equal(t, []*TabletConfig{reloaded}, notified)
+	assert.Equal(t, 5*time.Second, env.Config().Oltp.QueryTimeout)
+}
+
+func TestReloadableConfigUpdate(t *testing.T) {
+	initial := NewDefaultConfig()
+	initial.DB = &dbconfigs.DBConfigs{DBName: "db"}
+	rc := NewReloadableConfig(initial)
+	notified := 0
+	rc.OnChange(func(config *TabletConfig) {
+		notified++
+	})
+
+	rc.Update(func(config *TabletConfig) {
+		config.Healthcheck.UnhealthyThreshold = time.Minute
+	})
+	updated := rc.Load()
+	assert.NotSame(t, initial, updated)
+	assert.Equal(t, time.Minute, updated.Healthcheck.UnhealthyThreshold)
+	assert.Same(t, initial.DB, updated.DB)
+	// The stored config, which readers may still hold, is left as it was.
+	assert.Equal(t, NewDefaultConfig().Healthcheck.UnhealthyThreshold, initial.Healthcheck.UnhealthyThreshold)
+	assert.Zero(t, notified)
+}
+
+func TestNewEnvWithCheckMySQL(t *testing.T) {
+	NewEnv(NewDefaultConfig(), "TestNewEnvWithCheckMySQL", nil, nil).CheckMySQL()
+
+	checks := 0
+	env := NewEnvWithCheckMySQL(NewDefaultConfig(), "TestNewEnvWithCheckMySQL", nil, nil, func() { checks++ })
+	env.CheckMySQL()
+	env.CheckMySQL()
+	assert.Equal(t, 2, checks)
+}
+
+func TestNewEnvWithError(t *testing.T) {
+	env, err := NewEnvWithError(NewDefaultConfig(), "TestNewEnvWithError", nil, nil)
+	require.NoError(t, err)
+	require.NotNil(t, env)
+
+	config := NewDefaultConfig()
+	config.TxPool.Size = -1
+	env, err = NewEnvWithError(config, "TestNewEnvWithError", nil, nil)
+	require.EqualError(t, err, "--queryserver-config-transaction-cap (TxPool.Size) must be > 0 (specified value: -1)")
+	require.Nil(t, env)
+}
+
+func TestNewEnvWithExporter(t *testing.T) {
+	exporter := servenv.NewExporter("TestNewEnvWithExporter", "Keyspace")
+	env := NewEnvWithExporter(NewDefaultConfig(), exporter, nil, nil)
+	assert.Same(t, exporter, env.Exporter())
+
+	env.Stats().InternalErrors.Add("Task", 1)
+	assert.Contains(t, expvar.Get("InternalErrors").String(), `"TestNewEnvWithExporter.Task": 1`)
+}
+
+func TestPanicContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Empty(t, PanicContext(ctx))
+	assert.Equal(t, " (tablet: zone1-0000000100)", PanicContext(ctx, "tablet: zone1-0000000100"))
+
+	ctx = callerid.NewContext(ctx, callerid.NewEffectiveCallerID("user1", "", ""), nil)
+	assert.Equal(t, " (tablet: zone1-0000000100, caller: user1)", PanicContext(ctx, "tablet: zone1-0000000100"))
+}
+
+func TestLogErrorWithContext(t *testing.T) {
+	env := NewEnv(NewDefaultConfig(), "TestLogErrorWithContext", nil, nil)
+	ctx := callerid.NewContext(context.Background(), callerid.NewEffectiveCallerID("user1", "", ""), nil)
+	before := env.Stats().InternalErrors.Counts()["Panic"]
+	func() {
+		defer env.LogErrorWithContext(ctx)
+		panic("boom")
+	}()
+	assert.Equal(t, before+1, env.Stats().InternalErrors.Counts()["Panic"])
+}
@@ -94,7 +94,7 @@ var logComputeRowSerializerKey = logutil.NewThrottledLogger("ComputeRowSerialize
 // a subcomponent. These should also be idempotent.
 type TabletServer struct {
 	exporter               *servenv.Exporter
-	config                 *tabletenv.TabletConfig
+	config                 *tabletenv.ReloadableConfig
 	stats                  *tabletenv.Stats
 	QueryTimeout           atomic.Int64
 	TerseErrors            bool
@@ -161,7 +161,7 @@ func NewTabletServer(ctx context.Context, name string, config *tabletenv.TabletC
 	tsv := &TabletServer{
 		exporter:               exporter,
 		stats:                  tabletenv.NewStats(exporter),
-		config:                 config,
+		config:                 tabletenv.NewReloadableConfig(config),
 		TerseErrors:            config.TerseErrors,
 		TruncateErrorLen:       config.TruncateErrorLen,
 		enableHotRowProtection: config.HotRowProtection.Mode != tabletenv.Disable,
@@ -171,6 +171,7 @@ func NewTabletServer(ctx context.Context, name string, config *tabletenv.TabletC
 		parser:                 parser,
 	}
 	tsv.QueryTimeout.Store(config.Oltp.QueryTimeout.Nanoseconds())
+	tsv.OnConfigChange(tsv.applyConfig)
 
 	tsOnce.Do(func() { srvTopoServer = srvtopo.NewResilientServer(ctx, topoServer, "TabletSrvTopo") })
 
@@ -190,7 +191,7 @@ func NewTabletServer(ctx context.Context, name string, config *tabletenv.TabletC
 	tsv.lagThrottler = throttle.NewThrottler(tsv, srvTopoServer, topoServer, alias.Cell, tsv.rt.HeartbeatWriter(), tabletTypeFunc)
 	tsv.vstreamer = vstreamer.NewEngine(tsv, srvTopoServer, tsv.se, tsv.lagThrottler, alias.Cell)
 	tsv.tracker = schema.NewTracker(tsv, tsv.vstreamer, tsv.se)
-	tsv.watcher = NewBinlogWatcher(tsv, tsv.vstreamer, tsv.config)
+	tsv.watcher = NewBinlogWatcher(tsv, tsv.vstreamer)
 	tsv.qe = NewQueryEngine(tsv, tsv.se)
 	tsv.txThrottler = txthrottler.NewTxThrottler(tsv, topoServer)
 	tsv.te = NewTxEngine(tsv)
@@ -274,13 +275,15 @@ func (tsv *TabletServer) InitDBConfig(target *querypb.Target, dbcfgs *dbconfigs.
 	}
 	tsv.sm.Init(tsv, target)
 	tsv.sm.target = target.CloneVT()
-	tsv.config.DB = dbcfgs
+	tsv.config.Update(func(config *tabletenv.TabletConfig) {
+		config.DB = dbcfgs
+	})
 
-	tsv.se.InitDBConfig(tsv.config.DB.DbaWithDB())
+	tsv.se.InitDBConfig(tsv.Config().DB.DbaWithDB())
 	tsv.rt.InitDBConfig(target, mysqld)
 	tsv.txThrottler.InitDBConfig(target)
 	tsv.vstreamer.InitDBConfig(target.Keyspace, target.Shard)
-	tsv.hs.InitDBConfig(target, tsv.config.DB.DbaWithDB())
+	tsv.hs.InitDBConfig(target, tsv.Config().DB.DbaWithDB())
 	tsv.onlineDDLExecutor.InitDBConfig(target.Keyspace, target.Shard, dbcfgs.DBName)
 	tsv.lagThrottler.InitDBConfig(target.Keyspace, target.Shard)
 	tsv.tableGC.InitDBConfig(target.Keyspace, target.Shard, dbcfgs.DBName)
@@ -302,7 +305,44 @@ func (tsv *TabletServer) Exporter() *servenv.Exporter {
 
 // Config satisfies tabletenv.Env.
 func (tsv *TabletServer) Config() *tabletenv.TabletConfig {
-	return tsv.config
+	return tsv.config.Load()
+}
+
+// OnConfigChange satisfies tabletenv.Env.
+func (tsv *TabletServer) OnConfigChange(fn func(config *tabletenv.TabletConfig)) {
+	tsv.config.OnChange(fn)
+}
+
+// SetConfig satisfies tabletenv.ConfigReloader. Only some settings of the
+// new config take effect without a restart:
+//   - the OLTP query timeout and the sizes of the OLTP read, OLAP read and
+//     transaction pools, which are applied right away;
+//   - whether the queries are annotated, which is read from Config for
+//     every query;
+//   - whether the replication is watched, which is read from Config the
+//     next time the tablet transitions to a non-primary type.
+//
+// The sub-components read the other settings, such as the terse errors or
+// the hot row protection, when they're created, so changing them has no
+// effect until the tablet restarts. The db configs can't be reloaded: those
+// of the current config are kept.
+func (tsv *TabletServer) SetConfig(config *tabletenv.TabletConfig) {
+	config = config.Clone()
+	config.DB = tsv.Config().DB
+	tsv.config.Store(config)
+}
+
+// applyConfig applies the settings of a reloaded config that can change
+// at runtime.
+func (tsv *TabletServer) applyConfig(config *tabletenv.TabletConfig) {
+	tsv.QueryTimeout.Store(config.Oltp.QueryTimeout.Nanoseconds())
+	tsv.SetPoolSize(config.OltpReadPool.Size)
+	if config.OlapReadPool.Size > 0 {
+		tsv.SetStreamPoolSize(config.OlapReadPool.Size)
+	}
+	if config.TxPool.Size > 0 {
+		tsv.SetTxPoolSize(config.TxPool.Size)
+	}
 }
 
 // Stats satisfies tabletenv.Env.
@@ -323,6 +363,14 @@ func (tsv *TabletServer) LogError() {
 	}
 }
 
+// LogErrorWithContext satisfies tabletenv.Env.
+func (tsv *TabletServer) LogErrorWithContext(ctx context.Context) {
+	if x := recover(); x != nil {
+		log.Errorf("Uncaught panic%s:\n%v\n%s", tabletenv.PanicContext(ctx, "tablet: "+topoproto.TabletAliasString(tsv.alias)), x, tb.Stack(4))
+		tsv.stats.InternalErrors.Add("Panic", 1)
+	}
+}
+
 // RegisterQueryRuleSource registers ruleSource for setting query rules.
 func (tsv *TabletServer) RegisterQueryRuleSource(ruleSource string) {
 	tsv.qe.queryRuleSources.RegisterSource(ruleSource)
@@ -545,7 +593,7 @@ func (tsv *TabletServer) begin(ctx context.Context, target *querypb.Target, save
 }
 
 func (tsv *TabletServer) getPriorityFromOptions(options *querypb.ExecuteOptions) int {
-	priority := tsv.config.TxThrottlerDefaultPriority
+	priority := tsv.Config().TxThrottlerDefaultPriority
 	if options == nil {
 		return priority
 	}
@@ -808,7 +856,7 @@ func (tsv *TabletServer) execute(ctx context.Context, target *querypb.Target, sq
 		allowOnShutdown = true
 		// Execute calls happen for OLTP only, so we can directly fetch the
 		// OLTP TX timeout.
-		txTimeout := tsv.config.TxTimeoutForWorkload(querypb.ExecuteOptions_OLTP)
+		txTimeout := tsv.Config().TxTimeoutForWorkload(querypb.ExecuteOptions_OLTP)
 		// Use the smaller of the two values (0 means infinity).
 		// TODO(sougou): Assign deadlines to each transaction and set query timeout accordingly.
 		timeout = smallerTimeout(timeout, txTimeout)
@@ -869,10 +917,10 @@ func (tsv *TabletServer) execute(ctx context.Context, target *querypb.Target, sq
 			result = result.StripMetadata(sqltypes.IncludeFieldsOrDefault(options))
 
 			// Change database name in mysql output to the keyspace name
-			if tsv.sm.target.Keyspace != tsv.config.DB.DBName && sqltypes.IncludeFieldsOrDefault(options) == querypb.ExecuteOptions_ALL {
+			if tsv.sm.target.Keyspace != tsv.Config().DB.DBName && sqltypes.IncludeFieldsOrDefault(options) == querypb.ExecuteOptions_ALL {
 				switch qre.plan.PlanID {
 				case planbuilder.PlanSelect, planbuilder.PlanSelectImpossible:
-					dbName := tsv.config.DB.DBName
+					dbName := tsv.Config().DB.DBName
 					ksName := tsv.sm.target.Keyspace
 					for _, f := range result.Fields {
 						if f.Database == dbName {
@@ -917,7 +965,7 @@ func (tsv *TabletServer) streamExecute(ctx context.Context, target *querypb.Targ
 		allowOnShutdown = true
 		// Use the transaction timeout. StreamExecute calls happen for OLAP only,
 		// so we can directly fetch the OLAP TX timeout.
-		timeout = tsv.config.TxTimeoutForWorkload(querypb.ExecuteOptions_OLAP)
+		timeout = tsv.Config().TxTimeoutForWorkload(querypb.ExecuteOptions_OLAP)
 	}
 
 	return tsv.execRequest(
@@ -1230,7 +1278,7 @@ func (tsv *TabletServer) VStreamResults(ctx context.Context, target *querypb.Tar
 
 // ReserveBeginExecute implements the QueryService interface
 func (tsv *TabletServer) ReserveBeginExecute(ctx context.Context, target *querypb.Target, preQueries []string, postBeginQueries []string, sql string, bindVariables map[string]*querypb.BindVariable, options *querypb.ExecuteOptions) (state queryservice.ReservedTransactionState, result *sqltypes.Result, err error) {
-	if tsv.config.EnableSettingsPool {
+	if tsv.Config().EnableSettingsPool {
 		state, result, err = tsv.beginExecuteWithSettings(ctx, target, preQueries, postBeginQueries, sql, bindVariables, options)
 		// If there is an error and the error message is about allowing query in reserved connection only,
 		// then we do not return an error from here and continue to use the reserved connection path.
@@ -1290,7 +1338,7 @@ func (tsv *TabletServer) ReserveBeginStreamExecute(
 	options *querypb.ExecuteOptions,
 	callback func(*sqltypes.Result) error,
 ) (state queryservice.ReservedTransactionState, err error) {
-	if tsv.config.EnableSettingsPool {
+	if tsv.Config().EnableSettingsPool {
 		return tsv.beginStreamExecuteWithSettings(ctx, target, preQueries, postBeginQueries, sql, bindVariables, options, callback)
 	}
 
@@ -1332,7 +1380,7 @@ func (tsv *TabletServer) ReserveBeginStreamExecute(
 
 // ReserveExecute implements the QueryService interface
 func (tsv *TabletServer) ReserveExecute(ctx context.Context, target *querypb.Target, preQueries []string, sql string, bindVariables map[string]*querypb.BindVariable, transactionID int64, options *querypb.ExecuteOptions) (state queryservice.ReservedState, result *sqltypes.Result, err error) {
-	if tsv.config.EnableSettingsPool {
+	if tsv.Config().EnableSettingsPool {
 		result, err = tsv.executeWithSettings(ctx, target, preQueries, sql, bindVariables, transactionID, options)
 		// If there is an error and the error message is about allowing query in reserved connection only,
 		// then we do not return an error from here and continue to use the reserved connection path.
@@ -1350,7 +1398,7 @@ func (tsv *TabletServer) ReserveExecute(ctx context.Context, target *querypb.Tar
 		allowOnShutdown = true
 		// ReserveExecute is for OLTP only, so we can directly fetch the OLTP
 		// TX timeout.
-		txTimeout := tsv.config.TxTimeoutForWorkload(querypb.ExecuteOptions_OLTP)
+		txTimeout := tsv.Config().TxTimeoutForWorkload(querypb.ExecuteOptions_OLTP)
 		// Use the smaller of the two values (0 means infinity).
 		timeout = smallerTimeout(timeout, txTimeout)
 	}
@@ -1395,7 +1443,7 @@ func (tsv *TabletServer) ReserveStreamExecute(
 	options *querypb.ExecuteOptions,
 	callback func(*sqltypes.Result) error,
 ) (state queryservice.ReservedState, err error) {
-	if tsv.config.EnableSettingsPool {
+	if tsv.Config().EnableSettingsPool {
 		return state, tsv.streamExecute(ctx, target, sql, bindVariables, transactionID, 0, preQueries, options, callback)
 	}
 
@@ -1407,7 +1455,7 @@ func (tsv *TabletServer) ReserveStreamExecute(
 		allowOnShutdown = true
 		// Use the transaction timeout. ReserveStreamExecute is used for OLAP
 		// only, so we can directly fetch the OLAP TX timeout.
-		timeout = tsv.config.TxTimeoutForWorkload(querypb.ExecuteOptions_OLAP)
+		timeout = tsv.Config().TxTimeoutForWorkload(querypb.ExecuteOptions_OLAP)
 	}
 
 	err = tsv.execRequest(
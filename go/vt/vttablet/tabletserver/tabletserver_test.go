@@ -52,7 +52,9 @@ import (
 	"vitess.io/vitess/go/vt/topo/memorytopo"
 	"vitess.io/vitess/go/vt/vterrors"
 	"vitess.io/vitess/go/vt/vttablet/tabletserver/tabletenv"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/throttle/throttlerapp"
 
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
 	querypb "vitess.io/vitess/go/vt/proto/query"
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
@@ -670,7 +672,7 @@ func TestTabletServerReserveConnection(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	db, tsv := setupTabletServerTest(t, ctx, "")
-	tsv.config.EnableSettingsPool = false
+	tsv.Config().EnableSettingsPool = false
 	defer tsv.StopService()
 	defer db.Close()
 
@@ -742,7 +744,7 @@ func TestTabletServerReserveAndBeginCommit(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	db, tsv := setupTabletServerTest(t, ctx, "")
-	tsv.config.EnableSettingsPool = false
+	tsv.Config().EnableSettingsPool = false
 	defer tsv.StopService()
 	defer db.Close()
 
@@ -2042,6 +2044,59 @@ func TestACLHUP(t *testing.T) {
 	}
 }
 
+func TestSetConfig(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db, tsv := setupTabletServerTest(t, ctx, "")
+	defer tsv.StopService()
+	defer db.Close()
+
+	var notified *tabletenv.TabletConfig
+	tsv.OnConfigChange(func(config *tabletenv.TabletConfig) {
+		notified = config
+	})
+
+	dbcfgs := tsv.Config().DB
+	config := tabletenv.NewDefaultConfig()
+	config.OltpReadPool.Size = 7
+	config.OlapReadPool.Size = 8
+	config.TxPool.Size = 9
+	config.Oltp.QueryTimeout = 5 * time.Second
+	tsv.SetConfig(config)
+
+	require.Same(t, tsv.Config(), notified)
+	require.Same(t, dbcfgs, tsv.Config().DB)
+	require.Nil(t, config.DB, "the given config must not be modified")
+	assert.Equal(t, 7, tsv.PoolSize())
+	assert.Equal(t, 8, tsv.StreamPoolSize())
+	assert.Equal(t, 9, tsv.TxPoolSize())
+	assert.Equal(t, 5*time.Second, tsv.loadQueryTimeout())
+}
+
+type blockingVStreamer struct{}
+
+func (blockingVStreamer) Stream(ctx context.Context, startPos string, tablePKs []*binlogdatapb.TableLastPK, filter *binlogdatapb.Filter, throttlerApp throttlerapp.Name, send func([]*binlogdatapb.VEvent) error) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestSetConfigWatchReplication(t *testing.T) {
+	config := tabletenv.NewDefaultConfig()
+	env := tabletenv.NewEnv(config, "TestSetConfigWatchReplication", collations.MySQL8(), sqlparser.NewTestParser())
+	blw := NewBinlogWatcher(env, blockingVStreamer{})
+	blw.Open()
+	require.Nil(t, blw.cancel)
+
+	// The watcher picks up the reloaded config when it's opened.
+	config = config.Clone()
+	config.WatchReplication = true
+	env.(tabletenv.ConfigReloader).SetConfig(config)
+	blw.Open()
+	require.NotNil(t, blw.cancel)
+	blw.Close()
+	require.Nil(t, blw.cancel)
+}
+
 func TestConfigChanges(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -2105,7 +2160,7 @@ func TestReserveBeginExecute(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	db, tsv := setupTabletServerTest(t, ctx, "")
-	tsv.config.EnableSettingsPool = false
+	tsv.Config().EnableSettingsPool = false
 	defer tsv.StopService()
 	defer db.Close()
 	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
@@ -2132,7 +2187,7 @@ func TestReserveExecute_WithoutTx(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	db, tsv := setupTabletServerTest(t, ctx, "")
-	tsv.config.EnableSettingsPool = false
+	tsv.Config().EnableSettingsPool = false
 	defer tsv.StopService()
 	defer db.Close()
 
@@ -2157,7 +2212,7 @@ func TestReserveExecute_WithTx(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	db, tsv := setupTabletServerTest(t, ctx, "")
-	tsv.config.EnableSettingsPool = false
+	tsv.Config().EnableSettingsPool = false
 	defer tsv.StopService()
 	defer db.Close()
 	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
@@ -2219,7 +2274,7 @@ func TestRelease(t *testing.T) {
 			ctx, cancel := context.WithCancel(context.Background())
 			defer cancel()
 			db, tsv := setupTabletServerTest(t, ctx, "")
-			tsv.config.EnableSettingsPool = false
+			tsv.Config().EnableSettingsPool = false
 			defer tsv.StopService()
 			defer db.Close()
 			db.AddQueryPattern(".*", &sqltypes.Result{})
@@ -2264,7 +2319,7 @@ func TestReserveStats(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	db, tsv := setupTabletServerTest(t, ctx, "")
-	tsv.config.EnableSettingsPool = false
+	tsv.Config().EnableSettingsPool = false
 	defer tsv.StopService()
 	defer db.Close()
 
@@ -2431,7 +2486,7 @@ func TestDatabaseNameReplaceByKeyspaceNameBeginExecuteMethod(t *testing.T) {
 }
 
 func setDBName(db *fakesqldb.DB, tsv *TabletServer, s string) {
-	tsv.config.DB.DBName = "databaseInMysql"
+	tsv.Config().DB.DBName = "databaseInMysql"
 	db.SetName("databaseInMysql")
 }
 
@@ -2439,7 +2494,7 @@ func TestDatabaseNameReplaceByKeyspaceNameReserveExecuteMethod(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	db, tsv := setupTabletServerTest(t, ctx, "keyspaceName")
-	tsv.config.EnableSettingsPool = false
+	tsv.Config().EnableSettingsPool = false
 	setDBName(db, tsv, "databaseInMysql")
 	defer tsv.StopService()
 	defer db.Close()
@@ -97,6 +97,7 @@ type TabletManagerClient interface {
 	ExecuteHook(ctx context.Context, tablet *topodatapb.Tablet, hk *hook.Hook) (*hook.HookResult, error)
 
 	// RefreshState asks the remote tablet to reload its tablet record
+	// and its DB credentials
 	RefreshState(ctx context.Context, tablet *topodatapb.Tablet) error
 
 	// RunHealthCheck asks the remote tablet to run a health check cycle
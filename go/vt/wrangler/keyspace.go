@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
@@ -44,6 +45,12 @@ const (
 // validateNewWorkflow ensures that the specified workflow doesn't already exist
 // in the keyspace.
 func (wr *Wrangler) validateNewWorkflow(ctx context.Context, keyspace, workflow string) error {
+	return wr.validateNewWorkflowIgnoringFrozen(ctx, keyspace, workflow, nil)
+}
+
+// validateNewWorkflowIgnoringFrozen is validateNewWorkflow without the check
+// for previous frozen workflows on the given shards.
+func (wr *Wrangler) validateNewWorkflowIgnoringFrozen(ctx context.Context, keyspace, workflow string, ignoreFrozenShards []string) error {
 	allshards, err := wr.ts.FindAllShardsInKeyspace(ctx, keyspace, nil)
 	if err != nil {
 		return err
@@ -70,11 +77,17 @@ func (wr *Wrangler) validateNewWorkflow(ctx context.Context, keyspace, workflow
 			}{{
 				fmt.Sprintf("select 1 from _vt.vreplication where db_name=%s and workflow=%s", encodeString(primary.DbName()), encodeString(workflow)),
 				fmt.Sprintf("workflow %s already exists in keyspace %s on tablet %d", workflow, keyspace, primary.Alias.Uid),
-			}, {
-				fmt.Sprintf("select 1 from _vt.vreplication where db_name=%s and message='FROZEN' and workflow_sub_type != %d", encodeString(primary.DbName()), binlogdatapb.VReplicationWorkflowSubType_Partial),
-				fmt.Sprintf("found previous frozen workflow on tablet %d, please review and delete it first before creating a new workflow",
-					primary.Alias.Uid),
 			}}
+			if !slices.Contains(ignoreFrozenShards, si.ShardName()) {
+				validations = append(validations, struct {
+					query string
+					msg   string
+				}{
+					fmt.Sprintf("select 1 from _vt.vreplication where db_name=%s and message='FROZEN' and workflow_sub_type != %d", encodeString(primary.DbName()), binlogdatapb.VReplicationWorkflowSubType_Partial),
+					fmt.Sprintf("found previous frozen workflow on tablet %d, please review and delete it first before creating a new workflow",
+						primary.Alias.Uid),
+				})
+			}
 			for _, validation := range validations {
 				p3qr, err := wr.tmc.VReplicationExec(ctx, primary.Tablet, validation.query)
 				if err != nil {
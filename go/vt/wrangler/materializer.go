@@ -134,6 +134,7 @@ func (wr *Wrangler) MoveTables(ctx context.Context, workflow, sourceKeyspace, ta
 	cell, tabletTypesStr string, allTables bool, excludeTables string, autoStart, stopAfterCopy bool,
 	externalCluster string, dropForeignKeys, deferSecondaryKeys bool, sourceTimeZone, onDDL string,
 	sourceShards []string, noRoutingRules bool, atomicCopy bool) (err error) {
+	defer wr.recordAction("MoveTables", time.Now())
 	//FIXME validate tableSpecs, allTables, excludeTables
 	var tables []string
 	var externalTopo *topo.Server
@@ -1052,6 +1053,7 @@ func (wr *Wrangler) prepareMaterializerStreams(ctx context.Context, ms *vtctldat
 
 // Materialize performs the steps needed to materialize a list of tables based on the materialization specs.
 func (wr *Wrangler) Materialize(ctx context.Context, ms *vtctldatapb.MaterializeSettings) error {
+	defer wr.recordAction("Materialize", time.Now())
 	mz, err := wr.prepareMaterializerStreams(ctx, ms)
 	if err != nil {
 		return err
@@ -1118,7 +1120,7 @@ func (wr *Wrangler) buildMaterializer(ctx context.Context, ms *vtctldatapb.Mater
 		return nil, fmt.Errorf("no target shards specified for workflow %s ", ms.Workflow)
 	}
 
-	sourceTs := wr.ts
+	sourceTs := wr.sourceTs
 	if ms.ExternalCluster != "" { // when the source is an external mysql cluster mounted using the Mount command
 		externalTopo, err := wr.ts.OpenExternalVitessClusterServer(ctx, ms.ExternalCluster)
 		if err != nil {
@@ -3742,3 +3742,42 @@ func TestKeyRangesEqualOptimization(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildMaterializerSourceTopo(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sourceTs := memorytopo.NewServer(ctx, "cell")
+	defer sourceTs.Close()
+	targetTs := memorytopo.NewServer(ctx, "cell")
+	defer targetTs.Close()
+
+	require.NoError(t, sourceTs.CreateKeyspace(ctx, "sourceks", &topodatapb.Keyspace{}))
+	for _, shard := range []string{"-80", "80-"} {
+		require.NoError(t, sourceTs.CreateShard(ctx, "sourceks", shard))
+	}
+	require.NoError(t, sourceTs.SaveVSchema(ctx, "sourceks", &vschemapb.Keyspace{}))
+	require.NoError(t, targetTs.CreateKeyspace(ctx, "targetks", &topodatapb.Keyspace{}))
+	require.NoError(t, targetTs.CreateShard(ctx, "targetks", "0"))
+	require.NoError(t, targetTs.SaveVSchema(ctx, "targetks", &vschemapb.Keyspace{}))
+
+	ms := &vtctldatapb.MaterializeSettings{
+		Workflow:       "workflow",
+		SourceKeyspace: "sourceks",
+		TargetKeyspace: "targetks",
+	}
+	wr := New(logutil.NewConsoleLogger(), targetTs, newTestMaterializerTMClient(), collations.MySQL8(), sqlparser.NewTestParser())
+
+	// The source keyspace isn't in the topo server of the wrangler.
+	_, err := wr.buildMaterializer(ctx, ms)
+	require.Error(t, err)
+
+	wr.SetSourceTopoServer(sourceTs)
+	mz, err := wr.buildMaterializer(ctx, ms)
+	require.NoError(t, err)
+	var shards []string
+	for _, si := range mz.sourceShards {
+		shards = append(shards, si.ShardName())
+	}
+	require.ElementsMatch(t, []string{"-80", "80-"}, shards)
+	require.Len(t, mz.targetShards, 1)
+}
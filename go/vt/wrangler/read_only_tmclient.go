@@ -0,0 +1,267 @@
+/*
+Copyright 2024 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wrangler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"vitess.io/vitess/go/vt/hook"
+	"vitess.io/vitess/go/vt/logutil"
+	"vitess.io/vitess/go/vt/mysqlctl/tmutils"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vttablet/tmclient"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	replicationdatapb "vitess.io/vitess/go/vt/proto/replicationdata"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+var _ tmclient.TabletManagerClient = (*readOnlyTMClient)(nil)
+
+// readOnlyTMClient is the tablet manager client of the read-only
+// operations, such as ReshardPreflightReadOnly. It only passes on the
+// read RPCs they need to the client it wraps: VReplicationExec with a
+// select or show statement, FullStatus and GetSchema. Every other call
+// fails, so a new call in these operations fails until it is reviewed and
+// added here.
+type readOnlyTMClient struct {
+	tmc tmclient.TabletManagerClient
+}
+
+func errReadOnlyTMClient(call string) error {
+	return vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "%s is not allowed in a read-only operation", call)
+}
+
+func (tmc *readOnlyTMClient) VReplicationExec(ctx context.Context, tablet *topodatapb.Tablet, query string) (*querypb.QueryResult, error) {
+	switch sqlparser.Preview(query) {
+	case sqlparser.StmtSelect, sqlparser.StmtShow:
+		return tmc.tmc.VReplicationExec(ctx, tablet, query)
+	}
+	return nil, errReadOnlyTMClient(fmt.Sprintf("query %q", query))
+}
+
+func (tmc *readOnlyTMClient) FullStatus(ctx context.Context, tablet *topodatapb.Tablet) (*replicationdatapb.FullStatus, error) {
+	return tmc.tmc.FullStatus(ctx, tablet)
+}
+
+func (tmc *readOnlyTMClient) GetSchema(ctx context.Context, tablet *topodatapb.Tablet, request *tabletmanagerdatapb.GetSchemaRequest) (*tabletmanagerdatapb.SchemaDefinition, error) {
+	return tmc.tmc.GetSchema(ctx, tablet, request)
+}
+
+// Close does nothing, the wrapped client belongs to the caller.
+func (tmc *readOnlyTMClient) Close() {}
+
+func (tmc *readOnlyTMClient) Ping(ctx context.Context, tablet *topodatapb.Tablet) error {
+	return errReadOnlyTMClient("Ping")
+}
+
+func (tmc *readOnlyTMClient) GetPermissions(ctx context.Context, tablet *topodatapb.Tablet) (*tabletmanagerdatapb.Permissions, error) {
+	return nil, errReadOnlyTMClient("GetPermissions")
+}
+
+func (tmc *readOnlyTMClient) ResetSequences(ctx context.Context, tablet *topodatapb.Tablet, tables []string) error {
+	return errReadOnlyTMClient("ResetSequences")
+}
+
+func (tmc *readOnlyTMClient) SetReadOnly(ctx context.Context, tablet *topodatapb.Tablet) error {
+	return errReadOnlyTMClient("SetReadOnly")
+}
+
+func (tmc *readOnlyTMClient) SetReadWrite(ctx context.Context, tablet *topodatapb.Tablet) error {
+	return errReadOnlyTMClient("SetReadWrite")
+}
+
+func (tmc *readOnlyTMClient) ChangeType(ctx context.Context, tablet *topodatapb.Tablet, dbType topodatapb.TabletType, semiSync bool) error {
+	return errReadOnlyTMClient("ChangeType")
+}
+
+func (tmc *readOnlyTMClient) Sleep(ctx context.Context, tablet *topodatapb.Tablet, duration time.Duration) error {
+	return errReadOnlyTMClient("Sleep")
+}
+
+func (tmc *readOnlyTMClient) ExecuteHook(ctx context.Context, tablet *topodatapb.Tablet, hk *hook.Hook) (*hook.HookResult, error) {
+	return nil, errReadOnlyTMClient("ExecuteHook")
+}
+
+func (tmc *readOnlyTMClient) RefreshState(ctx context.Context, tablet *topodatapb.Tablet) error {
+	return errReadOnlyTMClient("RefreshState")
+}
+
+func (tmc *readOnlyTMClient) RunHealthCheck(ctx context.Context, tablet *topodatapb.Tablet) error {
+	return errReadOnlyTMClient("RunHealthCheck")
+}
+
+func (tmc *readOnlyTMClient) ReloadSchema(ctx context.Context, tablet *topodatapb.Tablet, waitPosition string) error {
+	return errReadOnlyTMClient("ReloadSchema")
+}
+
+func (tmc *readOnlyTMClient) PreflightSchema(ctx context.Context, tablet *topodatapb.Tablet, changes []string) ([]*tabletmanagerdatapb.SchemaChangeResult, error) {
+	return nil, errReadOnlyTMClient("PreflightSchema")
+}
+
+func (tmc *readOnlyTMClient) ApplySchema(ctx context.Context, tablet *topodatapb.Tablet, change *tmutils.SchemaChange) (*tabletmanagerdatapb.SchemaChangeResult, error) {
+	return nil, errReadOnlyTMClient("ApplySchema")
+}
+
+func (tmc *readOnlyTMClient) LockTables(ctx context.Context, tablet *topodatapb.Tablet) error {
+	return errReadOnlyTMClient("LockTables")
+}
+
+func (tmc *readOnlyTMClient) UnlockTables(ctx context.Context, tablet *topodatapb.Tablet) error {
+	return errReadOnlyTMClient("UnlockTables")
+}
+
+func (tmc *readOnlyTMClient) ExecuteQuery(ctx context.Context, tablet *topodatapb.Tablet, req *tabletmanagerdatapb.ExecuteQueryRequest) (*querypb.QueryResult, error) {
+	return nil, errReadOnlyTMClient("ExecuteQuery")
+}
+
+func (tmc *readOnlyTMClient) ExecuteFetchAsDba(ctx context.Context, tablet *topodatapb.Tablet, usePool bool, req *tabletmanagerdatapb.ExecuteFetchAsDbaRequest) (*querypb.QueryResult, error) {
+	return nil, errReadOnlyTMClient("ExecuteFetchAsDba")
+}
+
+func (tmc *readOnlyTMClient) ExecuteFetchAsAllPrivs(ctx context.Context, tablet *topodatapb.Tablet, req *tabletmanagerdatapb.ExecuteFetchAsAllPrivsRequest) (*querypb.QueryResult, error) {
+	return nil, errReadOnlyTMClient("ExecuteFetchAsAllPrivs")
+}
+
+func (tmc *readOnlyTMClient) ExecuteFetchAsApp(ctx context.Context, tablet *topodatapb.Tablet, usePool bool, req *tabletmanagerdatapb.ExecuteFetchAsAppRequest) (*querypb.QueryResult, error) {
+	return nil, errReadOnlyTMClient("ExecuteFetchAsApp")
+}
+
+func (tmc *readOnlyTMClient) PrimaryStatus(ctx context.Context, tablet *topodatapb.Tablet) (*replicationdatapb.PrimaryStatus, error) {
+	return nil, errReadOnlyTMClient("PrimaryStatus")
+}
+
+func (tmc *readOnlyTMClient) ReplicationStatus(ctx context.Context, tablet *topodatapb.Tablet) (*replicationdatapb.Status, error) {
+	return nil, errReadOnlyTMClient("ReplicationStatus")
+}
+
+func (tmc *readOnlyTMClient) StopReplication(ctx context.Context, tablet *topodatapb.Tablet) error {
+	return errReadOnlyTMClient("StopReplication")
+}
+
+func (tmc *readOnlyTMClient) StopReplicationMinimum(ctx context.Context, tablet *topodatapb.Tablet, stopPos string, waitTime time.Duration) (string, error) {
+	return "", errReadOnlyTMClient("StopReplicationMinimum")
+}
+
+func (tmc *readOnlyTMClient) StartReplication(ctx context.Context, tablet *topodatapb.Tablet, semiSync bool) error {
+	return errReadOnlyTMClient("StartReplication")
+}
+
+func (tmc *readOnlyTMClient) StartReplicationUntilAfter(ctx context.Context, tablet *topodatapb.Tablet, position string, duration time.Duration) error {
+	return errReadOnlyTMClient("StartReplicationUntilAfter")
+}
+
+func (tmc *readOnlyTMClient) GetReplicas(ctx context.Context, tablet *topodatapb.Tablet) ([]string, error) {
+	return nil, errReadOnlyTMClient("GetReplicas")
+}
+
+func (tmc *readOnlyTMClient) PrimaryPosition(ctx context.Context, tablet *topodatapb.Tablet) (string, error) {
+	return "", errReadOnlyTMClient("PrimaryPosition")
+}
+
+func (tmc *readOnlyTMClient) WaitForPosition(ctx context.Context, tablet *topodatapb.Tablet, pos string) error {
+	return errReadOnlyTMClient("WaitForPosition")
+}
+
+func (tmc *readOnlyTMClient) CreateVReplicationWorkflow(ctx context.Context, tablet *topodatapb.Tablet, request *tabletmanagerdatapb.CreateVReplicationWorkflowRequest) (*tabletmanagerdatapb.CreateVReplicationWorkflowResponse, error) {
+	return nil, errReadOnlyTMClient("CreateVReplicationWorkflow")
+}
+
+func (tmc *readOnlyTMClient) DeleteVReplicationWorkflow(ctx context.Context, tablet *topodatapb.Tablet, request *tabletmanagerdatapb.DeleteVReplicationWorkflowRequest) (*tabletmanagerdatapb.DeleteVReplicationWorkflowResponse, error) {
+	return nil, errReadOnlyTMClient("DeleteVReplicationWorkflow")
+}
+
+func (tmc *readOnlyTMClient) ReadVReplicationWorkflow(ctx context.Context, tablet *topodatapb.Tablet, request *tabletmanagerdatapb.ReadVReplicationWorkflowRequest) (*tabletmanagerdatapb.ReadVReplicationWorkflowResponse, error) {
+	return nil, errReadOnlyTMClient("ReadVReplicationWorkflow")
+}
+
+func (tmc *readOnlyTMClient) UpdateVReplicationWorkflow(ctx context.Context, tablet *topodatapb.Tablet, request *tabletmanagerdatapb.UpdateVReplicationWorkflowRequest) (*tabletmanagerdatapb.UpdateVReplicationWorkflowResponse, error) {
+	return nil, errReadOnlyTMClient("UpdateVReplicationWorkflow")
+}
+
+func (tmc *readOnlyTMClient) VReplicationWaitForPos(ctx context.Context, tablet *topodatapb.Tablet, id int32, pos string) error {
+	return errReadOnlyTMClient("VReplicationWaitForPos")
+}
+
+func (tmc *readOnlyTMClient) VDiff(ctx context.Context, tablet *topodatapb.Tablet, req *tabletmanagerdatapb.VDiffRequest) (*tabletmanagerdatapb.VDiffResponse, error) {
+	return nil, errReadOnlyTMClient("VDiff")
+}
+
+func (tmc *readOnlyTMClient) ResetReplication(ctx context.Context, tablet *topodatapb.Tablet) error {
+	return errReadOnlyTMClient("ResetReplication")
+}
+
+func (tmc *readOnlyTMClient) InitPrimary(ctx context.Context, tablet *topodatapb.Tablet, semiSync bool) (string, error) {
+	return "", errReadOnlyTMClient("InitPrimary")
+}
+
+func (tmc *readOnlyTMClient) PopulateReparentJournal(ctx context.Context, tablet *topodatapb.Tablet, timeCreatedNS int64, actionName string, tabletAlias *topodatapb.TabletAlias, pos string) error {
+	return errReadOnlyTMClient("PopulateReparentJournal")
+}
+
+func (tmc *readOnlyTMClient) InitReplica(ctx context.Context, tablet *topodatapb.Tablet, parent *topodatapb.TabletAlias, replicationPosition string, timeCreatedNS int64, semiSync bool) error {
+	return errReadOnlyTMClient("InitReplica")
+}
+
+func (tmc *readOnlyTMClient) DemotePrimary(ctx context.Context, tablet *topodatapb.Tablet) (*replicationdatapb.PrimaryStatus, error) {
+	return nil, errReadOnlyTMClient("DemotePrimary")
+}
+
+func (tmc *readOnlyTMClient) UndoDemotePrimary(ctx context.Context, tablet *topodatapb.Tablet, semiSync bool) error {
+	return errReadOnlyTMClient("UndoDemotePrimary")
+}
+
+func (tmc *readOnlyTMClient) ReplicaWasPromoted(ctx context.Context, tablet *topodatapb.Tablet) error {
+	return errReadOnlyTMClient("ReplicaWasPromoted")
+}
+
+func (tmc *readOnlyTMClient) ResetReplicationParameters(ctx context.Context, tablet *topodatapb.Tablet) error {
+	return errReadOnlyTMClient("ResetReplicationParameters")
+}
+
+func (tmc *readOnlyTMClient) SetReplicationSource(ctx context.Context, tablet *topodatapb.Tablet, parent *topodatapb.TabletAlias, timeCreatedNS int64, waitPosition string, forceStartReplication bool, semiSync bool) error {
+	return errReadOnlyTMClient("SetReplicationSource")
+}
+
+func (tmc *readOnlyTMClient) ReplicaWasRestarted(ctx context.Context, tablet *topodatapb.Tablet, parent *topodatapb.TabletAlias) error {
+	return errReadOnlyTMClient("ReplicaWasRestarted")
+}
+
+func (tmc *readOnlyTMClient) StopReplicationAndGetStatus(ctx context.Context, tablet *topodatapb.Tablet, stopReplicationMode replicationdatapb.StopReplicationMode) (*replicationdatapb.StopReplicationStatus, error) {
+	return nil, errReadOnlyTMClient("StopReplicationAndGetStatus")
+}
+
+func (tmc *readOnlyTMClient) PromoteReplica(ctx context.Context, tablet *topodatapb.Tablet, semiSync bool) (string, error) {
+	return "", errReadOnlyTMClient("PromoteReplica")
+}
+
+func (tmc *readOnlyTMClient) Backup(ctx context.Context, tablet *topodatapb.Tablet, req *tabletmanagerdatapb.BackupRequest) (logutil.EventStream, error) {
+	return nil, errReadOnlyTMClient("Backup")
+}
+
+func (tmc *readOnlyTMClient) RestoreFromBackup(ctx context.Context, tablet *topodatapb.Tablet, req *tabletmanagerdatapb.RestoreFromBackupRequest) (logutil.EventStream, error) {
+	return nil, errReadOnlyTMClient("RestoreFromBackup")
+}
+
+func (tmc *readOnlyTMClient) CheckThrottler(ctx context.Context, tablet *topodatapb.Tablet, request *tabletmanagerdatapb.CheckThrottlerRequest) (*tabletmanagerdatapb.CheckThrottlerResponse, error) {
+	return nil, errReadOnlyTMClient("CheckThrottler")
+}
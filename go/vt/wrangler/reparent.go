@@ -41,6 +41,7 @@ import (
 // primary, based on the current replication position. If there is no
 // match, it will fail.
 func (wr *Wrangler) ReparentTablet(ctx context.Context, tabletAlias *topodatapb.TabletAlias) error {
+	defer wr.recordAction("ReparentTablet", time.Now())
 	_, err := wr.vtctld.ReparentTablet(ctx, &vtctldatapb.ReparentTabletRequest{
 		Tablet: tabletAlias,
 	})
@@ -49,6 +50,7 @@ func (wr *Wrangler) ReparentTablet(ctx context.Context, tabletAlias *topodatapb.
 
 // InitShardPrimary will make the provided tablet the primary for the shard.
 func (wr *Wrangler) InitShardPrimary(ctx context.Context, keyspace, shard string, primaryElectTabletAlias *topodatapb.TabletAlias, force bool, waitReplicasTimeout time.Duration) (err error) {
+	defer wr.recordAction("InitShardPrimary", time.Now())
 	// lock the shard
 	ctx, unlock, lockErr := wr.ts.LockShard(ctx, keyspace, shard, fmt.Sprintf("InitShardPrimary(%v)", topoproto.TabletAliasString(primaryElectTabletAlias)))
 	if lockErr != nil {
@@ -82,6 +84,7 @@ func (wr *Wrangler) PlannedReparentShard(
 	primaryElectTabletAlias, avoidTabletAlias *topodatapb.TabletAlias,
 	waitReplicasTimeout, tolerableReplicationLag time.Duration,
 ) (err error) {
+	defer wr.recordAction("PlannedReparentShard", time.Now())
 	_, err = reparentutil.NewPlannedReparenter(wr.ts, wr.tmc, wr.logger).ReparentShard(
 		ctx,
 		keyspace,
@@ -100,6 +103,7 @@ func (wr *Wrangler) PlannedReparentShard(
 // EmergencyReparentShard will make the provided tablet the primary for
 // the shard, when the old primary is completely unreachable.
 func (wr *Wrangler) EmergencyReparentShard(ctx context.Context, keyspace, shard string, primaryElectTabletAlias *topodatapb.TabletAlias, waitReplicasTimeout time.Duration, ignoredTablets sets.Set[string], preventCrossCellPromotion bool, waitForAllTablets bool) (err error) {
+	defer wr.recordAction("EmergencyReparentShard", time.Now())
 	_, err = reparentutil.NewEmergencyReparenter(wr.ts, wr.tmc, wr.logger).ReparentShard(
 		ctx,
 		keyspace,
@@ -120,6 +124,7 @@ func (wr *Wrangler) EmergencyReparentShard(ctx context.Context, keyspace, shard
 // and updates it's tablet record in the topo. Updating the shard record is handled
 // by the new primary tablet
 func (wr *Wrangler) TabletExternallyReparented(ctx context.Context, newPrimaryAlias *topodatapb.TabletAlias) error {
+	defer wr.recordAction("TabletExternallyReparented", time.Now())
 
 	tabletInfo, err := wr.ts.GetTablet(ctx, newPrimaryAlias)
 	if err != nil {
@@ -18,28 +18,42 @@ package wrangler
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"golang.org/x/sync/semaphore"
 	"google.golang.org/protobuf/encoding/prototext"
 
 	"vitess.io/vitess/go/vt/log"
 	"vitess.io/vitess/go/vt/schema"
 	"vitess.io/vitess/go/vt/vtctl/workflow"
 
+	"vitess.io/vitess/go/mysql/replication"
+	"vitess.io/vitess/go/mysql/sqlerror"
 	"vitess.io/vitess/go/sqltypes"
 	"vitess.io/vitess/go/vt/concurrency"
 	"vitess.io/vitess/go/vt/key"
 	"vitess.io/vitess/go/vt/topo"
+	"vitess.io/vitess/go/vt/topo/topoproto"
 	"vitess.io/vitess/go/vt/topotools"
 	"vitess.io/vitess/go/vt/vterrors"
 	"vitess.io/vitess/go/vt/vtgate/vindexes"
 	"vitess.io/vitess/go/vt/vttablet/tabletmanager/vreplication"
 
 	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
 )
 
 type resharder struct {
@@ -57,19 +71,165 @@ type resharder struct {
 	stopAfterCopy      bool
 	onDDL              string
 	deferSecondaryKeys bool
+	// deferSecondaryKeysTables are the tables for which the creation of
+	// the secondary keys is deferred even if deferSecondaryKeys is not set.
+	// Since the flag applies to whole streams, the sharded ones are copied
+	// by a stream of their own from each source shard, and a reference
+	// stream defers them if all of its tables are in the list.
+	deferSecondaryKeysTables []string
+	// streamBatchSize is the maximum number of streams created by a single
+	// insert on each target. Zero means all streams are created at once.
+	streamBatchSize int
+	// verbose enables logging of the decisions and timings of the reshard.
+	verbose bool
+	// stopPositions maps source shards to the position at which the
+	// streams from them stop.
+	stopPositions map[string]string
+	// eventSink receives the audit events of the reshard phases, if set.
+	eventSink ReshardEventSink
+	actor     string
+	// onShardProgress is called at the start and end of the work on each
+	// target shard in the copySchema, createStreams and startStreams
+	// phases, if set.
+	onShardProgress func(shard string, phase string)
+	// onStreamsInsert is called with each insert that createStreams ran,
+	// if set. The inserts aren't logged since they can be large.
+	onStreamsInsert func(shard string, query string)
+	// onCopySchemaProgress is called after each table copySchema created
+	// on a target shard, if set.
+	onCopySchemaProgress func(shard string, copied, total int)
+	// repairRefStreams makes readRefStreams use the reference streams of
+	// the first source shard that has all of them when the source shards
+	// don't have the same ones, instead of failing.
+	repairRefStreams bool
+	// shardConcurrency is the maximum number of shards worked on
+	// concurrently, enforced by sem, so that reshards of many shards don't
+	// overwhelm the topo and the primaries.
+	shardConcurrency int
+	sem              *semaphore.Weighted
+	// includeReferenceTables are the tables treated as sharded even
+	// though the vschema marks them as reference tables.
+	includeReferenceTables []string
+	// execRetries is how many times a query that failed with a transient
+	// error is retried, with a delay starting at execRetryDelay and
+	// doubling after each retry.
+	execRetries    int
+	execRetryDelay time.Duration
+	// copySchemaTimeout is how long copySchema waits for the replicas of
+	// each target shard to apply the schema.
+	copySchemaTimeout time.Duration
+	// existingStreams are the ids of the streams that were already on each
+	// target shard, when the reshard ignores some of them, so that they are
+	// left alone when the streams of the reshard are started or cleaned up.
+	existingStreams map[string][]string
+	// autoStart makes the reshard start the streams once they are created.
+	// Otherwise they are left in the Stopped state they are created in.
+	autoStart bool
 }
 
 type refStream struct {
-	workflow    string
-	bls         *binlogdatapb.BinlogSource
-	cell        string
-	tabletTypes string
+	workflow        string
+	bls             *binlogdatapb.BinlogSource
+	cell            string
+	tabletTypes     string
+	workflowType    binlogdatapb.VReplicationWorkflowType
+	workflowSubType binlogdatapb.VReplicationWorkflowSubType
+}
+
+// ReshardOptions are the options of a reshard beyond the arguments of
+// Reshard. The zero value gives the default behavior. They are passed to
+// each call, so concurrent reshards run by the same Wrangler don't share
+// them.
+type ReshardOptions struct {
+	// StreamBatchSize limits the number of streams created by a single
+	// insert on each target shard. Zero creates them all in one insert.
+	StreamBatchSize int
+	// StrictMySQLVersionCheck reads the MySQL version of every source and
+	// target primary, and fails the reshard if a target primary runs an
+	// older version than a source one.
+	StrictMySQLVersionCheck bool
+	// ValidateSequences makes the reshard log the sequences of the tables
+	// of the keyspace that are missing, before creating the streams, see
+	// ValidateSequences. It reads the vschema of every keyspace and the
+	// schema of the sequence tables, so it's off by default.
+	ValidateSequences bool
+	// Verbose logs the decisions and timings of the reshard.
+	Verbose bool
+	// VSchemaOverride is used by the reshard instead of the vschema of the
+	// keyspace in the topo, to plan a reshard against a candidate vschema.
+	VSchemaOverride *vschemapb.Keyspace
+	// StopPositions maps source shards to the GTID position at which the
+	// streams replicating from them stop. It can't be combined with
+	// StopAfterCopy.
+	StopPositions map[string]string
+	// IgnoreFrozenTargetStreams lets a reshard use target shards that still
+	// have the frozen streams of a completed workflow.
+	IgnoreFrozenTargetStreams bool
+	// IgnoreTargetWorkflows are the workflows whose streams may exist on
+	// the target shards of a reshard, such as an unrelated workflow that
+	// keeps running during a phased migration. The streams of any other
+	// workflow still fail the reshard.
+	IgnoreTargetWorkflows []string
+	// RepairReferenceStreams lets a reshard whose source shards don't have
+	// the same reference streams copy those of the first source shard that
+	// has all of them, after logging the ones missing on the others. It can
+	// hide a real inconsistency, so it's off by default.
+	RepairReferenceStreams bool
+	// EventSink receives an audit event at the start and end of each phase
+	// of the reshard. No events are emitted if it's nil.
+	EventSink ReshardEventSink
+	// Actor identifies who started the reshard in the audit events.
+	Actor string
+	// OnShardProgress is called with the name of each target shard and one
+	// of the ReshardProgress phases, once when the reshard starts working
+	// on the shard in that phase and once when it is done with it.
+	OnShardProgress func(shard string, phase string)
+	// OnStreamsInsert is called with the name of the target shard and the
+	// query of each insert into _vt.vreplication that a reshard runs, once
+	// it succeeded, for example to keep an audit trail of the streams.
+	OnStreamsInsert func(shard string, query string)
+	// OnCopySchemaProgress is called with the name of the target shard, the
+	// number of tables and views copied to it so far and their total each
+	// time the reshard creates one of them on the shard while copying the
+	// schema.
+	OnCopySchemaProgress func(shard string, copied, total int)
+	// ShardConcurrency is the maximum number of shards the reshard works on
+	// concurrently. Zero means the default of 16.
+	ShardConcurrency int
+	// CopySchemaTimeout is how long the reshard waits for the replicas of
+	// each target shard when copying the schema to it. Zero means the
+	// default of 30s.
+	CopySchemaTimeout time.Duration
+	// IncludeReferenceTables are the tables a reshard copies like sharded
+	// tables even though the vschema marks them as reference tables.
+	IncludeReferenceTables []string
+	// ExecRetries is how many times a reshard retries a query on a tablet
+	// that failed with a transient error, waiting ExecRetryDelay before the
+	// first retry and twice as long before each following one. Zero means
+	// the defaults of 3 retries and 100ms.
+	ExecRetries    int
+	ExecRetryDelay time.Duration
+
+	// DeferSecondaryKeysTables are the tables for which a reshard defers
+	// the creation of the secondary keys when DeferSecondaryKeys is not set.
+	// The sharded ones are copied by streams of their own.
+	DeferSecondaryKeysTables []string
 }
 
 // Reshard initiates a resharding workflow.
+//
+// Unless autoStart is set, the streams are left in the Stopped state they
+// are created in, for example to check them before copying any data, and
+// the caller is responsible for starting them. The other options of the
+// reshard are given by opts.
 func (wr *Wrangler) Reshard(ctx context.Context, keyspace, workflow string, sources, targets []string,
-	skipSchemaCopy bool, cell, tabletTypes, onDDL string, autoStart, stopAfterCopy, deferSecondaryKeys bool) error {
-	if err := wr.validateNewWorkflow(ctx, keyspace, workflow); err != nil {
+	skipSchemaCopy bool, cell, tabletTypes, onDDL string, autoStart, stopAfterCopy, deferSecondaryKeys bool, opts ReshardOptions) error {
+	defer wr.recordAction("Reshard", time.Now())
+	var ignoreFrozenShards []string
+	if opts.IgnoreFrozenTargetStreams {
+		ignoreFrozenShards = targets
+	}
+	if err := wr.validateNewWorkflowIgnoringFrozen(ctx, keyspace, workflow, ignoreFrozenShards); err != nil {
 		return err
 	}
 	if err := wr.ts.ValidateSrvKeyspace(ctx, keyspace, cell); err != nil {
@@ -78,43 +238,805 @@ func (wr *Wrangler) Reshard(ctx context.Context, keyspace, workflow string, sour
 		return err2
 	}
 
-	rs, err := wr.buildResharder(ctx, keyspace, workflow, sources, targets, cell, tabletTypes)
+	start := time.Now()
+	rs, err := wr.buildResharder(ctx, keyspace, workflow, sources, targets, cell, tabletTypes, opts)
 	if err != nil {
 		return vterrors.Wrap(err, "buildResharder")
 	}
+	rs.logf("buildResharder took %v", time.Since(start))
 
 	rs.onDDL = onDDL
 	rs.stopAfterCopy = stopAfterCopy
 	rs.deferSecondaryKeys = deferSecondaryKeys
+	rs.autoStart = autoStart
+	rs.streamBatchSize = opts.StreamBatchSize
+	rs.stopPositions = opts.StopPositions
+	rs.eventSink = opts.EventSink
+	rs.onShardProgress = opts.OnShardProgress
+	rs.onCopySchemaProgress = opts.OnCopySchemaProgress
+	rs.onStreamsInsert = opts.OnStreamsInsert
+	rs.actor = opts.Actor
+	if err := rs.validateStopPositions(); err != nil {
+		return vterrors.Wrap(err, "validateStopPositions")
+	}
+	if opts.ValidateSequences {
+		gaps, err := wr.validateSequences(ctx, rs.vschema)
+		if err != nil {
+			return vterrors.Wrap(err, "validateSequences")
+		}
+		for _, gap := range gaps {
+			wr.Logger().Warningf("Sequence misconfiguration in keyspace %v, inserts may fail after cutover: %v", keyspace, gap)
+		}
+	}
 	if !skipSchemaCopy {
-		if err := rs.copySchema(ctx); err != nil {
+		if err := rs.runPhase(ctx, "copySchema", rs.copySchema); err != nil {
 			return vterrors.Wrap(err, "copySchema")
 		}
 	}
-	if err := rs.createStreams(ctx); err != nil {
+	if err := rs.runPhase(ctx, "createStreams", rs.createStreams); err != nil {
 		return vterrors.Wrap(err, "createStreams")
 	}
+	if err := wr.setReshardStage(ctx, keyspace, workflow, ReshardStageCreated); err != nil {
+		return vterrors.Wrap(err, "setReshardStage")
+	}
+	if err := wr.PersistReshardRouting(ctx, keyspace, workflow, rs.routing()); err != nil {
+		return vterrors.Wrap(err, "PersistReshardRouting")
+	}
 
-	if autoStart {
-		if err := rs.startStreams(ctx); err != nil {
+	if rs.autoStart {
+		if err := rs.runPhase(ctx, "startStreams", rs.startStreams); err != nil {
 			return vterrors.Wrap(err, "startStreams")
 		}
+		if err := wr.AdvanceReshardStage(ctx, keyspace, workflow, ReshardStageCopying); err != nil {
+			return err
+		}
 	} else {
 		wr.Logger().Infof("Streams will not be started since -auto_start is set to false")
 	}
 	return nil
 }
 
-func (wr *Wrangler) buildResharder(ctx context.Context, keyspace, workflow string, sources, targets []string, cell, tabletTypes string) (*resharder, error) {
+// Outcomes of the phases of a reshard reported in a ReshardEvent.
+const (
+	ReshardEventStarted   = "started"
+	ReshardEventSucceeded = "succeeded"
+	ReshardEventFailed    = "failed"
+)
+
+// ReshardEvent is an audit event emitted at the start and end of each phase
+// of a reshard.
+type ReshardEvent struct {
+	Time         time.Time
+	Keyspace     string
+	Workflow     string
+	Actor        string
+	Phase        string
+	SourceShards int
+	TargetShards int
+	// Outcome is started when the phase begins, and succeeded or failed
+	// when it ends.
+	Outcome string
+	Error   string
+}
+
+// ReshardEventSink consumes the audit events of reshards, for example by
+// forwarding them to an audit pipeline.
+type ReshardEventSink interface {
+	EmitReshardEvent(ctx context.Context, event *ReshardEvent)
+}
+
+// Phases of a reshard reported to OnShardProgress for each target shard.
+const (
+	ReshardProgressCopySchema    = "copy_schema"
+	ReshardProgressCreateStreams = "create_streams"
+	ReshardProgressStartStreams  = "start_streams"
+)
+
+// withShardProgress wraps f to report the start and end of its work on each
+// shard to onShardProgress.
+func (rs *resharder) withShardProgress(phase string, f func(*topo.ShardInfo) error) func(*topo.ShardInfo) error {
+	if rs.onShardProgress == nil {
+		return f
+	}
+	return func(si *topo.ShardInfo) error {
+		rs.onShardProgress(si.ShardName(), phase)
+		defer rs.onShardProgress(si.ShardName(), phase)
+		return f(si)
+	}
+}
+
+// runPhase runs one phase of the reshard, emitting an event to the sink
+// before and after it.
+func (rs *resharder) runPhase(ctx context.Context, phase string, f func(context.Context) error) error {
+	rs.emitEvent(ctx, phase, ReshardEventStarted, nil)
+	start := time.Now()
+	err := f(ctx)
+	if err != nil {
+		rs.emitEvent(ctx, phase, ReshardEventFailed, err)
+		return err
+	}
+	rs.logf("%s took %v", phase, time.Since(start))
+	rs.emitEvent(ctx, phase, ReshardEventSucceeded, nil)
+	return nil
+}
+
+func (rs *resharder) emitEvent(ctx context.Context, phase, outcome string, err error) {
+	if rs.eventSink == nil {
+		return
+	}
+	event := &ReshardEvent{
+		Time:         time.Now(),
+		Keyspace:     rs.keyspace,
+		Workflow:     rs.workflow,
+		Actor:        rs.actor,
+		Phase:        phase,
+		SourceShards: len(rs.sourceShards),
+		TargetShards: len(rs.targetShards),
+		Outcome:      outcome,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	rs.eventSink.EmitReshardEvent(ctx, event)
+}
+
+// ReshardStage is the stage of a reshard that runs in several steps, like
+// copying overnight and switching traffic the next day.
+type ReshardStage string
+
+// The stages of a reshard, in the order they are reached.
+const (
+	ReshardStageCreated  = ReshardStage("created")
+	ReshardStageCopying  = ReshardStage("copying")
+	ReshardStageReady    = ReshardStage("ready")
+	ReshardStageSwitched = ReshardStage("switched")
+)
+
+// reshardStageTransitions maps each stage to the stage that follows it.
+var reshardStageTransitions = map[ReshardStage]ReshardStage{
+	ReshardStageCreated: ReshardStageCopying,
+	ReshardStageCopying: ReshardStageReady,
+	ReshardStageReady:   ReshardStageSwitched,
+}
+
+func reshardStageKey(keyspace, workflow string) string {
+	return fmt.Sprintf("reshard.%s.%s.stage", keyspace, workflow)
+}
+
+// GetReshardStage returns the stage of the reshard, as stored in the topo
+// metadata by Reshard and AdvanceReshardStage.
+func (wr *Wrangler) GetReshardStage(ctx context.Context, keyspace, workflow string) (ReshardStage, error) {
+	metadataKey := reshardStageKey(keyspace, workflow)
+	values, err := wr.ts.GetMetadata(ctx, metadataKey)
+	if err != nil && !topo.IsErrType(err, topo.NoNode) {
+		return "", err
+	}
+	stage, ok := values[metadataKey]
+	if !ok {
+		return "", fmt.Errorf("no stage found for reshard %s in keyspace %s", workflow, keyspace)
+	}
+	return ReshardStage(stage), nil
+}
+
+// AdvanceReshardStage moves the reshard to the given stage, which must be
+// the one that follows its current stage. The keyspace is locked while the
+// stage is checked and updated, so that concurrent callers can't both
+// advance from the same stage.
+func (wr *Wrangler) AdvanceReshardStage(ctx context.Context, keyspace, workflow string, stage ReshardStage) (err error) {
+	ctx, unlock, lockErr := wr.ts.LockKeyspace(ctx, keyspace, "AdvanceReshardStage")
+	if lockErr != nil {
+		return lockErr
+	}
+	defer unlock(&err)
+
+	current, err := wr.GetReshardStage(ctx, keyspace, workflow)
+	if err != nil {
+		return err
+	}
+	if reshardStageTransitions[current] != stage {
+		return fmt.Errorf("cannot move reshard %s in keyspace %s from stage %s to stage %s", workflow, keyspace, current, stage)
+	}
+	return wr.setReshardStage(ctx, keyspace, workflow, stage)
+}
+
+func (wr *Wrangler) setReshardStage(ctx context.Context, keyspace, workflow string, stage ReshardStage) error {
+	if err := wr.ts.UpsertMetadata(ctx, reshardStageKey(keyspace, workflow), string(stage)); err != nil {
+		return err
+	}
+	wr.Logger().Infof("Reshard %s.%s is now in stage %s", keyspace, workflow, stage)
+	return nil
+}
+
+// deleteReshardMetadata removes the metadata stored in the topo for the
+// reshard, so that a later reshard reusing the workflow name starts afresh.
+// It is called when the workflow is canceled or completed.
+func (wr *Wrangler) deleteReshardMetadata(ctx context.Context, keyspace, workflow string) error {
+	for _, metadataKey := range []string{
+		reshardStageKey(keyspace, workflow),
+		reshardRoutingKey(keyspace, workflow),
+		reshardRollbackPlanKey(keyspace, workflow),
+	} {
+		if err := wr.ts.DeleteMetadata(ctx, metadataKey); err != nil && !topo.IsErrType(err, topo.NoNode) {
+			return vterrors.Wrapf(err, "failed to delete %s", metadataKey)
+		}
+	}
+	return nil
+}
+
+func reshardRoutingKey(keyspace, workflow string) string {
+	return fmt.Sprintf("reshard.%s.%s.routing", keyspace, workflow)
+}
+
+// PersistReshardRouting stores the source shards that each target shard of
+// the reshard replicates from, so that the data of every target can later be
+// compared with exactly the sources it was copied from.
+func (wr *Wrangler) PersistReshardRouting(ctx context.Context, keyspace, workflow string, mapping map[string][]string) error {
+	data, err := json.Marshal(mapping)
+	if err != nil {
+		return err
+	}
+	return wr.ts.UpsertMetadata(ctx, reshardRoutingKey(keyspace, workflow), string(data))
+}
+
+// GetReshardRouting returns the source shards of each target shard of the
+// reshard, as stored by PersistReshardRouting.
+func (wr *Wrangler) GetReshardRouting(ctx context.Context, keyspace, workflow string) (map[string][]string, error) {
+	metadataKey := reshardRoutingKey(keyspace, workflow)
+	values, err := wr.ts.GetMetadata(ctx, metadataKey)
+	if err != nil && !topo.IsErrType(err, topo.NoNode) {
+		return nil, err
+	}
+	data, ok := values[metadataKey]
+	if !ok {
+		return nil, fmt.Errorf("no routing found for reshard %s in keyspace %s", workflow, keyspace)
+	}
+	var mapping map[string][]string
+	if err := json.Unmarshal([]byte(data), &mapping); err != nil {
+		return nil, vterrors.Wrapf(err, "failed to parse routing %s", metadataKey)
+	}
+	return mapping, nil
+}
+
+// reshardStream is a stream of a reshard workflow, on a target primary.
+type reshardStream struct {
+	id          int32
+	target      *topo.TabletInfo
+	sourceShard string
+}
+
+// reshardStreams reads the streams of the workflow from the primaries of the
+// shards of the keyspace.
+func (wr *Wrangler) reshardStreams(ctx context.Context, keyspace, workflow string) ([]*reshardStream, error) {
+	shards, err := wr.ts.GetShardNames(ctx, keyspace)
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "GetShardNames(%s)", keyspace)
+	}
+	sort.Strings(shards)
+	var streams []*reshardStream
+	for _, shard := range shards {
+		si, err := wr.ts.GetShard(ctx, keyspace, shard)
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "GetShard(%s) failed", shard)
+		}
+		if si.PrimaryAlias == nil {
+			return nil, fmt.Errorf("shard %v has no primary", shard)
+		}
+		primary, err := wr.ts.GetTablet(ctx, si.PrimaryAlias)
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "GetTablet(%s) failed", si.PrimaryAlias)
+		}
+		query := fmt.Sprintf("select id, source from _vt.vreplication where db_name=%s and workflow=%s",
+			encodeString(primary.DbName()), encodeString(workflow))
+		p3qr, err := wr.tmc.VReplicationExec(ctx, primary.Tablet, query)
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "VReplicationExec(%v, %s)", primary.Tablet, query)
+		}
+		for _, row := range sqltypes.Proto3ToResult(p3qr).Rows {
+			id, err := row[0].ToInt32()
+			if err != nil {
+				return nil, err
+			}
+			var bls binlogdatapb.BinlogSource
+			rowBytes, err := row[1].ToBytes()
+			if err != nil {
+				return nil, err
+			}
+			if err := prototext.Unmarshal(rowBytes, &bls); err != nil {
+				return nil, vterrors.Wrapf(err, "prototext.Unmarshal: %v", row)
+			}
+			streams = append(streams, &reshardStream{id: id, target: primary, sourceShard: bls.Shard})
+		}
+	}
+	if len(streams) == 0 {
+		return nil, fmt.Errorf("no streams found for workflow %s in keyspace %s", workflow, keyspace)
+	}
+	return streams, nil
+}
+
+// reshardSourcePrimaries returns the primaries of the source shards of the
+// streams, by shard name.
+func (wr *Wrangler) reshardSourcePrimaries(ctx context.Context, keyspace string, streams []*reshardStream) (map[string]*topo.TabletInfo, error) {
+	primaries := make(map[string]*topo.TabletInfo)
+	for _, stream := range streams {
+		if _, ok := primaries[stream.sourceShard]; ok {
+			continue
+		}
+		si, err := wr.ts.GetShard(ctx, keyspace, stream.sourceShard)
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "GetShard(%s) failed", stream.sourceShard)
+		}
+		if si.PrimaryAlias == nil {
+			return nil, fmt.Errorf("source shard %v has no primary", stream.sourceShard)
+		}
+		primary, err := wr.ts.GetTablet(ctx, si.PrimaryAlias)
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "GetTablet(%s) failed", si.PrimaryAlias)
+		}
+		primaries[stream.sourceShard] = primary
+	}
+	return primaries, nil
+}
+
+// QuiesceReshardSources sets the primaries of the source shards of the
+// reshard read-only and waits up to timeout until every stream of the
+// workflow has caught up with the position at which its source stopped
+// taking writes, so that writes can then be switched to the targets without
+// losing any. The sources stay read-only when it succeeds, until writes are
+// switched or UnquiesceReshardSources is called, and are made read-write
+// again if anything fails. It returns the position of each source shard.
+func (wr *Wrangler) QuiesceReshardSources(ctx context.Context, keyspace, workflow string, timeout time.Duration) (map[string]string, error) {
+	streams, err := wr.reshardStreams(ctx, keyspace, workflow)
+	if err != nil {
+		return nil, err
+	}
+	primaries, err := wr.reshardSourcePrimaries(ctx, keyspace, streams)
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]string, 0, len(primaries))
+	for shard := range primaries {
+		sources = append(sources, shard)
+	}
+	sort.Strings(sources)
+
+	var quiesced []string
+	restore := func() {
+		for _, shard := range quiesced {
+			if err := wr.tmc.SetReadWrite(ctx, primaries[shard].Tablet); err != nil {
+				wr.Logger().Errorf("Failed to make the primary %v of source shard %s read-write again: %v",
+					topoproto.TabletAliasString(primaries[shard].Alias), shard, err)
+			}
+		}
+	}
+	positions := make(map[string]string, len(sources))
+	for _, shard := range sources {
+		if err := wr.tmc.SetReadOnly(ctx, primaries[shard].Tablet); err != nil {
+			restore()
+			return nil, vterrors.Wrapf(err, "SetReadOnly(%v)", topoproto.TabletAliasString(primaries[shard].Alias))
+		}
+		quiesced = append(quiesced, shard)
+		pos, err := wr.tmc.PrimaryPosition(ctx, primaries[shard].Tablet)
+		if err != nil {
+			restore()
+			return nil, vterrors.Wrapf(err, "PrimaryPosition(%v)", topoproto.TabletAliasString(primaries[shard].Alias))
+		}
+		positions[shard] = pos
+		wr.Logger().Infof("Source shard %s of reshard %s.%s is read-only at position %s", shard, keyspace, workflow, pos)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for _, stream := range streams {
+		if err := wr.tmc.VReplicationWaitForPos(waitCtx, stream.target.Tablet, stream.id, positions[stream.sourceShard]); err != nil {
+			restore()
+			return nil, vterrors.Wrapf(err, "stream %d on %v did not catch up with source shard %s",
+				stream.id, topoproto.TabletAliasString(stream.target.Alias), stream.sourceShard)
+		}
+	}
+	return positions, nil
+}
+
+// UnquiesceReshardSources makes the primaries of the source shards of the
+// reshard read-write again after QuiesceReshardSources, for when writes are
+// not switched to the targets after all.
+func (wr *Wrangler) UnquiesceReshardSources(ctx context.Context, keyspace, workflow string) error {
+	streams, err := wr.reshardStreams(ctx, keyspace, workflow)
+	if err != nil {
+		return err
+	}
+	primaries, err := wr.reshardSourcePrimaries(ctx, keyspace, streams)
+	if err != nil {
+		return err
+	}
+	for shard, primary := range primaries {
+		if err := wr.tmc.SetReadWrite(ctx, primary.Tablet); err != nil {
+			return vterrors.Wrapf(err, "SetReadWrite(%v) for source shard %s", topoproto.TabletAliasString(primary.Alias), shard)
+		}
+	}
+	return nil
+}
+
+// ReshardParams are the arguments of Reshard.
+type ReshardParams struct {
+	Keyspace           string
+	Workflow           string
+	Sources            []string
+	Targets            []string
+	SkipSchemaCopy     bool
+	Cell               string
+	TabletTypes        string
+	OnDDL              string
+	AutoStart          bool
+	StopAfterCopy      bool
+	DeferSecondaryKeys bool
+	Options            ReshardOptions
+}
+
+// ReverseReshardShard is a shard of a ReverseReshardPlan, as it was in the
+// topology when the plan was made.
+type ReverseReshardShard struct {
+	Name     string
+	KeyRange string
+	Primary  string
+}
+
+// ReverseReshardPlan describes the reshard that undoes a reshard: it
+// replicates from the targets of the forward reshard back to its sources.
+type ReverseReshardPlan struct {
+	Keyspace        string
+	Workflow        string
+	ReverseWorkflow string
+	Sources         []*ReverseReshardShard
+	Targets         []*ReverseReshardShard
+}
+
+// ReshardRollbackHandle refers to the rollback plan stored for a reshard.
+type ReshardRollbackHandle struct {
+	// MetadataKey is the topo metadata key the plan is stored under.
+	MetadataKey string
+	Plan        *ReverseReshardPlan
+}
+
+func reshardRollbackPlanKey(keyspace, workflow string) string {
+	return fmt.Sprintf("reshard.%s.%s.rollback_plan", keyspace, workflow)
+}
+
+// PlanReverseReshard computes the reshard that undoes resharding keyspace
+// from the sources to the targets shards, from the current topology.
+func (wr *Wrangler) PlanReverseReshard(ctx context.Context, keyspace, workflow string, sources, targets []string) (*ReverseReshardPlan, error) {
+	readShards := func(shards []string) ([]*topo.ShardInfo, []*ReverseReshardShard, error) {
+		var infos []*topo.ShardInfo
+		var planShards []*ReverseReshardShard
+		for _, shard := range shards {
+			si, err := wr.ts.GetShard(ctx, keyspace, shard)
+			if err != nil {
+				return nil, nil, vterrors.Wrapf(err, "GetShard(%s) failed", shard)
+			}
+			infos = append(infos, si)
+			planShards = append(planShards, &ReverseReshardShard{
+				Name:     si.ShardName(),
+				KeyRange: key.KeyRangeString(si.KeyRange),
+				Primary:  topoproto.TabletAliasString(si.PrimaryAlias),
+			})
+		}
+		return infos, planShards, nil
+	}
+	// The targets of the forward reshard are the sources of the reverse one.
+	reverseSourceShards, reverseSources, err := readShards(targets)
+	if err != nil {
+		return nil, err
+	}
+	reverseTargetShards, reverseTargets, err := readShards(sources)
+	if err != nil {
+		return nil, err
+	}
+	if err := topotools.ValidateForReshard(reverseSourceShards, reverseTargetShards); err != nil {
+		return nil, vterrors.Wrap(err, "ValidateForReshard")
+	}
+	return &ReverseReshardPlan{
+		Keyspace:        keyspace,
+		Workflow:        workflow,
+		ReverseWorkflow: workflow + "_reverse",
+		Sources:         reverseSources,
+		Targets:         reverseTargets,
+	}, nil
+}
+
+// ReshardWithRollbackPlan runs Reshard and stores the plan to reverse it in
+// the topo metadata, so that a rollback doesn't depend on the topology at
+// the time it runs. The plan is computed and stored before the reshard
+// starts, and removed again if the reshard fails or once the workflow is
+// canceled or completed.
+func (wr *Wrangler) ReshardWithRollbackPlan(ctx context.Context, params *ReshardParams) (*ReshardRollbackHandle, error) {
+	plan, err := wr.PlanReverseReshard(ctx, params.Keyspace, params.Workflow, params.Sources, params.Targets)
+	if err != nil {
+		return nil, vterrors.Wrap(err, "PlanReverseReshard")
+	}
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return nil, err
+	}
+	handle := &ReshardRollbackHandle{
+		MetadataKey: reshardRollbackPlanKey(params.Keyspace, params.Workflow),
+		Plan:        plan,
+	}
+	if err := wr.ts.UpsertMetadata(ctx, handle.MetadataKey, string(data)); err != nil {
+		return nil, vterrors.Wrapf(err, "failed to store rollback plan %s", handle.MetadataKey)
+	}
+	if err := wr.Reshard(ctx, params.Keyspace, params.Workflow, params.Sources, params.Targets, params.SkipSchemaCopy, params.Cell,
+		params.TabletTypes, params.OnDDL, params.AutoStart, params.StopAfterCopy, params.DeferSecondaryKeys, params.Options); err != nil {
+		if derr := wr.ts.DeleteMetadata(ctx, handle.MetadataKey); derr != nil {
+			wr.Logger().Errorf("Failed to delete rollback plan %s of the failed reshard: %v", handle.MetadataKey, derr)
+		}
+		return nil, err
+	}
+	return handle, nil
+}
+
+// GetReshardRollbackPlan returns the rollback plan stored by
+// ReshardWithRollbackPlan for the workflow.
+func (wr *Wrangler) GetReshardRollbackPlan(ctx context.Context, keyspace, workflow string) (*ReverseReshardPlan, error) {
+	metadataKey := reshardRollbackPlanKey(keyspace, workflow)
+	values, err := wr.ts.GetMetadata(ctx, metadataKey)
+	if err != nil && !topo.IsErrType(err, topo.NoNode) {
+		return nil, err
+	}
+	data, ok := values[metadataKey]
+	if !ok {
+		return nil, fmt.Errorf("no rollback plan found for workflow %s in keyspace %s", workflow, keyspace)
+	}
+	plan := &ReverseReshardPlan{}
+	if err := json.Unmarshal([]byte(data), plan); err != nil {
+		return nil, vterrors.Wrapf(err, "failed to parse rollback plan %s", metadataKey)
+	}
+	return plan, nil
+}
+
+// ReshardPreflightReport is the outcome of ReshardPreflightReadOnly.
+type ReshardPreflightReport struct {
+	Keyspace string
+	Workflow string
+	// Ready is true if no problem was found, in which case the reshard is
+	// expected to be created successfully.
+	Ready    bool
+	Problems []string
+	// The fields below are only set if the source and target shards could
+	// be validated.
+	Sources []string
+	Targets []string
+	// Routing maps each target shard to the source shards it would
+	// replicate from.
+	Routing          map[string][]string
+	ReferenceStreams []string
+	// SequenceGaps are sequence misconfigurations that don't prevent the
+	// reshard, but would make inserts fail after the cutover. The preflight
+	// always looks for them, while Reshard only does if ValidateSequences
+	// is set.
+	SequenceGaps []string
+}
+
+// ReshardPreflightReadOnly runs all the validations of Reshard for the
+// given parameters without creating the reshard, and reports whether it
+// would succeed. It only reads the topo and the tablets, which is
+// enforced: it runs on a read-only view of the topo server, which refuses
+// writes and locks, and on a tablet manager client that only passes on
+// the read RPCs it needs, see readOnlyTMClient. This makes it safe to run
+// where changes are under strict control.
+func (wr *Wrangler) ReshardPreflightReadOnly(ctx context.Context, params *ReshardParams) (*ReshardPreflightReport, error) {
+	preflight := *wr
+	preflight.ts = wr.ts.ReadOnly()
+	preflight.sourceTs = preflight.ts
+	if wr.sourceTs != wr.ts {
+		preflight.sourceTs = wr.sourceTs.ReadOnly()
+	}
+	preflight.tmc = &readOnlyTMClient{tmc: wr.tmc}
+
+	report := &ReshardPreflightReport{Keyspace: params.Keyspace, Workflow: params.Workflow}
+	var ignoreFrozenShards []string
+	if params.Options.IgnoreFrozenTargetStreams {
+		ignoreFrozenShards = params.Targets
+	}
+	if err := preflight.validateNewWorkflowIgnoringFrozen(ctx, params.Keyspace, params.Workflow, ignoreFrozenShards); err != nil {
+		report.Problems = append(report.Problems, err.Error())
+	}
+	if err := preflight.ts.ValidateSrvKeyspace(ctx, params.Keyspace, params.Cell); err != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("SrvKeyspace for keyspace %s is corrupt in cell %s: %v", params.Keyspace, params.Cell, err))
+	}
+	rs, err := preflight.buildResharder(ctx, params.Keyspace, params.Workflow, params.Sources, params.Targets, params.Cell, params.TabletTypes, params.Options)
+	if err != nil {
+		report.Problems = append(report.Problems, vterrors.Wrap(err, "buildResharder").Error())
+		return report, nil
+	}
+	rs.stopAfterCopy = params.StopAfterCopy
+	rs.stopPositions = params.Options.StopPositions
+	if err := rs.validateStopPositions(); err != nil {
+		report.Problems = append(report.Problems, vterrors.Wrap(err, "validateStopPositions").Error())
+	}
+
+	for _, si := range rs.sourceShards {
+		report.Sources = append(report.Sources, si.ShardName())
+	}
+	for _, si := range rs.targetShards {
+		report.Targets = append(report.Targets, si.ShardName())
+	}
+	report.Routing = rs.routing()
+	for name := range rs.refStreams {
+		report.ReferenceStreams = append(report.ReferenceStreams, name)
+	}
+	sort.Strings(report.ReferenceStreams)
+	gaps, err := preflight.validateSequences(ctx, rs.vschema)
+	if err != nil {
+		report.Problems = append(report.Problems, vterrors.Wrap(err, "validateSequences").Error())
+	}
+	report.SequenceGaps = gaps
+	report.Ready = len(report.Problems) == 0
+	return report, nil
+}
+
+// ErrKeyRangeCoverage is returned by a reshard when the source or target
+// shards have gaps or overlaps between their key ranges, or the target shards
+// don't cover the same key range as the source shards.
+type ErrKeyRangeCoverage struct {
+	// KeyRange is the key range that is not covered, or covered twice. It's
+	// nil if there are no shards at all.
+	KeyRange *topodatapb.KeyRange
+	msg      string
+}
+
+func (e *ErrKeyRangeCoverage) Error() string {
+	return e.msg
+}
+
+// ErrTargetNotEmpty is returned by a reshard when its target shards already
+// have streams, which have to be cleaned up before retrying.
+type ErrTargetNotEmpty struct {
+	// Shards are the target shards that have streams.
+	Shards []string
+}
+
+func (e *ErrTargetNotEmpty) Error() string {
+	return "some streams already exist in the target shards, please clean them up and retry the command"
+}
+
+// ErrStreamMismatch is returned by a reshard when the source shards don't
+// all have the same reference streams.
+type ErrStreamMismatch struct {
+	// Shard is the source shard whose streams differ from the others.
+	Shard string
+	// Workflow is the workflow of a stream that the other source shards
+	// don't have. It's empty if the shard is missing some of their streams.
+	Workflow string
+	msg      string
+}
+
+func (e *ErrStreamMismatch) Error() string {
+	return e.msg
+}
+
+// validateKeyRangeCoverage checks that the target shards cover exactly the
+// key range of the source shards, without gaps or overlaps, since the rows in
+// a key range that no target covers would be silently dropped by the streams.
+// The error names the key range that is not covered, or covered twice.
+func validateKeyRangeCoverage(sourceShards, targetShards []*topo.ShardInfo) error {
+	sources, err := sortedContiguousKeyRanges("source", sourceShards)
+	if err != nil {
+		return err
+	}
+	targets, err := sortedContiguousKeyRanges("target", targetShards)
+	if err != nil {
+		return err
+	}
+	first, last := sources[0].KeyRange, sources[len(sources)-1].KeyRange
+	targetFirst, targetLast := targets[0].KeyRange, targets[len(targets)-1].KeyRange
+	switch key.KeyRangeStartCompare(targetFirst, first) {
+	case 1:
+		keyRange := &topodatapb.KeyRange{Start: first.GetStart(), End: targetFirst.GetStart()}
+		return &ErrKeyRangeCoverage{
+			KeyRange: keyRange,
+			msg:      fmt.Sprintf("target shards don't cover key range %s of the source shards", key.KeyRangeString(keyRange)),
+		}
+	case -1:
+		keyRange := &topodatapb.KeyRange{Start: targetFirst.GetStart(), End: first.GetStart()}
+		return &ErrKeyRangeCoverage{
+			KeyRange: keyRange,
+			msg:      fmt.Sprintf("target shards cover key range %s outside of the source shards", key.KeyRangeString(keyRange)),
+		}
+	}
+	switch key.KeyRangeEndCompare(targetLast, last) {
+	case -1:
+		keyRange := &topodatapb.KeyRange{Start: targetLast.GetEnd(), End: last.GetEnd()}
+		return &ErrKeyRangeCoverage{
+			KeyRange: keyRange,
+			msg:      fmt.Sprintf("target shards don't cover key range %s of the source shards", key.KeyRangeString(keyRange)),
+		}
+	case 1:
+		keyRange := &topodatapb.KeyRange{Start: last.GetEnd(), End: targetLast.GetEnd()}
+		return &ErrKeyRangeCoverage{
+			KeyRange: keyRange,
+			msg:      fmt.Sprintf("target shards cover key range %s outside of the source shards", key.KeyRangeString(keyRange)),
+		}
+	}
+	return nil
+}
+
+// sortedContiguousKeyRanges returns the shards sorted by the start of their
+// key ranges, or an error naming the first gap or overlap between them.
+func sortedContiguousKeyRanges(kind string, shards []*topo.ShardInfo) ([]*topo.ShardInfo, error) {
+	if len(shards) == 0 {
+		return nil, &ErrKeyRangeCoverage{msg: fmt.Sprintf("there are no %s shards", kind)}
+	}
+	sorted := append([]*topo.ShardInfo(nil), shards...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return key.KeyRangeStartCompare(sorted[i].KeyRange, sorted[j].KeyRange) < 0
+	})
+	for i := 1; i < len(sorted); i++ {
+		prev, cur := sorted[i-1].KeyRange, sorted[i].KeyRange
+		cmp := 1
+		if !key.Empty(prev.GetEnd()) && !key.Empty(cur.GetStart()) {
+			cmp = key.Compare(prev.GetEnd(), cur.GetStart())
+		}
+		switch {
+		case cmp < 0:
+			keyRange := &topodatapb.KeyRange{Start: prev.GetEnd(), End: cur.GetStart()}
+			return nil, &ErrKeyRangeCoverage{
+				KeyRange: keyRange,
+				msg:      fmt.Sprintf("%s shards don't cover key range %s between shards %s and %s", kind, key.KeyRangeString(keyRange), sorted[i-1].ShardName(), sorted[i].ShardName()),
+			}
+		case cmp > 0:
+			end := cur.GetEnd()
+			if key.KeyRangeEndCompare(prev, cur) < 0 {
+				end = prev.GetEnd()
+			}
+			keyRange := &topodatapb.KeyRange{Start: cur.GetStart(), End: end}
+			return nil, &ErrKeyRangeCoverage{
+				KeyRange: keyRange,
+				msg:      fmt.Sprintf("%s shards %s and %s overlap in key range %s", kind, sorted[i-1].ShardName(), sorted[i].ShardName(), key.KeyRangeString(keyRange)),
+			}
+		}
+	}
+	return sorted, nil
+}
+
+// newResharder returns a resharder of the workflow in the keyspace with the
+// options that don't depend on its shards.
+func (wr *Wrangler) newResharder(keyspace, workflow string, opts ReshardOptions) *resharder {
 	rs := &resharder{
-		wr:              wr,
-		keyspace:        keyspace,
-		workflow:        workflow,
-		sourcePrimaries: make(map[string]*topo.TabletInfo),
-		targetPrimaries: make(map[string]*topo.TabletInfo),
-		cell:            cell,
-		tabletTypes:     tabletTypes,
+		wr:               wr,
+		keyspace:         keyspace,
+		workflow:         workflow,
+		sourcePrimaries:  make(map[string]*topo.TabletInfo),
+		targetPrimaries:  make(map[string]*topo.TabletInfo),
+		existingStreams:  make(map[string][]string),
+		verbose:          opts.Verbose,
+		shardConcurrency: defaultReshardShardConcurrency,
+		execRetries:      defaultReshardExecRetries,
+		execRetryDelay:   defaultReshardExecRetryDelay,
+	}
+	if opts.ShardConcurrency > 0 {
+		rs.shardConcurrency = opts.ShardConcurrency
 	}
+	rs.includeReferenceTables = opts.IncludeReferenceTables
+	rs.repairRefStreams = opts.RepairReferenceStreams
+	if opts.ExecRetries > 0 {
+		rs.execRetries = opts.ExecRetries
+	}
+	if opts.ExecRetryDelay > 0 {
+		rs.execRetryDelay = opts.ExecRetryDelay
+	}
+	rs.sem = semaphore.NewWeighted(int64(rs.shardConcurrency))
+	return rs
+}
+
+func (wr *Wrangler) buildResharder(ctx context.Context, keyspace, workflow string, sources, targets []string, cell, tabletTypes string, opts ReshardOptions) (*resharder, error) {
+	rs := wr.newResharder(keyspace, workflow, opts)
+	rs.cell = cell
+	rs.tabletTypes = tabletTypes
+	rs.copySchemaTimeout = defaultReshardCopySchemaTimeout
+	rs.autoStart = true
+	if opts.CopySchemaTimeout > 0 {
+		rs.copySchemaTimeout = opts.CopySchemaTimeout
+	}
+	rs.deferSecondaryKeysTables = opts.DeferSecondaryKeysTables
 	for _, shard := range sources {
 		si, err := wr.ts.GetShard(ctx, keyspace, shard)
 		if err != nil {
@@ -129,6 +1051,7 @@ func (wr *Wrangler) buildResharder(ctx context.Context, keyspace, workflow strin
 			return nil, vterrors.Wrapf(err, "GetTablet(%s) failed", si.PrimaryAlias)
 		}
 		rs.sourcePrimaries[si.ShardName()] = primary
+		rs.logf("Found source shard %v with key range %v and primary %v", si.ShardName(), key.KeyRangeString(si.KeyRange), topoproto.TabletAliasString(si.PrimaryAlias))
 	}
 	for _, shard := range targets {
 		si, err := wr.ts.GetShard(ctx, keyspace, shard)
@@ -144,114 +1067,801 @@ func (wr *Wrangler) buildResharder(ctx context.Context, keyspace, workflow strin
 			return nil, vterrors.Wrapf(err, "GetTablet(%s) failed", si.PrimaryAlias)
 		}
 		rs.targetPrimaries[si.ShardName()] = primary
+		rs.logf("Found target shard %v with key range %v and primary %v", si.ShardName(), key.KeyRangeString(si.KeyRange), topoproto.TabletAliasString(si.PrimaryAlias))
+	}
+	if err := validateKeyRangeCoverage(rs.sourceShards, rs.targetShards); err != nil {
+		return nil, vterrors.Wrap(err, "validateKeyRangeCoverage")
 	}
 	if err := topotools.ValidateForReshard(rs.sourceShards, rs.targetShards); err != nil {
 		return nil, vterrors.Wrap(err, "ValidateForReshard")
 	}
-	if err := rs.validateTargets(ctx); err != nil {
+	if err := rs.validateCells(ctx); err != nil {
+		return nil, vterrors.Wrap(err, "validateCells")
+	}
+	if err := rs.validateTargets(ctx, opts.IgnoreFrozenTargetStreams, opts.IgnoreTargetWorkflows); err != nil {
 		return nil, vterrors.Wrap(err, "validateTargets")
 	}
-
-	vschema, err := wr.ts.GetVSchema(ctx, keyspace)
-	if err != nil {
-		return nil, vterrors.Wrap(err, "GetVSchema")
+	if opts.StrictMySQLVersionCheck {
+		if err := rs.validateMySQLVersions(ctx); err != nil {
+			return nil, vterrors.Wrap(err, "validateMySQLVersions")
+		}
 	}
-	rs.vschema = vschema
 
+	if opts.VSchemaOverride != nil {
+		rs.vschema = opts.VSchemaOverride
+		rs.logf("Using the vschema override instead of the vschema of keyspace %v", keyspace)
+	} else {
+		vschema, err := wr.ts.GetVSchema(ctx, keyspace)
+		if err != nil {
+			return nil, vterrors.Wrap(err, "GetVSchema")
+		}
+		rs.vschema = vschema
+	}
+	if err := rs.validateDeferSecondaryKeysTables(); err != nil {
+		return nil, vterrors.Wrap(err, "validateDeferSecondaryKeysTables")
+	}
 	if err := rs.readRefStreams(ctx); err != nil {
 		return nil, vterrors.Wrap(err, "readRefStreams")
 	}
+	for name, rstream := range rs.refStreams {
+		rs.logf("Found reference stream %v from %v/%v", name, rstream.bls.Keyspace, rstream.bls.Shard)
+	}
 	return rs, nil
 }
 
+// CellsAndAliases lists the values accepted by the cell option of a
+// reshard: cell names and cell aliases, the latter with their member cells.
+type CellsAndAliases struct {
+	Cells   []string
+	Aliases map[string][]string
+}
+
+// ListCellsAndAliases returns all cells and cell aliases known to the topo,
+// so that the cell option of a reshard can be validated before streams
+// are created.
+func (wr *Wrangler) ListCellsAndAliases(ctx context.Context) (*CellsAndAliases, error) {
+	cells, err := wr.ts.GetCellInfoNames(ctx)
+	if err != nil {
+		return nil, vterrors.Wrap(err, "GetCellInfoNames")
+	}
+	aliases, err := wr.ts.GetCellsAliases(ctx, false)
+	if err != nil {
+		return nil, vterrors.Wrap(err, "GetCellsAliases")
+	}
+	result := &CellsAndAliases{
+		Cells:   cells,
+		Aliases: make(map[string][]string, len(aliases)),
+	}
+	sort.Strings(result.Cells)
+	for name, alias := range aliases {
+		members := append([]string(nil), alias.Cells...)
+		sort.Strings(members)
+		result.Aliases[name] = members
+	}
+	return result, nil
+}
+
+// validateCells checks that every entry of the comma-separated cell option
+// is a cell or a cell alias, since the streams of a reshard that names an
+// unknown cell never find a tablet to replicate from.
+func (rs *resharder) validateCells(ctx context.Context) error {
+	if rs.cell == "" {
+		return nil
+	}
+	known, err := rs.wr.ListCellsAndAliases(ctx)
+	if err != nil {
+		return err
+	}
+	var invalid []string
+	for _, cell := range strings.Split(rs.cell, ",") {
+		cell = strings.TrimSpace(cell)
+		if cell == "" {
+			continue
+		}
+		if _, ok := known.Aliases[cell]; ok || slices.Contains(known.Cells, cell) {
+			continue
+		}
+		invalid = append(invalid, cell)
+	}
+	if len(invalid) != 0 {
+		return fmt.Errorf("cells %s are neither cells nor cell aliases", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// TargetServingState reports the serving state of a single reshard
+// target shard as seen by EnsureTargetsNonServing.
+type TargetServingState struct {
+	Shard        string
+	WasServing   bool
+	Transitioned bool
+}
+
+// EnsureTargetsNonServing checks that the given reshard target shards are
+// not in serving state, which is what buildResharder requires. Any target
+// that is serving is reported and, unless dryRun is set, transitioned to
+// non-serving. A target is only transitioned if its key range is still fully
+// covered by other serving shards in the keyspace; a target that is the only
+// serving shard for some part of its key range is carrying live traffic and
+// is refused.
+func (wr *Wrangler) EnsureTargetsNonServing(ctx context.Context, keyspace string, shards []string, dryRun bool) (states []*TargetServingState, err error) {
+	if !dryRun {
+		var unlock func(*error)
+		ctx, unlock, err = wr.ts.LockKeyspace(ctx, keyspace, "EnsureTargetsNonServing")
+		if err != nil {
+			return nil, err
+		}
+		defer unlock(&err)
+	}
+
+	allShards, err := wr.ts.FindAllShardsInKeyspace(ctx, keyspace, nil)
+	if err != nil {
+		return nil, err
+	}
+	isTarget := make(map[string]bool, len(shards))
+	for _, shard := range shards {
+		if _, ok := allShards[shard]; !ok {
+			return nil, fmt.Errorf("target shard %v not found in keyspace %v", shard, keyspace)
+		}
+		isTarget[shard] = true
+	}
+	var otherServing []*topodatapb.KeyRange
+	for name, si := range allShards {
+		if !isTarget[name] && si.IsPrimaryServing {
+			otherServing = append(otherServing, si.KeyRange)
+		}
+	}
+
+	for _, shard := range shards {
+		si := allShards[shard]
+		state := &TargetServingState{Shard: shard, WasServing: si.IsPrimaryServing}
+		states = append(states, state)
+		if !si.IsPrimaryServing {
+			continue
+		}
+		if !keyRangeCoveredBy(si.KeyRange, otherServing) {
+			return states, fmt.Errorf("target shard %v is the only serving shard for part of key range %v, refusing to transition it to non-serving", shard, key.KeyRangeString(si.KeyRange))
+		}
+		if dryRun {
+			wr.Logger().Printf("Target shard %v/%v is in serving state and would be transitioned to non-serving\n", keyspace, shard)
+			continue
+		}
+		if _, err := wr.ts.UpdateShardFields(ctx, keyspace, shard, func(si *topo.ShardInfo) error {
+			si.IsPrimaryServing = false
+			return nil
+		}); err != nil {
+			return states, vterrors.Wrapf(err, "UpdateShardFields(%v/%v)", keyspace, shard)
+		}
+		state.Transitioned = true
+		wr.Logger().Infof("Transitioned target shard %v/%v to non-serving", keyspace, shard)
+	}
+	return states, nil
+}
+
+// ReshardTargetShards reports the result of CreateReshardTargetShards.
+type ReshardTargetShards struct {
+	Created  []string
+	Existing []string
+}
+
+// CreateReshardTargetShards creates non-serving target shards with the
+// given key ranges, so that a reshard can be started without creating the
+// shards by hand. Shards that already exist are left untouched and
+// reported as existing, unless they are serving, which is refused.
+func (wr *Wrangler) CreateReshardTargetShards(ctx context.Context, keyspace string, targetRanges []*topodatapb.KeyRange) (*ReshardTargetShards, error) {
+	result := &ReshardTargetShards{}
+	for _, kr := range targetRanges {
+		shard := key.KeyRangeString(kr)
+		err := wr.ts.CreateShard(ctx, keyspace, shard)
+		switch {
+		case err == nil:
+			result.Created = append(result.Created, shard)
+		case topo.IsErrType(err, topo.NodeExists):
+			si, err := wr.ts.GetShard(ctx, keyspace, shard)
+			if err != nil {
+				return result, vterrors.Wrapf(err, "GetShard(%s) failed", shard)
+			}
+			if si.IsPrimaryServing {
+				return result, fmt.Errorf("target shard %v already exists in keyspace %v and is in serving state", shard, keyspace)
+			}
+			result.Existing = append(result.Existing, shard)
+			continue
+		default:
+			return result, vterrors.Wrapf(err, "CreateShard(%s) failed", shard)
+		}
+		// CreateShard makes a shard serving if it overlaps no other shard,
+		// but a reshard target must not serve until traffic is switched.
+		if _, err := wr.ts.UpdateShardFields(ctx, keyspace, shard, func(si *topo.ShardInfo) error {
+			if !si.IsPrimaryServing {
+				return topo.NewError(topo.NoUpdateNeeded, shard)
+			}
+			si.IsPrimaryServing = false
+			return nil
+		}); err != nil {
+			return result, vterrors.Wrapf(err, "UpdateShardFields(%v/%v)", keyspace, shard)
+		}
+		wr.Logger().Infof("Created target shard %v/%v", keyspace, shard)
+	}
+	return result, nil
+}
+
+// keyRangeCoveredBy returns true if the union of ranges fully contains kr.
+func keyRangeCoveredBy(kr *topodatapb.KeyRange, ranges []*topodatapb.KeyRange) bool {
+	var intersecting []*topodatapb.KeyRange
+	for _, r := range ranges {
+		if key.KeyRangeIntersect(kr, r) {
+			intersecting = append(intersecting, r)
+		}
+	}
+	if len(intersecting) == 0 {
+		return false
+	}
+	sort.Slice(intersecting, func(i, j int) bool {
+		return key.KeyRangeLess(intersecting[i], intersecting[j])
+	})
+	covered := intersecting[0]
+	for _, r := range intersecting[1:] {
+		if key.KeyRangeContainsKeyRange(covered, r) {
+			continue
+		}
+		merged, ok := key.KeyRangeAdd(covered, r)
+		if !ok {
+			return false
+		}
+		covered = merged
+	}
+	return key.KeyRangeContainsKeyRange(covered, kr)
+}
+
 // validateTargets ensures that the target shards have no existing
 // VReplication workflow streams as that is an invalid starting
-// state for the non-serving shards involved in a Reshard.
-func (rs *resharder) validateTargets(ctx context.Context) error {
-	err := rs.forAll(rs.targetShards, func(target *topo.ShardInfo) error {
+// state for the non-serving shards involved in a Reshard. If
+// ignoreFrozen is set, the frozen streams left by a completed workflow
+// are logged and otherwise ignored, and so are the streams of the
+// ignoreWorkflows. The shards that have other streams are returned in an
+// ErrTargetNotEmpty.
+func (rs *resharder) validateTargets(ctx context.Context, ignoreFrozen bool, ignoreWorkflows []string) error {
+	var (
+		mu       sync.Mutex
+		notEmpty []string
+	)
+	err := rs.forAll(ctx, rs.targetShards, func(target *topo.ShardInfo) error {
 		targetPrimary := rs.targetPrimaries[target.ShardName()]
-		query := fmt.Sprintf("select 1 from _vt.vreplication where db_name=%s", encodeString(targetPrimary.DbName()))
-		p3qr, err := rs.wr.tmc.VReplicationExec(ctx, targetPrimary.Tablet, query)
+		hasStreams := false
+		if ignoreFrozen || len(ignoreWorkflows) > 0 {
+			var (
+				ids []string
+				err error
+			)
+			if hasStreams, ids, err = rs.validateTargetIgnoring(ctx, target, targetPrimary, ignoreFrozen, ignoreWorkflows); err != nil {
+				return err
+			}
+			if len(ids) > 0 {
+				mu.Lock()
+				rs.existingStreams[target.ShardName()] = ids
+				mu.Unlock()
+			}
+		} else {
+			query := fmt.Sprintf("select 1 from _vt.vreplication where db_name=%s", encodeString(targetPrimary.DbName()))
+			p3qr, err := rs.vreplicationExec(ctx, targetPrimary.Tablet, query)
+			if err != nil {
+				return vterrors.Wrapf(err, "VReplicationExec(%v, %s)", targetPrimary.Tablet, query)
+			}
+			hasStreams = len(p3qr.Rows) != 0
+		}
+		if hasStreams {
+			mu.Lock()
+			defer mu.Unlock()
+			notEmpty = append(notEmpty, target.ShardName())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(notEmpty) > 0 {
+		sort.Strings(notEmpty)
+		return &ErrTargetNotEmpty{Shards: notEmpty}
+	}
+	return nil
+}
+
+// validateTargetIgnoring returns whether the target shard has streams
+// other than frozen ones, if ignoreFrozen is set, and those of the
+// ignoreWorkflows, and the ids of the streams it ignored.
+func (rs *resharder) validateTargetIgnoring(ctx context.Context, target *topo.ShardInfo, targetPrimary *topo.TabletInfo, ignoreFrozen bool, ignoreWorkflows []string) (bool, []string, error) {
+	query := fmt.Sprintf("select id, workflow, message from _vt.vreplication where db_name=%s", encodeString(targetPrimary.DbName()))
+	p3qr, err := rs.vreplicationExec(ctx, targetPrimary.Tablet, query)
+	if err != nil {
+		return false, nil, vterrors.Wrapf(err, "VReplicationExec(%v, %s)", targetPrimary.Tablet, query)
+	}
+	var frozen, ignored, ids []string
+	for _, row := range sqltypes.Proto3ToResult(p3qr).Rows {
+		stream := fmt.Sprintf("%v (workflow %v)", row[0].ToString(), row[1].ToString())
+		switch {
+		case ignoreFrozen && row[2].ToString() == workflow.Frozen:
+			frozen = append(frozen, stream)
+		case slices.Contains(ignoreWorkflows, row[1].ToString()):
+			ignored = append(ignored, stream)
+		default:
+			return true, nil, nil
+		}
+		ids = append(ids, row[0].ToString())
+	}
+	if len(frozen) > 0 {
+		rs.wr.Logger().Infof("Ignoring frozen streams on target shard %v: %v", target.ShardName(), strings.Join(frozen, ", "))
+	}
+	if len(ignored) > 0 {
+		rs.wr.Logger().Infof("Ignoring streams of unrelated workflows on target shard %v: %v", target.ShardName(), strings.Join(ignored, ", "))
+	}
+	return false, ids, nil
+}
+
+// validateMySQLVersions checks that no target primary runs an older MySQL
+// version than a source primary, since copying schema and data to an
+// older version can fail in ways that only show up deep into the copy.
+// The versions are reported per shard. It costs a FullStatus RPC per
+// primary, so it only runs if StrictMySQLVersionCheck is set.
+func (rs *resharder) validateMySQLVersions(ctx context.Context) error {
+	getVersions := func(shards []*topo.ShardInfo, primaries map[string]*topo.TabletInfo, kind string) (map[string]string, error) {
+		versions := make(map[string]string, len(shards))
+		var mu sync.Mutex
+		err := rs.forAll(ctx, shards, func(si *topo.ShardInfo) error {
+			primary := primaries[si.ShardName()]
+			status, err := rs.wr.tmc.FullStatus(ctx, primary.Tablet)
+			if err != nil {
+				return vterrors.Wrapf(err, "FullStatus(%v)", topoproto.TabletAliasString(primary.Alias))
+			}
+			rs.wr.Logger().Infof("%s shard %v/%v primary %v runs MySQL %v", kind, rs.keyspace, si.ShardName(),
+				topoproto.TabletAliasString(primary.Alias), status.Version)
+			mu.Lock()
+			defer mu.Unlock()
+			versions[si.ShardName()] = status.Version
+			return nil
+		})
+		return versions, err
+	}
+	sourceVersions, err := getVersions(rs.sourceShards, rs.sourcePrimaries, "Source")
+	if err != nil {
+		return err
+	}
+	targetVersions, err := getVersions(rs.targetShards, rs.targetPrimaries, "Target")
+	if err != nil {
+		return err
+	}
+
+	var downgrades []string
+	for _, target := range rs.targetShards {
+		targetVersion, ok := targetVersions[target.ShardName()]
+		if !ok {
+			continue
+		}
+		for _, source := range rs.sourceShards {
+			sourceVersion, ok := sourceVersions[source.ShardName()]
+			if !ok {
+				continue
+			}
+			cmp, err := compareMySQLVersions(sourceVersion, targetVersion)
+			if err != nil {
+				rs.wr.Logger().Warningf("Cannot compare MySQL version %q of source shard %v with %q of target shard %v: %v",
+					sourceVersion, source.ShardName(), targetVersion, target.ShardName(), err)
+				continue
+			}
+			if cmp > 0 {
+				downgrades = append(downgrades, fmt.Sprintf("source shard %v runs MySQL %v but target shard %v runs older MySQL %v",
+					source.ShardName(), sourceVersion, target.ShardName(), targetVersion))
+			}
+		}
+	}
+	if len(downgrades) == 0 {
+		return nil
+	}
+	return fmt.Errorf("incompatible MySQL versions: %s", strings.Join(downgrades, "; "))
+}
+
+// compareMySQLVersions compares two MySQL server versions such as
+// "8.0.34-log" by their numeric major, minor and patch parts. It returns
+// a negative number if a is older than b, a positive one if a is newer,
+// and zero if they are the same.
+func compareMySQLVersions(a, b string) (int, error) {
+	parse := func(version string) ([]int, error) {
+		if version == "" {
+			return nil, errors.New("empty version")
+		}
+		var parts []int
+		for _, token := range strings.Split(strings.Split(version, "-")[0], ".") {
+			part, err := strconv.Atoi(token)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version %q: %v", version, err)
+			}
+			parts = append(parts, part)
+		}
+		return parts, nil
+	}
+	aParts, err := parse(a)
+	if err != nil {
+		return 0, err
+	}
+	bParts, err := parse(b)
+	if err != nil {
+		return 0, err
+	}
+	return slices.Compare(aParts, bParts), nil
+}
+
+// ValidateSequences checks that the sequence of every table of the keyspace
+// with an auto increment column is declared as a sequence table in the
+// vschema of an unsharded keyspace, and that the sequence table exists on
+// the primary of that keyspace. It returns a description of every problem
+// it finds, which would otherwise only show as failed inserts.
+func (wr *Wrangler) ValidateSequences(ctx context.Context, keyspace string) ([]string, error) {
+	vschema, err := wr.ts.GetVSchema(ctx, keyspace)
+	if err != nil {
+		return nil, vterrors.Wrap(err, "GetVSchema")
+	}
+	return wr.validateSequences(ctx, vschema)
+}
+
+func (wr *Wrangler) validateSequences(ctx context.Context, vschema *vschemapb.Keyspace) ([]string, error) {
+	tableNames := make([]string, 0, len(vschema.Tables))
+	for tableName, table := range vschema.Tables {
+		if table.AutoIncrement != nil {
+			tableNames = append(tableNames, tableName)
+		}
+	}
+	if len(tableNames) == 0 {
+		return nil, nil
+	}
+	sort.Strings(tableNames)
+
+	vschemas := make(map[string]*vschemapb.Keyspace)
+	keyspaces, err := wr.ts.GetKeyspaces(ctx)
+	if err != nil {
+		return nil, vterrors.Wrap(err, "GetKeyspaces")
+	}
+	for _, ks := range keyspaces {
+		vs, err := wr.ts.GetVSchema(ctx, ks)
+		if err != nil {
+			if topo.IsErrType(err, topo.NoNode) {
+				continue
+			}
+			return nil, vterrors.Wrapf(err, "GetVSchema(%s)", ks)
+		}
+		vschemas[ks] = vs
+	}
+
+	var gaps []string
+	for _, tableName := range tableNames {
+		sequence := vschema.Tables[tableName].AutoIncrement.Sequence
+		seqKeyspace, seqTable, err := wr.parser.ParseTable(sequence)
+		if err != nil {
+			gaps = append(gaps, fmt.Sprintf("table %s: invalid sequence %s: %v", tableName, sequence, err))
+			continue
+		}
+		if seqKeyspace == "" {
+			var found []string
+			for ks, vs := range vschemas {
+				if vs.Tables[seqTable] != nil && vs.Tables[seqTable].Type == vindexes.TypeSequence {
+					found = append(found, ks)
+				}
+			}
+			sort.Strings(found)
+			switch len(found) {
+			case 0:
+				gaps = append(gaps, fmt.Sprintf("table %s: sequence %s not found in the vschema of any keyspace", tableName, sequence))
+				continue
+			case 1:
+				seqKeyspace = found[0]
+			default:
+				gaps = append(gaps, fmt.Sprintf("table %s: sequence %s is ambiguous, found in keyspaces %s", tableName, sequence, strings.Join(found, ", ")))
+				continue
+			}
+		}
+		vs, ok := vschemas[seqKeyspace]
+		if !ok || vs.Tables[seqTable] == nil || vs.Tables[seqTable].Type != vindexes.TypeSequence {
+			gaps = append(gaps, fmt.Sprintf("table %s: %s.%s is not a sequence table in the vschema", tableName, seqKeyspace, seqTable))
+			continue
+		}
+		if vs.Sharded {
+			gaps = append(gaps, fmt.Sprintf("table %s: sequence %s.%s is in sharded keyspace %s", tableName, seqKeyspace, seqTable, seqKeyspace))
+			continue
+		}
+		if gap := wr.checkSequenceTable(ctx, seqKeyspace, seqTable); gap != "" {
+			gaps = append(gaps, fmt.Sprintf("table %s: %s", tableName, gap))
+		}
+	}
+	return gaps, nil
+}
+
+// checkSequenceTable returns why the sequence table can't be found on the
+// primary of the unsharded keyspace, or an empty string if it is there.
+func (wr *Wrangler) checkSequenceTable(ctx context.Context, keyspace, table string) string {
+	shards, err := wr.ts.GetShardNames(ctx, keyspace)
+	if err != nil || len(shards) == 0 {
+		return fmt.Sprintf("sequence keyspace %s has no shards", keyspace)
+	}
+	si, err := wr.ts.GetShard(ctx, keyspace, shards[0])
+	if err != nil || si.PrimaryAlias == nil {
+		return fmt.Sprintf("sequence keyspace %s has no primary", keyspace)
+	}
+	primary, err := wr.ts.GetTablet(ctx, si.PrimaryAlias)
+	if err != nil {
+		return fmt.Sprintf("sequence keyspace %s has no primary: %v", keyspace, err)
+	}
+	sd, err := wr.tmc.GetSchema(ctx, primary.Tablet, &tabletmanagerdatapb.GetSchemaRequest{Tables: []string{table}})
+	if err != nil {
+		return fmt.Sprintf("cannot read the schema of %s: %v", topoproto.TabletAliasString(si.PrimaryAlias), err)
+	}
+	for _, td := range sd.GetTableDefinitions() {
+		if td.Name == table {
+			return ""
+		}
+	}
+	return fmt.Sprintf("sequence table %s.%s does not exist on %s", keyspace, table, topoproto.TabletAliasString(si.PrimaryAlias))
+}
+
+// validateStopPositions checks that the stop positions are valid GTID
+// positions of source shards, and that they can be reached.
+func (rs *resharder) validateStopPositions() error {
+	if len(rs.stopPositions) == 0 {
+		return nil
+	}
+	if rs.stopAfterCopy {
+		return errors.New("stop positions cannot be combined with stop_after_copy, the streams would stop after the copy without reaching them")
+	}
+	for shard, pos := range rs.stopPositions {
+		if !slices.ContainsFunc(rs.sourceShards, func(si *topo.ShardInfo) bool { return si.ShardName() == shard }) {
+			return fmt.Errorf("stop position given for shard %v, which is not a source shard", shard)
+		}
+		position, err := replication.DecodePosition(pos)
+		if err != nil {
+			return vterrors.Wrapf(err, "invalid stop position %q for source shard %v", pos, shard)
+		}
+		if position.IsZero() {
+			return fmt.Errorf("empty stop position for source shard %v", shard)
+		}
+	}
+	return nil
+}
+
+// setStopPositions sets the stop position of the streams created on the
+// target from the source shards that have one.
+func (rs *resharder) setStopPositions(ctx context.Context, targetPrimary *topo.TabletInfo) error {
+	query := fmt.Sprintf("select id, source from _vt.vreplication where db_name=%s and workflow=%s",
+		encodeString(targetPrimary.DbName()), encodeString(rs.workflow))
+	p3qr, err := rs.vreplicationExec(ctx, targetPrimary.Tablet, query)
+	if err != nil {
+		return vterrors.Wrapf(err, "VReplicationExec(%v, %s)", targetPrimary.Tablet, query)
+	}
+	idsByPos := make(map[string][]string)
+	for _, row := range sqltypes.Proto3ToResult(p3qr).Rows {
+		var bls binlogdatapb.BinlogSource
+		rowBytes, err := row[1].ToBytes()
 		if err != nil {
+			return err
+		}
+		if err := prototext.Unmarshal(rowBytes, &bls); err != nil {
+			return vterrors.Wrapf(err, "prototext.Unmarshal: %v", row)
+		}
+		if pos, ok := rs.stopPositions[bls.Shard]; ok && bls.Keyspace == rs.keyspace {
+			idsByPos[pos] = append(idsByPos[pos], row[0].ToString())
+		}
+	}
+	positions := make([]string, 0, len(idsByPos))
+	for pos := range idsByPos {
+		positions = append(positions, pos)
+	}
+	sort.Strings(positions)
+	for _, pos := range positions {
+		query := fmt.Sprintf("update _vt.vreplication set stop_pos=%s where id in (%s)",
+			encodeString(pos), strings.Join(idsByPos[pos], ", "))
+		if _, err := rs.vreplicationExec(ctx, targetPrimary.Tablet, query); err != nil {
 			return vterrors.Wrapf(err, "VReplicationExec(%v, %s)", targetPrimary.Tablet, query)
 		}
-		if len(p3qr.Rows) != 0 {
-			return errors.New("some streams already exist in the target shards, please clean them up and retry the command")
+		rs.logf("Streams %v on %v will stop at %v", strings.Join(idsByPos[pos], ", "), topoproto.TabletAliasString(targetPrimary.Alias), pos)
+	}
+	return nil
+}
+
+// readRefStreams reads the reference streams of the source shards, which
+// all have to have the same ones. If repairRefStreams is set, the streams of
+// the first source shard that has all of them are used instead, and the
+// streams missing on the other source shards are logged.
+func (rs *resharder) readRefStreams(ctx context.Context) error {
+	var mu sync.Mutex
+	byShard := make(map[string]map[string]*refStream, len(rs.sourceShards))
+	err := rs.forAll(ctx, rs.sourceShards, func(source *topo.ShardInfo) error {
+		streams, err := rs.readShardRefStreams(ctx, source)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		byShard[source.ShardName()] = streams
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	first := rs.sourceShards[0].ShardName()
+	var mismatch error
+	for _, source := range rs.sourceShards[1:] {
+		if mismatch = refStreamsMismatch(source.ShardName(), byShard[first], byShard[source.ShardName()]); mismatch != nil {
+			break
+		}
+	}
+	if mismatch == nil {
+		rs.refStreams = byShard[first]
+		return nil
+	}
+	if !rs.repairRefStreams {
+		return mismatch
+	}
+
+	all := make(map[string]bool)
+	for _, streams := range byShard {
+		for key := range streams {
+			all[key] = true
+		}
+	}
+	var complete string
+	for _, source := range rs.sourceShards {
+		if len(byShard[source.ShardName()]) == len(all) {
+			complete = source.ShardName()
+			break
+		}
+	}
+	if complete == "" {
+		rs.wr.Logger().Warningf("Cannot repair the reference streams, no source shard has all of them: %v", mismatch)
+		return mismatch
+	}
+	for _, source := range rs.sourceShards {
+		var missing []string
+		for key := range all {
+			if byShard[source.ShardName()][key] == nil {
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			rs.wr.Logger().Warningf("Source shard %v is missing reference streams %v, using those of source shard %v",
+				source.ShardName(), strings.Join(missing, ", "), complete)
+		}
+	}
+	rs.refStreams = byShard[complete]
+	return nil
+}
+
+// refStreamsMismatch returns an ErrStreamMismatch if the reference streams of
+// the source shard differ from those of the first source shard.
+func refStreamsMismatch(shard string, first, streams map[string]*refStream) error {
+	keys := make([]string, 0, len(streams))
+	for key := range streams {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if first[key] == nil {
+			workflow := streams[key].workflow
+			return &ErrStreamMismatch{
+				Shard:    shard,
+				Workflow: workflow,
+				msg:      fmt.Sprintf("streams are mismatched across source shards for workflow: %s", workflow),
+			}
+		}
+	}
+	missing := make(map[string]bool)
+	for key := range first {
+		if streams[key] == nil {
+			missing[key] = true
+		}
+	}
+	if len(missing) != 0 {
+		return &ErrStreamMismatch{
+			Shard: shard,
+			msg:   fmt.Sprintf("streams are mismatched across source shards: %v", missing),
 		}
-		return nil
-	})
-	return err
+	}
+	return nil
 }
 
-func (rs *resharder) readRefStreams(ctx context.Context) error {
-	var mu sync.Mutex
-	err := rs.forAll(rs.sourceShards, func(source *topo.ShardInfo) error {
-		sourcePrimary := rs.sourcePrimaries[source.ShardName()]
+// readShardRefStreams returns the reference streams of the source shard by
+// workflow, keyspace and shard.
+func (rs *resharder) readShardRefStreams(ctx context.Context, source *topo.ShardInfo) (map[string]*refStream, error) {
+	sourcePrimary := rs.sourcePrimaries[source.ShardName()]
 
-		query := fmt.Sprintf("select workflow, source, cell, tablet_types from _vt.vreplication where db_name=%s and message != 'FROZEN'", encodeString(sourcePrimary.DbName()))
-		p3qr, err := rs.wr.tmc.VReplicationExec(ctx, sourcePrimary.Tablet, query)
-		if err != nil {
-			return vterrors.Wrapf(err, "VReplicationExec(%v, %s)", sourcePrimary.Tablet, query)
-		}
-		qr := sqltypes.Proto3ToResult(p3qr)
+	query := fmt.Sprintf("select workflow, source, cell, tablet_types, workflow_type, workflow_sub_type from _vt.vreplication where db_name=%s and message != 'FROZEN'", encodeString(sourcePrimary.DbName()))
+	p3qr, err := rs.vreplicationExec(ctx, sourcePrimary.Tablet, query)
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "VReplicationExec(%v, %s)", sourcePrimary.Tablet, query)
+	}
+	qr := sqltypes.Proto3ToResult(p3qr)
 
-		mu.Lock()
-		defer mu.Unlock()
+	streams := make(map[string]*refStream)
+	for _, row := range qr.Rows {
 
-		mustCreate := false
-		var ref map[string]bool
-		if rs.refStreams == nil {
-			rs.refStreams = make(map[string]*refStream)
-			mustCreate = true
-		} else {
-			// Copy the ref streams for comparison.
-			ref = make(map[string]bool, len(rs.refStreams))
-			for k := range rs.refStreams {
-				ref[k] = true
-			}
+		workflow := row[0].ToString()
+		if workflow == "" {
+			return nil, fmt.Errorf("VReplication streams must have named workflows for migration: shard: %s:%s", source.Keyspace(), source.ShardName())
 		}
-		for _, row := range qr.Rows {
-
-			workflow := row[0].ToString()
-			if workflow == "" {
-				return fmt.Errorf("VReplication streams must have named workflows for migration: shard: %s:%s", source.Keyspace(), source.ShardName())
-			}
-			var bls binlogdatapb.BinlogSource
-			rowBytes, err := row[1].ToBytes()
-			if err != nil {
-				return err
-			}
-			if err := prototext.Unmarshal(rowBytes, &bls); err != nil {
-				return vterrors.Wrapf(err, "prototext.Unmarshal: %v", row)
-			}
-			isReference, err := rs.blsIsReference(&bls)
-			if err != nil {
-				return vterrors.Wrap(err, "blsIsReference")
-			}
-			if !isReference {
-				continue
-			}
-			key := fmt.Sprintf("%s:%s:%s", workflow, bls.Keyspace, bls.Shard)
-			if mustCreate {
-				rs.refStreams[key] = &refStream{
-					workflow:    workflow,
-					bls:         &bls,
-					cell:        row[2].ToString(),
-					tabletTypes: row[3].ToString(),
-				}
-			} else {
-				if !ref[key] {
-					return fmt.Errorf("streams are mismatched across source shards for workflow: %s", workflow)
-				}
-				delete(ref, key)
-			}
+		var bls binlogdatapb.BinlogSource
+		rowBytes, err := row[1].ToBytes()
+		if err != nil {
+			return nil, err
 		}
-		if len(ref) != 0 {
-			return fmt.Errorf("streams are mismatched across source shards: %v", ref)
+		if err := prototext.Unmarshal(rowBytes, &bls); err != nil {
+			return nil, vterrors.Wrapf(err, "prototext.Unmarshal: %v", row)
 		}
-		return nil
-	})
-	return err
+		isReference, err := rs.blsIsReference(&bls)
+		if err != nil {
+			return nil, vterrors.Wrap(err, "blsIsReference")
+		}
+		if !isReference {
+			continue
+		}
+		key := fmt.Sprintf("%s:%s:%s", workflow, bls.Keyspace, bls.Shard)
+		workflowType, err := row[4].ToInt32()
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "invalid workflow_type: %v", row)
+		}
+		workflowSubType, err := row[5].ToInt32()
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "invalid workflow_sub_type: %v", row)
+		}
+		streams[key] = &refStream{
+			workflow:        workflow,
+			bls:             &bls,
+			cell:            row[2].ToString(),
+			tabletTypes:     row[3].ToString(),
+			workflowType:    binlogdatapb.VReplicationWorkflowType(workflowType),
+			workflowSubType: binlogdatapb.VReplicationWorkflowSubType(workflowSubType),
+		}
+	}
+	return streams, nil
+}
+
+// ReferenceStreamInfo describes a reference stream found on the source
+// shards of a reshard, which the reshard copies to every target shard.
+type ReferenceStreamInfo struct {
+	Workflow       string
+	SourceKeyspace string
+	SourceShard    string
+	Cell           string
+	TabletTypes    string
+}
+
+// ReferenceStreams returns the reference streams found by readRefStreams,
+// sorted by workflow and source.
+func (rs *resharder) ReferenceStreams() []ReferenceStreamInfo {
+	names := make([]string, 0, len(rs.refStreams))
+	for name := range rs.refStreams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	streams := make([]ReferenceStreamInfo, 0, len(names))
+	for _, name := range names {
+		rstream := rs.refStreams[name]
+		streams = append(streams, ReferenceStreamInfo{
+			Workflow:       rstream.workflow,
+			SourceKeyspace: rstream.bls.Keyspace,
+			SourceShard:    rstream.bls.Shard,
+			Cell:           rstream.cell,
+			TabletTypes:    rstream.tabletTypes,
+		})
+	}
+	return streams
+}
+
+// ReshardReferenceStreams returns the reference streams that Reshard would
+// copy to the target shards, after checking that all the source shards have
+// the same ones, without creating any stream.
+func (wr *Wrangler) ReshardReferenceStreams(ctx context.Context, keyspace, workflow string, sources, targets []string, opts ReshardOptions) ([]ReferenceStreamInfo, error) {
+	rs, err := wr.buildResharder(ctx, keyspace, workflow, sources, targets, "", "", opts)
+	if err != nil {
+		return nil, vterrors.Wrap(err, "buildResharder")
+	}
+	return rs.ReferenceStreams(), nil
 }
 
 // blsIsReference is partially copied from streamMigrater.templatize.
@@ -285,7 +1895,7 @@ func (rs *resharder) identifyRuleType(rule *binlogdatapb.Rule) (workflow.StreamT
 	if !ok && !schema.IsInternalOperationTableName(rule.Match) {
 		return 0, fmt.Errorf("table %v not found in vschema", rule.Match)
 	}
-	if vtable != nil && vtable.Type == vindexes.TypeReference {
+	if rs.isReferenceTable(rule.Match, vtable) {
 		return workflow.StreamTypeReference, nil
 	}
 	// In this case, 'sharded' means that it's not a reference
@@ -293,100 +1903,646 @@ func (rs *resharder) identifyRuleType(rule *binlogdatapb.Rule) (workflow.StreamT
 	return workflow.StreamTypeSharded, nil
 }
 
+// validateDeferSecondaryKeysTables checks that deferSecondaryKeysTables are
+// tables of the vschema.
+func (rs *resharder) validateDeferSecondaryKeysTables() error {
+	for _, tableName := range rs.deferSecondaryKeysTables {
+		if _, ok := rs.vschema.Tables[tableName]; !ok {
+			return fmt.Errorf("table %v not found in vschema", tableName)
+		}
+	}
+	return nil
+}
+
+// defersAllTables returns whether every table of the stream is one of
+// deferSecondaryKeysTables.
+func (rs *resharder) defersAllTables(bls *binlogdatapb.BinlogSource) bool {
+	if len(rs.deferSecondaryKeysTables) == 0 {
+		return false
+	}
+	for _, rule := range bls.GetFilter().GetRules() {
+		if !slices.Contains(rs.deferSecondaryKeysTables, rule.Match) {
+			return false
+		}
+	}
+	return true
+}
+
+// isReferenceTable returns whether the reshard treats the table as a
+// reference table, which is the case if the vschema says so unless the
+// table is one of includeReferenceTables.
+func (rs *resharder) isReferenceTable(name string, vtable *vschemapb.Table) bool {
+	if vtable == nil || vtable.Type != vindexes.TypeReference {
+		return false
+	}
+	return !slices.Contains(rs.includeReferenceTables, name)
+}
+
+// defaultReshardCopySchemaTimeout is the default time copySchema waits for
+// the replicas of each target shard.
+const defaultReshardCopySchemaTimeout = 30 * time.Second
+
 func (rs *resharder) copySchema(ctx context.Context) error {
 	oneSource := rs.sourceShards[0].PrimaryAlias
-	err := rs.forAll(rs.targetShards, func(target *topo.ShardInfo) error {
-		return rs.wr.CopySchemaShard(ctx, oneSource, []string{"/.*"}, nil, false, rs.keyspace, target.ShardName(), 1*time.Second, false)
-	})
+	timeout := rs.copySchemaTimeout
+	if timeout <= 0 {
+		timeout = defaultReshardCopySchemaTimeout
+	}
+	err := rs.forAll(ctx, rs.targetShards, rs.withShardProgress(ReshardProgressCopySchema, func(target *topo.ShardInfo) error {
+		var progress func(copied, total int)
+		if rs.onCopySchemaProgress != nil {
+			shard := target.ShardName()
+			progress = func(copied, total int) {
+				rs.onCopySchemaProgress(shard, copied, total)
+			}
+		}
+		return rs.wr.CopySchemaShardWithProgress(ctx, oneSource, []string{"/.*"}, nil, false, rs.keyspace, target.ShardName(), timeout, false, progress)
+	}))
 	return err
 }
 
-// createStreams creates all of the VReplication streams that
-// need to now exist on the new shards.
-func (rs *resharder) createStreams(ctx context.Context) error {
+// plannedStream is a stream a reshard creates on a target shard.
+type plannedStream struct {
+	workflow        string
+	source          *binlogdatapb.BinlogSource
+	cell            string
+	tabletTypes     string
+	workflowType    binlogdatapb.VReplicationWorkflowType
+	workflowSubType binlogdatapb.VReplicationWorkflowSubType
+	// deferSecondaryKeys defers the creation of the secondary keys of the
+	// tables of the stream until their copy is done.
+	deferSecondaryKeys bool
+}
+
+// PlanReshardStreams returns the sources of the streams of the workflow that
+// Reshard would create on each target shard with the same arguments, without
+// creating them. The copies of the reference streams that Reshard also
+// creates belong to the workflows of the originals, so they aren't part of
+// the plan. The plan can be checked against the streams once they are
+// created with VerifyReshardStreams.
+func (wr *Wrangler) PlanReshardStreams(ctx context.Context, keyspace, workflow string, sources, targets []string,
+	cell, tabletTypes, onDDL string, stopAfterCopy bool, opts ReshardOptions) (ReshardStreamPlan, error) {
+	rs, err := wr.buildResharder(ctx, keyspace, workflow, sources, targets, cell, tabletTypes, opts)
+	if err != nil {
+		return nil, vterrors.Wrap(err, "buildResharder")
+	}
+	rs.onDDL = onDDL
+	rs.stopAfterCopy = stopAfterCopy
+	plan := make(ReshardStreamPlan, len(rs.targetShards))
+	for target, streams := range rs.planStreams() {
+		for _, stream := range streams {
+			if stream.workflow == rs.workflow {
+				plan[target] = append(plan[target], stream.source)
+			}
+		}
+	}
+	return plan, nil
+}
+
+// planStreams returns the streams to create on each target shard: one from
+// each source shard whose key range intersects the target's, which excludes
+// the reference tables, and a copy of each reference stream.
+func (rs *resharder) planStreams() map[string][]*plannedStream {
 	var excludeRules []*binlogdatapb.Rule
 	for tableName, table := range rs.vschema.Tables {
-		if table.Type == vindexes.TypeReference {
+		if rs.isReferenceTable(tableName, table) {
 			excludeRules = append(excludeRules, &binlogdatapb.Rule{
 				Match:  tableName,
 				Filter: "exclude",
 			})
 		}
 	}
+	// The sharded tables for which only deferSecondaryKeysTables defers
+	// the secondary keys are excluded from the main streams.
+	var deferredTables []string
+	if !rs.deferSecondaryKeys {
+		for _, tableName := range rs.deferSecondaryKeysTables {
+			if !rs.isReferenceTable(tableName, rs.vschema.Tables[tableName]) {
+				deferredTables = append(deferredTables, tableName)
+			}
+		}
+		sort.Strings(deferredTables)
+		for _, tableName := range deferredTables {
+			excludeRules = append(excludeRules, &binlogdatapb.Rule{
+				Match:  tableName,
+				Filter: "exclude",
+			})
+		}
+	}
+	refStreamNames := make([]string, 0, len(rs.refStreams))
+	for name := range rs.refStreams {
+		refStreamNames = append(refStreamNames, name)
+	}
+	sort.Strings(refStreamNames)
 
-	err := rs.forAll(rs.targetShards, func(target *topo.ShardInfo) error {
-		targetPrimary := rs.targetPrimaries[target.ShardName()]
-
-		ig := vreplication.NewInsertGenerator(binlogdatapb.VReplicationWorkflowState_Stopped, targetPrimary.DbName())
-
-		// copy excludeRules to prevent data race.
-		copyExcludeRules := append([]*binlogdatapb.Rule(nil), excludeRules...)
+	plans := make(map[string][]*plannedStream, len(rs.targetShards))
+	for _, target := range rs.targetShards {
+		var streams []*plannedStream
 		for _, source := range rs.sourceShards {
 			if !key.KeyRangeIntersect(target.KeyRange, source.KeyRange) {
 				continue
 			}
+			rs.logf("Target shard %v intersects source shard %v", target.ShardName(), source.ShardName())
 			filter := &binlogdatapb.Filter{
-				Rules: append(copyExcludeRules, &binlogdatapb.Rule{
+				Rules: append(append([]*binlogdatapb.Rule(nil), excludeRules...), &binlogdatapb.Rule{
 					Match:  "/.*",
 					Filter: key.KeyRangeString(target.KeyRange),
 				}),
 			}
-			bls := &binlogdatapb.BinlogSource{
-				Keyspace:      rs.keyspace,
-				Shard:         source.ShardName(),
-				Filter:        filter,
-				StopAfterCopy: rs.stopAfterCopy,
-				OnDdl:         binlogdatapb.OnDDLAction(binlogdatapb.OnDDLAction_value[rs.onDDL]),
+			streams = append(streams, &plannedStream{
+				workflow: rs.workflow,
+				source: &binlogdatapb.BinlogSource{
+					Keyspace:      rs.keyspace,
+					Shard:         source.ShardName(),
+					Filter:        filter,
+					StopAfterCopy: rs.stopAfterCopy,
+					OnDdl:         binlogdatapb.OnDDLAction(binlogdatapb.OnDDLAction_value[rs.onDDL]),
+				},
+				cell:               rs.cell,
+				tabletTypes:        rs.tabletTypes,
+				workflowType:       binlogdatapb.VReplicationWorkflowType_Reshard,
+				workflowSubType:    binlogdatapb.VReplicationWorkflowSubType_None,
+				deferSecondaryKeys: rs.deferSecondaryKeys,
+			})
+			if len(deferredTables) == 0 {
+				continue
+			}
+			// A keyrange filter is only supported for a regular expression,
+			// so each table is matched by one that only it matches.
+			deferredFilter := &binlogdatapb.Filter{}
+			for _, tableName := range deferredTables {
+				deferredFilter.Rules = append(deferredFilter.Rules, &binlogdatapb.Rule{
+					Match:  "/^" + regexp.QuoteMeta(tableName) + "$",
+					Filter: key.KeyRangeString(target.KeyRange),
+				})
 			}
-			ig.AddRow(rs.workflow, bls, "", rs.cell, rs.tabletTypes,
-				binlogdatapb.VReplicationWorkflowType_Reshard,
-				binlogdatapb.VReplicationWorkflowSubType_None,
-				rs.deferSecondaryKeys)
+			streams = append(streams, &plannedStream{
+				workflow: rs.workflow,
+				source: &binlogdatapb.BinlogSource{
+					Keyspace:      rs.keyspace,
+					Shard:         source.ShardName(),
+					Filter:        deferredFilter,
+					StopAfterCopy: rs.stopAfterCopy,
+					OnDdl:         binlogdatapb.OnDDLAction(binlogdatapb.OnDDLAction_value[rs.onDDL]),
+				},
+				cell:               rs.cell,
+				tabletTypes:        rs.tabletTypes,
+				workflowType:       binlogdatapb.VReplicationWorkflowType_Reshard,
+				workflowSubType:    binlogdatapb.VReplicationWorkflowSubType_None,
+				deferSecondaryKeys: true,
+			})
+		}
+		for _, name := range refStreamNames {
+			rstream := rs.refStreams[name]
+			streams = append(streams, &plannedStream{
+				workflow:        rstream.workflow,
+				source:          rstream.bls,
+				cell:            rstream.cell,
+				tabletTypes:     rstream.tabletTypes,
+				workflowType:    rstream.workflowType,
+				workflowSubType: rstream.workflowSubType,
+				// Reference streams only copy the tables named by their rules.
+				deferSecondaryKeys: rs.deferSecondaryKeys || rs.defersAllTables(rstream.bls),
+			})
+		}
+		plans[target.ShardName()] = streams
+	}
+	return plans
+}
+
+// createStreams creates all of the VReplication streams that
+// need to now exist on the new shards.
+func (rs *resharder) createStreams(ctx context.Context) error {
+	plans := rs.planStreams()
+	err := rs.forAll(ctx, rs.targetShards, rs.withShardProgress(ReshardProgressCreateStreams, func(target *topo.ShardInfo) error {
+		targetPrimary := rs.targetPrimaries[target.ShardName()]
+
+		// Each row is added by a closure so that the rows can be split
+		// into batches of streamBatchSize rows per insert.
+		var rows []func(ig *vreplication.InsertGenerator)
+		for _, stream := range plans[target.ShardName()] {
+			stream := stream
+			rows = append(rows, func(ig *vreplication.InsertGenerator) {
+				ig.AddRow(stream.workflow, stream.source, "", stream.cell, stream.tabletTypes,
+					stream.workflowType, stream.workflowSubType, stream.deferSecondaryKeys)
+			})
 		}
 
-		for _, rstream := range rs.refStreams {
-			ig.AddRow(rstream.workflow, rstream.bls, "", rstream.cell, rstream.tabletTypes,
-				//todo: fix based on original stream
-				binlogdatapb.VReplicationWorkflowType_Reshard,
-				binlogdatapb.VReplicationWorkflowSubType_None,
-				rs.deferSecondaryKeys)
+		batchSize := rs.streamBatchSize
+		if batchSize <= 0 || batchSize > len(rows) {
+			batchSize = max(len(rows), 1)
+		}
+		numBatches := max((len(rows)+batchSize-1)/batchSize, 1)
+		rs.logf("Creating %d streams, including %d reference streams, on target shard %v in %d inserts",
+			len(rows), len(rs.refStreams), target.ShardName(), numBatches)
+		for batch := 0; batch < numBatches; batch++ {
+			ig := vreplication.NewInsertGenerator(binlogdatapb.VReplicationWorkflowState_Stopped, targetPrimary.DbName())
+			end := min((batch+1)*batchSize, len(rows))
+			for _, addRow := range rows[batch*batchSize : end] {
+				addRow(ig)
+			}
+			query := ig.String()
+			// An insert that timed out may still have been applied, so it's
+			// only retried if the tablet couldn't be reached.
+			if _, err := rs.retryVReplicationExec(ctx, targetPrimary.Tablet, query, isUnavailableError); err != nil {
+				if numBatches == 1 {
+					return vterrors.Wrapf(err, "VReplicationExec(%v, %s)", targetPrimary.Tablet, query)
+				}
+				return vterrors.Wrapf(err, "VReplicationExec(%v, %s) failed for batch %d of %d on target shard %v, streams from the %d earlier batches were already created",
+					targetPrimary.Tablet, query, batch+1, numBatches, target.ShardName(), batch)
+			}
+			if rs.onStreamsInsert != nil {
+				rs.onStreamsInsert(target.ShardName(), query)
+			}
+		}
+		if len(rs.stopPositions) > 0 {
+			return rs.setStopPositions(ctx, targetPrimary)
+		}
+		return nil
+	}))
+	if err != nil {
+		if cerr := rs.cleanupTargets(ctx); cerr != nil {
+			rs.wr.Logger().Errorf("Failed to clean up the streams created on the target shards: %v", cerr)
 		}
-		query := ig.String()
-		if _, err := rs.wr.tmc.VReplicationExec(ctx, targetPrimary.Tablet, query); err != nil {
+	}
+	return err
+}
+
+// cleanupTargets deletes the streams created by createStreams from the
+// target primaries, so that a reshard that failed part way through can be
+// run again without cleaning up the target shards by hand. The streams that
+// were on the targets before the reshard are kept.
+func (rs *resharder) cleanupTargets(ctx context.Context) error {
+	return rs.forAll(ctx, rs.targetShards, func(target *topo.ShardInfo) error {
+		targetPrimary := rs.targetPrimaries[target.ShardName()]
+		query := fmt.Sprintf("delete from _vt.vreplication where %s", rs.createdStreamsWhere(target))
+		if _, err := rs.vreplicationExec(ctx, targetPrimary.Tablet, query); err != nil {
 			return vterrors.Wrapf(err, "VReplicationExec(%v, %s)", targetPrimary.Tablet, query)
 		}
 		return nil
 	})
-
-	return err
 }
 
+// startStreams starts the streams created by the reshard on the target
+// shards. The target shards may still have the frozen streams of a
+// completed workflow or the streams of ignored workflows, so only the
+// streams of the reshard's workflow and of the copies of the reference
+// streams are started.
 func (rs *resharder) startStreams(ctx context.Context) error {
-	err := rs.forAll(rs.targetShards, func(target *topo.ShardInfo) error {
+	err := rs.forAll(ctx, rs.targetShards, rs.withShardProgress(ReshardProgressStartStreams, func(target *topo.ShardInfo) error {
 		targetPrimary := rs.targetPrimaries[target.ShardName()]
-		// This is the rare case where we truly want to update every stream/record
-		// because we've already confirmed that there were no existing workflows
-		// on the shards when we started, and we want to start all of the ones
-		// that we've created on the new shards as we're migrating them.
-		// We use the comment directive to indicate that this is intentional
-		// and OK.
-		query := fmt.Sprintf("update /*vt+ %s */ _vt.vreplication set state='Running' where db_name=%s",
-			vreplication.AllowUnsafeWriteCommentDirective, encodeString(targetPrimary.DbName()))
-		if _, err := rs.wr.tmc.VReplicationExec(ctx, targetPrimary.Tablet, query); err != nil {
+		query := fmt.Sprintf("update _vt.vreplication set state='Running' where %s", rs.createdStreamsWhere(target))
+		if _, err := rs.vreplicationExec(ctx, targetPrimary.Tablet, query); err != nil {
 			return vterrors.Wrapf(err, "VReplicationExec(%v, %s)", targetPrimary.Tablet, query)
 		}
 		return nil
-	})
+	}))
 	return err
 }
 
-func (rs *resharder) forAll(shards []*topo.ShardInfo, f func(*topo.ShardInfo) error) error {
+// createdStreamsWhere returns the condition of a query on _vt.vreplication
+// that matches the streams created by the reshard on the target shard: the
+// streams of the reshard's workflow and of the reference workflows that
+// aren't frozen, other than those that were on the target before the
+// reshard.
+func (rs *resharder) createdStreamsWhere(target *topo.ShardInfo) string {
+	workflows := map[string]bool{rs.workflow: true}
+	for _, rstream := range rs.refStreams {
+		workflows[rstream.workflow] = true
+	}
+	names := make([]string, 0, len(workflows))
+	for name := range workflows {
+		names = append(names, encodeString(name))
+	}
+	sort.Strings(names)
+	where := fmt.Sprintf("db_name=%s and workflow in (%s) and message != 'FROZEN'",
+		encodeString(rs.targetPrimaries[target.ShardName()].DbName()), strings.Join(names, ", "))
+	if ids := rs.existingStreams[target.ShardName()]; len(ids) > 0 {
+		where += fmt.Sprintf(" and id not in (%s)", strings.Join(ids, ", "))
+	}
+	return where
+}
+
+// StartWorkflowForShards starts the streams of the given workflow, and the
+// copies of the reference streams made by the reshard, on the named target
+// shards only, leaving the streams on all other shards in their current
+// state. This allows a reshard created with auto_start disabled to be
+// started on a few canary shards first and on the rest once the copy load
+// has been observed.
+func (wr *Wrangler) StartWorkflowForShards(ctx context.Context, keyspace, workflow string, shards []string, opts ReshardOptions) error {
+	if len(shards) == 0 {
+		return fmt.Errorf("no target shards specified for workflow %v", workflow)
+	}
+	rs := wr.newResharder(keyspace, workflow, opts)
+	for _, shard := range shards {
+		si, err := wr.ts.GetShard(ctx, keyspace, shard)
+		if err != nil {
+			return vterrors.Wrapf(err, "GetShard(%s) failed", shard)
+		}
+		if si.PrimaryAlias == nil {
+			return fmt.Errorf("target shard %v has no primary", shard)
+		}
+		primary, err := wr.ts.GetTablet(ctx, si.PrimaryAlias)
+		if err != nil {
+			return vterrors.Wrapf(err, "GetTablet(%s) failed", si.PrimaryAlias)
+		}
+		rs.targetShards = append(rs.targetShards, si)
+		rs.targetPrimaries[si.ShardName()] = primary
+	}
+	// The reference workflows are found like when the streams were
+	// created, from the source shards of the workflow.
+	if err := rs.readWorkflowSources(ctx); err != nil {
+		return vterrors.Wrap(err, "readWorkflowSources")
+	}
+	if opts.VSchemaOverride != nil {
+		rs.vschema = opts.VSchemaOverride
+	} else {
+		vschema, err := wr.ts.GetVSchema(ctx, keyspace)
+		if err != nil {
+			return vterrors.Wrap(err, "GetVSchema")
+		}
+		rs.vschema = vschema
+	}
+	if err := rs.readRefStreams(ctx); err != nil {
+		return vterrors.Wrap(err, "readRefStreams")
+	}
+	return rs.startStreams(ctx)
+}
+
+// readWorkflowSources sets the source keyspace and shards of the resharder
+// from the streams of its workflow on the target shards.
+func (rs *resharder) readWorkflowSources(ctx context.Context) error {
+	sources := make(map[string]bool)
+	for _, target := range rs.targetShards {
+		targetPrimary := rs.targetPrimaries[target.ShardName()]
+		query := fmt.Sprintf("select source from _vt.vreplication where db_name=%s and workflow=%s",
+			encodeString(targetPrimary.DbName()), encodeString(rs.workflow))
+		p3qr, err := rs.vreplicationExec(ctx, targetPrimary.Tablet, query)
+		if err != nil {
+			return vterrors.Wrapf(err, "VReplicationExec(%v, %s)", targetPrimary.Tablet, query)
+		}
+		rows := sqltypes.Proto3ToResult(p3qr).Rows
+		if len(rows) == 0 {
+			return fmt.Errorf("no streams found for workflow %s on target shard %s", rs.workflow, target.ShardName())
+		}
+		for _, row := range rows {
+			var bls binlogdatapb.BinlogSource
+			rowBytes, err := row[0].ToBytes()
+			if err != nil {
+				return err
+			}
+			if err := prototext.Unmarshal(rowBytes, &bls); err != nil {
+				return vterrors.Wrapf(err, "prototext.Unmarshal: %v", row)
+			}
+			rs.keyspace = bls.Keyspace
+			sources[bls.Shard] = true
+		}
+	}
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, shard := range names {
+		si, err := rs.wr.ts.GetShard(ctx, rs.keyspace, shard)
+		if err != nil {
+			return vterrors.Wrapf(err, "GetShard(%s) failed", shard)
+		}
+		if si.PrimaryAlias == nil {
+			return fmt.Errorf("source shard %v has no primary", shard)
+		}
+		primary, err := rs.wr.ts.GetTablet(ctx, si.PrimaryAlias)
+		if err != nil {
+			return vterrors.Wrapf(err, "GetTablet(%s) failed", si.PrimaryAlias)
+		}
+		rs.sourceShards = append(rs.sourceShards, si)
+		rs.sourcePrimaries[si.ShardName()] = primary
+	}
+	return nil
+}
+
+// ReshardStreamPlan describes the streams a reshard is expected to have
+// created, keyed by target shard. Only the keyspace, shard and filter of
+// each BinlogSource are significant.
+type ReshardStreamPlan map[string][]*binlogdatapb.BinlogSource
+
+// VerifyReshardStreams reads back the streams of the given workflow on
+// each target shard in the plan and compares their source shards and
+// filters against it. It returns one message per mismatch found, so an
+// empty result means the created streams match the plan.
+func (wr *Wrangler) VerifyReshardStreams(ctx context.Context, keyspace, workflow string, plan ReshardStreamPlan, opts ReshardOptions) ([]string, error) {
+	rs := wr.newResharder(keyspace, workflow, opts)
+	var mismatches []string
+	targets := make([]string, 0, len(plan))
+	for target := range plan {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	for _, target := range targets {
+		si, err := wr.ts.GetShard(ctx, keyspace, target)
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "GetShard(%s) failed", target)
+		}
+		if si.PrimaryAlias == nil {
+			return nil, fmt.Errorf("target shard %v has no primary", target)
+		}
+		primary, err := wr.ts.GetTablet(ctx, si.PrimaryAlias)
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "GetTablet(%s) failed", si.PrimaryAlias)
+		}
+		query := fmt.Sprintf("select id, source from _vt.vreplication where db_name=%s and workflow=%s",
+			encodeString(primary.DbName()), encodeString(workflow))
+		p3qr, err := rs.vreplicationExec(ctx, primary.Tablet, query)
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "VReplicationExec(%v, %s)", primary.Tablet, query)
+		}
+		// A source shard can have more than one stream to the target, for
+		// example for the tables whose secondary keys are deferred, so the
+		// streams are matched by source shard and filter.
+		type actualStream struct {
+			id    string
+			rules []string
+		}
+		actual := make(map[string][]*actualStream)
+		for _, row := range sqltypes.Proto3ToResult(p3qr).Rows {
+			var bls binlogdatapb.BinlogSource
+			rowBytes, err := row[1].ToBytes()
+			if err != nil {
+				return nil, err
+			}
+			if err := prototext.Unmarshal(rowBytes, &bls); err != nil {
+				return nil, vterrors.Wrapf(err, "prototext.Unmarshal: %v", row)
+			}
+			sourceKey := bls.Keyspace + "/" + bls.Shard
+			rules := filterRuleStrings(bls.Filter)
+			if slices.ContainsFunc(actual[sourceKey], func(stream *actualStream) bool { return slices.Equal(stream.rules, rules) }) {
+				mismatches = append(mismatches, fmt.Sprintf("target %v: duplicate stream id %v for source %v", target, row[0].ToString(), sourceKey))
+				continue
+			}
+			actual[sourceKey] = append(actual[sourceKey], &actualStream{id: row[0].ToString(), rules: rules})
+		}
+		var unmatched []*binlogdatapb.BinlogSource
+		for _, want := range plan[target] {
+			sourceKey := want.Keyspace + "/" + want.Shard
+			wantRules := filterRuleStrings(want.Filter)
+			i := slices.IndexFunc(actual[sourceKey], func(stream *actualStream) bool { return slices.Equal(stream.rules, wantRules) })
+			if i < 0 {
+				unmatched = append(unmatched, want)
+				continue
+			}
+			actual[sourceKey] = slices.Delete(actual[sourceKey], i, i+1)
+		}
+		for _, want := range unmatched {
+			sourceKey := want.Keyspace + "/" + want.Shard
+			if len(actual[sourceKey]) == 0 {
+				mismatches = append(mismatches, fmt.Sprintf("target %v: missing stream for source %v", target, sourceKey))
+				continue
+			}
+			got := actual[sourceKey][0]
+			actual[sourceKey] = actual[sourceKey][1:]
+			mismatches = append(mismatches, fmt.Sprintf("target %v: stream for source %v has filter rules %v, want %v", target, sourceKey, got.rules, filterRuleStrings(want.Filter)))
+		}
+		unexpected := make([]string, 0, len(actual))
+		for sourceKey, streams := range actual {
+			for range streams {
+				unexpected = append(unexpected, sourceKey)
+			}
+		}
+		sort.Strings(unexpected)
+		for _, sourceKey := range unexpected {
+			mismatches = append(mismatches, fmt.Sprintf("target %v: unexpected stream for source %v", target, sourceKey))
+		}
+	}
+	return mismatches, nil
+}
+
+// filterRuleStrings returns the rules of the filter as sorted strings, so
+// that filters can be compared regardless of rule order. The order of the
+// exclude rules created for reference tables is not deterministic.
+func filterRuleStrings(filter *binlogdatapb.Filter) []string {
+	var rules []string
+	for _, rule := range filter.GetRules() {
+		rules = append(rules, fmt.Sprintf("%s:%s", rule.Match, rule.Filter))
+	}
+	sort.Strings(rules)
+	return rules
+}
+
+// logf logs to the wrangler's logger if verbose logging is enabled.
+func (rs *resharder) logf(format string, args ...any) {
+	if rs.verbose {
+		rs.wr.Logger().Infof("Reshard %v.%v: "+format, append([]any{rs.keyspace, rs.workflow}, args...)...)
+	}
+}
+
+// routing returns the source shards whose key ranges intersect each target
+// shard, which are the sources the streams of the target replicate from.
+func (rs *resharder) routing() map[string][]string {
+	mapping := make(map[string][]string, len(rs.targetShards))
+	for _, target := range rs.targetShards {
+		var sources []string
+		for _, source := range rs.sourceShards {
+			if key.KeyRangeIntersect(target.KeyRange, source.KeyRange) {
+				sources = append(sources, source.ShardName())
+			}
+		}
+		mapping[target.ShardName()] = sources
+	}
+	return mapping
+}
+
+// Defaults of the retries of the queries of a reshard on the tablets.
+const (
+	defaultReshardExecRetries    = 3
+	defaultReshardExecRetryDelay = 100 * time.Millisecond
+)
+
+// vreplicationExec runs the query on the tablet, retrying it if it fails
+// with a transient error.
+func (rs *resharder) vreplicationExec(ctx context.Context, tablet *topodatapb.Tablet, query string) (*querypb.QueryResult, error) {
+	return rs.retryVReplicationExec(ctx, tablet, query, isTransientError)
+}
+
+// retryVReplicationExec runs the query on the tablet, and retries it up to
+// execRetries times with an exponential backoff while it fails with an error
+// for which retryable returns true.
+func (rs *resharder) retryVReplicationExec(ctx context.Context, tablet *topodatapb.Tablet, query string, retryable func(error) bool) (*querypb.QueryResult, error) {
+	delay := rs.execRetryDelay
+	for attempt := 0; ; attempt++ {
+		qr, err := rs.wr.tmc.VReplicationExec(ctx, tablet, query)
+		if err == nil || attempt >= rs.execRetries || !retryable(err) {
+			return qr, err
+		}
+		rs.wr.Logger().Warningf("VReplicationExec(%v, %s) failed, retrying in %v (attempt %d of %d): %v",
+			topoproto.TabletAliasString(tablet.Alias), query, delay, attempt+1, rs.execRetries, err)
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// isTransientError returns whether the error is one that the same query may
+// not hit again, such as a lost connection or a timeout, as opposed to an
+// error in the query or in the state of the tablet.
+func isTransientError(err error) bool {
+	// IsEphemeralError treats every error that isn't a MySQL error as
+	// ephemeral, so it's only used for MySQL errors.
+	var sqlErr *sqlerror.SQLError
+	if errors.As(err, &sqlErr) {
+		return sqlerror.IsEphemeralError(sqlErr)
+	}
+	switch vterrors.Code(err) {
+	case vtrpcpb.Code_UNAVAILABLE, vtrpcpb.Code_DEADLINE_EXCEEDED:
+		return true
+	}
+	return false
+}
+
+// isUnavailableError returns whether the error is that the tablet couldn't
+// be reached, in which case the query wasn't run.
+func isUnavailableError(err error) bool {
+	return vterrors.Code(err) == vtrpcpb.Code_UNAVAILABLE
+}
+
+// defaultReshardShardConcurrency is the default maximum number of shards
+// a resharder works on concurrently.
+const defaultReshardShardConcurrency = 16
+
+// forAll runs f on every shard, at most shardConcurrency at a time, and
+// returns the errors of all of them. If ctx is done while it waits for a
+// shard to finish, the shards that were not started yet fail with the error
+// of ctx.
+func (rs *resharder) forAll(ctx context.Context, shards []*topo.ShardInfo, f func(*topo.ShardInfo) error) error {
 	var wg sync.WaitGroup
 	allErrors := &concurrency.AllErrorRecorder{}
+	notStarted := func(shard *topo.ShardInfo, err error) {
+		allErrors.RecordError(vterrors.Wrapf(err, "shard %s/%s not started", shard.Keyspace(), shard.ShardName()))
+	}
 	for _, shard := range shards {
+		if rs.sem != nil {
+			// Acquire can only fail if ctx is done.
+			if err := rs.sem.Acquire(ctx, 1); err != nil {
+				notStarted(shard, err)
+				continue
+			}
+		}
+		// Acquire may still succeed once ctx is done.
+		if err := ctx.Err(); err != nil {
+			if rs.sem != nil {
+				rs.sem.Release(1)
+			}
+			notStarted(shard, err)
+			continue
+		}
 		wg.Add(1)
 		go func(shard *topo.ShardInfo) {
 			defer wg.Done()
+			if rs.sem != nil {
+				defer rs.sem.Release(1)
+			}
 
 			if err := f(shard); err != nil {
 				allErrors.RecordError(err)
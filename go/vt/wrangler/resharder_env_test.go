@@ -36,6 +36,7 @@ import (
 	"vitess.io/vitess/go/vt/vttablet/tmclient"
 
 	querypb "vitess.io/vitess/go/vt/proto/query"
+	replicationdatapb "vitess.io/vitess/go/vt/proto/replicationdata"
 	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 )
@@ -112,6 +113,9 @@ func newTestResharderEnv(t *testing.T, ctx context.Context, sources, targets []s
 
 func (env *testResharderEnv) expectValidation() {
 	for _, tablet := range env.tablets {
+		if tablet.Keyspace != env.keyspace {
+			continue
+		}
 		tabletID := int(tablet.Alias.Uid)
 		// wr.validateNewWorkflow
 		env.tmc.expectVRQuery(tabletID, fmt.Sprintf("select 1 from _vt.vreplication where db_name='vt_%s' and workflow='%s'", env.keyspace, env.workflow), &sqltypes.Result{})
@@ -126,10 +130,13 @@ func (env *testResharderEnv) expectValidation() {
 
 func (env *testResharderEnv) expectNoRefStream() {
 	for _, tablet := range env.tablets {
+		if tablet.Keyspace != env.keyspace {
+			continue
+		}
 		tabletID := int(tablet.Alias.Uid)
 		if tabletID < 200 {
 			// readRefStreams
-			env.tmc.expectVRQuery(tabletID, fmt.Sprintf("select workflow, source, cell, tablet_types from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), &sqltypes.Result{})
+			env.tmc.expectVRQuery(tabletID, fmt.Sprintf("select workflow, source, cell, tablet_types, workflow_type, workflow_sub_type from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), &sqltypes.Result{})
 		}
 	}
 }
@@ -184,16 +191,40 @@ type testResharderTMClient struct {
 
 	mu        sync.Mutex
 	vrQueries map[int][]*queryResult
+	// mysqlVersions overrides the MySQL version reported by FullStatus
+	// for a tablet, which defaults to defaultTestMySQLVersion.
+	mysqlVersions map[int]string
+	// fullStatusErr is returned by FullStatus if set.
+	fullStatusErr error
+	// reloadedSchemas lists the tablets asked to reload their schema.
+	reloadedSchemas []int
+	// refreshedStates lists the tablets asked to refresh their state.
+	refreshedStates []int
+	// readOnly records which tablets were set read-only or read-write.
+	readOnly map[int]bool
+	// primaryPositions are the positions returned by PrimaryPosition.
+	primaryPositions map[int]string
+	// waitForPosErr is returned by VReplicationWaitForPos, which records
+	// the positions streams are waited for in waitedForPos.
+	waitForPosErr error
+	waitedForPos  map[int32]string
 }
 
+const defaultTestMySQLVersion = "8.0.34"
+
 type queryResult struct {
 	query  string
 	result *querypb.QueryResult
+	err    error
 }
 
 func newTestResharderTMClient() *testResharderTMClient {
 	return &testResharderTMClient{
-		vrQueries: make(map[int][]*queryResult),
+		vrQueries:        make(map[int][]*queryResult),
+		mysqlVersions:    make(map[int]string),
+		readOnly:         make(map[int]bool),
+		primaryPositions: make(map[int]string),
+		waitedForPos:     make(map[int32]string),
 	}
 }
 
@@ -201,6 +232,63 @@ func (tmc *testResharderTMClient) GetSchema(ctx context.Context, tablet *topodat
 	return tmc.schema, nil
 }
 
+func (tmc *testResharderTMClient) ReloadSchema(ctx context.Context, tablet *topodatapb.Tablet, waitPosition string) error {
+	tmc.mu.Lock()
+	defer tmc.mu.Unlock()
+	tmc.reloadedSchemas = append(tmc.reloadedSchemas, int(tablet.Alias.Uid))
+	return nil
+}
+
+func (tmc *testResharderTMClient) RefreshState(ctx context.Context, tablet *topodatapb.Tablet) error {
+	tmc.mu.Lock()
+	defer tmc.mu.Unlock()
+	tmc.refreshedStates = append(tmc.refreshedStates, int(tablet.Alias.Uid))
+	return nil
+}
+
+func (tmc *testResharderTMClient) SetReadOnly(ctx context.Context, tablet *topodatapb.Tablet) error {
+	tmc.mu.Lock()
+	defer tmc.mu.Unlock()
+	tmc.readOnly[int(tablet.Alias.Uid)] = true
+	return nil
+}
+
+func (tmc *testResharderTMClient) SetReadWrite(ctx context.Context, tablet *topodatapb.Tablet) error {
+	tmc.mu.Lock()
+	defer tmc.mu.Unlock()
+	tmc.readOnly[int(tablet.Alias.Uid)] = false
+	return nil
+}
+
+func (tmc *testResharderTMClient) PrimaryPosition(ctx context.Context, tablet *topodatapb.Tablet) (string, error) {
+	tmc.mu.Lock()
+	defer tmc.mu.Unlock()
+	return tmc.primaryPositions[int(tablet.Alias.Uid)], nil
+}
+
+func (tmc *testResharderTMClient) VReplicationWaitForPos(ctx context.Context, tablet *topodatapb.Tablet, id int32, pos string) error {
+	tmc.mu.Lock()
+	defer tmc.mu.Unlock()
+	if tmc.waitForPosErr != nil {
+		return tmc.waitForPosErr
+	}
+	tmc.waitedForPos[id] = pos
+	return nil
+}
+
+func (tmc *testResharderTMClient) FullStatus(ctx context.Context, tablet *topodatapb.Tablet) (*replicationdatapb.FullStatus, error) {
+	tmc.mu.Lock()
+	defer tmc.mu.Unlock()
+	if tmc.fullStatusErr != nil {
+		return nil, tmc.fullStatusErr
+	}
+	version, ok := tmc.mysqlVersions[int(tablet.Alias.Uid)]
+	if !ok {
+		version = defaultTestMySQLVersion
+	}
+	return &replicationdatapb.FullStatus{Version: version}, nil
+}
+
 func (tmc *testResharderTMClient) expectVRQuery(tabletID int, query string, result *sqltypes.Result) {
 	tmc.mu.Lock()
 	defer tmc.mu.Unlock()
@@ -211,6 +299,17 @@ func (tmc *testResharderTMClient) expectVRQuery(tabletID int, query string, resu
 	})
 }
 
+// expectVRQueryError makes the query fail with err on the tablet.
+func (tmc *testResharderTMClient) expectVRQueryError(tabletID int, query string, err error) {
+	tmc.mu.Lock()
+	defer tmc.mu.Unlock()
+
+	tmc.vrQueries[tabletID] = append(tmc.vrQueries[tabletID], &queryResult{
+		query: query,
+		err:   err,
+	})
+}
+
 func (tmc *testResharderTMClient) VReplicationExec(ctx context.Context, tablet *topodatapb.Tablet, query string) (*querypb.QueryResult, error) {
 	tmc.mu.Lock()
 	defer tmc.mu.Unlock()
@@ -228,7 +327,7 @@ func (tmc *testResharderTMClient) VReplicationExec(ctx context.Context, tablet *
 		return nil, fmt.Errorf("tablet %v: unexpected query %s, want: %s", tablet, query, qrs[0].query)
 	}
 	tmc.vrQueries[int(tablet.Alias.Uid)] = qrs[1:]
-	return qrs[0].result, nil
+	return qrs[0].result, qrs[0].err
 }
 
 func (tmc *testResharderTMClient) ExecuteFetchAsDba(ctx context.Context, tablet *topodatapb.Tablet, usePool bool, req *tabletmanagerdatapb.ExecuteFetchAsDbaRequest) (*querypb.QueryResult, error) {
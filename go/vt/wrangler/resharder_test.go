@@ -20,17 +20,27 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/semaphore"
+	"google.golang.org/protobuf/proto"
 
 	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/key"
+	"vitess.io/vitess/go/vt/logutil"
+	"vitess.io/vitess/go/vt/topo"
+	"vitess.io/vitess/go/vt/vterrors"
 	"vitess.io/vitess/go/vt/vtgate/vindexes"
 
 	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
 	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
 )
 
 const rsSelectFrozenQuery = "select 1 from _vt.vreplication where db_name='vt_ks' and message='FROZEN' and workflow_sub_type != 1"
@@ -104,10 +114,10 @@ func TestResharderOneToMany(t *testing.T) {
 					tc.cells+`', '`+tc.tabletTypes+`', [0-9]*, 0, 'Stopped', 'vt_ks', 4, 0, false\)`+eol,
 				&sqltypes.Result{},
 			)
-			env.tmc.expectVRQuery(200, "update /*vt+ ALLOW_UNSAFE_VREPLICATION_WRITE */ _vt.vreplication set state='Running' where db_name='vt_ks'", &sqltypes.Result{})
-			env.tmc.expectVRQuery(210, "update /*vt+ ALLOW_UNSAFE_VREPLICATION_WRITE */ _vt.vreplication set state='Running' where db_name='vt_ks'", &sqltypes.Result{})
+			env.tmc.expectVRQuery(200, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest') and message != 'FROZEN'", &sqltypes.Result{})
+			env.tmc.expectVRQuery(210, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest') and message != 'FROZEN'", &sqltypes.Result{})
 
-			err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, tc.cells, tc.tabletTypes, defaultOnDDL, true, false, false)
+			err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, tc.cells, tc.tabletTypes, defaultOnDDL, true, false, false, ReshardOptions{})
 			require.NoError(t, err)
 			env.tmc.verifyQueries(t)
 		})
@@ -143,9 +153,9 @@ func TestResharderManyToOne(t *testing.T) {
 		&sqltypes.Result{},
 	)
 
-	env.tmc.expectVRQuery(200, "update /*vt+ ALLOW_UNSAFE_VREPLICATION_WRITE */ _vt.vreplication set state='Running' where db_name='vt_ks'", &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest') and message != 'FROZEN'", &sqltypes.Result{})
 
-	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false)
+	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, ReshardOptions{})
 	assert.NoError(t, err)
 	env.tmc.verifyQueries(t)
 }
@@ -185,10 +195,10 @@ func TestResharderManyToMany(t *testing.T) {
 		&sqltypes.Result{},
 	)
 
-	env.tmc.expectVRQuery(200, "update /*vt+ ALLOW_UNSAFE_VREPLICATION_WRITE */ _vt.vreplication set state='Running' where db_name='vt_ks'", &sqltypes.Result{})
-	env.tmc.expectVRQuery(210, "update /*vt+ ALLOW_UNSAFE_VREPLICATION_WRITE */ _vt.vreplication set state='Running' where db_name='vt_ks'", &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest') and message != 'FROZEN'", &sqltypes.Result{})
+	env.tmc.expectVRQuery(210, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest') and message != 'FROZEN'", &sqltypes.Result{})
 
-	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false)
+	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, ReshardOptions{})
 	assert.NoError(t, err)
 	env.tmc.verifyQueries(t)
 }
@@ -240,10 +250,10 @@ func TestResharderOneRefTable(t *testing.T) {
 		&sqltypes.Result{},
 	)
 
-	env.tmc.expectVRQuery(200, "update /*vt+ ALLOW_UNSAFE_VREPLICATION_WRITE */ _vt.vreplication set state='Running' where db_name='vt_ks'", &sqltypes.Result{})
-	env.tmc.expectVRQuery(210, "update /*vt+ ALLOW_UNSAFE_VREPLICATION_WRITE */ _vt.vreplication set state='Running' where db_name='vt_ks'", &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest') and message != 'FROZEN'", &sqltypes.Result{})
+	env.tmc.expectVRQuery(210, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest') and message != 'FROZEN'", &sqltypes.Result{})
 
-	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false)
+	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, ReshardOptions{})
 	assert.NoError(t, err)
 	env.tmc.verifyQueries(t)
 }
@@ -296,12 +306,92 @@ func TestReshardStopFlags(t *testing.T) {
 	)
 	// -auto_start=false is tested by NOT expecting the update query which sets state to RUNNING
 
-	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, false, true, false)
+	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, false, true, false, ReshardOptions{})
 	assert.NoError(t, err)
 	env.tmc.verifyQueries(t)
 }
 
 // TestResharderOneRefStream tests the case where there's one ref table and an associated stream.
+func TestResharderNoAutoStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+
+	env.expectValidation()
+	env.expectNoRefStream()
+	// Only the inserts are expected: the streams are not started.
+	env.tmc.expectVRQuery(200, insertPrefix+`.*'Stopped', 'vt_ks'`, &sqltypes.Result{})
+	env.tmc.expectVRQuery(210, insertPrefix+`.*'Stopped', 'vt_ks'`, &sqltypes.Result{})
+
+	err := env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, false, false, false, ReshardOptions{})
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+
+	stage, err := env.wr.GetReshardStage(ctx, env.keyspace, env.workflow)
+	require.NoError(t, err)
+	require.Equal(t, ReshardStageCreated, stage)
+}
+
+func TestResharderDeferSecondaryKeysTables(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+	opts := ReshardOptions{DeferSecondaryKeysTables: []string{"t1", "r1"}}
+
+	vs := &vschemapb.Keyspace{
+		Tables: map[string]*vschemapb.Table{
+			"t1": {},
+			"t2": {},
+			"r1": {
+				Type: vindexes.TypeReference,
+			},
+		},
+	}
+	require.NoError(t, env.wr.ts.SaveVSchema(ctx, env.keyspace, vs))
+
+	env.expectValidation()
+	bls := &binlogdatapb.BinlogSource{
+		Keyspace: "ks1",
+		Shard:    "0",
+		Filter: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match: "r1",
+			}},
+		},
+	}
+	result := sqltypes.MakeTestResult(sqltypes.MakeTestFields(
+		"workflow|source|cell|tablet_types|workflow_type|workflow_sub_type",
+		"varchar|varchar|varchar|varchar|int64|int64"),
+		fmt.Sprintf("r1|%v|cell1|primary,replica|4|0", bls),
+	)
+	env.tmc.expectVRQuery(100, "select workflow, source, cell, tablet_types, workflow_type, workflow_sub_type from _vt.vreplication where db_name='vt_ks' and message != 'FROZEN'", result)
+
+	// t1 is copied by a stream of its own that defers the secondary keys,
+	// and so is r1 since it's the only table of its reference stream.
+	for i, shard := range env.targets {
+		env.tmc.expectVRQuery(
+			200+10*i,
+			insertPrefix+
+				`\('resharderTest', 'keyspace:\\"ks\\" shard:\\"0\\" filter:{rules:{match:\\"r1\\" filter:\\"exclude\\"} rules:{match:\\"t1\\" filter:\\"exclude\\"} rules:{match:\\"/.*\\" filter:\\"`+shard+`\\"}}', '', [0-9]*, [0-9]*, '', '', [0-9]*, 0, 'Stopped', 'vt_ks', 4, 0, false\), `+
+				`\('resharderTest', 'keyspace:\\"ks\\" shard:\\"0\\" filter:{rules:{match:\\"/\^t1\$\\" filter:\\"`+shard+`\\"}}', '', [0-9]*, [0-9]*, '', '', [0-9]*, 0, 'Stopped', 'vt_ks', 4, 0, true\), `+
+				`\('r1', 'keyspace:\\"ks1\\" shard:\\"0\\" filter:{rules:{match:\\"r1\\"}}', '', [0-9]*, [0-9]*, 'cell1', 'primary,replica', [0-9]*, 0, 'Stopped', 'vt_ks', 4, 0, true\)`+eol,
+			&sqltypes.Result{},
+		)
+	}
+
+	err := env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, false, false, false, opts)
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+
+	// The tables must be in the vschema.
+	opts.DeferSecondaryKeysTables = []string{"t3"}
+	env.expectValidation()
+	err = env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, false, false, false, opts)
+	require.EqualError(t, err, "buildResharder: validateDeferSecondaryKeysTables: table t3 not found in vschema")
+}
+
 func TestResharderOneRefStream(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -341,11 +431,11 @@ func TestResharderOneRefStream(t *testing.T) {
 		},
 	}
 	result := sqltypes.MakeTestResult(sqltypes.MakeTestFields(
-		"workflow|source|cell|tablet_types",
-		"varchar|varchar|varchar|varchar"),
-		fmt.Sprintf("t1|%v|cell1|primary,replica", bls),
+		"workflow|source|cell|tablet_types|workflow_type|workflow_sub_type",
+		"varchar|varchar|varchar|varchar|int64|int64"),
+		fmt.Sprintf("t1|%v|cell1|primary,replica|4|0", bls),
 	)
-	env.tmc.expectVRQuery(100, fmt.Sprintf("select workflow, source, cell, tablet_types from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), result)
+	env.tmc.expectVRQuery(100, fmt.Sprintf("select workflow, source, cell, tablet_types, workflow_type, workflow_sub_type from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), result)
 
 	refRow := `\('t1', 'keyspace:\\"ks1\\" shard:\\"0\\" filter:{rules:{match:\\"t1\\"}}', '', [0-9]*, [0-9]*, 'cell1', 'primary,replica', [0-9]*, 0, 'Stopped', 'vt_ks', 4, 0, false\)`
 	env.tmc.expectVRQuery(
@@ -363,10 +453,82 @@ func TestResharderOneRefStream(t *testing.T) {
 		&sqltypes.Result{},
 	)
 
-	env.tmc.expectVRQuery(200, "update /*vt+ ALLOW_UNSAFE_VREPLICATION_WRITE */ _vt.vreplication set state='Running' where db_name='vt_ks'", &sqltypes.Result{})
-	env.tmc.expectVRQuery(210, "update /*vt+ ALLOW_UNSAFE_VREPLICATION_WRITE */ _vt.vreplication set state='Running' where db_name='vt_ks'", &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest', 't1') and message != 'FROZEN'", &sqltypes.Result{})
+	env.tmc.expectVRQuery(210, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest', 't1') and message != 'FROZEN'", &sqltypes.Result{})
+
+	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, ReshardOptions{})
+	assert.NoError(t, err)
+	env.tmc.verifyQueries(t)
+}
+
+// TestResharderRefStreamWorkflowType tests that the copies of the reference
+// streams keep the workflow type of the originals.
+func TestResharderRefStreamWorkflowType(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+
+	schm := &tabletmanagerdatapb.SchemaDefinition{
+		TableDefinitions: []*tabletmanagerdatapb.TableDefinition{{
+			Name:              "t1",
+			Columns:           []string{"c1", "c2"},
+			PrimaryKeyColumns: []string{"c1"},
+			Fields:            sqltypes.MakeTestFields("c1|c2", "int64|int64"),
+		}},
+	}
+	env.tmc.schema = schm
+
+	vs := &vschemapb.Keyspace{
+		Tables: map[string]*vschemapb.Table{
+			"t1": {
+				Type: vindexes.TypeReference,
+			},
+		},
+	}
+	if err := env.wr.ts.SaveVSchema(context.Background(), env.keyspace, vs); err != nil {
+		t.Fatal(err)
+	}
+
+	env.expectValidation()
+
+	bls := &binlogdatapb.BinlogSource{
+		Keyspace: "ks1",
+		Shard:    "0",
+		Filter: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match: "t1",
+			}},
+		},
+	}
+	result := sqltypes.MakeTestResult(sqltypes.MakeTestFields(
+		"workflow|source|cell|tablet_types|workflow_type|workflow_sub_type",
+		"varchar|varchar|varchar|varchar|int64|int64"),
+		fmt.Sprintf("t1|%v|cell1|primary,replica|%d|%d", bls,
+			binlogdatapb.VReplicationWorkflowType_Materialize, binlogdatapb.VReplicationWorkflowSubType_None),
+	)
+	env.tmc.expectVRQuery(100, fmt.Sprintf("select workflow, source, cell, tablet_types, workflow_type, workflow_sub_type from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), result)
+
+	refRow := `\('t1', 'keyspace:\\"ks1\\" shard:\\"0\\" filter:{rules:{match:\\"t1\\"}}', '', [0-9]*, [0-9]*, 'cell1', 'primary,replica', [0-9]*, 0, 'Stopped', 'vt_ks', 0, 0, false\)`
+	env.tmc.expectVRQuery(
+		200,
+		insertPrefix+
+			`\('resharderTest', 'keyspace:\\"ks\\" shard:\\"0\\" filter:{rules:{match:\\"t1\\" filter:\\"exclude\\"} rules:{match:\\"/.*\\" filter:\\"-80\\"}}', '', [0-9]*, [0-9]*, '', '', [0-9]*, 0, 'Stopped', 'vt_ks', 4, 0, false\).*`+
+			refRow+eol,
+		&sqltypes.Result{},
+	)
+	env.tmc.expectVRQuery(
+		210,
+		insertPrefix+
+			`\('resharderTest', 'keyspace:\\"ks\\" shard:\\"0\\" filter:{rules:{match:\\"t1\\" filter:\\"exclude\\"} rules:{match:\\"/.*\\" filter:\\"80-\\"}}', '', [0-9]*, [0-9]*, '', '', [0-9]*, 0, 'Stopped', 'vt_ks', 4, 0, false\).*`+
+			refRow+eol,
+		&sqltypes.Result{},
+	)
+
+	env.tmc.expectVRQuery(200, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest', 't1') and message != 'FROZEN'", &sqltypes.Result{})
+	env.tmc.expectVRQuery(210, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest', 't1') and message != 'FROZEN'", &sqltypes.Result{})
 
-	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false)
+	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, ReshardOptions{})
 	assert.NoError(t, err)
 	env.tmc.verifyQueries(t)
 }
@@ -421,11 +583,11 @@ func TestResharderNoRefStream(t *testing.T) {
 		},
 	}
 	result := sqltypes.MakeTestResult(sqltypes.MakeTestFields(
-		"workflow|source|cell|tablet_types",
-		"varchar|varchar|varchar|varchar"),
-		fmt.Sprintf("t1|%v|cell1|primary,replica", bls),
+		"workflow|source|cell|tablet_types|workflow_type|workflow_sub_type",
+		"varchar|varchar|varchar|varchar|int64|int64"),
+		fmt.Sprintf("t1|%v|cell1|primary,replica|4|0", bls),
 	)
-	env.tmc.expectVRQuery(100, fmt.Sprintf("select workflow, source, cell, tablet_types from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), result)
+	env.tmc.expectVRQuery(100, fmt.Sprintf("select workflow, source, cell, tablet_types, workflow_type, workflow_sub_type from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), result)
 
 	env.tmc.expectVRQuery(
 		200,
@@ -442,10 +604,10 @@ func TestResharderNoRefStream(t *testing.T) {
 		&sqltypes.Result{},
 	)
 
-	env.tmc.expectVRQuery(200, "update /*vt+ ALLOW_UNSAFE_VREPLICATION_WRITE */ _vt.vreplication set state='Running' where db_name='vt_ks'", &sqltypes.Result{})
-	env.tmc.expectVRQuery(210, "update /*vt+ ALLOW_UNSAFE_VREPLICATION_WRITE */ _vt.vreplication set state='Running' where db_name='vt_ks'", &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest') and message != 'FROZEN'", &sqltypes.Result{})
+	env.tmc.expectVRQuery(210, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest') and message != 'FROZEN'", &sqltypes.Result{})
 
-	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false)
+	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, ReshardOptions{})
 	assert.NoError(t, err)
 	env.tmc.verifyQueries(t)
 }
@@ -484,10 +646,10 @@ func TestResharderCopySchema(t *testing.T) {
 		&sqltypes.Result{},
 	)
 
-	env.tmc.expectVRQuery(200, "update /*vt+ ALLOW_UNSAFE_VREPLICATION_WRITE */ _vt.vreplication set state='Running' where db_name='vt_ks'", &sqltypes.Result{})
-	env.tmc.expectVRQuery(210, "update /*vt+ ALLOW_UNSAFE_VREPLICATION_WRITE */ _vt.vreplication set state='Running' where db_name='vt_ks'", &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest') and message != 'FROZEN'", &sqltypes.Result{})
+	env.tmc.expectVRQuery(210, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest') and message != 'FROZEN'", &sqltypes.Result{})
 
-	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, false, "", "", defaultOnDDL, true, false, false)
+	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, false, "", "", defaultOnDDL, true, false, false, ReshardOptions{})
 	assert.NoError(t, err)
 	env.tmc.verifyQueries(t)
 }
@@ -520,7 +682,7 @@ func TestResharderDupWorkflow(t *testing.T) {
 	env.tmc.expectVRQuery(200, rsSelectFrozenQuery, &sqltypes.Result{})
 	env.tmc.expectVRQuery(100, rsSelectFrozenQuery, &sqltypes.Result{})
 
-	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false)
+	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, ReshardOptions{})
 	assert.EqualError(t, err, "validateWorkflowName.VReplicationExec: workflow resharderTest already exists in keyspace ks on tablet 210")
 	env.tmc.verifyQueries(t)
 }
@@ -547,7 +709,7 @@ func TestResharderServingState(t *testing.T) {
 	env.tmc.expectVRQuery(100, rsSelectFrozenQuery, &sqltypes.Result{})
 	env.tmc.expectVRQuery(200, rsSelectFrozenQuery, &sqltypes.Result{})
 	env.tmc.expectVRQuery(210, rsSelectFrozenQuery, &sqltypes.Result{})
-	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, []string{"-80"}, nil, true, "", "", defaultOnDDL, true, false, false)
+	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, []string{"-80"}, nil, true, "", "", defaultOnDDL, true, false, false, ReshardOptions{})
 	assert.EqualError(t, err, "buildResharder: source shard -80 is not in serving state")
 
 	env.tmc.expectVRQuery(100, fmt.Sprintf("select 1 from _vt.vreplication where db_name='vt_%s' and workflow='%s'", env.keyspace, env.workflow), &sqltypes.Result{})
@@ -556,7 +718,7 @@ func TestResharderServingState(t *testing.T) {
 	env.tmc.expectVRQuery(100, rsSelectFrozenQuery, &sqltypes.Result{})
 	env.tmc.expectVRQuery(200, rsSelectFrozenQuery, &sqltypes.Result{})
 	env.tmc.expectVRQuery(210, rsSelectFrozenQuery, &sqltypes.Result{})
-	err = env.wr.Reshard(context.Background(), env.keyspace, env.workflow, []string{"0"}, []string{"0"}, true, "", "", defaultOnDDL, true, false, false)
+	err = env.wr.Reshard(context.Background(), env.keyspace, env.workflow, []string{"0"}, []string{"0"}, true, "", "", defaultOnDDL, true, false, false, ReshardOptions{})
 	assert.EqualError(t, err, "buildResharder: target shard 0 is in serving state")
 
 	env.tmc.expectVRQuery(100, fmt.Sprintf("select 1 from _vt.vreplication where db_name='vt_%s' and workflow='%s'", env.keyspace, env.workflow), &sqltypes.Result{})
@@ -565,8 +727,79 @@ func TestResharderServingState(t *testing.T) {
 	env.tmc.expectVRQuery(100, rsSelectFrozenQuery, &sqltypes.Result{})
 	env.tmc.expectVRQuery(200, rsSelectFrozenQuery, &sqltypes.Result{})
 	env.tmc.expectVRQuery(210, rsSelectFrozenQuery, &sqltypes.Result{})
-	err = env.wr.Reshard(context.Background(), env.keyspace, env.workflow, []string{"0"}, []string{"-80"}, true, "", "", defaultOnDDL, true, false, false)
-	assert.EqualError(t, err, "buildResharder: ValidateForReshard: source and target keyranges don't match: - vs -80")
+	err = env.wr.Reshard(context.Background(), env.keyspace, env.workflow, []string{"0"}, []string{"-80"}, true, "", "", defaultOnDDL, true, false, false, ReshardOptions{})
+	assert.EqualError(t, err, "buildResharder: validateKeyRangeCoverage: target shards don't cover key range 80- of the source shards")
+	var coverageErr *ErrKeyRangeCoverage
+	require.ErrorAs(t, err, &coverageErr)
+	assert.Equal(t, "80-", key.KeyRangeString(coverageErr.KeyRange))
+}
+
+func TestValidateKeyRangeCoverage(t *testing.T) {
+	shards := func(names ...string) []*topo.ShardInfo {
+		var shards []*topo.ShardInfo
+		for _, name := range names {
+			keyRanges, err := key.ParseShardingSpec(name)
+			require.NoError(t, err)
+			shards = append(shards, topo.NewShardInfo("ks", name, &topodatapb.Shard{KeyRange: keyRanges[0]}, nil))
+		}
+		return shards
+	}
+	testCases := []struct {
+		name    string
+		sources []string
+		targets []string
+		wantErr string
+	}{{
+		name:    "split",
+		sources: []string{"-80"},
+		targets: []string{"40-80", "-40"},
+	}, {
+		name:    "merge",
+		sources: []string{"-80", "80-"},
+		targets: []string{"-"},
+	}, {
+		name:    "gap between targets",
+		sources: []string{"-"},
+		targets: []string{"-40", "80-"},
+		wantErr: "target shards don't cover key range 40-80 between shards -40 and 80-",
+	}, {
+		name:    "overlapping targets",
+		sources: []string{"-"},
+		targets: []string{"-80", "40-c0", "c0-"},
+		wantErr: "target shards -80 and 40-c0 overlap in key range 40-80",
+	}, {
+		name:    "target starts late",
+		sources: []string{"-80"},
+		targets: []string{"40-80"},
+		wantErr: "target shards don't cover key range -40 of the source shards",
+	}, {
+		name:    "target ends early",
+		sources: []string{"-"},
+		targets: []string{"-40", "40-c0"},
+		wantErr: "target shards don't cover key range c0- of the source shards",
+	}, {
+		name:    "target beyond sources",
+		sources: []string{"40-80"},
+		targets: []string{"40-60", "60-"},
+		wantErr: "target shards cover key range 80- outside of the source shards",
+	}, {
+		name:    "gap between sources",
+		sources: []string{"-40", "80-"},
+		targets: []string{"-"},
+		wantErr: "source shards don't cover key range 40-80 between shards -40 and 80-",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateKeyRangeCoverage(shards(tc.sources...), shards(tc.targets...))
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tc.wantErr)
+			var coverageErr *ErrKeyRangeCoverage
+			require.ErrorAs(t, err, &coverageErr)
+		})
+	}
 }
 
 func TestResharderTargetAlreadyResharding(t *testing.T) {
@@ -599,8 +832,11 @@ func TestResharderTargetAlreadyResharding(t *testing.T) {
 	env.tmc.expectVRQuery(200, fmt.Sprintf("select 1 from _vt.vreplication where db_name='vt_%s'", env.keyspace), result)
 	env.tmc.expectVRQuery(210, fmt.Sprintf("select 1 from _vt.vreplication where db_name='vt_%s'", env.keyspace), &sqltypes.Result{})
 	env.tmc.expectVRQuery(100, rsSelectFrozenQuery, &sqltypes.Result{})
-	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false)
+	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, ReshardOptions{})
 	assert.EqualError(t, err, "buildResharder: validateTargets: some streams already exist in the target shards, please clean them up and retry the command")
+	var notEmptyErr *ErrTargetNotEmpty
+	require.ErrorAs(t, err, &notEmptyErr)
+	assert.Equal(t, []string{"-80"}, notEmptyErr.Shards)
 	env.tmc.verifyQueries(t)
 }
 
@@ -643,13 +879,13 @@ func TestResharderUnnamedStream(t *testing.T) {
 		},
 	}
 	result := sqltypes.MakeTestResult(sqltypes.MakeTestFields(
-		"workflow|source|cell|tablet_types",
-		"varchar|varchar|varchar|varchar"),
-		fmt.Sprintf("|%v|cell1|primary,replica", bls),
+		"workflow|source|cell|tablet_types|workflow_type|workflow_sub_type",
+		"varchar|varchar|varchar|varchar|int64|int64"),
+		fmt.Sprintf("|%v|cell1|primary,replica|4|0", bls),
 	)
-	env.tmc.expectVRQuery(100, fmt.Sprintf("select workflow, source, cell, tablet_types from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), result)
+	env.tmc.expectVRQuery(100, fmt.Sprintf("select workflow, source, cell, tablet_types, workflow_type, workflow_sub_type from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), result)
 
-	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false)
+	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, ReshardOptions{})
 	assert.EqualError(t, err, "buildResharder: readRefStreams: VReplication streams must have named workflows for migration: shard: ks:0")
 	env.tmc.verifyQueries(t)
 }
@@ -693,11 +929,11 @@ func TestResharderMismatchedRefStreams(t *testing.T) {
 		},
 	}
 	result1 := sqltypes.MakeTestResult(sqltypes.MakeTestFields(
-		"workflow|source|cell|tablet_types",
-		"varchar|varchar|varchar|varchar"),
-		fmt.Sprintf("t1|%v|cell1|primary,replica", bls1),
+		"workflow|source|cell|tablet_types|workflow_type|workflow_sub_type",
+		"varchar|varchar|varchar|varchar|int64|int64"),
+		fmt.Sprintf("t1|%v|cell1|primary,replica|4|0", bls1),
 	)
-	env.tmc.expectVRQuery(100, fmt.Sprintf("select workflow, source, cell, tablet_types from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), result1)
+	env.tmc.expectVRQuery(100, fmt.Sprintf("select workflow, source, cell, tablet_types, workflow_type, workflow_sub_type from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), result1)
 	bls2 := &binlogdatapb.BinlogSource{
 		Keyspace: "ks2",
 		Shard:    "0",
@@ -708,19 +944,103 @@ func TestResharderMismatchedRefStreams(t *testing.T) {
 		},
 	}
 	result2 := sqltypes.MakeTestResult(sqltypes.MakeTestFields(
-		"workflow|source|cell|tablet_types",
-		"varchar|varchar|varchar|varchar"),
-		fmt.Sprintf("t1|%v|cell1|primary,replica", bls1),
-		fmt.Sprintf("t1|%v|cell1|primary,replica", bls2),
+		"workflow|source|cell|tablet_types|workflow_type|workflow_sub_type",
+		"varchar|varchar|varchar|varchar|int64|int64"),
+		fmt.Sprintf("t1|%v|cell1|primary,replica|4|0", bls1),
+		fmt.Sprintf("t1|%v|cell1|primary,replica|4|0", bls2),
 	)
-	env.tmc.expectVRQuery(110, fmt.Sprintf("select workflow, source, cell, tablet_types from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), result2)
+	env.tmc.expectVRQuery(110, fmt.Sprintf("select workflow, source, cell, tablet_types, workflow_type, workflow_sub_type from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), result2)
 
-	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false)
+	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, ReshardOptions{})
 	want := "buildResharder: readRefStreams: streams are mismatched across source shards"
 	if err == nil || !strings.HasPrefix(err.Error(), want) {
 		t.Errorf("Reshard err: %v, want %v", err, want)
 	}
+	var mismatchErr *ErrStreamMismatch
+	require.ErrorAs(t, err, &mismatchErr)
+	env.tmc.verifyQueries(t)
+}
+
+// TestResharderRepairMismatchedRefStreams tests that with
+// RepairReferenceStreams the reference streams of the source shard that has
+// all of them are used, and that the missing ones are logged.
+func TestResharderRepairMismatchedRefStreams(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"-80", "80-"}, []string{"0"})
+	defer env.close()
+	logger := logutil.NewMemoryLogger()
+	env.wr.SetLogger(logger)
+	opts := ReshardOptions{RepairReferenceStreams: true}
+
+	schm := &tabletmanagerdatapb.SchemaDefinition{
+		TableDefinitions: []*tabletmanagerdatapb.TableDefinition{{
+			Name:              "t1",
+			Columns:           []string{"c1", "c2"},
+			PrimaryKeyColumns: []string{"c1"},
+			Fields:            sqltypes.MakeTestFields("c1|c2", "int64|int64"),
+		}},
+	}
+	env.tmc.schema = schm
+
+	vs := &vschemapb.Keyspace{
+		Tables: map[string]*vschemapb.Table{
+			"t1": {
+				Type: vindexes.TypeReference,
+			},
+		},
+	}
+	err := env.wr.ts.SaveVSchema(context.Background(), env.keyspace, vs)
+	require.NoError(t, err)
+
+	env.expectValidation()
+
+	bls1 := &binlogdatapb.BinlogSource{
+		Keyspace: "ks1",
+		Shard:    "0",
+		Filter: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match: "t1",
+			}},
+		},
+	}
+	result1 := sqltypes.MakeTestResult(sqltypes.MakeTestFields(
+		"workflow|source|cell|tablet_types|workflow_type|workflow_sub_type",
+		"varchar|varchar|varchar|varchar|int64|int64"),
+		fmt.Sprintf("t1|%v|cell1|primary,replica|4|0", bls1),
+	)
+	env.tmc.expectVRQuery(100, fmt.Sprintf("select workflow, source, cell, tablet_types, workflow_type, workflow_sub_type from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), result1)
+	bls2 := &binlogdatapb.BinlogSource{
+		Keyspace: "ks2",
+		Shard:    "0",
+		Filter: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match: "t1",
+			}},
+		},
+	}
+	result2 := sqltypes.MakeTestResult(sqltypes.MakeTestFields(
+		"workflow|source|cell|tablet_types|workflow_type|workflow_sub_type",
+		"varchar|varchar|varchar|varchar|int64|int64"),
+		fmt.Sprintf("t1|%v|cell1|primary,replica|4|0", bls1),
+		fmt.Sprintf("t1|%v|cell1|primary,replica|4|0", bls2),
+	)
+	env.tmc.expectVRQuery(110, fmt.Sprintf("select workflow, source, cell, tablet_types, workflow_type, workflow_sub_type from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), result2)
+
+	env.tmc.expectVRQuery(
+		200,
+		insertPrefix+
+			`.*\('t1', 'keyspace:\\"ks1\\" shard:\\"0\\" filter:{rules:{match:\\"t1\\"}}', '', [0-9]*, [0-9]*, 'cell1', 'primary,replica', [0-9]*, 0, 'Stopped', 'vt_ks', 4, 0, false\).*`+
+			`\('t1', 'keyspace:\\"ks2\\" shard:\\"0\\" filter:{rules:{match:\\"t1\\"}}', '', [0-9]*, [0-9]*, 'cell1', 'primary,replica', [0-9]*, 0, 'Stopped', 'vt_ks', 4, 0, false\)`+
+			eol,
+		&sqltypes.Result{},
+	)
+	env.tmc.expectVRQuery(200, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest', 't1') and message != 'FROZEN'", &sqltypes.Result{})
+
+	err = env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, opts)
+	require.NoError(t, err)
 	env.tmc.verifyQueries(t)
+	require.Contains(t, logger.String(), "Source shard -80 is missing reference streams t1:ks2:0, using those of source shard 80-")
 }
 
 func TestResharderTableNotInVSchema(t *testing.T) {
@@ -751,13 +1071,13 @@ func TestResharderTableNotInVSchema(t *testing.T) {
 		},
 	}
 	result := sqltypes.MakeTestResult(sqltypes.MakeTestFields(
-		"workflow|source|cell|tablet_types",
-		"varchar|varchar|varchar|varchar"),
-		fmt.Sprintf("t1|%v|cell1|primary,replica", bls),
+		"workflow|source|cell|tablet_types|workflow_type|workflow_sub_type",
+		"varchar|varchar|varchar|varchar|int64|int64"),
+		fmt.Sprintf("t1|%v|cell1|primary,replica|4|0", bls),
 	)
-	env.tmc.expectVRQuery(100, fmt.Sprintf("select workflow, source, cell, tablet_types from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), result)
+	env.tmc.expectVRQuery(100, fmt.Sprintf("select workflow, source, cell, tablet_types, workflow_type, workflow_sub_type from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), result)
 
-	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false)
+	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, ReshardOptions{})
 	assert.EqualError(t, err, "buildResharder: readRefStreams: blsIsReference: table t1 not found in vschema")
 	env.tmc.verifyQueries(t)
 }
@@ -817,13 +1137,13 @@ func TestResharderMixedTablesOrder1(t *testing.T) {
 		},
 	}
 	result := sqltypes.MakeTestResult(sqltypes.MakeTestFields(
-		"workflow|source|cell|tablet_types",
-		"varchar|varchar|varchar|varchar"),
-		fmt.Sprintf("t1t2|%v|cell1|primary,replica", bls),
+		"workflow|source|cell|tablet_types|workflow_type|workflow_sub_type",
+		"varchar|varchar|varchar|varchar|int64|int64"),
+		fmt.Sprintf("t1t2|%v|cell1|primary,replica|4|0", bls),
 	)
-	env.tmc.expectVRQuery(100, fmt.Sprintf("select workflow, source, cell, tablet_types from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), result)
+	env.tmc.expectVRQuery(100, fmt.Sprintf("select workflow, source, cell, tablet_types, workflow_type, workflow_sub_type from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), result)
 
-	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false)
+	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, ReshardOptions{})
 	want := "buildResharder: readRefStreams: blsIsReference: cannot reshard streams with a mix of reference and sharded tables"
 	if err == nil || !strings.HasPrefix(err.Error(), want) {
 		t.Errorf("Reshard err: %v, want %v", err.Error(), want)
@@ -886,16 +1206,1591 @@ func TestResharderMixedTablesOrder2(t *testing.T) {
 		},
 	}
 	result := sqltypes.MakeTestResult(sqltypes.MakeTestFields(
-		"workflow|source|cell|tablet_types",
-		"varchar|varchar|varchar|varchar"),
-		fmt.Sprintf("t1t2|%v|cell1|primary,replica", bls),
+		"workflow|source|cell|tablet_types|workflow_type|workflow_sub_type",
+		"varchar|varchar|varchar|varchar|int64|int64"),
+		fmt.Sprintf("t1t2|%v|cell1|primary,replica|4|0", bls),
 	)
-	env.tmc.expectVRQuery(100, fmt.Sprintf("select workflow, source, cell, tablet_types from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), result)
+	env.tmc.expectVRQuery(100, fmt.Sprintf("select workflow, source, cell, tablet_types, workflow_type, workflow_sub_type from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), result)
 
-	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false)
+	err := env.wr.Reshard(context.Background(), env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, ReshardOptions{})
 	want := "buildResharder: readRefStreams: blsIsReference: cannot reshard streams with a mix of reference and sharded tables"
 	if err == nil || !strings.HasPrefix(err.Error(), want) {
 		t.Errorf("Reshard err: %v, want %v", err.Error(), want)
 	}
 	env.tmc.verifyQueries(t)
 }
+
+func TestEnsureTargetsNonServing(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+
+	_, err := env.wr.ts.UpdateShardFields(ctx, env.keyspace, "-80", func(si *topo.ShardInfo) error {
+		si.IsPrimaryServing = true
+		return nil
+	})
+	require.NoError(t, err)
+
+	states, err := env.wr.EnsureTargetsNonServing(ctx, env.keyspace, env.targets, true)
+	require.NoError(t, err)
+	require.Equal(t, []*TargetServingState{
+		{Shard: "-80", WasServing: true},
+		{Shard: "80-"},
+	}, states)
+	si, err := env.wr.ts.GetShard(ctx, env.keyspace, "-80")
+	require.NoError(t, err)
+	require.True(t, si.IsPrimaryServing)
+
+	states, err = env.wr.EnsureTargetsNonServing(ctx, env.keyspace, env.targets, false)
+	require.NoError(t, err)
+	require.Equal(t, []*TargetServingState{
+		{Shard: "-80", WasServing: true, Transitioned: true},
+		{Shard: "80-"},
+	}, states)
+	si, err = env.wr.ts.GetShard(ctx, env.keyspace, "-80")
+	require.NoError(t, err)
+	require.False(t, si.IsPrimaryServing)
+
+	// If the source is not serving, the target carries live traffic.
+	for _, shard := range []string{"0", "-80"} {
+		_, err = env.wr.ts.UpdateShardFields(ctx, env.keyspace, shard, func(si *topo.ShardInfo) error {
+			si.IsPrimaryServing = shard == "-80"
+			return nil
+		})
+		require.NoError(t, err)
+	}
+	_, err = env.wr.EnsureTargetsNonServing(ctx, env.keyspace, env.targets, false)
+	require.EqualError(t, err, "target shard -80 is the only serving shard for part of key range -80, refusing to transition it to non-serving")
+}
+
+func TestStartWorkflowForShards(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+
+	vs := &vschemapb.Keyspace{
+		Tables: map[string]*vschemapb.Table{
+			"t1": {
+				Type: vindexes.TypeReference,
+			},
+		},
+	}
+	err := env.wr.ts.SaveVSchema(ctx, env.keyspace, vs)
+	require.NoError(t, err)
+
+	// Only the canary shard -80 (tablet 200) is started, with the copy of
+	// the reference stream t1; any query sent to the 80- primary (tablet
+	// 210) would fail as unexpected.
+	env.tmc.expectVRQuery(200, "select source from _vt.vreplication where db_name='vt_ks' and workflow='resharderTest'",
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("source", "varchar"),
+			`keyspace:"ks" shard:"0" filter:{rules:{match:"/.*" filter:"-80"}}`,
+		))
+	bls := &binlogdatapb.BinlogSource{
+		Keyspace: "ks1",
+		Shard:    "0",
+		Filter: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match: "t1",
+			}},
+		},
+	}
+	env.tmc.expectVRQuery(100, "select workflow, source, cell, tablet_types, workflow_type, workflow_sub_type from _vt.vreplication where db_name='vt_ks' and message != 'FROZEN'",
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields(
+			"workflow|source|cell|tablet_types|workflow_type|workflow_sub_type",
+			"varchar|varchar|varchar|varchar|int64|int64"),
+			fmt.Sprintf("t1|%v|cell1|primary,replica|4|0", bls),
+		))
+	env.tmc.expectVRQuery(200, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest', 't1') and message != 'FROZEN'", &sqltypes.Result{})
+	err = env.wr.StartWorkflowForShards(ctx, env.keyspace, env.workflow, []string{"-80"}, ReshardOptions{})
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+
+	err = env.wr.StartWorkflowForShards(ctx, env.keyspace, env.workflow, nil, ReshardOptions{})
+	require.EqualError(t, err, "no target shards specified for workflow resharderTest")
+}
+
+// TestResharderStreamBatchSize tests that streams are created in batches
+// when a stream batch size is set, and that a failed batch is reported.
+func TestResharderStreamBatchSize(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+	opts := ReshardOptions{StreamBatchSize: 1}
+
+	schm := &tabletmanagerdatapb.SchemaDefinition{
+		TableDefinitions: []*tabletmanagerdatapb.TableDefinition{{
+			Name:              "t1",
+			Columns:           []string{"c1", "c2"},
+			PrimaryKeyColumns: []string{"c1"},
+			Fields:            sqltypes.MakeTestFields("c1|c2", "int64|int64"),
+		}},
+	}
+	env.tmc.schema = schm
+
+	vs := &vschemapb.Keyspace{
+		Tables: map[string]*vschemapb.Table{
+			"t1": {
+				Type: vindexes.TypeReference,
+			},
+		},
+	}
+	err := env.wr.ts.SaveVSchema(ctx, env.keyspace, vs)
+	require.NoError(t, err)
+
+	env.expectValidation()
+
+	bls := &binlogdatapb.BinlogSource{
+		Keyspace: "ks1",
+		Shard:    "0",
+		Filter: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match: "t1",
+			}},
+		},
+	}
+	result := sqltypes.MakeTestResult(sqltypes.MakeTestFields(
+		"workflow|source|cell|tablet_types|workflow_type|workflow_sub_type",
+		"varchar|varchar|varchar|varchar|int64|int64"),
+		fmt.Sprintf("t1|%v|cell1|primary,replica|4|0", bls),
+	)
+	env.tmc.expectVRQuery(100, fmt.Sprintf("select workflow, source, cell, tablet_types, workflow_type, workflow_sub_type from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), result)
+
+	refRow := `\('t1', 'keyspace:\\"ks1\\" shard:\\"0\\" filter:{rules:{match:\\"t1\\"}}', '', [0-9]*, [0-9]*, 'cell1', 'primary,replica', [0-9]*, 0, 'Stopped', 'vt_ks', 4, 0, false\)`
+	env.tmc.expectVRQuery(
+		200,
+		insertPrefix+
+			`\('resharderTest', 'keyspace:\\"ks\\" shard:\\"0\\" filter:{rules:{match:\\"t1\\" filter:\\"exclude\\"} rules:{match:\\"/.*\\" filter:\\"-80\\"}}', '', [0-9]*, [0-9]*, '', '', [0-9]*, 0, 'Stopped', 'vt_ks', 4, 0, false\)`+eol,
+		&sqltypes.Result{},
+	)
+	env.tmc.expectVRQuery(200, insertPrefix+refRow+eol, &sqltypes.Result{})
+	// The second batch on 80- is not expected, which makes it fail.
+	env.tmc.expectVRQuery(
+		210,
+		insertPrefix+
+			`\('resharderTest', 'keyspace:\\"ks\\" shard:\\"0\\" filter:{rules:{match:\\"t1\\" filter:\\"exclude\\"} rules:{match:\\"/.*\\" filter:\\"80-\\"}}', '', [0-9]*, [0-9]*, '', '', [0-9]*, 0, 'Stopped', 'vt_ks', 4, 0, false\)`+eol,
+		&sqltypes.Result{},
+	)
+
+	// The streams that were created are cleaned up after the failure.
+	cleanupQuery := "delete from _vt.vreplication where db_name='vt_ks' and workflow in ('resharderTest', 't1') and message != 'FROZEN'"
+	env.tmc.expectVRQuery(200, cleanupQuery, &sqltypes.Result{})
+	env.tmc.expectVRQuery(210, cleanupQuery, &sqltypes.Result{})
+
+	err = env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, opts)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed for batch 2 of 2 on target shard 80-, streams from the 1 earlier batches were already created")
+	env.tmc.verifyQueries(t)
+}
+
+func TestResharderCleanupTargets(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+
+	schm := &tabletmanagerdatapb.SchemaDefinition{
+		TableDefinitions: []*tabletmanagerdatapb.TableDefinition{{
+			Name:              "t1",
+			Columns:           []string{"c1", "c2"},
+			PrimaryKeyColumns: []string{"c1"},
+			Fields:            sqltypes.MakeTestFields("c1|c2", "int64|int64"),
+		}},
+	}
+	env.tmc.schema = schm
+
+	env.expectValidation()
+	env.expectNoRefStream()
+
+	cleanupQuery := "delete from _vt.vreplication where db_name='vt_ks' and workflow in ('resharderTest') and message != 'FROZEN'"
+	env.tmc.expectVRQuery(
+		200,
+		insertPrefix+
+			`\('resharderTest', 'keyspace:\\"ks\\" shard:\\"0\\" filter:{rules:{match:\\"/.*\\" filter:\\"-80\\"}}', '', [0-9]*, [0-9]*, '', '', [0-9]*, 0, 'Stopped', 'vt_ks', 4, 0, false\)`+eol,
+		&sqltypes.Result{},
+	)
+	env.tmc.expectVRQuery(200, cleanupQuery, &sqltypes.Result{})
+	// The insert on 80- doesn't match the expected query, which makes it fail.
+	env.tmc.expectVRQuery(210, cleanupQuery, &sqltypes.Result{})
+
+	err := env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, ReshardOptions{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "createStreams")
+	env.tmc.verifyQueries(t)
+
+	// Once cleaned up, the targets pass the validation of a new reshard.
+	for _, tablet := range []int{200, 210} {
+		env.tmc.expectVRQuery(tablet, "select 1 from _vt.vreplication where db_name='vt_ks'", &sqltypes.Result{})
+	}
+	env.expectNoRefStream()
+	_, err = env.wr.buildResharder(ctx, env.keyspace, env.workflow, env.sources, env.targets, "", "", ReshardOptions{})
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+}
+
+// TestResharderCleanupTargetsKeepsExistingStreams tests that the cleanup of
+// a failed reshard keeps the streams that were on the targets before it,
+// even those of a workflow named like a reference workflow.
+func TestResharderCleanupTargetsKeepsExistingStreams(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+	opts := ReshardOptions{IgnoreTargetWorkflows: []string{"t1"}}
+
+	vs := &vschemapb.Keyspace{
+		Tables: map[string]*vschemapb.Table{
+			"t1": {
+				Type: vindexes.TypeReference,
+			},
+		},
+	}
+	err := env.wr.ts.SaveVSchema(ctx, env.keyspace, vs)
+	require.NoError(t, err)
+
+	for _, tabletID := range []int{100, 200, 210} {
+		env.tmc.expectVRQuery(tabletID, "select 1 from _vt.vreplication where db_name='vt_ks' and workflow='resharderTest'", &sqltypes.Result{})
+		env.tmc.expectVRQuery(tabletID, rsSelectFrozenQuery, &sqltypes.Result{})
+	}
+	env.tmc.expectVRQuery(200, "select id, workflow, message from _vt.vreplication where db_name='vt_ks'",
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("id|workflow|message", "int64|varchar|varchar"),
+			"5|t1|",
+		))
+	env.tmc.expectVRQuery(210, "select id, workflow, message from _vt.vreplication where db_name='vt_ks'", &sqltypes.Result{})
+	bls := &binlogdatapb.BinlogSource{
+		Keyspace: "ks1",
+		Shard:    "0",
+		Filter: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match: "t1",
+			}},
+		},
+	}
+	env.tmc.expectVRQuery(100, "select workflow, source, cell, tablet_types, workflow_type, workflow_sub_type from _vt.vreplication where db_name='vt_ks' and message != 'FROZEN'",
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields(
+			"workflow|source|cell|tablet_types|workflow_type|workflow_sub_type",
+			"varchar|varchar|varchar|varchar|int64|int64"),
+			fmt.Sprintf("t1|%v|cell1|primary,replica|4|0", bls),
+		))
+
+	env.tmc.expectVRQuery(200, insertPrefix, &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, "delete from _vt.vreplication where db_name='vt_ks' and workflow in ('resharderTest', 't1') and message != 'FROZEN' and id not in (5)", &sqltypes.Result{})
+	// The insert on 80- doesn't match the expected query, which makes it fail.
+	env.tmc.expectVRQuery(210, "delete from _vt.vreplication where db_name='vt_ks' and workflow in ('resharderTest', 't1') and message != 'FROZEN'", &sqltypes.Result{})
+
+	err = env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, opts)
+	require.ErrorContains(t, err, "createStreams")
+	env.tmc.verifyQueries(t)
+}
+
+func TestVerifyReshardStreams(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"-80", "80-"}, []string{"-40", "40-"})
+	defer env.close()
+
+	source := func(shard, keyRange string) *binlogdatapb.BinlogSource {
+		return &binlogdatapb.BinlogSource{
+			Keyspace: "ks",
+			Shard:    shard,
+			Filter: &binlogdatapb.Filter{
+				Rules: []*binlogdatapb.Rule{{
+					Match:  "/.*",
+					Filter: keyRange,
+				}},
+			},
+		}
+	}
+	plan := ReshardStreamPlan{
+		"-40": {source("-80", "-40")},
+		"40-": {source("-80", "40-"), source("80-", "40-")},
+	}
+	fields := sqltypes.MakeTestFields("id|source", "int64|varchar")
+	query := "select id, source from _vt.vreplication where db_name='vt_ks' and workflow='resharderTest'"
+
+	env.tmc.expectVRQuery(200, query, sqltypes.MakeTestResult(fields,
+		fmt.Sprintf("1|%v", source("-80", "-40"))))
+	env.tmc.expectVRQuery(210, query, sqltypes.MakeTestResult(fields,
+		fmt.Sprintf("1|%v", source("-80", "40-")),
+		fmt.Sprintf("2|%v", source("80-", "40-"))))
+	mismatches, err := env.wr.VerifyReshardStreams(ctx, env.keyspace, env.workflow, plan, ReshardOptions{})
+	require.NoError(t, err)
+	require.Empty(t, mismatches)
+	env.tmc.verifyQueries(t)
+
+	// Transient errors are retried.
+	opts := ReshardOptions{ExecRetryDelay: time.Millisecond}
+	env.tmc.expectVRQueryError(200, query, vterrors.New(vtrpcpb.Code_UNAVAILABLE, "connection refused"))
+	env.tmc.expectVRQuery(200, query, sqltypes.MakeTestResult(fields,
+		fmt.Sprintf("1|%v", source("-80", "-40"))))
+	env.tmc.expectVRQuery(210, query, sqltypes.MakeTestResult(fields,
+		fmt.Sprintf("1|%v", source("-80", "40-")),
+		fmt.Sprintf("2|%v", source("80-", "40-"))))
+	mismatches, err = env.wr.VerifyReshardStreams(ctx, env.keyspace, env.workflow, plan, opts)
+	require.NoError(t, err)
+	require.Empty(t, mismatches)
+	env.tmc.verifyQueries(t)
+
+	env.tmc.expectVRQuery(200, query, sqltypes.MakeTestResult(fields,
+		fmt.Sprintf("1|%v", source("-80", "-80")),
+		fmt.Sprintf("2|%v", source("80-", "-40"))))
+	env.tmc.expectVRQuery(210, query, sqltypes.MakeTestResult(fields,
+		fmt.Sprintf("1|%v", source("-80", "40-"))))
+	mismatches, err = env.wr.VerifyReshardStreams(ctx, env.keyspace, env.workflow, plan, opts)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"target -40: stream for source ks/-80 has filter rules [/.*:-80], want [/.*:-40]",
+		"target -40: unexpected stream for source ks/80-",
+		"target 40-: missing stream for source ks/80-",
+	}, mismatches)
+	env.tmc.verifyQueries(t)
+}
+
+// TestVerifyReshardStreamsDeferredTables tests that the two streams from
+// each source shard of a reshard that defers the secondary keys of some
+// tables are verified by their filters.
+func TestVerifyReshardStreamsDeferredTables(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+	opts := ReshardOptions{DeferSecondaryKeysTables: []string{"t1"}}
+
+	vs := &vschemapb.Keyspace{
+		Tables: map[string]*vschemapb.Table{
+			"t1": {},
+			"t2": {},
+		},
+	}
+	require.NoError(t, env.wr.ts.SaveVSchema(ctx, env.keyspace, vs))
+
+	for _, tabletID := range []int{200, 210} {
+		env.tmc.expectVRQuery(tabletID, "select 1 from _vt.vreplication where db_name='vt_ks'", &sqltypes.Result{})
+	}
+	env.expectNoRefStream()
+	plan, err := env.wr.PlanReshardStreams(ctx, env.keyspace, env.workflow, env.sources, env.targets, "", "", defaultOnDDL, false, opts)
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+
+	fields := sqltypes.MakeTestFields("id|source", "int64|varchar")
+	query := "select id, source from _vt.vreplication where db_name='vt_ks' and workflow='resharderTest'"
+	for i, target := range env.targets {
+		require.Len(t, plan[target], 2)
+		// The streams are read back in the opposite order.
+		env.tmc.expectVRQuery(200+10*i, query, sqltypes.MakeTestResult(fields,
+			fmt.Sprintf("1|%v", plan[target][1]),
+			fmt.Sprintf("2|%v", plan[target][0])))
+	}
+	mismatches, err := env.wr.VerifyReshardStreams(ctx, env.keyspace, env.workflow, plan, opts)
+	require.NoError(t, err)
+	require.Empty(t, mismatches)
+	env.tmc.verifyQueries(t)
+
+	// A duplicate of one of the streams is still reported.
+	for i, target := range env.targets {
+		env.tmc.expectVRQuery(200+10*i, query, sqltypes.MakeTestResult(fields,
+			fmt.Sprintf("1|%v", plan[target][0]),
+			fmt.Sprintf("2|%v", plan[target][1]),
+			fmt.Sprintf("3|%v", plan[target][1])))
+	}
+	mismatches, err = env.wr.VerifyReshardStreams(ctx, env.keyspace, env.workflow, plan, opts)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"target -80: duplicate stream id 3 for source ks/0",
+		"target 80-: duplicate stream id 3 for source ks/0",
+	}, mismatches)
+	env.tmc.verifyQueries(t)
+}
+
+func TestListCellsAndAliases(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+
+	err := env.topoServ.CreateCellInfo(ctx, "cell2", &topodatapb.CellInfo{})
+	require.NoError(t, err)
+	err = env.topoServ.CreateCellsAlias(ctx, "region", &topodatapb.CellsAlias{Cells: []string{"cell2", "cell"}})
+	require.NoError(t, err)
+
+	got, err := env.wr.ListCellsAndAliases(ctx)
+	require.NoError(t, err)
+	require.Equal(t, &CellsAndAliases{
+		Cells:   []string{"cell", "cell2"},
+		Aliases: map[string][]string{"region": {"cell", "cell2"}},
+	}, got)
+}
+
+func TestResharderValidateCells(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+
+	err := env.topoServ.CreateCellInfo(ctx, "cell2", &topodatapb.CellInfo{})
+	require.NoError(t, err)
+	err = env.topoServ.CreateCellsAlias(ctx, "region", &topodatapb.CellsAlias{Cells: []string{"cell2", "cell"}})
+	require.NoError(t, err)
+
+	for _, cell := range []string{"", "cell", "region", "cell, cell2,region"} {
+		rs := &resharder{wr: env.wr, cell: cell}
+		require.NoError(t, rs.validateCells(ctx), cell)
+	}
+	rs := &resharder{wr: env.wr, cell: "cell,cel2,region,regoin"}
+	require.EqualError(t, rs.validateCells(ctx), "cells cel2, regoin are neither cells nor cell aliases")
+
+	// The streams are not planned if a cell is invalid.
+	_, err = env.wr.PlanReshardStreams(ctx, env.keyspace, env.workflow, env.sources, env.targets, "cell,cel2", "", defaultOnDDL, false, ReshardOptions{})
+	require.EqualError(t, err, "buildResharder: validateCells: cells cel2 are neither cells nor cell aliases")
+	env.tmc.verifyQueries(t)
+}
+
+func TestResharderMySQLVersions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+	env.tmc.mysqlVersions[210] = "5.7.40-log"
+
+	// The versions are not checked by default, which doesn't cost any
+	// FullStatus RPC.
+	env.tmc.fullStatusErr = fmt.Errorf("FullStatus not expected")
+	env.expectValidation()
+	env.expectNoRefStream()
+	env.tmc.expectVRQuery(200, insertPrefix, &sqltypes.Result{})
+	env.tmc.expectVRQuery(210, insertPrefix, &sqltypes.Result{})
+	err := env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, false, false, false, ReshardOptions{})
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+
+	env.tmc.fullStatusErr = nil
+	opts := ReshardOptions{StrictMySQLVersionCheck: true}
+	env.expectValidation()
+	err = env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, false, false, false, opts)
+	require.ErrorContains(t, err, "incompatible MySQL versions: source shard 0 runs MySQL 8.0.34 but target shard 80- runs older MySQL 5.7.40-log")
+	env.tmc.verifyQueries(t)
+
+	env.tmc.fullStatusErr = fmt.Errorf("FullStatus not supported")
+	env.expectValidation()
+	err = env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, false, false, false, opts)
+	require.ErrorContains(t, err, "FullStatus not supported")
+	env.tmc.verifyQueries(t)
+
+	env.tmc.fullStatusErr = nil
+	env.tmc.mysqlVersions[210] = "8.0.34"
+	env.expectValidation()
+	env.expectNoRefStream()
+	env.tmc.expectVRQuery(200, insertPrefix, &sqltypes.Result{})
+	env.tmc.expectVRQuery(210, insertPrefix, &sqltypes.Result{})
+	err = env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, false, false, false, opts)
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+}
+
+func TestCompareMySQLVersions(t *testing.T) {
+	testcases := []struct {
+		a, b string
+		want int
+		err  string
+	}{
+		{a: "8.0.34", b: "8.0.34-log", want: 0},
+		{a: "8.0.34", b: "8.0.35", want: -1},
+		{a: "8.0.34", b: "5.7.40", want: 1},
+		{a: "8.0", b: "8.0.1", want: -1},
+		{a: "", b: "8.0.1", err: "empty version"},
+		{a: "8.0.x", b: "8.0.1", err: `invalid version "8.0.x"`},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.a+"/"+tc.b, func(t *testing.T) {
+			got, err := compareMySQLVersions(tc.a, tc.b)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestCreateReshardTargetShards(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+
+	keyRanges := func(shards ...string) []*topodatapb.KeyRange {
+		var krs []*topodatapb.KeyRange
+		for _, shard := range shards {
+			_, kr, err := topo.ValidateShardName(shard)
+			require.NoError(t, err)
+			krs = append(krs, kr)
+		}
+		return krs
+	}
+
+	got, err := env.wr.CreateReshardTargetShards(ctx, env.keyspace, keyRanges("-80", "80-c0", "c0-"))
+	require.NoError(t, err)
+	require.Equal(t, &ReshardTargetShards{
+		Created:  []string{"80-c0", "c0-"},
+		Existing: []string{"-80"},
+	}, got)
+	for _, shard := range []string{"80-c0", "c0-"} {
+		si, err := env.topoServ.GetShard(ctx, env.keyspace, shard)
+		require.NoError(t, err)
+		require.False(t, si.IsPrimaryServing)
+	}
+
+	// A new shard that overlaps no other shard is still created non-serving.
+	err = env.topoServ.CreateKeyspace(ctx, "ks2", &topodatapb.Keyspace{})
+	require.NoError(t, err)
+	got, err = env.wr.CreateReshardTargetShards(ctx, "ks2", keyRanges("-80"))
+	require.NoError(t, err)
+	require.Equal(t, &ReshardTargetShards{Created: []string{"-80"}}, got)
+	si, err := env.topoServ.GetShard(ctx, "ks2", "-80")
+	require.NoError(t, err)
+	require.False(t, si.IsPrimaryServing)
+
+	_, err = env.topoServ.UpdateShardFields(ctx, env.keyspace, "-80", func(si *topo.ShardInfo) error {
+		si.IsPrimaryServing = true
+		return nil
+	})
+	require.NoError(t, err)
+	_, err = env.wr.CreateReshardTargetShards(ctx, env.keyspace, keyRanges("-80"))
+	require.EqualError(t, err, "target shard -80 already exists in keyspace ks and is in serving state")
+}
+
+func TestResharderVerbose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"-80", "80-"}, []string{"0"})
+	defer env.close()
+	logger := logutil.NewMemoryLogger()
+	env.wr.SetLogger(logger)
+	opts := ReshardOptions{Verbose: true}
+
+	env.expectValidation()
+	env.expectNoRefStream()
+	env.tmc.expectVRQuery(200, insertPrefix, &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest') and message != 'FROZEN'", &sqltypes.Result{})
+
+	err := env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, opts)
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+
+	logs := logger.String()
+	for _, want := range []string{
+		"Reshard ks.resharderTest: Found source shard -80 with key range -80 and primary cell-0000000100",
+		"Reshard ks.resharderTest: Found target shard 0 with key range - and primary cell-0000000200",
+		"Reshard ks.resharderTest: buildResharder took ",
+		"Reshard ks.resharderTest: Target shard 0 intersects source shard 80-",
+		"Reshard ks.resharderTest: Creating 2 streams, including 0 reference streams, on target shard 0 in 1 inserts",
+		"Reshard ks.resharderTest: createStreams took ",
+		"Reshard ks.resharderTest: startStreams took ",
+	} {
+		require.Contains(t, logs, want)
+	}
+}
+
+func TestReshardWithRollbackPlan(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+
+	_, err := env.wr.GetReshardRollbackPlan(ctx, env.keyspace, env.workflow)
+	require.EqualError(t, err, "no rollback plan found for workflow resharderTest in keyspace ks")
+
+	env.expectValidation()
+	env.expectNoRefStream()
+	env.tmc.expectVRQuery(
+		200,
+		insertPrefix+
+			`\('resharderTest', 'keyspace:\\"ks\\" shard:\\"0\\" filter:{rules:{match:\\"/.*\\" filter:\\"-80\\"}}', '', [0-9]*, [0-9]*, '', '', [0-9]*, 0, 'Stopped', 'vt_ks', 4, 0, false\)`+eol,
+		&sqltypes.Result{},
+	)
+	env.tmc.expectVRQuery(
+		210,
+		insertPrefix+
+			`\('resharderTest', 'keyspace:\\"ks\\" shard:\\"0\\" filter:{rules:{match:\\"/.*\\" filter:\\"80-\\"}}', '', [0-9]*, [0-9]*, '', '', [0-9]*, 0, 'Stopped', 'vt_ks', 4, 0, false\)`+eol,
+		&sqltypes.Result{},
+	)
+
+	handle, err := env.wr.ReshardWithRollbackPlan(ctx, &ReshardParams{
+		Keyspace:       env.keyspace,
+		Workflow:       env.workflow,
+		Sources:        env.sources,
+		Targets:        env.targets,
+		SkipSchemaCopy: true,
+		OnDDL:          defaultOnDDL,
+	})
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+
+	want := &ReverseReshardPlan{
+		Keyspace:        "ks",
+		Workflow:        "resharderTest",
+		ReverseWorkflow: "resharderTest_reverse",
+		Sources: []*ReverseReshardShard{
+			{Name: "-80", KeyRange: "-80", Primary: "cell-0000000200"},
+			{Name: "80-", KeyRange: "80-", Primary: "cell-0000000210"},
+		},
+		Targets: []*ReverseReshardShard{
+			{Name: "0", KeyRange: "-", Primary: "cell-0000000100"},
+		},
+	}
+	assert.Equal(t, "reshard.ks.resharderTest.rollback_plan", handle.MetadataKey)
+	assert.Equal(t, want, handle.Plan)
+
+	plan, err := env.wr.GetReshardRollbackPlan(ctx, env.keyspace, env.workflow)
+	require.NoError(t, err)
+	assert.Equal(t, want, plan)
+
+	// A failed reshard doesn't leave a rollback plan behind.
+	_, err = env.wr.ReshardWithRollbackPlan(ctx, &ReshardParams{
+		Keyspace: env.keyspace,
+		Workflow: "failing",
+		Sources:  env.sources,
+		Targets:  env.targets,
+	})
+	require.Error(t, err)
+	_, err = env.wr.GetReshardRollbackPlan(ctx, env.keyspace, "failing")
+	require.EqualError(t, err, "no rollback plan found for workflow failing in keyspace ks")
+}
+
+func TestResharderVSchemaOverride(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+
+	// The vschema in the topo doesn't know t1, the override makes it a
+	// reference table.
+	opts := ReshardOptions{
+		VSchemaOverride: &vschemapb.Keyspace{
+			Tables: map[string]*vschemapb.Table{
+				"t1": {
+					Type: vindexes.TypeReference,
+				},
+			},
+		},
+	}
+
+	env.expectValidation()
+	env.expectNoRefStream()
+
+	env.tmc.expectVRQuery(
+		200,
+		insertPrefix+
+			`\('resharderTest', 'keyspace:\\"ks\\" shard:\\"0\\" filter:{rules:{match:\\"t1\\" filter:\\"exclude\\"} rules:{match:\\"/.*\\" filter:\\"-80\\"}}', '', [0-9]*, [0-9]*, '', '', [0-9]*, 0, 'Stopped', 'vt_ks', 4, 0, false\)`+
+			eol,
+		&sqltypes.Result{},
+	)
+	env.tmc.expectVRQuery(
+		210,
+		insertPrefix+
+			`\('resharderTest', 'keyspace:\\"ks\\" shard:\\"0\\" filter:{rules:{match:\\"t1\\" filter:\\"exclude\\"} rules:{match:\\"/.*\\" filter:\\"80-\\"}}', '', [0-9]*, [0-9]*, '', '', [0-9]*, 0, 'Stopped', 'vt_ks', 4, 0, false\)`+
+			eol,
+		&sqltypes.Result{},
+	)
+
+	err := env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, false, false, false, opts)
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+
+	vs, err := env.wr.ts.GetVSchema(ctx, env.keyspace)
+	require.NoError(t, err)
+	require.Empty(t, vs.Tables)
+}
+
+func TestValidateSequences(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+
+	env.addTablet(300, "global", "0", topodatapb.TabletType_PRIMARY)
+	env.tmc.schema = &tabletmanagerdatapb.SchemaDefinition{
+		TableDefinitions: []*tabletmanagerdatapb.TableDefinition{{
+			Name: "user_seq",
+		}},
+	}
+	err := env.wr.ts.SaveVSchema(ctx, "global", &vschemapb.Keyspace{
+		Tables: map[string]*vschemapb.Table{
+			"user_seq":  {Type: vindexes.TypeSequence},
+			"order_seq": {Type: vindexes.TypeSequence},
+			"t":         {},
+		},
+	})
+	require.NoError(t, err)
+
+	gaps, err := env.wr.ValidateSequences(ctx, env.keyspace)
+	require.NoError(t, err)
+	require.Empty(t, gaps)
+
+	err = env.wr.ts.SaveVSchema(ctx, env.keyspace, &vschemapb.Keyspace{
+		Sharded: true,
+		Tables: map[string]*vschemapb.Table{
+			"t1": {AutoIncrement: &vschemapb.AutoIncrement{Column: "id", Sequence: "user_seq"}},
+			"t2": {AutoIncrement: &vschemapb.AutoIncrement{Column: "id", Sequence: "missing_seq"}},
+			"t3": {AutoIncrement: &vschemapb.AutoIncrement{Column: "id", Sequence: "global.t"}},
+			"t4": {AutoIncrement: &vschemapb.AutoIncrement{Column: "id", Sequence: "`global`.`order_seq`"}},
+		},
+	})
+	require.NoError(t, err)
+
+	gaps, err = env.wr.ValidateSequences(ctx, env.keyspace)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"table t2: sequence missing_seq not found in the vschema of any keyspace",
+		"table t3: global.t is not a sequence table in the vschema",
+		"table t4: sequence table global.order_seq does not exist on cell-0000000300",
+	}, gaps)
+
+	// Reshard only looks for the gaps if asked to.
+	const wantWarning = "Sequence misconfiguration in keyspace ks, inserts may fail after cutover: table t2: sequence missing_seq not found in the vschema of any keyspace"
+	for _, validate := range []bool{false, true} {
+		logger := logutil.NewMemoryLogger()
+		env.wr.SetLogger(logger)
+		opts := ReshardOptions{ValidateSequences: validate}
+		env.expectValidation()
+		env.expectNoRefStream()
+		env.tmc.expectVRQuery(200, insertPrefix, &sqltypes.Result{})
+		env.tmc.expectVRQuery(210, insertPrefix, &sqltypes.Result{})
+		err = env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, false, false, false, opts)
+		require.NoError(t, err)
+		env.tmc.verifyQueries(t)
+		require.Equal(t, validate, strings.Contains(logger.String(), wantWarning), logger.String())
+	}
+}
+
+func TestResharderStopPositions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"-80", "80-"}, []string{"0"})
+	defer env.close()
+
+	stopPos := "MySQL56/14b68925-696a-11ea-aee7-fec597a91f5e:1-3"
+	opts := ReshardOptions{
+		StopPositions: map[string]string{"-80": stopPos},
+	}
+
+	env.expectValidation()
+	env.expectNoRefStream()
+	env.tmc.expectVRQuery(200, insertPrefix, &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, "select id, source from _vt.vreplication where db_name='vt_ks' and workflow='resharderTest'",
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("id|source", "int64|varchar"),
+			`1|keyspace:"ks" shard:"-80"`,
+			`2|keyspace:"ks" shard:"80-"`,
+		))
+	env.tmc.expectVRQuery(200, "update _vt.vreplication set stop_pos='"+stopPos+"' where id in (1)", &sqltypes.Result{})
+
+	err := env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, false, false, false, opts)
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+
+	testCases := []struct {
+		name          string
+		stopPositions map[string]string
+		stopAfterCopy bool
+		wantErr       string
+	}{{
+		name:          "stop after copy",
+		stopPositions: map[string]string{"-80": stopPos},
+		stopAfterCopy: true,
+		wantErr:       "stop positions cannot be combined with stop_after_copy",
+	}, {
+		name:          "not a source shard",
+		stopPositions: map[string]string{"0": stopPos},
+		wantErr:       "stop position given for shard 0, which is not a source shard",
+	}, {
+		name:          "invalid position",
+		stopPositions: map[string]string{"80-": "MySQL56/bogus"},
+		wantErr:       `invalid stop position "MySQL56/bogus" for source shard 80-`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := ReshardOptions{StopPositions: tc.stopPositions}
+			env.expectValidation()
+			env.expectNoRefStream()
+
+			err := env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, false, tc.stopAfterCopy, false, opts)
+			require.ErrorContains(t, err, tc.wantErr)
+			env.tmc.verifyQueries(t)
+		})
+	}
+}
+
+func TestReshardStage(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"-80", "80-"}, []string{"0"})
+	defer env.close()
+
+	_, err := env.wr.GetReshardStage(ctx, env.keyspace, env.workflow)
+	require.EqualError(t, err, "no stage found for reshard resharderTest in keyspace ks")
+
+	env.expectValidation()
+	env.expectNoRefStream()
+	env.tmc.expectVRQuery(200, insertPrefix, &sqltypes.Result{})
+
+	err = env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, false, false, false, ReshardOptions{})
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+
+	stage, err := env.wr.GetReshardStage(ctx, env.keyspace, env.workflow)
+	require.NoError(t, err)
+	require.Equal(t, ReshardStageCreated, stage)
+
+	err = env.wr.AdvanceReshardStage(ctx, env.keyspace, env.workflow, ReshardStageReady)
+	require.EqualError(t, err, "cannot move reshard resharderTest in keyspace ks from stage created to stage ready")
+	for _, next := range []ReshardStage{ReshardStageCopying, ReshardStageReady, ReshardStageSwitched} {
+		require.NoError(t, env.wr.AdvanceReshardStage(ctx, env.keyspace, env.workflow, next))
+		stage, err = env.wr.GetReshardStage(ctx, env.keyspace, env.workflow)
+		require.NoError(t, err)
+		require.Equal(t, next, stage)
+	}
+	err = env.wr.AdvanceReshardStage(ctx, env.keyspace, env.workflow, ReshardStageCreated)
+	require.EqualError(t, err, "cannot move reshard resharderTest in keyspace ks from stage switched to stage created")
+}
+
+func TestAdvanceReshardStageConcurrent(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"-80", "80-"}, []string{"0"})
+	defer env.close()
+
+	require.NoError(t, env.wr.setReshardStage(ctx, env.keyspace, env.workflow, ReshardStageCreated))
+	const callers = 5
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			errs <- env.wr.AdvanceReshardStage(ctx, env.keyspace, env.workflow, ReshardStageCopying)
+		}()
+	}
+	advanced := 0
+	for i := 0; i < callers; i++ {
+		if err := <-errs; err == nil {
+			advanced++
+		} else {
+			require.EqualError(t, err, "cannot move reshard resharderTest in keyspace ks from stage copying to stage copying")
+		}
+	}
+	require.Equal(t, 1, advanced)
+}
+
+func TestResharderIgnoreFrozenTargetStreams(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"-80", "80-"}, []string{"0"})
+	defer env.close()
+	logger := logutil.NewMemoryLogger()
+	env.wr.SetLogger(logger)
+
+	frozenStreams := sqltypes.MakeTestResult(sqltypes.MakeTestFields("id|workflow|message", "int64|varchar|varchar"),
+		"1|oldWorkflow|FROZEN",
+		"2|oldWorkflow|FROZEN",
+	)
+	// expectValidation expects the validation of the new workflow, which
+	// only looks for frozen streams on the target if checkTargetFrozen.
+	expectValidation := func(checkTargetFrozen bool) {
+		for _, tabletID := range []int{100, 110, 200} {
+			env.tmc.expectVRQuery(tabletID, "select 1 from _vt.vreplication where db_name='vt_ks' and workflow='resharderTest'", &sqltypes.Result{})
+			switch {
+			case tabletID < 200:
+				env.tmc.expectVRQuery(tabletID, rsSelectFrozenQuery, &sqltypes.Result{})
+			case checkTargetFrozen:
+				env.tmc.expectVRQuery(tabletID, rsSelectFrozenQuery, sqltypes.MakeTestResult(sqltypes.MakeTestFields("1", "int64"), "1"))
+			}
+		}
+	}
+
+	// Without the option the frozen streams block the reshard.
+	expectValidation(true)
+	err := env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, false, false, false, ReshardOptions{})
+	require.ErrorContains(t, err, "found previous frozen workflow on tablet 200")
+	env.tmc.verifyQueries(t)
+
+	opts := ReshardOptions{IgnoreFrozenTargetStreams: true}
+	expectValidation(false)
+	env.tmc.expectVRQuery(200, "select id, workflow, message from _vt.vreplication where db_name='vt_ks'", frozenStreams)
+	env.expectNoRefStream()
+	env.tmc.expectVRQuery(200, insertPrefix, &sqltypes.Result{})
+	err = env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, false, false, false, opts)
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+	require.Contains(t, logger.String(), "Ignoring frozen streams on target shard 0: 1 (workflow oldWorkflow), 2 (workflow oldWorkflow)")
+
+	// Streams that aren't frozen still block the reshard.
+	expectValidation(false)
+	env.tmc.expectVRQuery(200, "select id, workflow, message from _vt.vreplication where db_name='vt_ks'",
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("id|workflow|message", "int64|varchar|varchar"),
+			"1|oldWorkflow|FROZEN",
+			"2|otherWorkflow|",
+		))
+	err = env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, false, false, false, opts)
+	require.ErrorContains(t, err, "some streams already exist in the target shards")
+	env.tmc.verifyQueries(t)
+}
+
+// TestResharderIgnoreFrozenTargetStreamsAutoStart tests that starting the
+// streams of a reshard leaves the frozen streams on the targets alone.
+func TestResharderIgnoreFrozenTargetStreamsAutoStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"-80", "80-"}, []string{"0"})
+	defer env.close()
+	opts := ReshardOptions{IgnoreFrozenTargetStreams: true}
+
+	for _, tabletID := range []int{100, 110, 200} {
+		env.tmc.expectVRQuery(tabletID, "select 1 from _vt.vreplication where db_name='vt_ks' and workflow='resharderTest'", &sqltypes.Result{})
+		if tabletID < 200 {
+			env.tmc.expectVRQuery(tabletID, rsSelectFrozenQuery, &sqltypes.Result{})
+		}
+	}
+	env.tmc.expectVRQuery(200, "select id, workflow, message from _vt.vreplication where db_name='vt_ks'",
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("id|workflow|message", "int64|varchar|varchar"),
+			"1|oldWorkflow|FROZEN",
+		))
+	env.expectNoRefStream()
+	env.tmc.expectVRQuery(200, insertPrefix, &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest') and message != 'FROZEN' and id not in (1)", &sqltypes.Result{})
+	err := env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, opts)
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+}
+
+func TestResharderIgnoreTargetWorkflows(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"-80", "80-"}, []string{"0"})
+	defer env.close()
+	logger := logutil.NewMemoryLogger()
+	env.wr.SetLogger(logger)
+	opts := ReshardOptions{IgnoreTargetWorkflows: []string{"keptWorkflow"}}
+
+	env.tmc.expectVRQuery(100, fmt.Sprintf("select 1 from _vt.vreplication where db_name='vt_%s' and workflow='%s'", env.keyspace, env.workflow), &sqltypes.Result{})
+	env.tmc.expectVRQuery(110, fmt.Sprintf("select 1 from _vt.vreplication where db_name='vt_%s' and workflow='%s'", env.keyspace, env.workflow), &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, fmt.Sprintf("select 1 from _vt.vreplication where db_name='vt_%s' and workflow='%s'", env.keyspace, env.workflow), &sqltypes.Result{})
+	env.tmc.expectVRQuery(100, rsSelectFrozenQuery, &sqltypes.Result{})
+	env.tmc.expectVRQuery(110, rsSelectFrozenQuery, &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, rsSelectFrozenQuery, &sqltypes.Result{})
+	// The stopped stream of keptWorkflow isn't started with the streams of
+	// the reshard.
+	env.tmc.expectVRQuery(200, "select id, workflow, message from _vt.vreplication where db_name='vt_ks'",
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("id|workflow|message", "int64|varchar|varchar"),
+			"1|keptWorkflow|",
+		))
+	env.expectNoRefStream()
+	env.tmc.expectVRQuery(200, insertPrefix, &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest') and message != 'FROZEN' and id not in (1)", &sqltypes.Result{})
+	err := env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, opts)
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+	require.Contains(t, logger.String(), "Ignoring streams of unrelated workflows on target shard 0: 1 (workflow keptWorkflow)")
+
+	// The streams of the other workflows still block the reshard.
+	env.tmc.expectVRQuery(100, fmt.Sprintf("select 1 from _vt.vreplication where db_name='vt_%s' and workflow='%s'", env.keyspace, env.workflow), &sqltypes.Result{})
+	env.tmc.expectVRQuery(110, fmt.Sprintf("select 1 from _vt.vreplication where db_name='vt_%s' and workflow='%s'", env.keyspace, env.workflow), &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, fmt.Sprintf("select 1 from _vt.vreplication where db_name='vt_%s' and workflow='%s'", env.keyspace, env.workflow), &sqltypes.Result{})
+	env.tmc.expectVRQuery(100, rsSelectFrozenQuery, &sqltypes.Result{})
+	env.tmc.expectVRQuery(110, rsSelectFrozenQuery, &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, rsSelectFrozenQuery, &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, "select id, workflow, message from _vt.vreplication where db_name='vt_ks'",
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("id|workflow|message", "int64|varchar|varchar"),
+			"1|keptWorkflow|",
+			"2|otherWorkflow|",
+		))
+	err = env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, false, false, false, opts)
+	var notEmptyErr *ErrTargetNotEmpty
+	require.ErrorAs(t, err, &notEmptyErr)
+	assert.Equal(t, []string{"0"}, notEmptyErr.Shards)
+	env.tmc.verifyQueries(t)
+}
+
+type testReshardEventSink struct {
+	events []*ReshardEvent
+}
+
+func (sink *testReshardEventSink) EmitReshardEvent(ctx context.Context, event *ReshardEvent) {
+	sink.events = append(sink.events, event)
+}
+
+func TestResharderEventSink(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"-80", "80-"}, []string{"0"})
+	defer env.close()
+
+	sink := &testReshardEventSink{}
+	opts := ReshardOptions{EventSink: sink, Actor: "alice"}
+
+	env.expectValidation()
+	env.expectNoRefStream()
+	env.tmc.expectVRQuery(200, insertPrefix, &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest') and message != 'FROZEN'", &sqltypes.Result{})
+
+	err := env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, opts)
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+
+	type phaseOutcome struct{ phase, outcome string }
+	var got []phaseOutcome
+	for _, event := range sink.events {
+		require.Equal(t, env.keyspace, event.Keyspace)
+		require.Equal(t, env.workflow, event.Workflow)
+		require.Equal(t, "alice", event.Actor)
+		require.Equal(t, 2, event.SourceShards)
+		require.Equal(t, 1, event.TargetShards)
+		require.Empty(t, event.Error)
+		got = append(got, phaseOutcome{event.Phase, event.Outcome})
+	}
+	require.Equal(t, []phaseOutcome{
+		{"createStreams", ReshardEventStarted},
+		{"createStreams", ReshardEventSucceeded},
+		{"startStreams", ReshardEventStarted},
+		{"startStreams", ReshardEventSucceeded},
+	}, got)
+
+	// The failure of a phase is reported in its final event.
+	sink.events = nil
+	env.expectValidation()
+	env.expectNoRefStream()
+
+	err = env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, true, false, false, opts)
+	require.ErrorContains(t, err, "createStreams")
+	env.tmc.verifyQueries(t)
+	require.Len(t, sink.events, 2)
+	require.Equal(t, ReshardEventFailed, sink.events[1].Outcome)
+	require.Contains(t, sink.events[1].Error, "does not expect any more queries")
+}
+
+func TestResharderShardProgress(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+	env.tmc.schema = &tabletmanagerdatapb.SchemaDefinition{
+		TableDefinitions: []*tabletmanagerdatapb.TableDefinition{{
+			Name:              "t1",
+			Columns:           []string{"c1", "c2"},
+			PrimaryKeyColumns: []string{"c1"},
+			Fields:            sqltypes.MakeTestFields("c1|c2", "int64|int64"),
+		}},
+	}
+
+	var mu sync.Mutex
+	progress := make(map[string][]string)
+	opts := ReshardOptions{
+		OnShardProgress: func(shard, phase string) {
+			mu.Lock()
+			defer mu.Unlock()
+			progress[shard] = append(progress[shard], phase)
+		},
+	}
+
+	env.expectValidation()
+	env.expectNoRefStream()
+	env.tmc.expectVRQuery(200, insertPrefix, &sqltypes.Result{})
+	env.tmc.expectVRQuery(210, insertPrefix, &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest') and message != 'FROZEN'", &sqltypes.Result{})
+	env.tmc.expectVRQuery(210, "update _vt.vreplication set state='Running' where db_name='vt_ks' and workflow in ('resharderTest') and message != 'FROZEN'", &sqltypes.Result{})
+
+	err := env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, false, "", "", defaultOnDDL, true, false, false, opts)
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+
+	want := []string{
+		ReshardProgressCopySchema, ReshardProgressCopySchema,
+		ReshardProgressCreateStreams, ReshardProgressCreateStreams,
+		ReshardProgressStartStreams, ReshardProgressStartStreams,
+	}
+	require.Equal(t, map[string][]string{"-80": want, "80-": want}, progress)
+}
+
+func TestResharderOnStreamsInsert(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+
+	var mu sync.Mutex
+	inserts := make(map[string][]string)
+	opts := ReshardOptions{
+		OnStreamsInsert: func(shard, query string) {
+			mu.Lock()
+			defer mu.Unlock()
+			inserts[shard] = append(inserts[shard], query)
+		},
+	}
+
+	env.expectValidation()
+	env.expectNoRefStream()
+	env.tmc.expectVRQuery(200, insertPrefix, &sqltypes.Result{})
+	env.tmc.expectVRQuery(210, insertPrefix, &sqltypes.Result{})
+
+	err := env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, false, false, false, opts)
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+
+	require.Len(t, inserts, 2)
+	for shard, queries := range inserts {
+		require.Len(t, queries, 1)
+		require.Regexp(t, insertPrefix[1:]+`.*filter:\\"`+shard+`\\"`, queries[0])
+	}
+}
+
+func TestReshardRouting(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"-40", "40-"}, []string{"-80", "80-"})
+	defer env.close()
+
+	_, err := env.wr.GetReshardRouting(ctx, env.keyspace, env.workflow)
+	require.EqualError(t, err, "no routing found for reshard resharderTest in keyspace ks")
+
+	env.expectValidation()
+	env.expectNoRefStream()
+	env.tmc.expectVRQuery(200, insertPrefix, &sqltypes.Result{})
+	env.tmc.expectVRQuery(210, insertPrefix, &sqltypes.Result{})
+
+	err = env.wr.Reshard(ctx, env.keyspace, env.workflow, env.sources, env.targets, true, "", "", defaultOnDDL, false, false, false, ReshardOptions{})
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+
+	routing, err := env.wr.GetReshardRouting(ctx, env.keyspace, env.workflow)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{
+		"-80": {"-40", "40-"},
+		"80-": {"40-"},
+	}, routing)
+}
+
+func TestQuiesceReshardSources(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"-80", "80-"}, []string{"0"})
+	defer env.close()
+	env.tmc.primaryPositions[100] = "MySQL56/14b68925-696a-11ea-aee7-fec597a91f5e:1-10"
+	env.tmc.primaryPositions[110] = "MySQL56/14b68925-696a-11ea-aee7-fec597a91f5e:1-20"
+
+	selectQuery := "select id, source from _vt.vreplication where db_name='vt_ks' and workflow='resharderTest'"
+	expectStreams := func() {
+		env.tmc.expectVRQuery(100, selectQuery, &sqltypes.Result{})
+		env.tmc.expectVRQuery(110, selectQuery, &sqltypes.Result{})
+		env.tmc.expectVRQuery(200, selectQuery, sqltypes.MakeTestResult(sqltypes.MakeTestFields("id|source", "int64|varchar"),
+			`1|keyspace:"ks" shard:"-80"`,
+			`2|keyspace:"ks" shard:"80-"`,
+		))
+	}
+
+	expectStreams()
+	positions, err := env.wr.QuiesceReshardSources(ctx, env.keyspace, env.workflow, time.Second)
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+	require.Equal(t, map[string]string{
+		"-80": env.tmc.primaryPositions[100],
+		"80-": env.tmc.primaryPositions[110],
+	}, positions)
+	require.Equal(t, map[int32]string{1: positions["-80"], 2: positions["80-"]}, env.tmc.waitedForPos)
+	require.Equal(t, map[int]bool{100: true, 110: true}, env.tmc.readOnly)
+
+	expectStreams()
+	require.NoError(t, env.wr.UnquiesceReshardSources(ctx, env.keyspace, env.workflow))
+	env.tmc.verifyQueries(t)
+	require.Equal(t, map[int]bool{100: false, 110: false}, env.tmc.readOnly)
+
+	// The sources are made read-write again if the targets don't catch up.
+	env.tmc.waitForPosErr = fmt.Errorf("context deadline exceeded")
+	expectStreams()
+	_, err = env.wr.QuiesceReshardSources(ctx, env.keyspace, env.workflow, time.Second)
+	require.ErrorContains(t, err, "stream 1 on cell-0000000200 did not catch up with source shard -80")
+	env.tmc.verifyQueries(t)
+	require.Equal(t, map[int]bool{100: false, 110: false}, env.tmc.readOnly)
+}
+
+func TestReshardPreflightReadOnly(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"-80", "80-"}, []string{"0"})
+	defer env.close()
+	params := &ReshardParams{
+		Keyspace: env.keyspace,
+		Workflow: env.workflow,
+		Sources:  env.sources,
+		Targets:  env.targets,
+	}
+
+	env.expectValidation()
+	env.expectNoRefStream()
+	report, err := env.wr.ReshardPreflightReadOnly(ctx, params)
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+	require.Equal(t, &ReshardPreflightReport{
+		Keyspace: env.keyspace,
+		Workflow: env.workflow,
+		Ready:    true,
+		Sources:  []string{"-80", "80-"},
+		Targets:  []string{"0"},
+		Routing:  map[string][]string{"0": {"-80", "80-"}},
+	}, report)
+	_, err = env.wr.GetReshardStage(ctx, env.keyspace, env.workflow)
+	require.Error(t, err, "the preflight must not create the reshard")
+
+	// The target shard already has streams.
+	for _, tablet := range env.tablets {
+		tabletID := int(tablet.Alias.Uid)
+		env.tmc.expectVRQuery(tabletID, "select 1 from _vt.vreplication where db_name='vt_ks' and workflow='resharderTest'", &sqltypes.Result{})
+		env.tmc.expectVRQuery(tabletID, rsSelectFrozenQuery, &sqltypes.Result{})
+	}
+	env.tmc.expectVRQuery(200, "select 1 from _vt.vreplication where db_name='vt_ks'", sqltypes.MakeTestResult(sqltypes.MakeTestFields("1", "int64"), "1"))
+	report, err = env.wr.ReshardPreflightReadOnly(ctx, params)
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+	require.False(t, report.Ready)
+	require.Len(t, report.Problems, 1)
+	require.Contains(t, report.Problems[0], "validateTargets")
+
+	// The tablet manager client of the preflight only runs reads.
+	tmc := &readOnlyTMClient{tmc: env.tmc}
+	tablet := env.tablets[200]
+	_, err = tmc.VReplicationExec(ctx, tablet, "update _vt.vreplication set state='Running'")
+	require.ErrorContains(t, err, "is not allowed in a read-only operation")
+	_, err = tmc.ExecuteFetchAsDba(ctx, tablet, false, &tabletmanagerdatapb.ExecuteFetchAsDbaRequest{Query: []byte("select 1")})
+	require.ErrorContains(t, err, "ExecuteFetchAsDba is not allowed in a read-only operation")
+	_, err = tmc.ReadVReplicationWorkflow(ctx, tablet, &tabletmanagerdatapb.ReadVReplicationWorkflowRequest{Workflow: env.workflow})
+	require.ErrorContains(t, err, "ReadVReplicationWorkflow is not allowed in a read-only operation")
+	require.ErrorContains(t, tmc.SetReadOnly(ctx, tablet), "SetReadOnly is not allowed in a read-only operation")
+	_, err = tmc.FullStatus(ctx, tablet)
+	require.NoError(t, err)
+}
+
+func TestResharderForAllConcurrency(t *testing.T) {
+	rs := &resharder{shardConcurrency: 2, sem: semaphore.NewWeighted(2)}
+	var shards []*topo.ShardInfo
+	for i := 0; i < 6; i++ {
+		shards = append(shards, topo.NewShardInfo("ks", fmt.Sprintf("%d", i), &topodatapb.Shard{}, nil))
+	}
+
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	err := rs.forAll(context.Background(), shards, func(si *topo.ShardInfo) error {
+		mu.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		if si.ShardName() == "1" || si.ShardName() == "4" {
+			return fmt.Errorf("shard %s failed", si.ShardName())
+		}
+		return nil
+	})
+	require.Equal(t, 2, maxRunning)
+	require.ErrorContains(t, err, "shard 1 failed")
+	require.ErrorContains(t, err, "shard 4 failed")
+}
+
+func TestResharderForAllCanceled(t *testing.T) {
+	rs := &resharder{shardConcurrency: 1, sem: semaphore.NewWeighted(1)}
+	var shards []*topo.ShardInfo
+	for i := 0; i < 3; i++ {
+		shards = append(shards, topo.NewShardInfo("ks", fmt.Sprintf("%d", i), &topodatapb.Shard{}, nil))
+	}
+
+	// The first shard cancels the context while it holds the only slot, so
+	// the other shards never start.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var started []string
+	err := rs.forAll(ctx, shards, func(si *topo.ShardInfo) error {
+		started = append(started, si.ShardName())
+		cancel()
+		return nil
+	})
+	require.Equal(t, []string{"0"}, started)
+	require.ErrorContains(t, err, "shard ks/1 not started: context canceled")
+	require.ErrorContains(t, err, "shard ks/2 not started")
+}
+
+func TestResharderIncludeReferenceTables(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+	opts := ReshardOptions{IncludeReferenceTables: []string{"t1"}}
+
+	vs := &vschemapb.Keyspace{
+		Tables: map[string]*vschemapb.Table{
+			"t1": {
+				Type: vindexes.TypeReference,
+			},
+			"t2": {
+				Type: vindexes.TypeReference,
+			},
+		},
+	}
+	require.NoError(t, env.wr.ts.SaveVSchema(ctx, env.keyspace, vs))
+
+	for _, tabletID := range []int{200, 210} {
+		env.tmc.expectVRQuery(tabletID, "select 1 from _vt.vreplication where db_name='vt_ks'", &sqltypes.Result{})
+	}
+	// The stream of t1 is not a reference stream since t1 is copied like a
+	// sharded table.
+	bls := &binlogdatapb.BinlogSource{
+		Keyspace: "ks1",
+		Shard:    "0",
+		Filter: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match: "t1",
+			}},
+		},
+	}
+	result := sqltypes.MakeTestResult(sqltypes.MakeTestFields(
+		"workflow|source|cell|tablet_types|workflow_type|workflow_sub_type",
+		"varchar|varchar|varchar|varchar|int64|int64"),
+		fmt.Sprintf("t1|%v|cell1|primary,replica|4|0", bls),
+	)
+	env.tmc.expectVRQuery(100, "select workflow, source, cell, tablet_types, workflow_type, workflow_sub_type from _vt.vreplication where db_name='vt_ks' and message != 'FROZEN'", result)
+
+	plan, err := env.wr.PlanReshardStreams(ctx, env.keyspace, env.workflow, env.sources, env.targets, "", "", defaultOnDDL, false, opts)
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+
+	for _, target := range env.targets {
+		require.Len(t, plan[target], 1)
+		require.Equal(t, []*binlogdatapb.Rule{
+			{Match: "t2", Filter: "exclude"},
+			{Match: "/.*", Filter: target},
+		}, plan[target][0].Filter.Rules)
+	}
+}
+
+func TestResharderExecRetries(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+	opts := ReshardOptions{ExecRetries: 2, ExecRetryDelay: time.Millisecond}
+
+	query := "select 1 from _vt.vreplication where db_name='vt_ks'"
+	unavailable := vterrors.New(vtrpcpb.Code_UNAVAILABLE, "connection refused")
+
+	// Transient errors are retried.
+	env.tmc.expectVRQueryError(200, query, unavailable)
+	env.tmc.expectVRQueryError(200, query, vterrors.New(vtrpcpb.Code_DEADLINE_EXCEEDED, "timeout"))
+	env.tmc.expectVRQuery(200, query, &sqltypes.Result{})
+	env.tmc.expectVRQuery(210, query, &sqltypes.Result{})
+	env.expectNoRefStream()
+	_, err := env.wr.buildResharder(ctx, env.keyspace, env.workflow, env.sources, env.targets, "", "", opts)
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+
+	// Up to ExecRetries times.
+	for i := 0; i < 3; i++ {
+		env.tmc.expectVRQueryError(200, query, unavailable)
+	}
+	env.tmc.expectVRQuery(210, query, &sqltypes.Result{})
+	_, err = env.wr.buildResharder(ctx, env.keyspace, env.workflow, env.sources, env.targets, "", "", opts)
+	require.ErrorContains(t, err, "connection refused")
+	env.tmc.verifyQueries(t)
+
+	// Other errors are not retried.
+	env.tmc.expectVRQueryError(200, query, vterrors.New(vtrpcpb.Code_FAILED_PRECONDITION, "bad state"))
+	env.tmc.expectVRQuery(210, query, &sqltypes.Result{})
+	_, err = env.wr.buildResharder(ctx, env.keyspace, env.workflow, env.sources, env.targets, "", "", opts)
+	require.ErrorContains(t, err, "bad state")
+	env.tmc.verifyQueries(t)
+}
+
+func TestReshardReferenceStreams(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"-80", "80-"}, []string{"-40", "40-"})
+	defer env.close()
+
+	vs := &vschemapb.Keyspace{
+		Tables: map[string]*vschemapb.Table{
+			"t1": {
+				Type: vindexes.TypeReference,
+			},
+			"t2": {
+				Type: vindexes.TypeReference,
+			},
+		},
+	}
+	require.NoError(t, env.wr.ts.SaveVSchema(ctx, env.keyspace, vs))
+
+	for _, tabletID := range []int{200, 210} {
+		env.tmc.expectVRQuery(tabletID, "select 1 from _vt.vreplication where db_name='vt_ks'", &sqltypes.Result{})
+	}
+	bls := func(keyspace, table string) *binlogdatapb.BinlogSource {
+		return &binlogdatapb.BinlogSource{
+			Keyspace: keyspace,
+			Shard:    "0",
+			Filter: &binlogdatapb.Filter{
+				Rules: []*binlogdatapb.Rule{{
+					Match: table,
+				}},
+			},
+		}
+	}
+	for _, tabletID := range []int{100, 110} {
+		result := sqltypes.MakeTestResult(sqltypes.MakeTestFields(
+			"workflow|source|cell|tablet_types|workflow_type|workflow_sub_type",
+			"varchar|varchar|varchar|varchar|int64|int64"),
+			fmt.Sprintf("t2|%v|cell2|replica|4|0", bls("ks2", "t2")),
+			fmt.Sprintf("t1|%v|cell1|primary,replica|4|0", bls("ks1", "t1")),
+		)
+		env.tmc.expectVRQuery(tabletID, "select workflow, source, cell, tablet_types, workflow_type, workflow_sub_type from _vt.vreplication where db_name='vt_ks' and message != 'FROZEN'", result)
+	}
+
+	streams, err := env.wr.ReshardReferenceStreams(ctx, env.keyspace, env.workflow, env.sources, env.targets, ReshardOptions{})
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+	require.Equal(t, []ReferenceStreamInfo{{
+		Workflow:       "t1",
+		SourceKeyspace: "ks1",
+		SourceShard:    "0",
+		Cell:           "cell1",
+		TabletTypes:    "primary,replica",
+	}, {
+		Workflow:       "t2",
+		SourceKeyspace: "ks2",
+		SourceShard:    "0",
+		Cell:           "cell2",
+		TabletTypes:    "replica",
+	}}, streams)
+}
+
+func TestResharderCopySchemaTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+
+	for _, tc := range []struct {
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{timeout: 0, want: defaultReshardCopySchemaTimeout},
+		{timeout: 5 * time.Minute, want: 5 * time.Minute},
+	} {
+		opts := ReshardOptions{CopySchemaTimeout: tc.timeout}
+		for _, tablet := range []int{200, 210} {
+			env.tmc.expectVRQuery(tablet, "select 1 from _vt.vreplication where db_name='vt_ks'", &sqltypes.Result{})
+		}
+		env.expectNoRefStream()
+		rs, err := env.wr.buildResharder(ctx, env.keyspace, env.workflow, env.sources, env.targets, "", "", opts)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, rs.copySchemaTimeout)
+	}
+	env.tmc.verifyQueries(t)
+}
+
+func TestPlanReshardStreams(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"-80", "80-"}, []string{"-40", "40-"})
+	defer env.close()
+
+	vs := &vschemapb.Keyspace{
+		Tables: map[string]*vschemapb.Table{
+			"t1": {
+				Type: vindexes.TypeReference,
+			},
+		},
+	}
+	require.NoError(t, env.wr.ts.SaveVSchema(ctx, env.keyspace, vs))
+
+	// PlanReshardStreams only runs the validations of buildResharder.
+	for _, tabletID := range []int{200, 210} {
+		env.tmc.expectVRQuery(tabletID, "select 1 from _vt.vreplication where db_name='vt_ks'", &sqltypes.Result{})
+	}
+	env.expectNoRefStream()
+	plan, err := env.wr.PlanReshardStreams(ctx, env.keyspace, env.workflow, env.sources, env.targets, "cell", "replica", "EXEC", true, ReshardOptions{})
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+
+	source := func(shard, keyRange string) *binlogdatapb.BinlogSource {
+		return &binlogdatapb.BinlogSource{
+			Keyspace: "ks",
+			Shard:    shard,
+			Filter: &binlogdatapb.Filter{
+				Rules: []*binlogdatapb.Rule{
+					{Match: "t1", Filter: "exclude"},
+					{Match: "/.*", Filter: keyRange},
+				},
+			},
+			StopAfterCopy: true,
+			OnDdl:         binlogdatapb.OnDDLAction_EXEC,
+		}
+	}
+	want := ReshardStreamPlan{
+		"-40": {source("-80", "-40")},
+		"40-": {source("-80", "40-"), source("80-", "40-")},
+	}
+	require.Len(t, plan, len(want))
+	for target, streams := range want {
+		require.Len(t, plan[target], len(streams))
+		for i, bls := range streams {
+			require.True(t, proto.Equal(bls, plan[target][i]), "target %s stream %d: got %v, want %v", target, i, plan[target][i], bls)
+		}
+	}
+}
+
+// TestPlanReshardStreamsWithRefStream tests that the plan of a reshard that
+// copies a reference stream passes VerifyReshardStreams, which only reads
+// the streams of the workflow.
+func TestPlanReshardStreamsWithRefStream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+
+	vs := &vschemapb.Keyspace{
+		Tables: map[string]*vschemapb.Table{
+			"t1": {
+				Type: vindexes.TypeReference,
+			},
+		},
+	}
+	require.NoError(t, env.wr.ts.SaveVSchema(ctx, env.keyspace, vs))
+
+	for _, tabletID := range []int{200, 210} {
+		env.tmc.expectVRQuery(tabletID, "select 1 from _vt.vreplication where db_name='vt_ks'", &sqltypes.Result{})
+	}
+	refBls := &binlogdatapb.BinlogSource{
+		Keyspace: "ks1",
+		Shard:    "0",
+		Filter: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match: "t1",
+			}},
+		},
+	}
+	env.tmc.expectVRQuery(100, "select workflow, source, cell, tablet_types, workflow_type, workflow_sub_type from _vt.vreplication where db_name='vt_ks' and message != 'FROZEN'",
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields(
+			"workflow|source|cell|tablet_types|workflow_type|workflow_sub_type",
+			"varchar|varchar|varchar|varchar|int64|int64"),
+			fmt.Sprintf("t1|%v|cell1|primary,replica|4|0", refBls),
+		))
+	plan, err := env.wr.PlanReshardStreams(ctx, env.keyspace, env.workflow, env.sources, env.targets, "", "", defaultOnDDL, false, ReshardOptions{})
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+
+	fields := sqltypes.MakeTestFields("id|source", "int64|varchar")
+	query := "select id, source from _vt.vreplication where db_name='vt_ks' and workflow='resharderTest'"
+	for i, target := range env.targets {
+		require.Len(t, plan[target], 1)
+		env.tmc.expectVRQuery(200+10*i, query, sqltypes.MakeTestResult(fields, fmt.Sprintf("1|%v", plan[target][0])))
+	}
+	mismatches, err := env.wr.VerifyReshardStreams(ctx, env.keyspace, env.workflow, plan, ReshardOptions{})
+	require.NoError(t, err)
+	require.Empty(t, mismatches)
+	env.tmc.verifyQueries(t)
+}
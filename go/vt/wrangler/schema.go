@@ -201,6 +201,15 @@ func (wr *Wrangler) CopySchemaShardFromShard(ctx context.Context, tables, exclud
 // the destination shard, and is propagated to the replicas through
 // binlogs.
 func (wr *Wrangler) CopySchemaShard(ctx context.Context, sourceTabletAlias *topodatapb.TabletAlias, tables, excludeTables []string, includeViews bool, destKeyspace, destShard string, waitReplicasTimeout time.Duration, skipVerify bool) error {
+	return wr.CopySchemaShardWithProgress(ctx, sourceTabletAlias, tables, excludeTables, includeViews, destKeyspace, destShard, waitReplicasTimeout, skipVerify, nil)
+}
+
+// CopySchemaShardWithProgress is like CopySchemaShard, but calls progress
+// with the number of tables and views copied so far and their total after
+// each one is created on the destination. It stops before creating the next
+// table once ctx is done, so a deadline on ctx bounds the whole copy.
+func (wr *Wrangler) CopySchemaShardWithProgress(ctx context.Context, sourceTabletAlias *topodatapb.TabletAlias, tables, excludeTables []string, includeViews bool, destKeyspace, destShard string, waitReplicasTimeout time.Duration, skipVerify bool, progress func(copied, total int)) error {
+	defer wr.recordAction("CopySchemaShard", time.Now())
 	destShardInfo, err := wr.ts.GetShard(ctx, destKeyspace, destShard)
 	if err != nil {
 		return fmt.Errorf("GetShard(%v, %v) failed: %v", destKeyspace, destShard, err)
@@ -231,7 +240,13 @@ func (wr *Wrangler) CopySchemaShard(ctx context.Context, sourceTabletAlias *topo
 	if err != nil {
 		return fmt.Errorf("GetTablet(%v) failed: %v", destShardInfo.PrimaryAlias, err)
 	}
-	for _, createSQL := range createSQLstmts {
+	// The first statement creates the database, the others the tables and
+	// views.
+	total := len(createSQLstmts) - 1
+	for i, createSQL := range createSQLstmts {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("CopySchemaShard interrupted after copying %d/%d tables: %w", max(i-1, 0), total, err)
+		}
 		err = wr.applySQLShard(ctx, destTabletInfo, createSQL)
 		if err != nil {
 			return fmt.Errorf("creating a table failed."+
@@ -239,6 +254,9 @@ func (wr *Wrangler) CopySchemaShard(ctx context.Context, sourceTabletAlias *topo
 				" Please remove all to be copied tables from the destination manually and run this command again."+
 				" Full error: %v", err)
 		}
+		if progress != nil && i > 0 {
+			progress(i, total)
+		}
 	}
 
 	// Remember the replication position after all the above were applied.
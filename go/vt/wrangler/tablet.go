@@ -25,8 +25,11 @@ import (
 	"vitess.io/vitess/go/vt/topo/topoproto"
 	"vitess.io/vitess/go/vt/topotools"
 	"vitess.io/vitess/go/vt/vtctl/reparentutil"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vttablet/tabletmanager/vreplication"
 
 	querypb "vitess.io/vitess/go/vt/proto/query"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
 )
@@ -205,6 +208,49 @@ func (wr *Wrangler) VReplicationExec(ctx context.Context, tabletAlias *topodatap
 	return wr.tmc.VReplicationExec(ctx, ti.Tablet, query)
 }
 
+// RestartVReplicationStreams makes the tablet reload its DB credentials and
+// restarts its failed VReplication streams, which then connect to MySQL
+// again with the reloaded credentials, e.g. after they were rotated. Only
+// the streams in the Error state are restarted, and they keep their message
+// until they report a new one. It returns the number of restarted streams.
+func (wr *Wrangler) RestartVReplicationStreams(ctx context.Context, tabletAlias *topodatapb.TabletAlias) (int, error) {
+	ti, err := wr.ts.GetTablet(ctx, tabletAlias)
+	if err != nil {
+		return 0, err
+	}
+	if err := wr.tmc.RefreshState(ctx, ti.Tablet); err != nil {
+		return 0, vterrors.Wrapf(err, "RefreshState(%v)", topoproto.TabletAliasString(tabletAlias))
+	}
+	// The tablet selects the failed streams and restarts them in a single
+	// statement, so a stream that recovers or fails in the meantime is
+	// handled consistently with its state at that point.
+	query := fmt.Sprintf("update /*vt+ %s */ _vt.vreplication set state='Running' where db_name=%s and state='Error'",
+		vreplication.AllowUnsafeWriteCommentDirective, encodeString(ti.DbName()))
+	qr, err := wr.tmc.VReplicationExec(ctx, ti.Tablet, query)
+	if err != nil {
+		return 0, vterrors.Wrapf(err, "VReplicationExec(%v, %s)", topoproto.TabletAliasString(tabletAlias), query)
+	}
+	return int(qr.RowsAffected), nil
+}
+
+// ReloadTabletSchema makes the tablet reload its schema cache, which
+// VReplication relies on to classify the tables of a workflow, and returns
+// the number of tables in the reloaded schema.
+func (wr *Wrangler) ReloadTabletSchema(ctx context.Context, tabletAlias *topodatapb.TabletAlias) (int, error) {
+	ti, err := wr.ts.GetTablet(ctx, tabletAlias)
+	if err != nil {
+		return 0, err
+	}
+	if err := wr.tmc.ReloadSchema(ctx, ti.Tablet, ""); err != nil {
+		return 0, vterrors.Wrapf(err, "ReloadSchema(%v)", topoproto.TabletAliasString(tabletAlias))
+	}
+	sd, err := wr.tmc.GetSchema(ctx, ti.Tablet, &tabletmanagerdatapb.GetSchemaRequest{TableSchemaOnly: true})
+	if err != nil {
+		return 0, vterrors.Wrapf(err, "GetSchema(%v)", topoproto.TabletAliasString(tabletAlias))
+	}
+	return len(sd.TableDefinitions), nil
+}
+
 // isPrimaryTablet is a shortcut way to determine whether the current tablet
 // is a primary before we allow its tablet record to be deleted. The canonical
 // way to determine the only true primary in a shard is to list all the tablets
@@ -21,8 +21,12 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
 	"vitess.io/vitess/go/mysql/collations"
+	"vitess.io/vitess/go/sqltypes"
 	"vitess.io/vitess/go/vt/logutil"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 	"vitess.io/vitess/go/vt/sqlparser"
 	"vitess.io/vitess/go/vt/topo"
@@ -241,3 +245,44 @@ func TestDeleteTabletShardNonExisting(t *testing.T) {
 		t.Fatalf("DeleteTablet failed: %v", err)
 	}
 }
+
+func TestRestartVReplicationStreams(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+	alias := &topodatapb.TabletAlias{Cell: "cell", Uid: 200}
+	updateQuery := "update /*vt+ ALLOW_UNSAFE_VREPLICATION_WRITE */ _vt.vreplication set state='Running' where db_name='vt_ks' and state='Error'"
+
+	env.tmc.expectVRQuery(200, updateQuery, &sqltypes.Result{RowsAffected: 2})
+	restarted, err := env.wr.RestartVReplicationStreams(ctx, alias)
+	require.NoError(t, err)
+	require.Equal(t, 2, restarted)
+	require.Equal(t, []int{200}, env.tmc.refreshedStates)
+	env.tmc.verifyQueries(t)
+
+	env.tmc.expectVRQuery(200, updateQuery, &sqltypes.Result{})
+	restarted, err = env.wr.RestartVReplicationStreams(ctx, alias)
+	require.NoError(t, err)
+	require.Zero(t, restarted)
+	require.Equal(t, []int{200, 200}, env.tmc.refreshedStates)
+	env.tmc.verifyQueries(t)
+}
+
+func TestReloadTabletSchema(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+	env.tmc.schema = &tabletmanagerdatapb.SchemaDefinition{
+		TableDefinitions: []*tabletmanagerdatapb.TableDefinition{{Name: "t1"}, {Name: "t2"}},
+	}
+
+	tables, err := env.wr.ReloadTabletSchema(ctx, &topodatapb.TabletAlias{Cell: "cell", Uid: 210})
+	require.NoError(t, err)
+	require.Equal(t, 2, tables)
+	require.Equal(t, []int{210}, env.tmc.reloadedSchemas)
+
+	_, err = env.wr.ReloadTabletSchema(ctx, &topodatapb.TabletAlias{Cell: "cell", Uid: 999})
+	require.Error(t, err)
+}
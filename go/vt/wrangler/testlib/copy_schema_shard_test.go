@@ -19,6 +19,7 @@ package testlib
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
@@ -40,14 +41,18 @@ import (
 )
 
 func TestCopySchemaShard_UseTabletAsSource(t *testing.T) {
-	copySchema(t, false /* useShardAsSource */)
+	copySchema(t, false /* useShardAsSource */, false /* withProgress */)
 }
 
 func TestCopySchemaShard_UseShardAsSource(t *testing.T) {
-	copySchema(t, true /* useShardAsSource */)
+	copySchema(t, true /* useShardAsSource */, false /* withProgress */)
 }
 
-func copySchema(t *testing.T, useShardAsSource bool) {
+func TestCopySchemaShard_WithProgress(t *testing.T) {
+	copySchema(t, false /* useShardAsSource */, true /* withProgress */)
+}
+
+func copySchema(t *testing.T, useShardAsSource, withProgress bool) {
 	delay := discovery.GetTabletPickerRetryDelay()
 	defer func() {
 		discovery.SetTabletPickerRetryDelay(delay)
@@ -162,7 +167,18 @@ func copySchema(t *testing.T, useShardAsSource bool) {
 	// PrimaryAlias in the shard record is updated asynchronously, so we should wait for it to succeed.
 	waitForShardPrimary(t, wr, destinationPrimary.Tablet)
 
-	if err := vp.Run([]string{"CopySchemaShard", "--include-views", source, "ks/-40"}); err != nil {
+	if withProgress {
+		var progress [][2]int
+		err := wr.CopySchemaShardWithProgress(ctx, sourceRdonly.Tablet.Alias, nil, nil, true, "ks", "-40", 10*time.Second, false, func(copied, total int) {
+			progress = append(progress, [2]int{copied, total})
+		})
+		if err != nil {
+			t.Fatalf("CopySchemaShardWithProgress failed: %v", err)
+		}
+		if want := [][2]int{{1, 2}, {2, 2}}; !reflect.DeepEqual(progress, want) {
+			t.Errorf("CopySchemaShardWithProgress reported progress %v, want %v", progress, want)
+		}
+	} else if err := vp.Run([]string{"CopySchemaShard", "--include-views", source, "ks/-40"}); err != nil {
 		t.Fatalf("CopySchemaShard failed: %v", err)
 	}
 
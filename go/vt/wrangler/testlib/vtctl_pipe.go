@@ -78,7 +78,7 @@ func NewVtctlPipe(t *testing.T, ts *topo.Server) *VtctlPipe {
 
 	// Create a gRPC server and listen on the port
 	server := grpc.NewServer()
-	grpcvtctlserver.StartServer(server, ts, collations.MySQL8(), sqlparser.NewTestParser())
+	grpcvtctlserver.StartServer(server, ts, collations.MySQL8(), sqlparser.NewTestParser(), nil)
 	go server.Serve(listener)
 
 	// Create a VtctlClient gRPC client to talk to the fake server
@@ -725,6 +725,11 @@ func (wr *Wrangler) DropTargets(ctx context.Context, targetKeyspace, workflow st
 	if err := wr.dropArtifacts(ctx, keepRoutingRules, sw); err != nil {
 		return nil, err
 	}
+	if !dryRun && ts.MigrationType() == binlogdatapb.MigrationType_SHARDS {
+		if err := wr.deleteReshardMetadata(ctx, ts.TargetKeyspaceName(), ts.WorkflowName()); err != nil {
+			return nil, err
+		}
+	}
 	if err := ts.TopoServer().RebuildSrvVSchema(ctx, nil); err != nil {
 		return nil, err
 	}
@@ -852,6 +857,11 @@ func (wr *Wrangler) DropSources(ctx context.Context, targetKeyspace, workflowNam
 	if err := wr.dropArtifacts(ctx, keepRoutingRules, sw); err != nil {
 		return nil, err
 	}
+	if !dryRun && ts.MigrationType() == binlogdatapb.MigrationType_SHARDS {
+		if err := wr.deleteReshardMetadata(ctx, ts.TargetKeyspaceName(), ts.WorkflowName()); err != nil {
+			return nil, err
+		}
+	}
 	if err := ts.TopoServer().RebuildSrvVSchema(ctx, nil); err != nil {
 		return nil, err
 	}
@@ -27,7 +27,6 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
-	"golang.org/x/sync/semaphore"
 
 	"vitess.io/vitess/go/mysql/collations"
 	"vitess.io/vitess/go/mysql/fakesqldb"
@@ -122,7 +121,7 @@ func newTestTableMigraterCustom(ctx context.Context, t *testing.T, sourceShards,
 	tme := &testMigraterEnv{}
 	tme.ts = memorytopo.NewServer(ctx, "cell1", "cell2")
 	tme.wr = New(logutil.NewConsoleLogger(), tme.ts, tmclient.NewTabletManagerClient(), collations.MySQL8(), sqlparser.NewTestParser())
-	tme.wr.sem = semaphore.NewWeighted(1)
+	tme.wr.SetConcurrencyLimit(1)
 	tme.sourceShards = sourceShards
 	tme.targetShards = targetShards
 	tme.tmeDB = fakesqldb.New(t)
@@ -392,7 +391,7 @@ func newTestTablePartialMigrater(ctx context.Context, t *testing.T, shards, shar
 	tme := &testMigraterEnv{}
 	tme.ts = memorytopo.NewServer(ctx, "cell1", "cell2")
 	tme.wr = New(logutil.NewConsoleLogger(), tme.ts, tmclient.NewTabletManagerClient(), collations.MySQL8(), sqlparser.NewTestParser())
-	tme.wr.sem = semaphore.NewWeighted(1)
+	tme.wr.SetConcurrencyLimit(1)
 	tme.sourceShards = shards
 	tme.targetShards = shards
 	tme.tmeDB = fakesqldb.New(t)
@@ -559,7 +558,7 @@ func newTestShardMigrater(ctx context.Context, t *testing.T, sourceShards, targe
 	tme.targetShards = targetShards
 	tme.tmeDB = fakesqldb.New(t)
 	expectVDiffQueries(tme.tmeDB)
-	tme.wr.sem = semaphore.NewWeighted(1)
+	tme.wr.SetConcurrencyLimit(1)
 
 	tabletID := 10
 	for _, shard := range sourceShards {
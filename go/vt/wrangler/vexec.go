@@ -35,10 +35,12 @@ import (
 	"vitess.io/vitess/go/vt/binlog/binlogplayer"
 	"vitess.io/vitess/go/vt/concurrency"
 	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/mysqlctl/tmutils"
 	"vitess.io/vitess/go/vt/sqlparser"
 	"vitess.io/vitess/go/vt/topo"
 	"vitess.io/vitess/go/vt/topo/topoproto"
 	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vttablet/tabletmanager/vreplication"
 
 	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
 	querypb "vitess.io/vitess/go/vt/proto/query"
@@ -697,6 +699,267 @@ func (wr *Wrangler) getReplicationStatusFromRow(ctx context.Context, row sqltype
 	return status, bls.Keyspace, nil
 }
 
+// transactionLag returns the transaction replication lag of the stream in
+// seconds at the given time, in seconds since epoch. Streams that are still
+// copying are considered infinitely behind.
+func (status *ReplicationStatus) transactionLag(now int64) int64 {
+	if status.State == binlogdatapb.VReplicationWorkflowState_Copying.String() {
+		return math.MaxInt64
+	}
+	lastTransactionTimestamp := status.TransactionTimestamp
+	if lastTransactionTimestamp == 0 /* no new events after copy */ ||
+		status.TimeHeartbeat > lastTransactionTimestamp /* no recent transactions, so all caught up */ {
+
+		lastTransactionTimestamp = status.TimeHeartbeat
+	}
+	return now - lastTransactionTimestamp
+}
+
+// LaggingStream is a stream that keeps a workflow from being ready for
+// cutover.
+type LaggingStream struct {
+	Shard      string
+	Tablet     string
+	ID         int32
+	State      string
+	LagSeconds int64
+}
+
+// CutoverReadiness is the result of CheckCutoverReady.
+type CutoverReadiness struct {
+	Ready          bool
+	LaggingStreams []*LaggingStream
+}
+
+// CheckCutoverReady checks whether every stream of the workflow is running
+// with a transaction lag of at most maxLagSeconds, which is what switching
+// traffic requires. Streams that are not running, or are running behind,
+// are listed with their current lag.
+func (wr *Wrangler) CheckCutoverReady(ctx context.Context, keyspace, workflow string, maxLagSeconds int64) (*CutoverReadiness, error) {
+	rsr, err := wr.getStreams(ctx, workflow, keyspace, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(rsr.ShardStatuses) == 0 {
+		return nil, fmt.Errorf("no streams found for workflow %s in keyspace %s", workflow, keyspace)
+	}
+	readiness := &CutoverReadiness{Ready: true}
+	now := time.Now().Unix()
+	for _, shardStatus := range rsr.ShardStatuses {
+		for _, status := range shardStatus.PrimaryReplicationStatuses {
+			lag := status.transactionLag(now)
+			if status.State == binlogdatapb.VReplicationWorkflowState_Running.String() && lag <= maxLagSeconds {
+				continue
+			}
+			readiness.Ready = false
+			readiness.LaggingStreams = append(readiness.LaggingStreams, &LaggingStream{
+				Shard:      status.Shard,
+				Tablet:     status.Tablet,
+				ID:         status.ID,
+				State:      status.State,
+				LagSeconds: lag,
+			})
+		}
+	}
+	sort.Slice(readiness.LaggingStreams, func(i, j int) bool {
+		if readiness.LaggingStreams[i].Shard != readiness.LaggingStreams[j].Shard {
+			return readiness.LaggingStreams[i].Shard < readiness.LaggingStreams[j].Shard
+		}
+		return readiness.LaggingStreams[i].ID < readiness.LaggingStreams[j].ID
+	})
+	return readiness, nil
+}
+
+// OnDDLUpdateResult is the outcome of SetWorkflowOnDDL on one shard.
+type OnDDLUpdateResult struct {
+	Shard          string
+	Tablet         string
+	StreamsUpdated int
+}
+
+// SetWorkflowOnDDL changes the onDDL action of every stream of the workflow
+// in the keyspace, which the streams use once they are restarted by the
+// update. It returns the number of streams updated on each shard.
+func (wr *Wrangler) SetWorkflowOnDDL(ctx context.Context, keyspace, workflow, action string) ([]*OnDDLUpdateResult, error) {
+	onDDL, ok := binlogdatapb.OnDDLAction_value[strings.ToUpper(action)]
+	if !ok {
+		actions := make([]string, 0, len(binlogdatapb.OnDDLAction_value))
+		for name := range binlogdatapb.OnDDLAction_value {
+			actions = append(actions, name)
+		}
+		sort.Strings(actions)
+		return nil, fmt.Errorf("invalid onDDL action %q, valid values are: %s", action, strings.Join(actions, ", "))
+	}
+	shards, err := wr.ts.GetShardNames(ctx, keyspace)
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "GetShardNames(%s)", keyspace)
+	}
+	sort.Strings(shards)
+	var results []*OnDDLUpdateResult
+	total := 0
+	for _, shard := range shards {
+		si, err := wr.ts.GetShard(ctx, keyspace, shard)
+		if err != nil {
+			return results, vterrors.Wrapf(err, "GetShard(%s) failed", shard)
+		}
+		if si.PrimaryAlias == nil {
+			return results, fmt.Errorf("shard %v has no primary", shard)
+		}
+		primary, err := wr.ts.GetTablet(ctx, si.PrimaryAlias)
+		if err != nil {
+			return results, vterrors.Wrapf(err, "GetTablet(%s) failed", si.PrimaryAlias)
+		}
+		query := fmt.Sprintf("select id, source from _vt.vreplication where db_name=%s and workflow=%s",
+			encodeString(primary.DbName()), encodeString(workflow))
+		p3qr, err := wr.tmc.VReplicationExec(ctx, primary.Tablet, query)
+		if err != nil {
+			return results, vterrors.Wrapf(err, "VReplicationExec(%v, %s)", primary.Tablet, query)
+		}
+		result := &OnDDLUpdateResult{Shard: shard, Tablet: topoproto.TabletAliasString(si.PrimaryAlias)}
+		for _, row := range sqltypes.Proto3ToResult(p3qr).Rows {
+			id, err := row[0].ToInt64()
+			if err != nil {
+				return results, err
+			}
+			var bls binlogdatapb.BinlogSource
+			rowBytes, err := row[1].ToBytes()
+			if err != nil {
+				return results, err
+			}
+			if err := prototext.Unmarshal(rowBytes, &bls); err != nil {
+				return results, vterrors.Wrapf(err, "prototext.Unmarshal: %v", row)
+			}
+			if bls.OnDdl == binlogdatapb.OnDDLAction(onDDL) {
+				continue
+			}
+			bls.OnDdl = binlogdatapb.OnDDLAction(onDDL)
+			source, err := prototext.Marshal(&bls)
+			if err != nil {
+				return results, err
+			}
+			query := fmt.Sprintf("update _vt.vreplication set source=%s where id=%d", encodeString(string(source)), id)
+			if _, err := wr.tmc.VReplicationExec(ctx, primary.Tablet, query); err != nil {
+				return results, vterrors.Wrapf(err, "VReplicationExec(%v, %s)", primary.Tablet, query)
+			}
+			result.StreamsUpdated++
+		}
+		total += len(p3qr.Rows)
+		results = append(results, result)
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("no streams found for workflow %s in keyspace %s", workflow, keyspace)
+	}
+	return results, nil
+}
+
+// ValidateFilterCoverage checks that the filter rules of every stream of the
+// workflow match all the tables in the schema of the stream's source shard,
+// either to copy them or to explicitly exclude them. It returns the tables
+// that are not matched by any rule, by target shard, and no entry for the
+// target shards whose streams cover all their source tables.
+func (wr *Wrangler) ValidateFilterCoverage(ctx context.Context, keyspace, workflow string) (map[string][]string, error) {
+	shards, err := wr.ts.GetShardNames(ctx, keyspace)
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "GetShardNames(%s)", keyspace)
+	}
+	// sourceTables caches the tables of each source shard by keyspace/shard.
+	sourceTables := make(map[string][]string)
+	getSourceTables := func(sourceKeyspace, sourceShard string) ([]string, error) {
+		key := topoproto.KeyspaceShardString(sourceKeyspace, sourceShard)
+		if tables, ok := sourceTables[key]; ok {
+			return tables, nil
+		}
+		si, err := wr.ts.GetShard(ctx, sourceKeyspace, sourceShard)
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "GetShard(%s) failed", key)
+		}
+		if si.PrimaryAlias == nil {
+			return nil, fmt.Errorf("source shard %v has no primary", key)
+		}
+		primary, err := wr.ts.GetTablet(ctx, si.PrimaryAlias)
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "GetTablet(%s) failed", si.PrimaryAlias)
+		}
+		sd, err := wr.tmc.GetSchema(ctx, primary.Tablet, &tabletmanagerdatapb.GetSchemaRequest{TableSchemaOnly: true})
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "GetSchema(%v)", topoproto.TabletAliasString(si.PrimaryAlias))
+		}
+		var tables []string
+		for _, td := range sd.TableDefinitions {
+			if td.Type == tmutils.TableView {
+				continue
+			}
+			tables = append(tables, td.Name)
+		}
+		sourceTables[key] = tables
+		return tables, nil
+	}
+
+	uncovered := make(map[string][]string)
+	total := 0
+	for _, shard := range shards {
+		si, err := wr.ts.GetShard(ctx, keyspace, shard)
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "GetShard(%s) failed", shard)
+		}
+		if si.PrimaryAlias == nil {
+			return nil, fmt.Errorf("shard %v has no primary", shard)
+		}
+		primary, err := wr.ts.GetTablet(ctx, si.PrimaryAlias)
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "GetTablet(%s) failed", si.PrimaryAlias)
+		}
+		query := fmt.Sprintf("select source from _vt.vreplication where db_name=%s and workflow=%s",
+			encodeString(primary.DbName()), encodeString(workflow))
+		p3qr, err := wr.tmc.VReplicationExec(ctx, primary.Tablet, query)
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "VReplicationExec(%v, %s)", primary.Tablet, query)
+		}
+		missing := make(map[string]bool)
+		for _, row := range sqltypes.Proto3ToResult(p3qr).Rows {
+			var bls binlogdatapb.BinlogSource
+			rowBytes, err := row[0].ToBytes()
+			if err != nil {
+				return nil, err
+			}
+			if err := prototext.Unmarshal(rowBytes, &bls); err != nil {
+				return nil, vterrors.Wrapf(err, "prototext.Unmarshal: %v", row)
+			}
+			if bls.Filter == nil {
+				// Streams without a filter, like those of the old resharding
+				// workflows, replicate all the tables.
+				continue
+			}
+			tables, err := getSourceTables(bls.Keyspace, bls.Shard)
+			if err != nil {
+				return nil, err
+			}
+			for _, table := range tables {
+				rule, err := vreplication.MatchTable(table, bls.Filter)
+				if err != nil {
+					return nil, vterrors.Wrapf(err, "invalid filter for stream from %s", topoproto.KeyspaceShardString(bls.Keyspace, bls.Shard))
+				}
+				if rule == nil {
+					missing[table] = true
+				}
+			}
+		}
+		total += len(p3qr.Rows)
+		if len(missing) > 0 {
+			tables := make([]string, 0, len(missing))
+			for table := range missing {
+				tables = append(tables, table)
+			}
+			sort.Strings(tables)
+			uncovered[shard] = tables
+		}
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("no streams found for workflow %s in keyspace %s", workflow, keyspace)
+	}
+	return uncovered, nil
+}
+
 func (wr *Wrangler) getStreams(ctx context.Context, workflow, keyspace string, shards []string) (*ReplicationStatusResult, error) {
 	var rsr ReplicationStatusResult
 	rsr.ShardStatuses = make(map[string]*ShardReplicationStatus)
@@ -806,21 +1069,8 @@ func (wr *Wrangler) getStreams(ctx context.Context, workflow, keyspace string, s
 			// been processed on the target
 			// We don't allow switching during the copy phase, so in that case we just return a large lag.
 			// All timestamps are in seconds since epoch
-			lastTransactionTimestamp := status.TransactionTimestamp
-			lastHeartbeatTime := status.TimeHeartbeat
-			if status.State == binlogdatapb.VReplicationWorkflowState_Copying.String() {
-				rsr.MaxVReplicationTransactionLag = math.MaxInt64
-			} else {
-				if lastTransactionTimestamp == 0 /* no new events after copy */ ||
-					lastHeartbeatTime > lastTransactionTimestamp /* no recent transactions, so all caught up */ {
-
-					lastTransactionTimestamp = lastHeartbeatTime
-				}
-				now := time.Now().Unix() /*seconds since epoch*/
-				transactionReplicationLag := now - lastTransactionTimestamp
-				if transactionReplicationLag > rsr.MaxVReplicationTransactionLag {
-					rsr.MaxVReplicationTransactionLag = transactionReplicationLag
-				}
+			if transactionReplicationLag := status.transactionLag(time.Now().Unix()); transactionReplicationLag > rsr.MaxVReplicationTransactionLag {
+				rsr.MaxVReplicationTransactionLag = transactionReplicationLag
 			}
 		}
 		si, err := wr.ts.GetShard(ctx, keyspace, primary.Shard)
@@ -20,6 +20,7 @@ import (
 	"context"
 	_ "embed"
 	"fmt"
+	"math"
 	"regexp"
 	"sort"
 	"strings"
@@ -470,3 +471,128 @@ func TestWorkflowUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckCutoverReady(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	workflow := "wrWorkflow"
+	keyspace := "target"
+	copyStateQuery := "select vrepl_id, table_name, lastpk from _vt.copy_state where vrepl_id in (1) and id in (select max(id) from _vt.copy_state where vrepl_id in (1) group by vrepl_id, table_name)"
+
+	env := newWranglerTestEnv(t, ctx, []string{"0"}, []string{"-80", "80-"}, nil, time.Now().Unix())
+	defer env.close()
+
+	// The streams are still copying, so they can't be cut over.
+	readiness, err := env.wr.CheckCutoverReady(ctx, keyspace, workflow, 30)
+	require.NoError(t, err)
+	require.False(t, readiness.Ready)
+	require.Len(t, readiness.LaggingStreams, 2)
+	for _, stream := range readiness.LaggingStreams {
+		require.Equal(t, "Copying", stream.State)
+		require.Equal(t, int64(math.MaxInt64), stream.LagSeconds)
+	}
+
+	for _, uid := range []int{200, 210} {
+		env.tmc.setVRResults(env.tmc.tablets[uid].tablet, copyStateQuery, &sqltypes.Result{})
+	}
+	readiness, err = env.wr.CheckCutoverReady(ctx, keyspace, workflow, 30)
+	require.NoError(t, err)
+	require.True(t, readiness.Ready)
+	require.Empty(t, readiness.LaggingStreams)
+
+	for _, uid := range []int{200, 210} {
+		env.tmc.setVRResults(env.tmc.tablets[uid].tablet, "select id, source, pos, stop_pos, max_replication_lag, state, db_name, time_updated, transaction_timestamp, time_heartbeat, time_throttled, component_throttled, message, tags, workflow_type, workflow_sub_type, defer_secondary_keys, rows_copied from _vt.vreplication where db_name = 'vt_target' and workflow = 'bad'", &sqltypes.Result{})
+	}
+	_, err = env.wr.CheckCutoverReady(ctx, keyspace, "bad", 30)
+	require.EqualError(t, err, "no streams found for workflow bad in keyspace target")
+
+	laggingEnv := newWranglerTestEnv(t, ctx, []string{"0"}, []string{"-80", "80-"}, nil, time.Now().Unix()-100)
+	defer laggingEnv.close()
+	for _, uid := range []int{200, 210} {
+		laggingEnv.tmc.setVRResults(laggingEnv.tmc.tablets[uid].tablet, copyStateQuery, &sqltypes.Result{})
+	}
+	readiness, err = laggingEnv.wr.CheckCutoverReady(ctx, keyspace, workflow, 30)
+	require.NoError(t, err)
+	require.False(t, readiness.Ready)
+	require.Len(t, readiness.LaggingStreams, 2)
+	for i, shard := range []string{"-80", "80-"} {
+		stream := readiness.LaggingStreams[i]
+		require.Equal(t, shard, stream.Shard)
+		require.Equal(t, int32(1), stream.ID)
+		require.Equal(t, "Running", stream.State)
+		require.GreaterOrEqual(t, stream.LagSeconds, int64(100))
+	}
+}
+
+func TestSetWorkflowOnDDL(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+
+	_, err := env.wr.SetWorkflowOnDDL(ctx, env.keyspace, env.workflow, "bogus")
+	require.EqualError(t, err, `invalid onDDL action "bogus", valid values are: EXEC, EXEC_IGNORE, IGNORE, STOP`)
+
+	selectQuery := fmt.Sprintf("select id, source from _vt.vreplication where db_name='vt_%s' and workflow='%s'", env.keyspace, env.workflow)
+	fields := sqltypes.MakeTestFields("id|source", "int64|varchar")
+	env.tmc.expectVRQuery(100, selectQuery, &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, selectQuery, sqltypes.MakeTestResult(fields,
+		`1|keyspace:"ks" shard:"0" filter:{rules:{match:"/.*" filter:"-80"}}`,
+	))
+	env.tmc.expectVRQuery(200, `/update _vt.vreplication set source='keyspace:\\"ks\\" shard:\\"0\\" filter:.* on_ddl:EXEC' where id=1`, &sqltypes.Result{})
+	env.tmc.expectVRQuery(210, selectQuery, sqltypes.MakeTestResult(fields,
+		`2|keyspace:"ks" shard:"0" filter:{rules:{match:"/.*" filter:"80-"}} on_ddl:EXEC`,
+	))
+
+	results, err := env.wr.SetWorkflowOnDDL(ctx, env.keyspace, env.workflow, "exec")
+	require.NoError(t, err)
+	require.Equal(t, []*OnDDLUpdateResult{
+		{Shard: "-80", Tablet: "cell-0000000200", StreamsUpdated: 1},
+		{Shard: "0", Tablet: "cell-0000000100", StreamsUpdated: 0},
+		{Shard: "80-", Tablet: "cell-0000000210", StreamsUpdated: 0},
+	}, results)
+	env.tmc.verifyQueries(t)
+
+	for _, uid := range []int{100, 200, 210} {
+		env.tmc.expectVRQuery(uid, selectQuery, &sqltypes.Result{})
+	}
+	_, err = env.wr.SetWorkflowOnDDL(ctx, env.keyspace, env.workflow, "stop")
+	require.EqualError(t, err, fmt.Sprintf("no streams found for workflow %s in keyspace %s", env.workflow, env.keyspace))
+	env.tmc.verifyQueries(t)
+}
+
+func TestValidateFilterCoverage(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestResharderEnv(t, ctx, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+	env.tmc.schema = &tabletmanagerdatapb.SchemaDefinition{
+		TableDefinitions: []*tabletmanagerdatapb.TableDefinition{
+			{Name: "t1", Type: "BASE TABLE"},
+			{Name: "t2", Type: "BASE TABLE"},
+			{Name: "t3", Type: "BASE TABLE"},
+			{Name: "v1", Type: "VIEW"},
+		},
+	}
+
+	selectQuery := fmt.Sprintf("select source from _vt.vreplication where db_name='vt_%s' and workflow='%s'", env.keyspace, env.workflow)
+	fields := sqltypes.MakeTestFields("source", "varchar")
+	env.tmc.expectVRQuery(100, selectQuery, &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, selectQuery, sqltypes.MakeTestResult(fields,
+		`keyspace:"ks" shard:"0" filter:{rules:{match:"/.*" filter:"-80"}}`,
+	))
+	env.tmc.expectVRQuery(210, selectQuery, sqltypes.MakeTestResult(fields,
+		`keyspace:"ks" shard:"0" filter:{rules:{match:"t1" filter:"80-"} rules:{match:"t3" filter:"exclude"}}`,
+	))
+	uncovered, err := env.wr.ValidateFilterCoverage(ctx, env.keyspace, env.workflow)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{"80-": {"t2"}}, uncovered)
+	env.tmc.verifyQueries(t)
+
+	for _, uid := range []int{100, 200, 210} {
+		env.tmc.expectVRQuery(uid, selectQuery, &sqltypes.Result{})
+	}
+	_, err = env.wr.ValidateFilterCoverage(ctx, env.keyspace, env.workflow)
+	require.EqualError(t, err, fmt.Sprintf("no streams found for workflow %s in keyspace %s", env.workflow, env.keyspace))
+	env.tmc.verifyQueries(t)
+}
@@ -68,6 +68,8 @@ type VReplicationWorkflowParams struct {
 	SourceShards, TargetShards []string
 	SkipSchemaCopy             bool
 	AutoStart, StopAfterCopy   bool
+	// ReshardOptions are the options of the reshard beyond its arguments.
+	ReshardOptions ReshardOptions
 
 	// MoveTables/Migrate and Reshard specific
 	DeferSecondaryKeys bool
@@ -461,7 +463,7 @@ func (vrw *VReplicationWorkflow) initReshard() error {
 	log.Infof("In VReplicationWorkflow.initReshard() for %+v", vrw)
 	return vrw.wr.Reshard(vrw.ctx, vrw.params.TargetKeyspace, vrw.params.Workflow, vrw.params.SourceShards,
 		vrw.params.TargetShards, vrw.params.SkipSchemaCopy, vrw.params.Cells, vrw.params.TabletTypes,
-		vrw.params.OnDDL, vrw.params.AutoStart, vrw.params.StopAfterCopy, vrw.params.DeferSecondaryKeys)
+		vrw.params.OnDDL, vrw.params.AutoStart, vrw.params.StopAfterCopy, vrw.params.DeferSecondaryKeys, vrw.params.ReshardOptions)
 }
 
 func (vrw *VReplicationWorkflow) switchReads() (*[]string, error) {
@@ -650,12 +650,21 @@ func TestReshardV2(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, wf)
 	require.Equal(t, WorkflowStateNotSwitched, wf.CurrentState())
+	require.NoError(t, tme.wr.setReshardStage(ctx, "ks", "test", ReshardStageSwitched))
+	require.NoError(t, tme.wr.PersistReshardRouting(ctx, "ks", "test", map[string][]string{"-80": {"-40", "40-"}, "80-": {"40-"}}))
+	require.NoError(t, tme.wr.ts.UpsertMetadata(ctx, reshardRollbackPlanKey("ks", "test"), "{}"))
 	tme.expectNoPreviousJournals()
 	expectReshardQueries(t, tme, p)
 	tme.expectNoPreviousJournals()
 	require.NoError(t, testSwitchForward(t, wf))
 	require.Equal(t, WorkflowStateAllSwitched, wf.CurrentState())
 	require.NoError(t, testComplete(t, wf))
+	_, err = tme.wr.GetReshardStage(ctx, "ks", "test")
+	require.EqualError(t, err, "no stage found for reshard test in keyspace ks")
+	_, err = tme.wr.GetReshardRouting(ctx, "ks", "test")
+	require.EqualError(t, err, "no routing found for reshard test in keyspace ks")
+	_, err = tme.wr.GetReshardRollbackPlan(ctx, "ks", "test")
+	require.EqualError(t, err, "no rollback plan found for workflow test in keyspace ks")
 	si, err := wf.wr.ts.GetShard(ctx, "ks", "-40")
 	require.Contains(t, err.Error(), "node doesn't exist")
 	require.Nil(t, si)
@@ -722,9 +731,18 @@ func TestReshardV2Cancel(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, wf)
 	require.Equal(t, WorkflowStateNotSwitched, wf.CurrentState())
+	require.NoError(t, tme.wr.setReshardStage(ctx, "ks", "test", ReshardStageCopying))
+	require.NoError(t, tme.wr.PersistReshardRouting(ctx, "ks", "test", map[string][]string{"-80": {"-40", "40-"}, "80-": {"40-"}}))
+	require.NoError(t, tme.wr.ts.UpsertMetadata(ctx, reshardRollbackPlanKey("ks", "test"), "{}"))
 	tme.expectNoPreviousJournals()
 	expectReshardQueries(t, tme, p)
 	require.NoError(t, wf.Cancel())
+	_, err = tme.wr.GetReshardStage(ctx, "ks", "test")
+	require.EqualError(t, err, "no stage found for reshard test in keyspace ks")
+	_, err = tme.wr.GetReshardRouting(ctx, "ks", "test")
+	require.EqualError(t, err, "no routing found for reshard test in keyspace ks")
+	_, err = tme.wr.GetReshardRollbackPlan(ctx, "ks", "test")
+	require.EqualError(t, err, "no rollback plan found for workflow test in keyspace ks")
 }
 
 func expectReshardQueries(t *testing.T, tme *testShardMigraterEnv, params *VReplicationWorkflowParams) {
@@ -20,12 +20,15 @@ package wrangler
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"golang.org/x/sync/semaphore"
 
 	"vitess.io/vitess/go/mysql/collations"
 	"vitess.io/vitess/go/sqltypes"
 	"vitess.io/vitess/go/vt/logutil"
+	"vitess.io/vitess/go/vt/servenv"
 	"vitess.io/vitess/go/vt/sqlparser"
 	"vitess.io/vitess/go/vt/topo"
 	"vitess.io/vitess/go/vt/vtctl/grpcvtctldserver"
@@ -41,6 +44,15 @@ var (
 	// so basing this to be greater than RemoteOperationTimeout is good.
 	// Use this as the default value for Context that need a deadline.
 	DefaultActionTimeout = topo.RemoteOperationTimeout * 4
+
+	// actionTimingsMu protects actionTimings.
+	actionTimingsMu sync.Mutex
+	// actionTimings count the high level actions run by wranglers, such as
+	// reparents, workflow creations and schema copies, and how long they
+	// take, by the name of the exporter they are exported by. They are
+	// created when the first action is recorded for an exporter, so that
+	// the wranglers created for each command share them.
+	actionTimings = make(map[string]*servenv.TimingsWrapper)
 )
 
 // Wrangler manages complex actions on the topology, like reparents,
@@ -48,29 +60,49 @@ var (
 //
 // Multiple go routines can use the same Wrangler at the same time,
 // provided they want to share the same logger / topo server / lock timeout.
+// Use CloneWithLogger to give one of them its own logger.
 type Wrangler struct {
 	logger   logutil.Logger
 	ts       *topo.Server
 	tmc      tmclient.TabletManagerClient
 	vtctld   vtctlservicepb.VtctldServer
 	sourceTs *topo.Server
+	// closeVtctld releases the VtctldServer created by New, if this
+	// wrangler created it, see Close.
+	closeVtctld func()
 	// VExecFunc is a test-only fixture that allows us to short circuit vexec commands.
 	// DO NOT USE in production code.
 	VExecFunc func(ctx context.Context, workflow, keyspace, query string, dryRun bool) (map[*topo.TabletInfo]*sqltypes.Result, error)
-	// Limt the number of concurrent background goroutines if needed.
+	// Limit the number of concurrent background goroutines if needed.
+	// A nil sem means no limit, see SetConcurrencyLimit.
 	sem            *semaphore.Weighted
 	collationEnv   *collations.Environment
 	parser         *sqlparser.Parser
 	WorkflowParams *VReplicationWorkflowParams
+	// statsExporter exports the timings of the actions, see
+	// SetStatsExporter. Nothing is recorded if it's nil.
+	statsExporter *servenv.Exporter
 }
 
 // New creates a new Wrangler object.
+// The wrangler owns the VtctldServer it creates, and its tablet manager
+// client, until Close is called.
 func New(logger logutil.Logger, ts *topo.Server, tmc tmclient.TabletManagerClient, collationEnv *collations.Environment, parser *sqlparser.Parser) *Wrangler {
+	vtctld := grpcvtctldserver.NewVtctldServer(ts, collationEnv, parser)
+	wr := NewWithVtctldServer(logger, ts, tmc, vtctld, collationEnv, parser)
+	wr.closeVtctld = vtctld.Close
+	return wr
+}
+
+// NewWithVtctldServer creates a new Wrangler object that delegates to the
+// given VtctldServer implementation, for example one that decorates the
+// server created by New.
+func NewWithVtctldServer(logger logutil.Logger, ts *topo.Server, tmc tmclient.TabletManagerClient, vtctld vtctlservicepb.VtctldServer, collationEnv *collations.Environment, parser *sqlparser.Parser) *Wrangler {
 	return &Wrangler{
 		logger:       logger,
 		ts:           ts,
 		tmc:          tmc,
-		vtctld:       grpcvtctldserver.NewVtctldServer(ts, collationEnv, parser),
+		vtctld:       vtctld,
 		sourceTs:     ts,
 		collationEnv: collationEnv,
 		parser:       parser,
@@ -91,6 +123,52 @@ func NewTestWrangler(logger logutil.Logger, ts *topo.Server, tmc tmclient.Tablet
 	}
 }
 
+// Close releases the resources this wrangler owns, that is the
+// VtctldServer created by New and its tablet manager client. The topo
+// servers, tablet manager client and VtctldServer given to the
+// constructors belong to the caller, which closes them itself; Close
+// leaves them alone. Clones do not own anything, only the original
+// wrangler should be closed, once no calls to it or its clones are in
+// progress.
+func (wr *Wrangler) Close() error {
+	if wr.closeVtctld != nil {
+		wr.closeVtctld()
+		wr.closeVtctld = nil
+	}
+	return nil
+}
+
+// recordAction records an action that started at start. It is meant to be
+// deferred at the top of the action, as in
+// defer wr.recordAction("Reshard", time.Now()).
+func (wr *Wrangler) recordAction(action string, start time.Time) {
+	if wr.statsExporter == nil {
+		return
+	}
+	actionTimingsFor(wr.statsExporter).Record(action, start)
+}
+
+// actionTimingsFor returns the WranglerActions timings of the exporter,
+// creating them the first time.
+func actionTimingsFor(exporter *servenv.Exporter) *servenv.TimingsWrapper {
+	actionTimingsMu.Lock()
+	defer actionTimingsMu.Unlock()
+	timings, ok := actionTimings[exporter.Name()]
+	if !ok {
+		timings = exporter.NewTimings("WranglerActions", "Count and duration of the wrangler actions", "Action")
+		actionTimings[exporter.Name()] = timings
+	}
+	return timings
+}
+
+// SetStatsExporter makes the wrangler record the count and duration of its
+// actions in the WranglerActions timings of the exporter. Without an
+// exporter, which is the default, nothing is recorded. Not synchronized,
+// no calls to this wrangler should be in progress.
+func (wr *Wrangler) SetStatsExporter(exporter *servenv.Exporter) {
+	wr.statsExporter = exporter
+}
+
 // TopoServer returns the topo.Server this wrangler is using.
 func (wr *Wrangler) TopoServer() *topo.Server {
 	return wr.ts
@@ -114,6 +192,39 @@ func (wr *Wrangler) SetLogger(logger logutil.Logger) {
 	wr.logger = logger
 }
 
+// CloneWithLogger returns a shallow copy of this wrangler that logs to the
+// given logger. The copy shares everything else with this wrangler, such
+// as the topo server, the tablet manager client and the background job
+// limit, so it is safe to use while this wrangler is in use, unlike
+// SetLogger. The copy does not need to be closed.
+func (wr *Wrangler) CloneWithLogger(logger logutil.Logger) *Wrangler {
+	clone := *wr
+	clone.logger = logger
+	clone.closeVtctld = nil
+	return &clone
+}
+
+// SetSourceTopoServer sets the topo server of the source keyspaces of the
+// workflows created by this wrangler, for when they are in another
+// cluster. It is the topo server of the wrangler by default. Not
+// synchronized, no calls to this wrangler should be in progress.
+func (wr *Wrangler) SetSourceTopoServer(ts *topo.Server) {
+	wr.sourceTs = ts
+}
+
+// SetConcurrencyLimit limits the number of background jobs, such as the
+// optimization of the copy_state tables, that this wrangler runs at the
+// same time. Jobs over the limit are skipped. A limit of zero or less
+// removes the limit, which is the default. Not synchronized, no calls to
+// this wrangler should be in progress.
+func (wr *Wrangler) SetConcurrencyLimit(n int) {
+	if n <= 0 {
+		wr.sem = nil
+		return
+	}
+	wr.sem = semaphore.NewWeighted(int64(n))
+}
+
 // Logger returns the logger associated with this wrangler.
 func (wr *Wrangler) Logger() logutil.Logger {
 	return wr.logger
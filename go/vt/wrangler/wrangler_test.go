@@ -0,0 +1,124 @@
+/*
+Copyright 2024 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wrangler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/mysql/collations"
+	"vitess.io/vitess/go/vt/logutil"
+	"vitess.io/vitess/go/vt/proto/topodata"
+	"vitess.io/vitess/go/vt/servenv"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vtctl/grpcvtctldserver"
+	"vitess.io/vitess/go/vt/vttablet/tmclient"
+
+	vtctlservicepb "vitess.io/vitess/go/vt/proto/vtctlservice"
+)
+
+func TestSetConcurrencyLimit(t *testing.T) {
+	wr := NewTestWrangler(logutil.NewMemoryLogger(), nil, nil)
+	require.Nil(t, wr.sem)
+
+	wr.SetConcurrencyLimit(2)
+	require.True(t, wr.sem.TryAcquire(2))
+	require.False(t, wr.sem.TryAcquire(1))
+
+	wr.SetConcurrencyLimit(0)
+	require.Nil(t, wr.sem)
+}
+
+func TestNewWithVtctldServer(t *testing.T) {
+	vtctld := grpcvtctldserver.NewTestVtctldServer(nil, nil)
+	wr := NewWithVtctldServer(logutil.NewMemoryLogger(), nil, nil, vtctld, collations.MySQL8(), sqlparser.NewTestParser())
+	require.Same(t, vtctld, wr.VtctldServer())
+}
+
+func TestRecordAction(t *testing.T) {
+	wr := NewWithVtctldServer(logutil.NewMemoryLogger(), nil, nil, &vtctlservicepb.UnimplementedVtctldServer{}, collations.MySQL8(), sqlparser.NewTestParser())
+	// Nothing is recorded without an exporter.
+	err := wr.ReparentTablet(context.Background(), &topodata.TabletAlias{Cell: "zone1", Uid: 100})
+	require.Error(t, err)
+	actionTimingsMu.Lock()
+	require.Empty(t, actionTimings)
+	actionTimingsMu.Unlock()
+
+	exporter := servenv.NewExporter("TestRecordAction", "Wrangler")
+	wr.SetStatsExporter(exporter)
+	err = wr.ReparentTablet(context.Background(), &topodata.TabletAlias{Cell: "zone1", Uid: 100})
+	require.Error(t, err)
+	require.EqualValues(t, 1, actionTimingsFor(exporter).Counts()["TestRecordAction.ReparentTablet"])
+
+	// The wranglers exporting to the same exporter share the timings.
+	clone := wr.CloneWithLogger(logutil.NewMemoryLogger())
+	err = clone.ReparentTablet(context.Background(), &topodata.TabletAlias{Cell: "zone1", Uid: 100})
+	require.Error(t, err)
+	other := NewWithVtctldServer(logutil.NewMemoryLogger(), nil, nil, &vtctlservicepb.UnimplementedVtctldServer{}, collations.MySQL8(), sqlparser.NewTestParser())
+	other.SetStatsExporter(exporter)
+	err = other.ReparentTablet(context.Background(), &topodata.TabletAlias{Cell: "zone1", Uid: 100})
+	require.Error(t, err)
+	require.EqualValues(t, 3, actionTimingsFor(exporter).Counts()["TestRecordAction.ReparentTablet"])
+}
+
+// closeRecordingTMC records the calls to Close.
+type closeRecordingTMC struct {
+	tmclient.TabletManagerClient
+	closed int
+}
+
+func (tmc *closeRecordingTMC) Close() {
+	tmc.closed++
+}
+
+func TestClose(t *testing.T) {
+	injected := &closeRecordingTMC{}
+	wr := NewWithVtctldServer(logutil.NewMemoryLogger(), nil, injected, &vtctlservicepb.UnimplementedVtctldServer{}, collations.MySQL8(), sqlparser.NewTestParser())
+	require.NoError(t, wr.Close())
+	require.Zero(t, injected.closed, "injected tablet manager client must not be closed")
+
+	owned := &closeRecordingTMC{}
+	wr = NewWithVtctldServer(logutil.NewMemoryLogger(), nil, injected, grpcvtctldserver.NewTestVtctldServer(nil, owned), collations.MySQL8(), sqlparser.NewTestParser())
+	wr.closeVtctld = wr.vtctld.(*grpcvtctldserver.VtctldServer).Close
+	require.NoError(t, wr.CloneWithLogger(logutil.NewMemoryLogger()).Close())
+	require.Zero(t, owned.closed, "clones must not close the owned server")
+	require.NoError(t, wr.Close())
+	require.NoError(t, wr.Close())
+	require.Equal(t, 1, owned.closed)
+	require.Zero(t, injected.closed)
+}
+
+func TestCloneWithLogger(t *testing.T) {
+	logger := logutil.NewMemoryLogger()
+	wr := NewTestWrangler(logger, nil, nil)
+	wr.SetConcurrencyLimit(1)
+
+	cloneLogger := logutil.NewMemoryLogger()
+	clone := wr.CloneWithLogger(cloneLogger)
+	require.NotSame(t, wr, clone)
+	require.Same(t, cloneLogger, clone.Logger())
+	require.Same(t, logger, wr.Logger())
+	require.Same(t, wr.vtctld, clone.vtctld)
+	require.Same(t, wr.parser, clone.parser)
+	require.Same(t, wr.sem, clone.sem)
+
+	clone.Logger().Infof("only in the clone")
+	require.Contains(t, cloneLogger.String(), "only in the clone")
+	require.Empty(t, logger.String())
+}